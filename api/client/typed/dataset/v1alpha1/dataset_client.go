@@ -28,6 +28,7 @@ import (
 type DatasetV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	DatasetsGetter
+	DatasetShareGrantsGetter
 }
 
 // DatasetV1alpha1Client is used to interact with features provided by the dataset group.
@@ -39,6 +40,10 @@ func (c *DatasetV1alpha1Client) Datasets(namespace string) DatasetInterface {
 	return newDatasets(c, namespace)
 }
 
+func (c *DatasetV1alpha1Client) DatasetShareGrants(namespace string) DatasetShareGrantInterface {
+	return newDatasetShareGrants(c, namespace)
+}
+
 // NewForConfig creates a new DatasetV1alpha1Client for the given config.
 // NewForConfig is equivalent to NewForConfigAndClient(c, httpClient),
 // where httpClient was generated with rest.HTTPClientFor(c).
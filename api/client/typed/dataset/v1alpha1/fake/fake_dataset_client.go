@@ -31,6 +31,10 @@ func (c *FakeDatasetV1alpha1) Datasets(namespace string) v1alpha1.DatasetInterfa
 	return &FakeDatasets{c, namespace}
 }
 
+func (c *FakeDatasetV1alpha1) DatasetShareGrants(namespace string) v1alpha1.DatasetShareGrantInterface {
+	return &FakeDatasetShareGrants{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeDatasetV1alpha1) RESTClient() rest.Interface {
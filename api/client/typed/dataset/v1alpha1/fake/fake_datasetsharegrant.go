@@ -0,0 +1,146 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	"context"
+
+	v1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeDatasetShareGrants implements DatasetShareGrantInterface
+type FakeDatasetShareGrants struct {
+	Fake *FakeDatasetV1alpha1
+	ns   string
+}
+
+var datasetsharegrantsResource = v1alpha1.SchemeGroupVersion.WithResource("datasetsharegrants")
+
+var datasetsharegrantsKind = v1alpha1.SchemeGroupVersion.WithKind("DatasetShareGrant")
+
+// Get takes name of the datasetShareGrant, and returns the corresponding datasetShareGrant object, and an error if there is any.
+func (c *FakeDatasetShareGrants) Get(ctx context.Context, name string, options v1.GetOptions) (result *v1alpha1.DatasetShareGrant, err error) {
+	emptyResult := &v1alpha1.DatasetShareGrant{}
+	obj, err := c.Fake.
+		Invokes(testing.NewGetActionWithOptions(datasetsharegrantsResource, c.ns, name, options), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1alpha1.DatasetShareGrant), err
+}
+
+// List takes label and field selectors, and returns the list of DatasetShareGrants that match those selectors.
+func (c *FakeDatasetShareGrants) List(ctx context.Context, opts v1.ListOptions) (result *v1alpha1.DatasetShareGrantList, err error) {
+	emptyResult := &v1alpha1.DatasetShareGrantList{}
+	obj, err := c.Fake.
+		Invokes(testing.NewListActionWithOptions(datasetsharegrantsResource, datasetsharegrantsKind, c.ns, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.DatasetShareGrantList{ListMeta: obj.(*v1alpha1.DatasetShareGrantList).ListMeta}
+	for _, item := range obj.(*v1alpha1.DatasetShareGrantList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested datasetsharegrants.
+func (c *FakeDatasetShareGrants) Watch(ctx context.Context, opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchActionWithOptions(datasetsharegrantsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a datasetShareGrant and creates it.  Returns the server's representation of the datasetShareGrant, and an error, if there is any.
+func (c *FakeDatasetShareGrants) Create(ctx context.Context, datasetShareGrant *v1alpha1.DatasetShareGrant, opts v1.CreateOptions) (result *v1alpha1.DatasetShareGrant, err error) {
+	emptyResult := &v1alpha1.DatasetShareGrant{}
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateActionWithOptions(datasetsharegrantsResource, c.ns, datasetShareGrant, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1alpha1.DatasetShareGrant), err
+}
+
+// Update takes the representation of a datasetShareGrant and updates it. Returns the server's representation of the datasetShareGrant, and an error, if there is any.
+func (c *FakeDatasetShareGrants) Update(ctx context.Context, datasetShareGrant *v1alpha1.DatasetShareGrant, opts v1.UpdateOptions) (result *v1alpha1.DatasetShareGrant, err error) {
+	emptyResult := &v1alpha1.DatasetShareGrant{}
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateActionWithOptions(datasetsharegrantsResource, c.ns, datasetShareGrant, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1alpha1.DatasetShareGrant), err
+}
+
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeDatasetShareGrants) UpdateStatus(ctx context.Context, datasetShareGrant *v1alpha1.DatasetShareGrant, opts v1.UpdateOptions) (result *v1alpha1.DatasetShareGrant, err error) {
+	emptyResult := &v1alpha1.DatasetShareGrant{}
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceActionWithOptions(datasetsharegrantsResource, "status", c.ns, datasetShareGrant, opts), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1alpha1.DatasetShareGrant), err
+}
+
+// Delete takes name of the datasetShareGrant and deletes it. Returns an error if one occurs.
+func (c *FakeDatasetShareGrants) Delete(ctx context.Context, name string, opts v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteActionWithOptions(datasetsharegrantsResource, c.ns, name, opts), &v1alpha1.DatasetShareGrant{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeDatasetShareGrants) DeleteCollection(ctx context.Context, opts v1.DeleteOptions, listOpts v1.ListOptions) error {
+	action := testing.NewDeleteCollectionActionWithOptions(datasetsharegrantsResource, c.ns, opts, listOpts)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.DatasetShareGrantList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched datasetShareGrant.
+func (c *FakeDatasetShareGrants) Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts v1.PatchOptions, subresources ...string) (result *v1alpha1.DatasetShareGrant, err error) {
+	emptyResult := &v1alpha1.DatasetShareGrant{}
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceActionWithOptions(datasetsharegrantsResource, c.ns, name, pt, data, opts, subresources...), emptyResult)
+
+	if obj == nil {
+		return emptyResult, err
+	}
+	return obj.(*v1alpha1.DatasetShareGrant), err
+}
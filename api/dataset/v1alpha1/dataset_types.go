@@ -22,6 +22,12 @@ import (
 )
 
 type DatasetStatusPhase string
+
+// +kubebuilder:validation:Enum=Parallel;Sequential
+type DatasetSourcesPolicy string
+
+// empty is allowed for an unset spec.source when spec.sources is used.
+// +kubebuilder:validation:Pattern=`^([A-Z][A-Z0-9_]*)?$`
 type DatasetType string
 
 const (
@@ -41,6 +47,9 @@ const (
 	DatasetStatusPhaseProcessing DatasetStatusPhase = "PROCESSING"
 	DatasetStatusPhaseFailed     DatasetStatusPhase = "FAILED"
 
+	DatasetSourcesPolicyParallel   DatasetSourcesPolicy = "Parallel"
+	DatasetSourcesPolicySequential DatasetSourcesPolicy = "Sequential"
+
 	// avoid unused error
 	_ = DatasetStatusPhasePending
 	_ = DatasetStatusPhaseReady
@@ -49,7 +58,8 @@ const (
 )
 
 type DatasetSource struct {
-	// +kubebuilder:validation:Enum=GIT;S3;HTTP;PVC;NFS;CONDA;REFERENCE;HUGGING_FACE;MODEL_SCOPE
+	// type is one of GIT, S3, HTTP, PVC, NFS, CONDA, REFERENCE, HUGGING_FACE and MODEL_SCOPE,
+	// or the type of an external loader configured in external_loader_types of the controller.
 	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="Value is immutable"
 	Type DatasetType `json:"type"`
 	// +kubebuilder:validation:Required
@@ -57,8 +67,8 @@ type DatasetSource struct {
 	// each type of dataset source has its own format of uri:
 	// - GIT: http[s]://<host>/<owner>/<repo>[.git] or git://<host>/<owner>/<repo>[.git]
 	// - S3: s3://<bucket>/<path/to/directory>
-	// - HTTP: http[s]://<host>/<path/to/directory>?<query>
-	// - PVC: pvc://<name>/<path/to/directory>
+	// - HTTP: http[s]://<host>/<path/to/directory>/ to crawl a directory index, or http[s]://<host>/<path/to/file> for a single file
+	// - PVC: pvc://<name>/<path/to/directory>, or pvc://<namespace>/<name>/<path/to/directory> with the namespaced option
 	// - NFS: nfs://<host>/<path/to/directory>
 	// - CONDA: conda://<name>?[python=<python_version>]
 	// - REFERENCE: dataset://<namespace>/<dataset>
@@ -70,18 +80,59 @@ type DatasetSource struct {
 	// +kubebuilder:pruning:PreserveUnknownFields
 	// options is a map of key-value pairs that can be used to specify additional options for the dataset source, e.g. {"branch": "master"}
 	// supported keys for each type of dataset source are:
-	// - GIT: branch, commit, depth, submodules
-	// - S3: region, endpoint, provider
-	// - HTTP: any key-value pair will be passed to the underlying http client as http headers
-	// - PVC:
-	// - NFS:
+	// - GIT: branch, commit, tag, tagPattern, depth, submodules, lfs, lfsInclude, lfsExclude, sparsePaths, filter
+	// - S3: region, endpoint, provider, include, exclude, includeRegex, excludeRegex, minSize, maxSize, modifiedAfter
+	// - HTTP: include, exclude, includeRegex, excludeRegex, minSize, maxSize, modifiedAfter, maxDepth,
+	//   checksum (auto, required or none, verifies the files against a sidecar .sha256 file),
+	//   any other key-value pair is sent as an http header
+	//   the token of the secret is sent as a bearer token, and its ca.crt is trusted in addition to the system CAs
+	// - PVC: readOnly, true binds the pvc read-only without labeling it, recording its uid in the baize.io/dataset-bound-pvc
+	//   annotation of the dataset instead, namespaced, true takes the namespace of the pvc from the uri, a pvc in another
+	//   namespace is bound read-only through a clone of its pv like REFERENCE datasets, and requires the directory to be
+	//   within a dataset shared with the namespace of the dataset or granted to it by a DatasetShareGrant
+	// - NFS: mountOptions, comma separated, replacing those of the pv template of the controller, nfsVersion,
+	//   share, the exported root the path of the uri is mounted relative to, defaults to /, readOnly,
+	//   only applied when the pv is created
 	// - CONDA: requirements.txt, environment.yaml
-	// - REFERENCE:
+	// - REFERENCE: waitSourceReady, true delays creating the pvc until the source dataset is READY,
+	//   mode, volume (default) binds a clone of the pv of the source dataset, copy provisions a pvc from volumeClaimTemplate
+	//   and copies the source dataset into it, copyMethod, job (default) copies every round the source dataset syncs,
+	//   csi clones the source pvc once through the dataSource of the pvc and requires the source in the same namespace
 	// - HUGGING_FACE: repo, repoType, endpoint, include, exclude, revision
-	// - MODEL_SCOPE: repo, repoType, include, exclude, revision
+	// - MODEL_SCOPE: repo, repoType, endpoint, include, exclude, revision
+	// in addition, the following keys are supported by all types of dataset source:
+	// - extract: auto, tar, zip or none (default), unpacks the copied archives in place
+	// - stripComponents: number of leading path elements to strip from the extracted files
+	// - keepArchive: keep the archives after they are extracted, defaults to false. archives unchanged since they were
+	//   last extracted are skipped, but deleted archives are copied again every round unless keepArchive is set
+	// - syncTimeout, extractTimeout, postCopyTimeout: durations, e.g. 30m, bounding each stage of the data loader
+	//   syncTimeout covers the free space check made before the sync when PVC expansion is enabled
+	// - syncMode: copy (default) only adds and updates files, mirror deletes the files removed from the source as well,
+	//   GIT always mirrors the checked out revision, mirror can not be used together with extract
+	// - maxDeletePercent: the largest share of the synced files mirror deletes in a round, defaults to 50
+	// - forceDelete: delete the files removed from the source even if they exceed maxDeletePercent, defaults to false
 	Options map[string]string `json:"options,omitempty"`
 }
 
+// DatasetSourceItem is one of the sources composed into a single dataset.
+type DatasetSourceItem struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MaxLength=40
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// name identifies the source within the dataset, it is used to name the container syncing it and to report its status.
+	Name string `json:"name"`
+
+	DatasetSource `json:",inline"`
+
+	// +kubebuilder:validation:Optional
+	// secretRef is the name of the secret that contains credentials for accessing this source.
+	SecretRef string `json:"secretRef,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="Value is immutable"
+	// path is the sub path within the dataset the source is synced to, relative to mountOptions.path, defaults to name.
+	Path string `json:"path,omitempty"`
+}
+
 type MountOptions struct {
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:default="/"
@@ -96,6 +147,10 @@ type MountOptions struct {
 	// mode is the permission mode of the mounted directory.
 	Mode string `json:"mode,omitempty"`
 	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern="^[0-7]{3,4}$"
+	// dirMode is the permission mode of the directories within the dataset, defaults to mode.
+	DirMode string `json:"dirMode,omitempty"`
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:default=1000
 	// uid is the user id of the mounted directory.
 	UID int64 `json:"uid,omitempty"`
@@ -106,6 +161,7 @@ type MountOptions struct {
 }
 
 // DatasetSpec defines the desired state of Dataset
+// +kubebuilder:validation:XValidation:rule="(has(self.source) && self.source.type != '') != (has(self.sources) && size(self.sources) > 0)",message="exactly one of source and sources must be set"
 type DatasetSpec struct {
 	// Share indicates whether the model is shareable with others.
 	// When set to true, the model can be shared according to the specified selector.
@@ -116,13 +172,25 @@ type DatasetSpec struct {
 	// If Share is true and ShareToNamespaceSelector is empty, that means all namespaces can access this.
 	// +kubebuilder:validation:Optional
 	ShareToNamespaceSelector *metav1.LabelSelector `json:"shareToNamespaceSelector,omitempty"`
-	// +kubebuilder:validation:Required
-	// source is the source of the dataset.
-	Source DatasetSource `json:"source"`
+	// +kubebuilder:validation:Optional
+	// source is the source of the dataset, exactly one of source and sources must be set.
+	Source DatasetSource `json:"source,omitempty"`
 	// +kubebuilder:validation:Optional
 	// secretRef is the name of the secret that contains credentials for accessing the dataset source.
 	SecretRef string `json:"secretRef,omitempty"`
 	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=name
+	// sources composes multiple sources into one dataset, each of them is synced to its own sub path by its own
+	// container of the round job. only types preloaded by data-loader are supported, CONDA is not.
+	Sources []DatasetSourceItem `json:"sources,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=Parallel
+	// sourcesPolicy is how the sources are synced within the round job, Parallel runs them as containers side by side,
+	// Sequential runs them one after another in the order listed.
+	SourcesPolicy DatasetSourcesPolicy `json:"sourcesPolicy,omitempty"`
+	// +kubebuilder:validation:Optional
 	// mountOptions is the options for mounting the dataset.
 	MountOptions MountOptions `json:"mountOptions,omitempty"`
 	// +kubebuilder:validation:Optional
@@ -145,6 +213,98 @@ type DataLoadStatus struct {
 	EndTime metav1.Time `json:"endTime,omitempty"`
 	// +kubebuilder:validation:Optional
 	Succeed bool `json:"succeed,omitempty"`
+	// +kubebuilder:validation:Optional
+	// revision is the revision of the source synced in this round, e.g. the git tag resolved from tagPattern.
+	Revision string `json:"revision,omitempty"`
+	// +kubebuilder:validation:Optional
+	// pvcExpansions is how many times the pvc was expanded and the round retried after it ran out of space.
+	PVCExpansions int32 `json:"pvcExpansions,omitempty"`
+	// +kubebuilder:validation:Optional
+	// sizeDelta is how many bytes the round added to the dataset, negative if it shrank.
+	SizeDelta int64 `json:"sizeDelta,omitempty"`
+	// +kubebuilder:validation:Optional
+	// fileCountDelta is how many files the round added to the dataset, negative if it removed more than it added.
+	FileCountDelta int64 `json:"fileCountDelta,omitempty"`
+}
+
+// DatasetFile is a file of the dataset.
+type DatasetFile struct {
+	// path is relative to the directory of the dataset.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// DatasetSourceStatus is the status of one of spec.sources.
+type DatasetSourceStatus struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	// round is the data sync round the status refers to.
+	Round int32 `json:"round,omitempty"`
+	// +kubebuilder:validation:Optional
+	Phase DatasetStatusPhase `json:"phase,omitempty"`
+	// +kubebuilder:validation:Optional
+	// revision is the revision of the source synced in the round, if the source type reports one.
+	Revision string `json:"revision,omitempty"`
+	// +kubebuilder:validation:Optional
+	// message describes why syncing the source failed.
+	Message string `json:"message,omitempty"`
+}
+
+// DatasetPlanSummary tells what syncing the source would transfer, delete and skip.
+type DatasetPlanSummary struct {
+	// +kubebuilder:validation:Optional
+	// revision is the revision of the source the plan resolved, if the source type reports one.
+	Revision string `json:"revision,omitempty"`
+	// +kubebuilder:validation:Optional
+	TransferFiles int64 `json:"transferFiles,omitempty"`
+	// +kubebuilder:validation:Optional
+	TransferBytes int64 `json:"transferBytes,omitempty"`
+	// +kubebuilder:validation:Optional
+	DeleteFiles int64 `json:"deleteFiles,omitempty"`
+	// +kubebuilder:validation:Optional
+	DeleteBytes int64 `json:"deleteBytes,omitempty"`
+	// +kubebuilder:validation:Optional
+	SkipFiles int64 `json:"skipFiles,omitempty"`
+	// +kubebuilder:validation:Optional
+	SkipBytes int64 `json:"skipBytes,omitempty"`
+}
+
+// DatasetSourcePlan is the plan of one of spec.sources.
+type DatasetSourcePlan struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Optional
+	DatasetPlanSummary `json:",inline"`
+	// +kubebuilder:validation:Optional
+	// message tells why the sync would fail, or why planning the source failed.
+	Message string `json:"message,omitempty"`
+}
+
+// DatasetPlan is the result of the plan requested by the baize.io/dataset-plan annotation.
+type DatasetPlan struct {
+	// +kubebuilder:validation:Optional
+	// request is the value of the baize.io/dataset-plan annotation the plan was made for.
+	Request string `json:"request,omitempty"`
+	// +kubebuilder:validation:Optional
+	Phase DatasetStatusPhase `json:"phase,omitempty"`
+	// +kubebuilder:validation:Optional
+	// message tells why the sync would fail, or why planning failed.
+	Message string `json:"message,omitempty"`
+	// +kubebuilder:validation:Optional
+	// the summary of all the sources.
+	DatasetPlanSummary `json:",inline"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// sources is the plan of each of spec.sources.
+	Sources []DatasetSourcePlan `json:"sources,omitempty"`
+	// +kubebuilder:validation:Optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// DatasetReference names a dataset in another namespace.
+type DatasetReference struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
 }
 
 // DatasetStatus defines the observed state of Dataset
@@ -159,6 +319,9 @@ type DatasetStatus struct {
 	// +kubebuilder:validation:Optional
 	InProcessingRound int32 `json:"inProcessingRound,omitempty"`
 	// +kubebuilder:validation:Optional
+	// queued indicates the round is waiting for other sync jobs to finish because of the concurrency limits of the controller.
+	Queued bool `json:"queued,omitempty"`
+	// +kubebuilder:validation:Optional
 	// lastSucceedRound is the number of the last data sync round.
 	LastSucceedRound int32 `json:"lastSucceedRound,omitempty"`
 	// +kubebuilder:validation:Optional
@@ -172,7 +335,51 @@ type DatasetStatus struct {
 	// +kubebuilder:validation:Optional
 	// readOnly indicates whether the dataset is mounted as read-only.
 	ReadOnly     bool        `json:"readOnly,omitempty"`
+	// +kubebuilder:validation:Optional
+	// subPath is the directory in the pvc holding the data of the dataset, e.g. the path of the uri of a PVC dataset,
+	// to be used as the subPath of the volumeMounts of the consumers. empty means the root of the pvc.
+	SubPath string `json:"subPath,omitempty"`
 	LastSyncTime metav1.Time `json:"lastSyncTime,omitempty"`
+	// +kubebuilder:validation:Optional
+	// revision is the revision of the source synced by the last succeeded round, if the source type reports one.
+	Revision string `json:"revision,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=name
+	// sources is the status of each of spec.sources in the current or the last round.
+	Sources []DatasetSourceStatus `json:"sources,omitempty"`
+	// +kubebuilder:validation:Optional
+	// sourcesSummary lists the name and the type of each of spec.sources, e.g. code:GIT,weights:S3, for kubectl get to show
+	// what the datasets composed of spec.sources are made of, since their spec.source is empty.
+	SourcesSummary string `json:"sourcesSummary,omitempty"`
+	// +kubebuilder:validation:Optional
+	// plan tells what the next sync would do, it is made on request by setting the baize.io/dataset-plan annotation.
+	Plan *DatasetPlan `json:"plan,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	// consumers are the REFERENCE datasets bound to the dataset, deleting the dataset is blocked while there are any
+	// unless it has the baize.io/dataset-force-delete annotation set to true.
+	Consumers []DatasetReference `json:"consumers,omitempty"`
+	// +kubebuilder:validation:Optional
+	// consumerCount is the number of consumers.
+	ConsumerCount int32 `json:"consumerCount,omitempty"`
+	// +kubebuilder:validation:Optional
+	// sourceRound is the lastSucceedRound of the source dataset of a REFERENCE dataset, whose phase, revision
+	// and lastSyncTime mirror the source dataset as well.
+	SourceRound int32 `json:"sourceRound,omitempty"`
+	// +kubebuilder:validation:Optional
+	// copiedSourceRound is the sourceRound last copied into the pvc of a REFERENCE dataset in copy mode.
+	CopiedSourceRound int32 `json:"copiedSourceRound,omitempty"`
+	// +kubebuilder:validation:Optional
+	// size is the total bytes of the files of the dataset after the last succeeded round, symlinks are not followed.
+	Size int64 `json:"size,omitempty"`
+	// +kubebuilder:validation:Optional
+	// fileCount is the number of the files of the dataset after the last succeeded round.
+	FileCount int64 `json:"fileCount,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +listType=atomic
+	// largestFiles are the largest files of the dataset after the last succeeded round, largest first.
+	LargestFiles []DatasetFile `json:"largestFiles,omitempty"`
 }
 
 // Dataset is the Schema for the datasets API
@@ -182,7 +389,11 @@ type DatasetStatus struct {
 // +kubebuilder:resource:shortName=data
 // +kubebuilder:printcolumn:name="type",type=string,JSONPath=`.spec.source.type`
 // +kubebuilder:printcolumn:name="uri",type=string,JSONPath=`.spec.source.uri`
+// +kubebuilder:printcolumn:name="sources",type=string,JSONPath=`.status.sourcesSummary`
 // +kubebuilder:printcolumn:name="phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="size",type=integer,JSONPath=`.status.size`
+// +kubebuilder:printcolumn:name="files",type=integer,JSONPath=`.status.fileCount`
+// +kubebuilder:printcolumn:name="consumers",type=integer,JSONPath=`.status.consumerCount`
 type Dataset struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
@@ -0,0 +1,86 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DatasetShareGrantPhase string
+
+const (
+	DatasetShareGrantPhaseActive  DatasetShareGrantPhase = "ACTIVE"
+	DatasetShareGrantPhaseExpired DatasetShareGrantPhase = "EXPIRED"
+)
+
+// DatasetShareGrantSpec defines the access granted to a dataset
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable, create a new grant instead"
+type DatasetShareGrantSpec struct {
+	// dataset is the name of the dataset in the namespace of the grant the access is granted to.
+	Dataset string `json:"dataset"`
+	// namespace is the namespace whose REFERENCE datasets may reference the dataset.
+	Namespace string `json:"namespace"`
+	// +kubebuilder:validation:Optional
+	// serviceAccounts are the service accounts in namespace meant to use the dataset, they are set as the
+	// baize.io/dataset-service-accounts annotation of the pvc of the REFERENCE datasets. the controller does
+	// not enforce them. empty means every service account.
+	ServiceAccounts []string `json:"serviceAccounts,omitempty"`
+	// +kubebuilder:validation:Optional
+	// expirationTime is when the access ends, the REFERENCE datasets then lose access like when the source
+	// dataset is unshared. the access never expires if not set.
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+}
+
+// DatasetShareGrantStatus defines the observed state of DatasetShareGrant
+type DatasetShareGrantStatus struct {
+	// +kubebuilder:validation:Optional
+	Phase DatasetShareGrantPhase `json:"phase,omitempty"`
+}
+
+// DatasetShareGrant is created by the owner of a dataset in the namespace of
+// the dataset to let the REFERENCE datasets of another namespace reference it,
+// whether the dataset is shared or not. Only users able to create grants in
+// the namespace of the dataset can grant access to it.
+// +genclient
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=dsg
+// +kubebuilder:printcolumn:name="dataset",type=string,JSONPath=`.spec.dataset`
+// +kubebuilder:printcolumn:name="grantee",type=string,JSONPath=`.spec.namespace`
+// +kubebuilder:printcolumn:name="phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="expiration",type=date,JSONPath=`.spec.expirationTime`
+// +kubebuilder:printcolumn:name="age",type=date,JSONPath=`.metadata.creationTimestamp`
+type DatasetShareGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatasetShareGrantSpec   `json:"spec,omitempty"`
+	Status DatasetShareGrantStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// DatasetShareGrantList contains a list of DatasetShareGrant
+type DatasetShareGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatasetShareGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatasetShareGrant{}, &DatasetShareGrantList{})
+}
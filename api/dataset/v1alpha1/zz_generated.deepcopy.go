@@ -69,6 +69,21 @@ func (in *Dataset) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetFile) DeepCopyInto(out *DatasetFile) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetFile.
+func (in *DatasetFile) DeepCopy() *DatasetFile {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DatasetList) DeepCopyInto(out *DatasetList) {
 	*out = *in
@@ -101,6 +116,159 @@ func (in *DatasetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetPlan) DeepCopyInto(out *DatasetPlan) {
+	*out = *in
+	out.DatasetPlanSummary = in.DatasetPlanSummary
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]DatasetSourcePlan, len(*in))
+		copy(*out, *in)
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetPlan.
+func (in *DatasetPlan) DeepCopy() *DatasetPlan {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetPlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetPlanSummary) DeepCopyInto(out *DatasetPlanSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetPlanSummary.
+func (in *DatasetPlanSummary) DeepCopy() *DatasetPlanSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetPlanSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetReference) DeepCopyInto(out *DatasetReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetReference.
+func (in *DatasetReference) DeepCopy() *DatasetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetShareGrant) DeepCopyInto(out *DatasetShareGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetShareGrant.
+func (in *DatasetShareGrant) DeepCopy() *DatasetShareGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetShareGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DatasetShareGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetShareGrantList) DeepCopyInto(out *DatasetShareGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DatasetShareGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetShareGrantList.
+func (in *DatasetShareGrantList) DeepCopy() *DatasetShareGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetShareGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DatasetShareGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetShareGrantSpec) DeepCopyInto(out *DatasetShareGrantSpec) {
+	*out = *in
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExpirationTime != nil {
+		in, out := &in.ExpirationTime, &out.ExpirationTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetShareGrantSpec.
+func (in *DatasetShareGrantSpec) DeepCopy() *DatasetShareGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetShareGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetShareGrantStatus) DeepCopyInto(out *DatasetShareGrantStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetShareGrantStatus.
+func (in *DatasetShareGrantStatus) DeepCopy() *DatasetShareGrantStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetShareGrantStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DatasetSource) DeepCopyInto(out *DatasetSource) {
 	*out = *in
@@ -123,6 +291,53 @@ func (in *DatasetSource) DeepCopy() *DatasetSource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetSourceItem) DeepCopyInto(out *DatasetSourceItem) {
+	*out = *in
+	in.DatasetSource.DeepCopyInto(&out.DatasetSource)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetSourceItem.
+func (in *DatasetSourceItem) DeepCopy() *DatasetSourceItem {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetSourceItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetSourcePlan) DeepCopyInto(out *DatasetSourcePlan) {
+	*out = *in
+	out.DatasetPlanSummary = in.DatasetPlanSummary
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetSourcePlan.
+func (in *DatasetSourcePlan) DeepCopy() *DatasetSourcePlan {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetSourcePlan)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatasetSourceStatus) DeepCopyInto(out *DatasetSourceStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetSourceStatus.
+func (in *DatasetSourceStatus) DeepCopy() *DatasetSourceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DatasetSourceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DatasetSpec) DeepCopyInto(out *DatasetSpec) {
 	*out = *in
@@ -132,6 +347,13 @@ func (in *DatasetSpec) DeepCopyInto(out *DatasetSpec) {
 		(*in).DeepCopyInto(*out)
 	}
 	in.Source.DeepCopyInto(&out.Source)
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]DatasetSourceItem, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	out.MountOptions = in.MountOptions
 	in.VolumeClaimTemplate.DeepCopyInto(&out.VolumeClaimTemplate)
 }
@@ -164,6 +386,26 @@ func (in *DatasetStatus) DeepCopyInto(out *DatasetStatus) {
 		}
 	}
 	in.LastSyncTime.DeepCopyInto(&out.LastSyncTime)
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]DatasetSourceStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = new(DatasetPlan)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Consumers != nil {
+		in, out := &in.Consumers, &out.Consumers
+		*out = make([]DatasetReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.LargestFiles != nil {
+		in, out := &in.LargestFiles, &out.LargestFiles
+		*out = make([]DatasetFile, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatasetStatus.
@@ -31,10 +31,10 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/pkg/log"
 
 	datasetcontroller "github.com/BaizeAI/dataset/internal/controller/dataset"
 	//+kubebuilder:scaffold:imports
@@ -64,16 +64,30 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", true,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	opts := zap.Options{
-		Development: true,
-	}
-	opts.BindFlags(flag.CommandLine)
+	// The zap flags are kept so existing deployments still start, they are
+	// mapped onto the log section of the config file.
+	var zapFlags log.ZapFlags
+	zapFlags.BindFlags(flag.CommandLine)
 	flag.Parse()
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	// controller-runtime 的日志与 controller 自身的日志使用同一份配置输出
+	ctrl.SetLogger(log.NewLogr("controller-runtime"))
 	if err := config2.ParseConfigFromFile(config); err != nil {
 		setupLog.Error(err, "unable to load config")
 		os.Exit(1)
 	}
+	logConfig := config2.GetLogConfig()
+	deprecatedFlags, err := zapFlags.Apply(flag.CommandLine, logConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to parse log flags")
+		os.Exit(1)
+	}
+	if err := log.InitEngine(logConfig); err != nil {
+		setupLog.Error(err, "unable to init log")
+		os.Exit(1)
+	}
+	if len(deprecatedFlags) > 0 {
+		setupLog.Info("flags are deprecated, set the log section of the config file instead", "flags", deprecatedFlags)
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
@@ -102,10 +116,18 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&datasetcontroller.DatasetReconciler{
+		Client:    mgr.GetClient(),
+		Scheme:    mgr.GetScheme(),
+		APIReader: mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Dataset")
+		os.Exit(1)
+	}
+	if err = (&datasetcontroller.DatasetShareGrantReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Dataset")
+		setupLog.Error(err, "unable to create controller", "controller", "DatasetShareGrant")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
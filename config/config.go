@@ -3,9 +3,12 @@ package config
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+
+	"github.com/BaizeAI/dataset/pkg/log"
 )
 
 var (
@@ -14,6 +17,146 @@ var (
 
 type configuration struct {
 	DatasetJobSpecYaml string `json:"dataset_job_spec_yaml"`
+	// NFSPVTemplateYaml is the PersistentVolume created for NFS datasets,
+	// the server, share and subdir of the csi volume are filled in from the
+	// uri of the dataset.
+	NFSPVTemplateYaml string `json:"nfs_pv_template_yaml"`
+	// ExternalLoaderTypes are the types of data sources handled by external
+	// data-loader-<type> executables shipped in the data-loader image.
+	ExternalLoaderTypes []string `json:"external_loader_types"`
+	// LoaderLimits are passed to every data-loader as flags.
+	LoaderLimits LoaderLimits `json:"loader_limits"`
+	// MaxConcurrentJobsPerNamespace caps the sync jobs running at the same
+	// time in a namespace, extra rounds are queued, 0 means unlimited. The
+	// running jobs are counted from the API server before each job is
+	// created, so the limits hold as long as a single controller replica
+	// reconciles the datasets, which leader election ensures.
+	MaxConcurrentJobsPerNamespace int `json:"max_concurrent_jobs_per_namespace"`
+	// MaxConcurrentJobsPerSourceHost caps the sync jobs running at the same
+	// time across the cluster that fetch from the same host, 0 means unlimited.
+	MaxConcurrentJobsPerSourceHost int `json:"max_concurrent_jobs_per_source_host"`
+	// Log configures the logs of the controller, including those of
+	// controller-runtime, and of the data-loaders it starts.
+	Log log.Config `json:"log"`
+	// Reference configures what happens to the REFERENCE datasets whose
+	// source dataset stops being shared with them.
+	Reference ReferenceConfig `json:"reference"`
+	// PVCExpansion sizes the pvcs of the datasets by the size the
+	// data-loaders estimate, and expands them when a round runs out of space.
+	PVCExpansion PVCExpansionConfig `json:"pvc_expansion"`
+}
+
+const (
+	// ReferenceRevokePolicyRetain keeps the pv and pvc of a REFERENCE dataset
+	// whose access has been revoked, pods using them keep running.
+	ReferenceRevokePolicyRetain = "Retain"
+	// ReferenceRevokePolicyDelete deletes the pv and pvc of a REFERENCE
+	// dataset once its access has been revoked for RevokeGracePeriod.
+	ReferenceRevokePolicyDelete = "Delete"
+
+	defaultReferenceRevokeGracePeriod   = time.Hour
+	defaultReferenceOrphanSweepInterval = 10 * time.Minute
+
+	defaultPVCExpansionHeadroomPercent = 20
+)
+
+type ReferenceConfig struct {
+	// RevokePolicy is Retain or Delete, Retain if empty.
+	RevokePolicy string `json:"revoke_policy"`
+	// RevokeGracePeriod is how long the pv and pvc are kept with the Delete
+	// policy, 1h if zero.
+	RevokeGracePeriod time.Duration `json:"revoke_grace_period"`
+	// OrphanSweepInterval is how often the pvs cloned for REFERENCE datasets
+	// that no longer exist are looked for and deleted, 10m if zero.
+	OrphanSweepInterval time.Duration `json:"orphan_sweep_interval"`
+}
+
+type PVCExpansionConfig struct {
+	// Enabled makes the data-loaders check the free space before syncing,
+	// and the controller expand the pvcs on storage classes allowing volume
+	// expansion and retry the rounds that ran out of space.
+	Enabled bool `json:"enabled"`
+	// InitialSize is requested by the pvcs whose volumeClaimTemplate requests
+	// no storage on storage classes allowing volume expansion, they are
+	// expanded to the estimated size by the first round. 100Ti if empty.
+	InitialSize string `json:"initial_size"`
+	// HeadroomPercent is added on top of the estimated size, 20 if zero.
+	HeadroomPercent int `json:"headroom_percent"`
+	// MaxSize caps the size the pvcs are expanded to, unlimited if empty.
+	MaxSize string `json:"max_size"`
+}
+
+type LoaderLimits struct {
+	BandwidthLimit string `json:"bandwidth_limit"`
+	MaxConcurrency int    `json:"max_concurrency"`
+	MaxRetries     int    `json:"max_retries"`
+}
+
+func GetLoaderLimits() LoaderLimits {
+	if config == nil {
+		return LoaderLimits{}
+	}
+	return config.LoaderLimits
+}
+
+func GetMaxConcurrentJobsPerNamespace() int {
+	if config == nil {
+		return 0
+	}
+	return config.MaxConcurrentJobsPerNamespace
+}
+
+func GetMaxConcurrentJobsPerSourceHost() int {
+	if config == nil {
+		return 0
+	}
+	return config.MaxConcurrentJobsPerSourceHost
+}
+
+func GetLogConfig() *log.Config {
+	if config == nil {
+		return &log.Config{}
+	}
+	return &config.Log
+}
+
+func GetReferenceRevokePolicy() string {
+	if config == nil || config.Reference.RevokePolicy != ReferenceRevokePolicyDelete {
+		return ReferenceRevokePolicyRetain
+	}
+	return ReferenceRevokePolicyDelete
+}
+
+func GetReferenceRevokeGracePeriod() time.Duration {
+	if config == nil || config.Reference.RevokeGracePeriod <= 0 {
+		return defaultReferenceRevokeGracePeriod
+	}
+	return config.Reference.RevokeGracePeriod
+}
+
+func GetReferenceOrphanSweepInterval() time.Duration {
+	if config == nil || config.Reference.OrphanSweepInterval <= 0 {
+		return defaultReferenceOrphanSweepInterval
+	}
+	return config.Reference.OrphanSweepInterval
+}
+
+func GetPVCExpansionConfig() PVCExpansionConfig {
+	if config == nil {
+		return PVCExpansionConfig{HeadroomPercent: defaultPVCExpansionHeadroomPercent}
+	}
+	expansion := config.PVCExpansion
+	if expansion.HeadroomPercent <= 0 {
+		expansion.HeadroomPercent = defaultPVCExpansionHeadroomPercent
+	}
+	return expansion
+}
+
+func GetExternalLoaderTypes() []string {
+	if config == nil {
+		return nil
+	}
+	return config.ExternalLoaderTypes
 }
 
 func GetDatasetJobSpecYaml() string {
@@ -42,6 +185,30 @@ template:
 	return config.DatasetJobSpecYaml
 }
 
+func GetNFSPVTemplateYaml() string {
+	if config == nil || config.NFSPVTemplateYaml == "" {
+		return `
+apiVersion: v1
+kind: PersistentVolume
+metadata:
+  annotations:
+    pv.kubernetes.io/provisioned-by: nfs.csi.k8s.io
+spec:
+  capacity:
+    storage: 100Ti
+  accessModes:
+    - ReadWriteMany
+  persistentVolumeReclaimPolicy: Retain
+  storageClassName: nfs-csi
+  mountOptions:
+    - nfsvers=4.1
+  csi:
+    driver: nfs.csi.k8s.io
+`
+	}
+	return config.NFSPVTemplateYaml
+}
+
 func ParseConfigFromFileContent(content string) error {
 	f, err := os.CreateTemp("", "dataset-config-*")
 	if err != nil {
@@ -0,0 +1,239 @@
+package dataloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+	"github.com/BaizeAI/dataset/pkg/log"
+	"github.com/BaizeAI/dataset/pkg/utils"
+)
+
+type ExtractMode string
+
+const (
+	ExtractModeAuto ExtractMode = "auto"
+	ExtractModeTar  ExtractMode = "tar"
+	ExtractModeZip  ExtractMode = "zip"
+	ExtractModeNone ExtractMode = "none"
+)
+
+// extractedStateFile records the archives extracted in previous rounds in the
+// synced directory, so that unchanged archives are not extracted again.
+const extractedStateFile = ".dataset-extracted.json"
+
+// extractedArchive identifies an extracted archive by its size and
+// modification time, along with how it has been extracted.
+type extractedArchive struct {
+	Size            int64     `json:"size"`
+	ModTime         time.Time `json:"modTime"`
+	StripComponents int       `json:"stripComponents"`
+}
+
+// readExtractedState reads the archives extracted in previous rounds, keyed
+// by their path relative to dir. A missing or unreadable state only means
+// that every archive is extracted again.
+func readExtractedState(logger *logrus.Entry, dir string) map[string]extractedArchive {
+	state := make(map[string]extractedArchive)
+	content, err := os.ReadFile(filepath.Join(dir, extractedStateFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("failed to read extracted archives, extracting all of them, err: %s", err)
+		}
+		return state
+	}
+
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		logger.Warnf("failed to parse extracted archives, extracting all of them, err: %s", err)
+		return make(map[string]extractedArchive)
+	}
+
+	return state
+}
+
+func writeExtractedState(dir string, state map[string]extractedArchive) error {
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, extractedStateFile), content, 0644) // #nosec G306
+}
+
+// ExtractOptions are the options of the extract stage, they are shared by all
+// types of data sources.
+type ExtractOptions struct {
+	// Extract is one of auto, tar, zip or none. auto extracts every archive
+	// recognized by its extension, tar and zip only extract archives of that
+	// format.
+	Extract         string `json:"extract"`
+	StripComponents string `json:"stripComponents"`
+	KeepArchive     string `json:"keepArchive"`
+
+	mode            ExtractMode
+	stripComponents int
+	keepArchive     bool
+}
+
+func parseExtractOptions(options map[string]string) (ExtractOptions, error) {
+	jsonContent, err := json.Marshal(options)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+
+	var extractOptions ExtractOptions
+	err = json.Unmarshal(jsonContent, &extractOptions)
+	if err != nil {
+		return ExtractOptions{}, err
+	}
+
+	extractOptions.mode = ExtractMode(extractOptions.Extract)
+	switch extractOptions.mode {
+	case "":
+		extractOptions.mode = ExtractModeNone
+	case ExtractModeAuto, ExtractModeTar, ExtractModeZip, ExtractModeNone:
+	default:
+		return ExtractOptions{}, fmt.Errorf("invalid extract %s, must be one of auto, tar, zip, none", extractOptions.Extract)
+	}
+
+	if extractOptions.StripComponents != "" {
+		extractOptions.stripComponents, err = strconv.Atoi(extractOptions.StripComponents)
+		if err != nil || extractOptions.stripComponents < 0 {
+			return ExtractOptions{}, fmt.Errorf("invalid stripComponents %s, must be a non-negative integer", extractOptions.StripComponents)
+		}
+	}
+	if extractOptions.KeepArchive != "" {
+		extractOptions.keepArchive, err = strconv.ParseBool(extractOptions.KeepArchive)
+		if err != nil {
+			return ExtractOptions{}, fmt.Errorf("invalid keepArchive %s: %w", extractOptions.KeepArchive, err)
+		}
+	}
+
+	return extractOptions, nil
+}
+
+func (o ExtractOptions) matches(format utils.ArchiveFormat) bool {
+	switch o.mode {
+	case ExtractModeAuto:
+		return true
+	case ExtractModeTar:
+		return format == utils.ArchiveFormatTar
+	case ExtractModeZip:
+		return format == utils.ArchiveFormatZip
+	default:
+		return false
+	}
+}
+
+// execExtract unpacks the archives copied by the data source in place, next
+// to the archive itself, and deletes the archives unless keepArchive is set.
+// Archives of the same size and modification time as when they were last
+// extracted are skipped. The data source copies deleted archives again every
+// round though, only keepArchive avoids transferring them again.
+func execExtract(ctx context.Context, rawOptions map[string]string, datasourceOptions datasources.Options, _ datasources.Secrets) error {
+	extractOptions, err := parseExtractOptions(rawOptions)
+	if err != nil {
+		return err
+	}
+	if extractOptions.mode == ExtractModeNone {
+		return nil
+	}
+
+	dir := filepath.Join(datasourceOptions.Root, datasourceOptions.Path)
+	logger := log.WithFields(logrus.Fields{
+		"action":          "extract",
+		"dir":             dir,
+		"extract":         extractOptions.mode,
+		"stripComponents": extractOptions.stripComponents,
+		"keepArchive":     extractOptions.keepArchive,
+	})
+
+	archives := make([]lo.Entry[string, utils.ArchiveFormat], 0)
+	// collect the archives first so that archives extracted from other
+	// archives are left as is
+	err = filepath.WalkDir(dir, func(walkPath string, walkDirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if walkDirEntry.IsDir() {
+			if walkDirEntry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+		if !walkDirEntry.Type().IsRegular() {
+			return nil
+		}
+
+		format, ok := utils.DetectArchiveFormat(walkDirEntry.Name())
+		if ok && extractOptions.matches(format) {
+			archives = append(archives, lo.Entry[string, utils.ArchiveFormat]{Key: walkPath, Value: format})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find archives in %s: %w", dir, err)
+	}
+
+	state := readExtractedState(logger, dir)
+	for _, entry := range archives {
+		archive, format := entry.Key, entry.Value
+
+		info, err := os.Stat(archive)
+		if err != nil {
+			return fmt.Errorf("failed to stat archive %s: %w", archive, err)
+		}
+		relPath, err := filepath.Rel(dir, archive)
+		if err != nil {
+			return err
+		}
+		extracted := extractedArchive{
+			Size:            info.Size(),
+			ModTime:         info.ModTime().UTC(),
+			StripComponents: extractOptions.stripComponents,
+		}
+
+		if previous, ok := state[relPath]; ok && previous.Size == extracted.Size &&
+			previous.ModTime.Equal(extracted.ModTime) && previous.StripComponents == extracted.StripComponents {
+			logger.Infof("skipping %s archive %s, extracted already", format, archive)
+		} else {
+			logger.Infof("extracting %s archive %s", format, archive)
+
+			err = utils.ExtractArchive(ctx, logger, archive, filepath.Dir(archive), format, extractOptions.stripComponents)
+			if err != nil {
+				return fmt.Errorf("failed to extract %s: %w", archive, err)
+			}
+
+			state[relPath] = extracted
+			err = writeExtractedState(dir, state)
+			if err != nil {
+				return fmt.Errorf("failed to record extracted archive %s: %w", archive, err)
+			}
+		}
+		if extractOptions.keepArchive {
+			continue
+		}
+
+		err = os.Remove(archive)
+		if err != nil {
+			return fmt.Errorf("failed to remove extracted archive %s: %w", archive, err)
+		}
+	}
+
+	return nil
+}
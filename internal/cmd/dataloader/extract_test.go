@@ -0,0 +1,219 @@
+package dataloader
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+func writeTestTar(t *testing.T, path string, files map[string]string) {
+	buffer := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buffer)
+	for name, content := range files {
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, os.WriteFile(path, buffer.Bytes(), 0644))
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	buffer := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buffer)
+	for name, content := range files {
+		w, err := zipWriter.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, os.WriteFile(path, buffer.Bytes(), 0644))
+}
+
+func TestParseExtractOptions(t *testing.T) {
+	cases := []struct {
+		name            string
+		options         map[string]string
+		mode            ExtractMode
+		stripComponents int
+		keepArchive     bool
+		wantErr         bool
+	}{
+		{
+			name:    "default",
+			options: map[string]string{},
+			mode:    ExtractModeNone,
+		},
+		{
+			name: "auto",
+			options: map[string]string{
+				"extract":         "auto",
+				"stripComponents": "2",
+				"keepArchive":     "true",
+			},
+			mode:            ExtractModeAuto,
+			stripComponents: 2,
+			keepArchive:     true,
+		},
+		{
+			name:    "zip",
+			options: map[string]string{"extract": "zip"},
+			mode:    ExtractModeZip,
+		},
+		{
+			name:    "invalid extract",
+			options: map[string]string{"extract": "rar"},
+			wantErr: true,
+		},
+		{
+			name:    "negative stripComponents",
+			options: map[string]string{"extract": "tar", "stripComponents": "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid keepArchive",
+			options: map[string]string{"extract": "tar", "keepArchive": "maybe"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options, err := parseExtractOptions(c.options)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.mode, options.mode)
+			assert.Equal(t, c.stripComponents, options.stripComponents)
+			assert.Equal(t, c.keepArchive, options.keepArchive)
+		})
+	}
+}
+
+func TestExecExtract(t *testing.T) {
+	cases := []struct {
+		name     string
+		options  map[string]string
+		exists   []string
+		notExist []string
+	}{
+		{
+			name:     "none",
+			options:  map[string]string{},
+			exists:   []string{"data/bundle.tar", "data/images.zip"},
+			notExist: []string{"data/bundle/train.csv", "data/images/a.png"},
+		},
+		{
+			name:     "auto",
+			options:  map[string]string{"extract": "auto"},
+			exists:   []string{"data/bundle/train.csv", "data/images/a.png"},
+			notExist: []string{"data/bundle.tar", "data/images.zip"},
+		},
+		{
+			name:     "tar only",
+			options:  map[string]string{"extract": "tar"},
+			exists:   []string{"data/bundle/train.csv", "data/images.zip"},
+			notExist: []string{"data/bundle.tar", "data/images/a.png"},
+		},
+		{
+			name:     "keepArchive w/ stripComponents",
+			options:  map[string]string{"extract": "auto", "stripComponents": "1", "keepArchive": "true"},
+			exists:   []string{"data/train.csv", "data/a.png", "data/bundle.tar", "data/images.zip"},
+			notExist: []string{"data/bundle/train.csv"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			require.NoError(t, os.MkdirAll(filepath.Join(root, "data"), 0755))
+			writeTestTar(t, filepath.Join(root, "data", "bundle.tar"), map[string]string{"bundle/train.csv": "a,b\n"})
+			writeTestZip(t, filepath.Join(root, "data", "images.zip"), map[string]string{"images/a.png": "png"})
+
+			err := execExtract(context.Background(), c.options, datasources.Options{Root: root, Path: "data"}, datasources.Secrets{})
+			require.NoError(t, err)
+			for _, p := range c.exists {
+				assert.FileExists(t, filepath.Join(root, p))
+			}
+			for _, p := range c.notExist {
+				assert.NoFileExists(t, filepath.Join(root, p))
+			}
+		})
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		err := execExtract(context.Background(), map[string]string{"extract": "rar"}, datasources.Options{Root: t.TempDir()}, datasources.Secrets{})
+		assert.Error(t, err)
+	})
+}
+
+func TestExecExtractSkipsExtractedArchives(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+	archive := filepath.Join(dir, "bundle.tar")
+	extracted := filepath.Join(dir, "bundle", "train.csv")
+	modTime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	writeArchive := func(content string) {
+		writeTestTar(t, archive, map[string]string{"bundle/train.csv": content})
+		require.NoError(t, os.Chtimes(archive, modTime, modTime))
+	}
+	extract := func(options map[string]string) {
+		require.NoError(t, execExtract(ctx, options, datasources.Options{Root: root, Path: "data"}, datasources.Secrets{}))
+	}
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	keepArchive := map[string]string{"extract": "auto", "keepArchive": "true"}
+
+	writeArchive("a,b\n")
+	extract(keepArchive)
+	assert.FileExists(t, extracted)
+	assert.FileExists(t, filepath.Join(dir, extractedStateFile))
+
+	// unchanged archives are not extracted again
+	require.NoError(t, os.Remove(extracted))
+	extract(keepArchive)
+	assert.NoFileExists(t, extracted)
+
+	// nor are archives the data source copies again after they were deleted
+	extract(map[string]string{"extract": "auto"})
+	assert.NoFileExists(t, archive)
+	writeArchive("a,b\n")
+	extract(map[string]string{"extract": "auto"})
+	assert.NoFileExists(t, archive)
+	assert.NoFileExists(t, extracted)
+
+	// changed archives are extracted again
+	modTime = modTime.Add(time.Hour)
+	writeArchive("a,b,c\n")
+	extract(keepArchive)
+	content, err := os.ReadFile(extracted)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b,c\n", string(content))
+
+	// and so are archives extracted with other stripComponents before
+	extract(map[string]string{"extract": "auto", "stripComponents": "1", "keepArchive": "true"})
+	assert.FileExists(t, filepath.Join(dir, "train.csv"))
+
+	t.Run("unreadable state", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, extractedStateFile), []byte("{"), 0644))
+		require.NoError(t, os.Remove(extracted))
+		extract(keepArchive)
+		assert.FileExists(t, extracted)
+	})
+}
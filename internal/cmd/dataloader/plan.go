@@ -0,0 +1,147 @@
+package dataloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+	"github.com/BaizeAI/dataset/pkg/log"
+	"github.com/BaizeAI/dataset/pkg/utils"
+)
+
+const (
+	planOutputText = "text"
+	planOutputJSON = "json"
+)
+
+type PlanCommandFlags struct {
+	CommandFlags
+
+	Output string
+}
+
+func newPlanCommand() *cobra.Command {
+	planCmd := &cobra.Command{
+		Use:   "plan <type> <uri>",
+		Short: "Show what loading the dataset would do without writing anything",
+		Long: `Show what loading the dataset would do without writing anything.
+
+The revision of the source is resolved and the files are compared with the
+current contents of the mount path, the files that would be transferred,
+deleted or skipped are listed together with their total sizes. The summary is
+written as the termination message for the controller to read.`,
+	}
+
+	flags := new(PlanCommandFlags)
+	addCommandFlags(planCmd, &flags.CommandFlags)
+	planCmd.Flags().StringVar(&flags.Output, "output", planOutputText, "Output format of the plan, text or json")
+
+	validateArgs := newCommandValidateArgsFunc(&flags.CommandFlags)
+	planCmd.Args = func(cmd *cobra.Command, args []string) error {
+		if flags.Output != planOutputText && flags.Output != planOutputJSON {
+			return fmt.Errorf("invalid flag --output %s, must be one of text or json", flags.Output)
+		}
+
+		return validateArgs(cmd, args)
+	}
+	planCmd.Run = newPlanCommandRunFunc(flags)
+
+	return planCmd
+}
+
+func execPlan(ctx context.Context, rawOptions map[string]string, datasourceOptions datasources.Options, secrets datasources.Secrets) (*datasources.Plan, error) {
+	datasourceLoader, err := datasources.NewLoader(rawOptions, datasourceOptions, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	planner, ok := datasourceLoader.(datasources.Planner)
+	if !ok {
+		return nil, fmt.Errorf("plan is not supported for data source type %s", datasourceOptions.Type)
+	}
+
+	return planner.Plan(ctx, datasourceOptions.URI, datasourceOptions.Path)
+}
+
+func printPlan(w io.Writer, plan *datasources.Plan, output string) error {
+	if output == planOutputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(plan)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if plan.Revision != "" {
+		_, _ = fmt.Fprintf(tw, "revision:\t%s\n\n", plan.Revision)
+	}
+	for _, action := range []struct {
+		name  string
+		files []datasources.PlanFile
+	}{
+		{"transfer", plan.Transfer},
+		{"delete", plan.Delete},
+		{"skip", plan.Skip},
+	} {
+		for _, file := range action.files {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", action.name, utils.FormatSize(file.Size), file.Path)
+		}
+	}
+
+	summary := plan.Summary()
+	_, _ = fmt.Fprintf(tw, "\ntransfer:\t%d files\t%s\n", summary.TransferFiles, utils.FormatSize(summary.TransferBytes))
+	_, _ = fmt.Fprintf(tw, "delete:\t%d files\t%s\n", summary.DeleteFiles, utils.FormatSize(summary.DeleteBytes))
+	_, _ = fmt.Fprintf(tw, "skip:\t%d files\t%s\n", summary.SkipFiles, utils.FormatSize(summary.SkipBytes))
+	if summary.DeleteError != "" {
+		_, _ = fmt.Fprintf(tw, "\nsync would fail: %s\n", summary.DeleteError)
+	}
+
+	return tw.Flush()
+}
+
+func newPlanCommandRunFunc(flags *PlanCommandFlags) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		// the plan is printed to stdout, keep the logs apart from it
+		err := initLog(&flags.CommandFlags, args[0], "stderr")
+		if err != nil {
+			handleError(err)
+			return
+		}
+
+		options, datasourceOptions, err := parseCommandOptions(&flags.CommandFlags, args)
+		if err != nil {
+			handleError(err)
+			return
+		}
+
+		secrets, err := datasources.ReadAndParseSecrets(flags.MountSecrets)
+		if err != nil {
+			log.Warnf("failed to read and parse secrets from %s, err: %s", constants.DatasetJobSecretsMountPath, err)
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		plan, err := execPlan(ctx, options, datasourceOptions, secrets)
+		if err != nil {
+			handleError(err)
+			return
+		}
+
+		err = printPlan(os.Stdout, plan, flags.Output)
+		if err != nil {
+			handleError(err)
+			return
+		}
+
+		writeTerminationMessage(flags.TerminationMessagePath, plan.Summary())
+	}
+}
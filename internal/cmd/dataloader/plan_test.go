@@ -0,0 +1,105 @@
+package dataloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+func TestPlanCommandArgs(t *testing.T) {
+	cases := []struct {
+		name    string
+		flags   []string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:  "text",
+			flags: []string{"--mount-path=/data"},
+			args:  []string{"S3", "s3://bucket"},
+		},
+		{
+			name:  "json",
+			flags: []string{"--mount-path=/data", "--output=json"},
+			args:  []string{"HUGGING_FACE", "huggingface://Qwen/Qwen2-7B"},
+		},
+		{
+			name:    "invalid output",
+			flags:   []string{"--mount-path=/data", "--output=yaml"},
+			args:    []string{"S3", "s3://bucket"},
+			wantErr: "invalid flag --output yaml, must be one of text or json",
+		},
+		{
+			name:    "missing mount path",
+			args:    []string{"S3", "s3://bucket"},
+			wantErr: "flag --mount-path is required",
+		},
+		{
+			name:    "missing uri",
+			flags:   []string{"--mount-path=/data"},
+			args:    []string{"S3"},
+			wantErr: "arguments <type> and <uri> are required",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd := newPlanCommand()
+			require.NoError(t, cmd.Flags().Parse(c.flags))
+			err := cmd.Args(cmd, c.args)
+			if c.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.wantErr)
+		})
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	plan := &datasources.Plan{
+		Revision: "8f2c1e0",
+		Transfer: []datasources.PlanFile{{Path: "train.csv", Size: 2048}},
+		Delete:   []datasources.PlanFile{{Path: "old.csv", Size: 1024}},
+		Skip:     []datasources.PlanFile{},
+	}
+
+	t.Run("text", func(t *testing.T) {
+		buffer := new(bytes.Buffer)
+		require.NoError(t, printPlan(buffer, plan, planOutputText))
+		output := buffer.String()
+		assert.Contains(t, output, "revision:  8f2c1e0")
+		assert.Regexp(t, `transfer\s+2\.0 ?KiB\s+train\.csv`, output)
+		assert.Regexp(t, `delete\s+1\.0 ?KiB\s+old\.csv`, output)
+		assert.Regexp(t, `skip:\s+0 files`, output)
+		assert.NotContains(t, output, "sync would fail")
+	})
+
+	t.Run("text w/ delete error", func(t *testing.T) {
+		buffer := new(bytes.Buffer)
+		require.NoError(t, printPlan(buffer, &datasources.Plan{DeleteError: "deleting 3 of 4 files exceeds maxDeletePercent 50"}, planOutputText))
+		assert.Contains(t, buffer.String(), "sync would fail: deleting 3 of 4 files exceeds maxDeletePercent 50")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buffer := new(bytes.Buffer)
+		require.NoError(t, printPlan(buffer, plan, planOutputJSON))
+		decoded := &datasources.Plan{}
+		require.NoError(t, json.Unmarshal(buffer.Bytes(), decoded))
+		assert.Equal(t, plan, decoded)
+	})
+}
+
+func TestExecPlanUnsupported(t *testing.T) {
+	_, err := execPlan(context.Background(), map[string]string{}, datasources.Options{
+		Type: datasources.TypeGit,
+		URI:  "https://github.com/BaizeAI/dataset.git",
+		Root: t.TempDir(),
+	}, datasources.Secrets{})
+	assert.EqualError(t, err, "plan is not supported for data source type GIT")
+}
@@ -1,14 +1,20 @@
 package dataloader
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 
 	"github.com/BaizeAI/dataset/internal/pkg/constants"
@@ -18,41 +24,86 @@ import (
 )
 
 func NewCommand() *cobra.Command {
-	supportedTypesOfDataSourcesCommandHelpStr := strings.Join(datasources.SupportedTypesString, "|")
+	supportedTypesOfDataSourcesCommandHelpStr := strings.Join(datasources.SupportedTypesString(), "|")
 
 	rootCmd := &cobra.Command{
 		Use:   fmt.Sprintf("data-loader [%s] <uri>", supportedTypesOfDataSourcesCommandHelpStr),
 		Short: "Load datasets from various data sources",
+		Long: fmt.Sprintf(`Load datasets from various data sources.
+
+Types other than %s are delegated to an external %s executable found in PATH,
+it reads the request as JSON from stdin and writes the response as JSON to stdout.`,
+			strings.Join(datasources.SupportedTypesString(), ", "), datasources.ExternalLoaderExecutable("<type>")),
 	}
 
 	flags := new(CommandFlags)
-
-	rootCmd.Flags().StringVar(&flags.MountPath, "mount-path", "", "Mount path for data source to copy to")
-	rootCmd.Flags().StringVar(&flags.MountMode, "mount-mode", "0755", "Mount mode for data source to copy to")
-	rootCmd.Flags().IntVar(&flags.MountUID, "mount-uid", 1000, "Mount UID for data source to copy to")
-	rootCmd.Flags().IntVar(&flags.MountGID, "mount-gid", 1000, "Mount GID for data source to copy to")
-	rootCmd.Flags().StringVar(&flags.MountRoot, "mount-root", "", "Mount root for data source to copy to")
-	rootCmd.Flags().StringVar(&flags.MountSecrets, "mount-secrets", constants.DatasetJobSecretsMountPath, "Mount secrets for data source to copy to")
-	rootCmd.Flags().StringArrayVarP(&flags.Options, "options", "o", []string{}, "Options for data source to copy from")
+	addCommandFlags(rootCmd, flags)
 
 	rootCmd.Args = newCommandValidateArgsFunc(flags)
 	rootCmd.Run = newCommandRunEFunc(flags)
 
+	rootCmd.AddCommand(newPlanCommand())
+
 	return rootCmd
 }
 
+func addCommandFlags(cmd *cobra.Command, flags *CommandFlags) {
+	cmd.Flags().StringVar(&flags.MountPath, "mount-path", "", "Mount path for data source to copy to")
+	cmd.Flags().StringVar(&flags.MountMode, "mount-mode", "0755", "Mount mode for data source to copy to")
+	cmd.Flags().StringVar(&flags.MountDirMode, "mount-dir-mode", "", "Mount mode of the directories for data source to copy to, the mount mode if empty")
+	cmd.Flags().IntVar(&flags.MountUID, "mount-uid", 1000, "Mount UID for data source to copy to")
+	cmd.Flags().IntVar(&flags.MountGID, "mount-gid", 1000, "Mount GID for data source to copy to")
+	cmd.Flags().StringVar(&flags.MountRoot, "mount-root", "", "Mount root for data source to copy to")
+	cmd.Flags().StringVar(&flags.MountSecrets, "mount-secrets", constants.DatasetJobSecretsMountPath, "Mount secrets for data source to copy to")
+	cmd.Flags().StringArrayVarP(&flags.Options, "options", "o", []string{}, "Options for data source to copy from")
+	cmd.Flags().StringVar(&flags.BandwidthLimit, "bandwidth-limit", "", "Total bandwidth limit per second with an optional B, K, M or G suffix, e.g. 10M, KiB if no suffix, unlimited if empty")
+	cmd.Flags().IntVar(&flags.MaxConcurrency, "max-concurrency", 0, "Maximum number of files transferred in parallel, 0 for the default of the loader")
+	cmd.Flags().IntVar(&flags.MaxRetries, "max-retries", 0, "Maximum number of retries of failed transfers, 0 for the default of the loader")
+	cmd.Flags().BoolVar(&flags.CheckFreeSpace, "check-free-space", false, "Estimate the size to transfer before syncing and fail early if the volume has not enough free space, for data sources able to plan")
+	cmd.Flags().StringVar(&flags.TerminationMessagePath, "termination-message-path", constants.DatasetJobTerminationMessagePath, "Path to write the sync result or the plan summary to for the controller to read")
+	cmd.Flags().StringVar(&flags.LogFormat, "log-format", log.FormatText, "Log format, text or json")
+	cmd.Flags().StringVar(&flags.LogLevel, "log-level", "debug", "Log level, one of panic, fatal, error, warn, info, debug or trace")
+	cmd.Flags().StringVar(&flags.DatasetNamespace, "dataset-namespace", "", "Namespace of the dataset being synced, added to every log entry")
+	cmd.Flags().StringVar(&flags.DatasetName, "dataset-name", "", "Name of the dataset being synced, added to every log entry")
+	cmd.Flags().Int64Var(&flags.DatasetRound, "dataset-round", 0, "Round of the dataset being synced, added to every log entry")
+}
+
 var (
 	optionsRegexp = regexp.MustCompile(`^(\w+)=(.*)$`)
 )
 
+const (
+	// maxLargestFiles is the number of the largest files reported to the
+	// controller.
+	maxLargestFiles = 5
+	// terminationMessageMaxBytes is the size kubelet truncates the termination
+	// message of a container to.
+	terminationMessageMaxBytes = 4096
+)
+
 type CommandFlags struct {
 	MountPath    string
 	MountMode    string
+	MountDirMode string
 	MountUID     int
 	MountGID     int
 	MountRoot    string
 	MountSecrets string
 	Options      []string
+
+	BandwidthLimit string
+	MaxConcurrency int
+	MaxRetries     int
+
+	CheckFreeSpace bool
+
+	TerminationMessagePath string
+
+	LogFormat        string
+	LogLevel         string
+	DatasetNamespace string
+	DatasetName      string
+	DatasetRound     int64
 }
 
 func newCommandValidateArgsFunc(flags *CommandFlags) func(cmd *cobra.Command, args []string) error {
@@ -60,25 +111,83 @@ func newCommandValidateArgsFunc(flags *CommandFlags) func(cmd *cobra.Command, ar
 		if len(args) < 2 || args[0] == "" || args[1] == "" {
 			return fmt.Errorf("arguments <type> and <uri> are required")
 		}
-		if !lo.Contains(datasources.SupportedTypesString, args[0]) {
-			return fmt.Errorf("data source type %s is not supported, supported types are %s", args[0], strings.Join(datasources.SupportedTypesString, ", "))
+		if !datasources.IsSupported(datasources.Type(args[0])) {
+			return fmt.Errorf("data source type %s is not supported, supported types are %s, or an external %s executable in PATH",
+				args[0], strings.Join(datasources.SupportedTypesString(), ", "), datasources.ExternalLoaderExecutable(datasources.Type(args[0])))
 		}
 		if flags.MountPath == "" {
 			return fmt.Errorf("flag --mount-path is required")
 		}
+		if flags.BandwidthLimit != "" {
+			if _, err := utils.ParseBandwidth(flags.BandwidthLimit); err != nil {
+				return fmt.Errorf("invalid flag --bandwidth-limit: %w", err)
+			}
+		}
+		if flags.MaxConcurrency < 0 {
+			return fmt.Errorf("flag --max-concurrency must not be negative")
+		}
+		if flags.MaxRetries < 0 {
+			return fmt.Errorf("flag --max-retries must not be negative")
+		}
 
 		return nil
 	}
 }
 
-func execPostCopy(_ map[string]string, datasourceOptions datasources.Options, _ datasources.Secrets) error {
-	err := utils.ChmodAndChownRecursively(
-		log.WithField("action", "post copy"),
-		filepath.Join(datasourceOptions.Root, datasourceOptions.Path),
-		datasourceOptions.UID,
-		datasourceOptions.GID,
-		datasourceOptions.Mode,
-	)
+// initLog configures the logs as the controller asked for, every entry carries
+// the dataset, namespace, round and type fields for the log pipeline.
+func initLog(flags *CommandFlags, typ string, output string) error {
+	err := log.InitEngine(&log.Config{
+		Output: output,
+		Format: flags.LogFormat,
+		Level:  flags.LogLevel,
+	})
+	if err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{
+		log.FieldType: typ,
+	}
+	if flags.DatasetName != "" {
+		fields[log.FieldDataset] = flags.DatasetName
+		fields[log.FieldNamespace] = flags.DatasetNamespace
+		fields[log.FieldRound] = flags.DatasetRound
+	}
+	log.AddFields(fields)
+
+	return nil
+}
+
+// execPostCopy changes the mode and the owner of the files the loader reports
+// as changed, or of all the files if it cannot tell, or if archives may have
+// been extracted.
+func execPostCopy(ctx context.Context, rawOptions map[string]string, datasourceOptions datasources.Options, _ datasources.Secrets, datasourceLoader datasources.Loader) error {
+	logger := log.WithField("action", "post copy")
+	dir := filepath.Join(datasourceOptions.Root, datasourceOptions.Path)
+	chmodChownOptions := utils.ChmodChownOptions{
+		UID:      datasourceOptions.UID,
+		GID:      datasourceOptions.GID,
+		FileMode: datasourceOptions.Mode,
+		DirMode:  datasourceOptions.DirMode,
+	}
+
+	var changedFiles []string
+	changedKnown := false
+	if reporter, ok := datasourceLoader.(datasources.ChangeReporter); ok {
+		changedFiles, changedKnown = reporter.ChangedFiles()
+	}
+	extractOptions, err := parseExtractOptions(rawOptions)
+	if err != nil {
+		return err
+	}
+
+	if changedKnown && extractOptions.mode == ExtractModeNone {
+		logger.Debugf("changing the mode and owner of %d changed files", len(changedFiles))
+		err = utils.ChmodAndChownFiles(ctx, logger, dir, changedFiles, chmodChownOptions)
+	} else {
+		err = utils.ChmodAndChownTree(ctx, logger, dir, chmodChownOptions)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to perform post chmod and chown operations, err: %w", err)
 	}
@@ -86,102 +195,222 @@ func execPostCopy(_ map[string]string, datasourceOptions datasources.Options, _
 	return nil
 }
 
-func execCopy(rawOptions map[string]string, datasourceOptions datasources.Options, secrets datasources.Secrets) error {
-	var err error
-	var datasourceLoader datasources.Loader
+// execStat tells how big the synced directory is for the controller to
+// report, failing to do so never fails the round.
+func execStat(ctx context.Context, datasourceOptions datasources.Options) *datasources.DirStats {
+	dir := filepath.Join(datasourceOptions.Root, datasourceOptions.Path)
 
-	switch datasourceOptions.Type {
-	case datasources.TypeS3:
-		datasourceLoader, err = datasources.NewS3Loader(rawOptions, datasourceOptions, secrets)
-		if err != nil {
-			return err
-		}
-	case datasources.TypeHTTP:
-		datasourceLoader, err = datasources.NewHTTPLoader(rawOptions, datasourceOptions, secrets)
-		if err != nil {
-			return err
-		}
-	case datasources.TypeGit:
-		datasourceLoader, err = datasources.NewGitLoader(rawOptions, datasourceOptions, secrets)
-		if err != nil {
-			return err
+	stats, err := datasources.StatDir(ctx, dir, maxLargestFiles)
+	if err != nil {
+		log.WithField("action", "stat").Warnf("failed to stat %s, err: %s", dir, err)
+		return nil
+	}
+
+	return stats
+}
+
+func execCopy(ctx context.Context, rawOptions map[string]string, datasourceOptions datasources.Options, secrets datasources.Secrets) (datasources.SyncResult, datasources.Loader, error) {
+	var result datasources.SyncResult
+
+	datasourceLoader, err := datasources.NewLoader(rawOptions, datasourceOptions, secrets)
+	if err != nil {
+		return result, nil, err
+	}
+
+	err = datasourceLoader.Sync(ctx, datasourceOptions.URI, datasourceOptions.Path)
+	if err != nil {
+		return result, nil, err
+	}
+
+	if revisioner, ok := datasourceLoader.(datasources.Revisioner); ok {
+		result.Revision = revisioner.Revision()
+	}
+
+	return result, datasourceLoader, nil
+}
+
+// execSync checks the free space if asked to and copies the data source.
+// The check is part of the sync stage, both share a single deadline rather
+// than taking up to twice the timeout.
+func execSync(ctx context.Context, checkSpace bool, timeout time.Duration, rawOptions map[string]string, datasourceOptions datasources.Options, secrets datasources.Secrets) (datasources.SyncResult, datasources.Loader, error) {
+	var result datasources.SyncResult
+	var datasourceLoader datasources.Loader
+	err := runStage(ctx, "sync", timeout, func(ctx context.Context) error {
+		var err error
+		if checkSpace {
+			result.RequiredBytes, err = checkFreeSpace(ctx, rawOptions, datasourceOptions, secrets)
+			if err != nil {
+				return err
+			}
 		}
-	case datasources.TypeConda:
-		datasourceLoader, err = datasources.NewCondaLoader(rawOptions, datasourceOptions, secrets)
-		if err != nil {
-			return err
+
+		var syncResult datasources.SyncResult
+		syncResult, datasourceLoader, err = execCopy(ctx, rawOptions, datasourceOptions, secrets)
+		result.Revision = syncResult.Revision
+		return err
+	})
+	return result, datasourceLoader, err
+}
+
+// fitTerminationMessage drops the largest files from the stats until the
+// result fits in the termination message, the message kubelet truncates is
+// no longer valid JSON.
+func fitTerminationMessage(result datasources.SyncResult) datasources.SyncResult {
+	if result.Stats == nil {
+		return result
+	}
+
+	stats := *result.Stats
+	result.Stats = &stats
+	for len(stats.LargestFiles) > 0 {
+		content, err := json.Marshal(result)
+		if err != nil || len(content) <= terminationMessageMaxBytes {
+			break
 		}
-	case datasources.TypeHuggingFace:
-		datasourceLoader, err = datasources.NewHuggingFaceLoader(rawOptions, datasourceOptions, secrets)
-		if err != nil {
-			return err
+		stats.LargestFiles = stats.LargestFiles[:len(stats.LargestFiles)-1]
+	}
+
+	return result
+}
+
+// writeTerminationMessage writes the result as JSON to the termination
+// message of the container. Failing to do so should never fail the round,
+// data-loader may well be run outside a pod.
+func writeTerminationMessage(path string, result any) {
+	if path == "" {
+		return
+	}
+
+	logger := log.WithField("terminationMessagePath", path)
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		logger.Warnf("failed to marshal result, err: %s", err)
+		return
+	}
+
+	err = os.WriteFile(path, content, 0644) // #nosec G306
+	if err != nil {
+		logger.Warnf("failed to write result, err: %s", err)
+		return
+	}
+
+	logger.Debugf("result written: %s", content)
+}
+
+// parseCommandOptions parses the --options flags and builds the options of
+// the data source from the flags and the arguments.
+func parseCommandOptions(flags *CommandFlags, args []string) (map[string]string, datasources.Options, error) {
+	flags.MountPath = filepath.Join(".", flags.MountPath)
+
+	if flags.MountRoot == "" {
+		flags.MountRoot = lo.Must(os.Getwd())
+	}
+
+	options := make(map[string]string)
+	for _, optionStr := range flags.Options {
+		option := optionsRegexp.FindStringSubmatch(optionStr)
+
+		if len(option) == 3 {
+			options[option[1]] = option[2]
+		} else {
+			options[option[1]] = ""
 		}
-	case datasources.TypeModelScope:
-		datasourceLoader, err = datasources.NewModelScopeLoader(rawOptions, datasourceOptions, secrets)
+	}
+
+	fileMode, err := strconv.ParseUint(flags.MountMode, 8, 32)
+	if err != nil {
+		return nil, datasources.Options{}, err
+	}
+	var dirMode uint64
+	if flags.MountDirMode != "" {
+		dirMode, err = strconv.ParseUint(flags.MountDirMode, 8, 32)
 		if err != nil {
-			return err
+			return nil, datasources.Options{}, err
 		}
-	default:
-		return fmt.Errorf("data source type %s is not supported", datasourceOptions.Type)
 	}
 
-	err = datasourceLoader.Sync(datasourceOptions.URI, datasourceOptions.Path)
+	datasourceOptions := datasources.Options{
+		Type: datasources.Type(args[0]),
+		URI:  args[1],
+		Path: flags.MountPath,
+		Root: flags.MountRoot,
+		UID:  flags.MountUID,
+		GID:  flags.MountGID,
+		Mode: os.FileMode(fileMode),
+
+		DirMode: os.FileMode(dirMode),
+
+		BandwidthLimit: flags.BandwidthLimit,
+		MaxConcurrency: flags.MaxConcurrency,
+		MaxRetries:     flags.MaxRetries,
+	}
+
+	syncModeOptions, err := parseSyncModeOptions(options)
 	if err != nil {
-		return err
+		return nil, datasources.Options{}, err
 	}
+	datasourceOptions.SyncMode = syncModeOptions.syncMode
+	datasourceOptions.MaxDeletePercent = syncModeOptions.maxDeletePercent
+	datasourceOptions.ForceDelete = syncModeOptions.forceDelete
 
-	return nil
+	return options, datasourceOptions, nil
 }
 
 func newCommandRunEFunc(flags *CommandFlags) func(cmd *cobra.Command, args []string) {
 	return func(cmd *cobra.Command, args []string) {
-		flags.MountPath = filepath.Join(".", flags.MountPath)
-
-		if flags.MountRoot == "" {
-			flags.MountRoot = lo.Must(os.Getwd())
+		err := initLog(flags, args[0], "")
+		if err != nil {
+			handleError(err)
+			return
 		}
 
-		options := make(map[string]string)
-		for _, optionStr := range flags.Options {
-			option := optionsRegexp.FindStringSubmatch(optionStr)
+		options, datasourceOptions, err := parseCommandOptions(flags, args)
+		if err != nil {
+			handleError(err)
+			return
+		}
 
-			if len(option) == 3 {
-				options[option[1]] = option[2]
-			} else {
-				options[option[1]] = ""
-			}
+		secrets, err := datasources.ReadAndParseSecrets(flags.MountSecrets)
+		if err != nil {
+			log.Warnf("failed to read and parse secrets from %s, err: %s", constants.DatasetJobSecretsMountPath, err)
 		}
 
-		fileMode, err := strconv.ParseUint(flags.MountMode, 8, 32)
+		timeoutOptions, err := parseTimeoutOptions(options)
 		if err != nil {
 			handleError(err)
 			return
 		}
 
-		datasourceOptions := datasources.Options{
-			Type: datasources.Type(args[0]),
-			URI:  args[1],
-			Path: flags.MountPath,
-			Root: flags.MountRoot,
-			UID:  flags.MountUID,
-			GID:  flags.MountGID,
-			Mode: os.FileMode(fileMode),
-		}
+		// SIGTERM is sent on job deletion or once activeDeadlineSeconds is
+		// exceeded, commands spawned by the loaders are stopped with it so
+		// that temporary credentials can be cleaned up before exiting.
+		ctx, stop := signal.NotifyContext(cmd.Context(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
 
-		secrets, err := datasources.ReadAndParseSecrets(flags.MountSecrets)
+		result, datasourceLoader, err := execSync(ctx, flags.CheckFreeSpace, timeoutOptions.syncTimeout, options, datasourceOptions, secrets)
 		if err != nil {
-			log.Warnf("failed to read and parse secrets from %s, err: %s", constants.DatasetJobSecretsMountPath, err)
+			handleSyncError(flags.TerminationMessagePath, result, err)
 		}
 
-		err = execCopy(options, datasourceOptions, secrets)
+		err = runStage(ctx, "extract", timeoutOptions.extractTimeout, func(ctx context.Context) error {
+			return execExtract(ctx, options, datasourceOptions, secrets)
+		})
 		if err != nil {
-			handleError(err)
+			handleSyncError(flags.TerminationMessagePath, result, err)
 		}
 
-		err = execPostCopy(options, datasourceOptions, secrets)
+		err = runStage(ctx, "post copy", timeoutOptions.postCopyTimeout, func(ctx context.Context) error {
+			return execPostCopy(ctx, options, datasourceOptions, secrets, datasourceLoader)
+		})
 		if err != nil {
 			handleError(err)
 		}
+
+		// stat once the archives have been extracted
+		result.Stats = execStat(ctx, datasourceOptions)
+
+		writeTerminationMessage(flags.TerminationMessagePath, fitTerminationMessage(result))
 	}
 }
 
@@ -0,0 +1,78 @@
+package dataloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+func TestFitTerminationMessage(t *testing.T) {
+	t.Run("without stats", func(t *testing.T) {
+		result := datasources.SyncResult{Revision: "v1"}
+		assert.Equal(t, result, fitTerminationMessage(result))
+	})
+
+	t.Run("fits already", func(t *testing.T) {
+		result := datasources.SyncResult{
+			Revision: "v1",
+			Stats: &datasources.DirStats{
+				Size:         300,
+				FileCount:    2,
+				LargestFiles: []datasources.PlanFile{{Path: "a", Size: 200}, {Path: "b", Size: 100}},
+			},
+		}
+		assert.Equal(t, result, fitTerminationMessage(result))
+	})
+
+	t.Run("drops the smallest files", func(t *testing.T) {
+		files := make([]datasources.PlanFile, 0, 100)
+		for i := 0; i < 100; i++ {
+			files = append(files, datasources.PlanFile{
+				Path: fmt.Sprintf("%s/file-%03d.bin", strings.Repeat("d", 64), i),
+				Size: int64(1000 - i),
+			})
+		}
+		result := datasources.SyncResult{
+			Revision: "v1",
+			Stats:    &datasources.DirStats{Size: 1 << 20, FileCount: 100, LargestFiles: files},
+		}
+
+		fitted := fitTerminationMessage(result)
+		content, err := json.Marshal(fitted)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(content), terminationMessageMaxBytes)
+		assert.NotEmpty(t, fitted.Stats.LargestFiles)
+		assert.Less(t, len(fitted.Stats.LargestFiles), 100)
+		assert.Equal(t, files[:len(fitted.Stats.LargestFiles)], fitted.Stats.LargestFiles)
+		assert.EqualValues(t, 1<<20, fitted.Stats.Size)
+		assert.EqualValues(t, 100, fitted.Stats.FileCount)
+		assert.Equal(t, "v1", fitted.Revision)
+
+		// the original result is left untouched
+		assert.Len(t, result.Stats.LargestFiles, 100)
+	})
+}
+
+func TestWriteTerminationMessage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "termination-log")
+	writeTerminationMessage(path, datasources.SyncResult{Revision: "v1", Stats: &datasources.DirStats{Size: 10, FileCount: 1}})
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var result datasources.SyncResult
+	require.NoError(t, json.Unmarshal(content, &result))
+	assert.Equal(t, "v1", result.Revision)
+	assert.EqualValues(t, 10, result.Stats.Size)
+
+	// failing to write only logs a warning
+	writeTerminationMessage(filepath.Join(t.TempDir(), "missing", "termination-log"), datasources.SyncResult{})
+	writeTerminationMessage("", datasources.SyncResult{})
+}
@@ -0,0 +1,57 @@
+package dataloader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"syscall"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+	"github.com/BaizeAI/dataset/pkg/log"
+	"github.com/BaizeAI/dataset/pkg/utils"
+)
+
+// checkFreeSpace plans the sync to estimate the size the volume needs once it
+// is done, i.e. the size used now plus the size to transfer, and fails early
+// with ENOSPC if there is not enough free space for the transfer. 0 is
+// returned without error if the loader is not able to plan, or the usage of
+// the volume is unknown.
+func checkFreeSpace(ctx context.Context, rawOptions map[string]string, datasourceOptions datasources.Options, secrets datasources.Secrets) (int64, error) {
+	logger := log.WithField("action", "check free space")
+	dir := filepath.Join(datasourceOptions.Root, datasourceOptions.Path)
+
+	used, available, err := utils.DiskUsage(datasourceOptions.Root)
+	if err != nil {
+		logger.Warnf("failed to get the disk usage of %s, skipping, err: %s", datasourceOptions.Root, err)
+		return 0, nil
+	}
+
+	plan, err := execPlan(ctx, rawOptions, datasourceOptions, secrets)
+	if err != nil {
+		logger.Warnf("failed to estimate the size to transfer to %s, skipping, err: %s", dir, err)
+		return 0, nil
+	}
+
+	summary := plan.Summary()
+	required := used + summary.TransferBytes
+	if summary.TransferBytes > available {
+		return required, fmt.Errorf("%s to transfer to %s exceeds the %s available: %w",
+			utils.FormatSize(summary.TransferBytes), dir, utils.FormatSize(available), syscall.ENOSPC)
+	}
+	logger.Infof("%s to transfer, %s available", utils.FormatSize(summary.TransferBytes), utils.FormatSize(available))
+
+	return required, nil
+}
+
+// handleSyncError reports the failure to the controller through the
+// termination message if it is able to act on it, e.g. by expanding the
+// volume, before exiting.
+func handleSyncError(terminationMessagePath string, result datasources.SyncResult, err error) {
+	if datasources.IsOutOfSpace(err) {
+		result.Revision = ""
+		result.Reason = datasources.SyncFailureReasonOutOfSpace
+		writeTerminationMessage(terminationMessagePath, result)
+	}
+
+	handleError(err)
+}
@@ -0,0 +1,213 @@
+package dataloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+	"github.com/BaizeAI/dataset/pkg/utils"
+)
+
+// newSizeServer serves HEAD requests of a single file of the given size.
+func newSizeServer(t *testing.T, size int64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	if _, _, err := utils.DiskUsage(t.TempDir()); err != nil {
+		t.Skipf("disk usage is unknown: %s", err)
+	}
+
+	cases := []struct {
+		name       string
+		typ        datasources.Type
+		uri        func(t *testing.T) string
+		minimum    int64
+		outOfSpace bool
+	}{
+		{
+			name:    "enough space",
+			typ:     datasources.TypeHTTP,
+			uri:     func(t *testing.T) string { return newSizeServer(t, 1024).URL + "/model.bin" },
+			minimum: 1024,
+		},
+		{
+			name:       "not enough space",
+			typ:        datasources.TypeHTTP,
+			uri:        func(t *testing.T) string { return newSizeServer(t, 1<<60).URL + "/model.bin" },
+			minimum:    1 << 60,
+			outOfSpace: true,
+		},
+		{
+			name: "unable to plan",
+			typ:  datasources.TypeGit,
+			uri:  func(*testing.T) string { return "https://github.com/BaizeAI/dataset.git" },
+		},
+		{
+			name: "plan failed",
+			typ:  datasources.TypeHTTP,
+			uri: func(t *testing.T) string {
+				server := newSizeServer(t, 1024)
+				server.Close()
+				return server.URL + "/model.bin"
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			uri := c.uri(t)
+			required, err := checkFreeSpace(context.Background(), map[string]string{}, datasources.Options{
+				Type: c.typ,
+				URI:  uri,
+				Root: t.TempDir(),
+			}, datasources.Secrets{})
+			if c.outOfSpace {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, syscall.ENOSPC))
+				assert.True(t, datasources.IsOutOfSpace(err))
+			} else {
+				require.NoError(t, err)
+			}
+			if c.minimum == 0 {
+				assert.Zero(t, required)
+				return
+			}
+			assert.GreaterOrEqual(t, required, c.minimum)
+		})
+	}
+}
+
+// newSlowFileServer serves a single file at /model.bin, holding the first
+// request for delay.
+func newSlowFileServer(t *testing.T, content string, delay time.Duration) *httptest.Server {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/model.bin" {
+			http.NotFound(w, r)
+			return
+		}
+		if requests.Add(1) == 1 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(content))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExecSync(t *testing.T) {
+	if _, _, err := utils.DiskUsage(t.TempDir()); err != nil {
+		t.Skipf("disk usage is unknown: %s", err)
+	}
+	newOptions := func(t *testing.T, uri string) datasources.Options {
+		return datasources.Options{Type: datasources.TypeHTTP, URI: uri, Root: t.TempDir(), Path: "data"}
+	}
+
+	t.Run("synced", func(t *testing.T) {
+		options := newOptions(t, newSlowFileServer(t, "weights", 0).URL+"/model.bin")
+		result, _, err := execSync(context.Background(), true, time.Minute, map[string]string{}, options, datasources.Secrets{})
+		require.NoError(t, err)
+		assert.Positive(t, result.RequiredBytes)
+		assert.FileExists(t, filepath.Join(options.Root, "data", "model.bin"))
+	})
+
+	t.Run("out of space", func(t *testing.T) {
+		options := newOptions(t, newSizeServer(t, 1<<60).URL+"/model.bin")
+		result, _, err := execSync(context.Background(), true, time.Minute, map[string]string{}, options, datasources.Secrets{})
+		require.Error(t, err)
+		assert.True(t, datasources.IsOutOfSpace(err))
+		assert.GreaterOrEqual(t, result.RequiredBytes, int64(1<<60))
+		assert.NoFileExists(t, filepath.Join(options.Root, "data", "model.bin"))
+	})
+
+	t.Run("check and sync share the timeout", func(t *testing.T) {
+		// the check takes the whole timeout, nothing is left to sync
+		options := newOptions(t, newSlowFileServer(t, "weights", 5*time.Second).URL+"/model.bin")
+		_, _, err := execSync(context.Background(), true, 200*time.Millisecond, map[string]string{}, options, datasources.Secrets{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "sync timed out after 200ms")
+		assert.NoFileExists(t, filepath.Join(options.Root, "data", "model.bin"))
+	})
+}
+
+func TestHandleSyncError(t *testing.T) {
+	// handleSyncError exits, run it in a child process
+	if path := os.Getenv("TEST_HANDLE_SYNC_ERROR_PATH"); path != "" {
+		err := errors.New("exit status 1: failed to copy")
+		if os.Getenv("TEST_HANDLE_SYNC_ERROR_OUT_OF_SPACE") == "true" {
+			err = fmt.Errorf("failed to write train.csv: %w", syscall.ENOSPC)
+		}
+		handleSyncError(path, datasources.SyncResult{Revision: "v1", RequiredBytes: 4096}, err)
+		return
+	}
+
+	cases := []struct {
+		name       string
+		outOfSpace bool
+		result     *datasources.SyncResult
+	}{
+		{
+			name:       "out of space",
+			outOfSpace: true,
+			result:     &datasources.SyncResult{Reason: datasources.SyncFailureReasonOutOfSpace, RequiredBytes: 4096},
+		},
+		{
+			name: "other errors",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "termination-log")
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHandleSyncError$") // #nosec G204
+			cmd.Env = append(os.Environ(),
+				"TEST_HANDLE_SYNC_ERROR_PATH="+path,
+				"TEST_HANDLE_SYNC_ERROR_OUT_OF_SPACE="+strconv.FormatBool(c.outOfSpace),
+			)
+			output, err := cmd.CombinedOutput()
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			assert.Equal(t, 1, exitErr.ExitCode())
+			assert.Contains(t, string(output), "failed to load data:")
+
+			if c.result == nil {
+				assert.NoFileExists(t, path)
+				return
+			}
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			result := &datasources.SyncResult{}
+			require.NoError(t, json.Unmarshal(content, result))
+			assert.Equal(t, c.result, result)
+		})
+	}
+}
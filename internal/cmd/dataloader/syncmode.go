@@ -0,0 +1,71 @@
+package dataloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+// SyncModeOptions decide whether the files removed from the source are
+// deleted from the dataset as well, they are shared by all types of data
+// sources.
+type SyncModeOptions struct {
+	// SyncMode is either copy (default) or mirror.
+	SyncMode string `json:"syncMode"`
+	// MaxDeletePercent is the largest share of the synced files that mirror
+	// mode deletes in a single round unless forceDelete is set.
+	MaxDeletePercent string `json:"maxDeletePercent"`
+	ForceDelete      string `json:"forceDelete"`
+
+	syncMode         datasources.SyncMode
+	maxDeletePercent int
+	forceDelete      bool
+}
+
+func parseSyncModeOptions(options map[string]string) (SyncModeOptions, error) {
+	jsonContent, err := json.Marshal(options)
+	if err != nil {
+		return SyncModeOptions{}, err
+	}
+
+	var syncModeOptions SyncModeOptions
+	err = json.Unmarshal(jsonContent, &syncModeOptions)
+	if err != nil {
+		return SyncModeOptions{}, err
+	}
+
+	syncModeOptions.syncMode, err = datasources.ParseSyncMode(syncModeOptions.SyncMode)
+	if err != nil {
+		return SyncModeOptions{}, err
+	}
+
+	if syncModeOptions.MaxDeletePercent != "" {
+		syncModeOptions.maxDeletePercent, err = strconv.Atoi(syncModeOptions.MaxDeletePercent)
+		if err != nil || syncModeOptions.maxDeletePercent <= 0 || syncModeOptions.maxDeletePercent > 100 {
+			return SyncModeOptions{}, fmt.Errorf("invalid maxDeletePercent %s, must be an integer between 1 and 100", syncModeOptions.MaxDeletePercent)
+		}
+	}
+	if syncModeOptions.ForceDelete != "" {
+		syncModeOptions.forceDelete, err = strconv.ParseBool(syncModeOptions.ForceDelete)
+		if err != nil {
+			return SyncModeOptions{}, fmt.Errorf("invalid forceDelete %s: %w", syncModeOptions.ForceDelete, err)
+		}
+	}
+
+	if syncModeOptions.syncMode == datasources.SyncModeMirror {
+		extractOptions, err := parseExtractOptions(options)
+		if err != nil {
+			return SyncModeOptions{}, err
+		}
+		// the extracted files are never upstream, while the archives are
+		// deleted once extracted, mirroring them would download the archives
+		// and delete the extracted files in every round
+		if extractOptions.mode != ExtractModeNone {
+			return SyncModeOptions{}, fmt.Errorf("syncMode mirror can not be used together with extract %s", extractOptions.mode)
+		}
+	}
+
+	return syncModeOptions, nil
+}
@@ -0,0 +1,82 @@
+package dataloader
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+func TestParseSyncModeOptions(t *testing.T) {
+	cases := []struct {
+		name             string
+		options          map[string]string
+		syncMode         datasources.SyncMode
+		maxDeletePercent int
+		forceDelete      bool
+		wantErr          string
+	}{
+		{
+			name:     "default",
+			options:  map[string]string{},
+			syncMode: datasources.SyncModeCopy,
+		},
+		{
+			name: "mirror",
+			options: map[string]string{
+				"syncMode":         "mirror",
+				"maxDeletePercent": "20",
+				"forceDelete":      "true",
+			},
+			syncMode:         datasources.SyncModeMirror,
+			maxDeletePercent: 20,
+			forceDelete:      true,
+		},
+		{
+			name:     "copy w/ extract",
+			options:  map[string]string{"syncMode": "copy", "extract": "auto"},
+			syncMode: datasources.SyncModeCopy,
+		},
+		{
+			name:     "mirror w/ extract none",
+			options:  map[string]string{"syncMode": "mirror", "extract": "none"},
+			syncMode: datasources.SyncModeMirror,
+		},
+		{
+			name:    "mirror w/ extract",
+			options: map[string]string{"syncMode": "mirror", "extract": "tar"},
+			wantErr: "syncMode mirror can not be used together with extract tar",
+		},
+		{
+			name:    "invalid syncMode",
+			options: map[string]string{"syncMode": "rsync"},
+			wantErr: "invalid syncMode rsync",
+		},
+		{
+			name:    "maxDeletePercent out of range",
+			options: map[string]string{"syncMode": "mirror", "maxDeletePercent": "101"},
+			wantErr: "invalid maxDeletePercent 101",
+		},
+		{
+			name:    "invalid forceDelete",
+			options: map[string]string{"syncMode": "mirror", "forceDelete": "yes please"},
+			wantErr: "invalid forceDelete",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options, err := parseSyncModeOptions(c.options)
+			if c.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.syncMode, options.syncMode)
+			assert.Equal(t, c.maxDeletePercent, options.maxDeletePercent)
+			assert.Equal(t, c.forceDelete, options.forceDelete)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package dataloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimeoutOptions bound how long each stage of data-loader may take, they are
+// shared by all types of data sources. Stages run without a timeout unless
+// set, while all of them are still stopped once data-loader is asked to
+// terminate.
+type TimeoutOptions struct {
+	SyncTimeout     string `json:"syncTimeout"`
+	ExtractTimeout  string `json:"extractTimeout"`
+	PostCopyTimeout string `json:"postCopyTimeout"`
+
+	syncTimeout     time.Duration
+	extractTimeout  time.Duration
+	postCopyTimeout time.Duration
+}
+
+func parseTimeoutOptions(options map[string]string) (TimeoutOptions, error) {
+	jsonContent, err := json.Marshal(options)
+	if err != nil {
+		return TimeoutOptions{}, err
+	}
+
+	var timeoutOptions TimeoutOptions
+	err = json.Unmarshal(jsonContent, &timeoutOptions)
+	if err != nil {
+		return TimeoutOptions{}, err
+	}
+
+	for _, timeout := range []struct {
+		name     string
+		value    string
+		duration *time.Duration
+	}{
+		{"syncTimeout", timeoutOptions.SyncTimeout, &timeoutOptions.syncTimeout},
+		{"extractTimeout", timeoutOptions.ExtractTimeout, &timeoutOptions.extractTimeout},
+		{"postCopyTimeout", timeoutOptions.PostCopyTimeout, &timeoutOptions.postCopyTimeout},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+
+		*timeout.duration, err = time.ParseDuration(timeout.value)
+		if err != nil || *timeout.duration <= 0 {
+			return TimeoutOptions{}, fmt.Errorf("invalid %s %s, must be a positive duration, e.g. 30m", timeout.name, timeout.value)
+		}
+	}
+
+	return timeoutOptions, nil
+}
+
+// runStage runs the stage with the timeout applied if any, and describes why
+// the stage has been stopped if it failed because of ctx.
+func runStage(ctx context.Context, stage string, timeout time.Duration, run func(ctx context.Context) error) error {
+	stageCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		stageCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err := run(stageCtx)
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("%s interrupted: %w", stage, err)
+	case errors.Is(stageCtx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%s timed out after %s: %w", stage, timeout, err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,120 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeoutOptions(t *testing.T) {
+	cases := []struct {
+		name            string
+		options         map[string]string
+		syncTimeout     time.Duration
+		extractTimeout  time.Duration
+		postCopyTimeout time.Duration
+		wantErr         bool
+	}{
+		{
+			name:    "no timeout",
+			options: map[string]string{},
+		},
+		{
+			name: "all timeouts",
+			options: map[string]string{
+				"syncTimeout":     "1h",
+				"extractTimeout":  "30m",
+				"postCopyTimeout": "90s",
+			},
+			syncTimeout:     time.Hour,
+			extractTimeout:  30 * time.Minute,
+			postCopyTimeout: 90 * time.Second,
+		},
+		{
+			name:    "invalid duration",
+			options: map[string]string{"syncTimeout": "1 hour"},
+			wantErr: true,
+		},
+		{
+			name:    "zero duration",
+			options: map[string]string{"extractTimeout": "0s"},
+			wantErr: true,
+		},
+		{
+			name:    "negative duration",
+			options: map[string]string{"postCopyTimeout": "-1m"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			options, err := parseTimeoutOptions(c.options)
+			if c.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.syncTimeout, options.syncTimeout)
+			assert.Equal(t, c.extractTimeout, options.extractTimeout)
+			assert.Equal(t, c.postCopyTimeout, options.postCopyTimeout)
+		})
+	}
+}
+
+func waitDone(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunStage(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		timeout time.Duration
+		run     func(ctx context.Context) error
+		err     string
+	}{
+		{
+			name: "succeeded",
+			ctx:  context.Background(),
+			run:  func(context.Context) error { return nil },
+		},
+		{
+			name: "failed",
+			ctx:  context.Background(),
+			run:  func(context.Context) error { return errors.New("exit status 1") },
+			err:  "exit status 1",
+		},
+		{
+			name:    "timed out",
+			ctx:     context.Background(),
+			timeout: 10 * time.Millisecond,
+			run:     waitDone,
+			err:     "sync timed out after 10ms: context deadline exceeded",
+		},
+		{
+			name:    "interrupted",
+			ctx:     canceled,
+			timeout: time.Hour,
+			run:     waitDone,
+			err:     "sync interrupted: context canceled",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := runStage(c.ctx, "sync", c.timeout, c.run)
+			if c.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, c.err)
+		})
+	}
+}
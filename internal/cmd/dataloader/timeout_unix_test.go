@@ -0,0 +1,33 @@
+//go:build unix
+
+package dataloader
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunStageSIGTERM(t *testing.T) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+	defer stop()
+
+	started := make(chan struct{})
+	go func() {
+		<-started
+		assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	}()
+
+	err := runStage(ctx, "sync", time.Hour, func(ctx context.Context) error {
+		close(started)
+		return waitDone(ctx)
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sync interrupted")
+}
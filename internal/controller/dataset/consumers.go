@@ -0,0 +1,79 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/pkg/kubeutils"
+	"github.com/BaizeAI/dataset/pkg/log"
+)
+
+// reconcileConsumers 将已经绑定了 pvc 的 REFERENCE dataset 记录到 source dataset 的 status.consumers，
+// source dataset 删除时如果还有 consumer，除非强制删除，否则保留 finalizer 阻止删除
+func (r *DatasetReconciler) reconcileConsumers(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
+	references, err := r.listReferences(ctx, ds)
+	if err != nil {
+		return fmt.Errorf("list consumers of dataset error: %v", err)
+	}
+
+	consumers := lo.FilterMap(references, func(consumer datasetv1alpha1.Dataset, _ int) (datasetv1alpha1.DatasetReference, bool) {
+		return datasetv1alpha1.DatasetReference{
+			Namespace: consumer.Namespace,
+			Name:      consumer.Name,
+		}, consumer.Status.PVCName != ""
+	})
+	sort.Slice(consumers, func(i, j int) bool {
+		if consumers[i].Namespace != consumers[j].Namespace {
+			return consumers[i].Namespace < consumers[j].Namespace
+		}
+		return consumers[i].Name < consumers[j].Name
+	})
+	ds.Status.Consumers = consumers
+	ds.Status.ConsumerCount = int32(len(consumers))
+
+	if !kubeutils.IsDeleted(ds) || len(consumers) == 0 {
+		return nil
+	}
+	if ds.Annotations[constants.DatasetForceDeleteAnnotation] == "true" {
+		datasetLogger(ds).Warnf("force deleting dataset referenced by %d datasets", len(consumers))
+		return nil
+	}
+	names := lo.Map(consumers, func(consumer datasetv1alpha1.DatasetReference, _ int) string {
+		return consumer.Namespace + "/" + consumer.Name
+	})
+	return fmt.Errorf("dataset is still referenced by %s, delete them first or set annotation %s=true to force delete",
+		strings.Join(names, ", "), constants.DatasetForceDeleteAnnotation)
+}
+
+// sourcesOfReference 在 REFERENCE dataset 或者使用其他 namespace 中 PVC 的 PVC dataset 变化时更新其 source dataset 的 consumers
+func (r *DatasetReconciler) sourcesOfReference(ctx context.Context, obj client.Object) []reconcile.Request {
+	ds, ok := obj.(*datasetv1alpha1.Dataset)
+	if !ok {
+		return nil
+	}
+	if crossNamespacePVC(ds) {
+		namespace, name, _, _ := pvcSource(ds)
+		datasets, err := r.datasetsOfPVC(ctx, namespace, name)
+		if err != nil {
+			log.Component("controller").Errorf("list datasets of pvc %s/%s error: %v", namespace, name, err)
+			return nil
+		}
+		return datasetRequests(datasets)
+	}
+	if ds.Spec.Source.Type != datasetv1alpha1.DatasetTypeReference {
+		return nil
+	}
+	key, err := referenceSource(ds)
+	if err != nil {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: key}}
+}
@@ -0,0 +1,111 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func testReferenceDataset(namespace, name, pvcName string) *datasetv1alpha1.Dataset {
+	return &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{
+				Type: datasetv1alpha1.DatasetTypeReference,
+				URI:  "dataset://data-team/corpus",
+			},
+		},
+		Status: datasetv1alpha1.DatasetStatus{PVCName: pvcName},
+	}
+}
+
+func testDeletedSourceDataset(annotations map[string]string) *datasetv1alpha1.Dataset {
+	now := metav1.NewTime(time.Now())
+	return &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "corpus",
+			Namespace:         "data-team",
+			Annotations:       annotations,
+			Finalizers:        []string{datasetFinalizer},
+			DeletionTimestamp: &now,
+		},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Share: true,
+			Source: datasetv1alpha1.DatasetSource{
+				Type: datasetv1alpha1.DatasetTypeHTTP,
+				URI:  "https://example.com/corpus/",
+			},
+		},
+	}
+}
+
+func TestReconcileConsumers(t *testing.T) {
+	ctx := context.Background()
+	sourceDs := testDeletedSourceDataset(nil)
+	sourceDs.DeletionTimestamp = nil
+	r := newTestReconciler(t,
+		sourceDs,
+		testReferenceDataset("ml-team", "train", "train"),
+		testReferenceDataset("cv-team", "images", "images"),
+		// 还没有绑定 pvc 的不是 consumer
+		testReferenceDataset("nlp-team", "pending", ""),
+	)
+
+	require.NoError(t, r.reconcileConsumers(ctx, sourceDs))
+	assert.Equal(t, []datasetv1alpha1.DatasetReference{
+		{Namespace: "cv-team", Name: "images"},
+		{Namespace: "ml-team", Name: "train"},
+	}, sourceDs.Status.Consumers)
+	assert.EqualValues(t, 2, sourceDs.Status.ConsumerCount)
+}
+
+func TestDeleteReferencedDataset(t *testing.T) {
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "data-team", Name: "corpus"}
+
+	t.Run("blocked by consumers", func(t *testing.T) {
+		r := newTestReconciler(t, testDeletedSourceDataset(nil), testReferenceDataset("ml-team", "train", "train"))
+
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		ds := &datasetv1alpha1.Dataset{}
+		require.NoError(t, r.Get(ctx, key, ds))
+		assert.Equal(t, []string{datasetFinalizer}, ds.Finalizers)
+		assert.EqualValues(t, 1, ds.Status.ConsumerCount)
+		cond := meta.FindStatusCondition(ds.Status.Conditions, "Consumers")
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Contains(t, cond.Message, "ml-team/train")
+	})
+
+	t.Run("no consumers", func(t *testing.T) {
+		r := newTestReconciler(t, testDeletedSourceDataset(nil), testReferenceDataset("ml-team", "pending", ""))
+
+		// 移除 finalizer 后 dataset 已经被删除，忽略随后更新 status 的错误
+		_, _ = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, key, &datasetv1alpha1.Dataset{})))
+	})
+
+	t.Run("force delete", func(t *testing.T) {
+		r := newTestReconciler(t,
+			testDeletedSourceDataset(map[string]string{constants.DatasetForceDeleteAnnotation: "true"}),
+			testReferenceDataset("ml-team", "train", "train"),
+		)
+
+		// 移除 finalizer 后 dataset 已经被删除，忽略随后更新 status 的错误
+		_, _ = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, key, &datasetv1alpha1.Dataset{})))
+	})
+}
@@ -0,0 +1,288 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+const (
+	// referenceOptionMode 为 REFERENCE dataset 使用 source dataset 数据的方式
+	referenceOptionMode = "mode"
+	// referenceModeVolume 绑定克隆的 source dataset 的 pv，只读
+	referenceModeVolume = "volume"
+	// referenceModeCopy 按 volumeClaimTemplate 创建新的 pvc 并复制 source dataset 的数据
+	referenceModeCopy = "copy"
+
+	referenceOptionCopyMethod = "copyMethod"
+	// copyMethodJob 在 source dataset 每次同步完成后通过 job 复制
+	copyMethodJob = "job"
+	// copyMethodCSI 创建 pvc 时通过 dataSource 由 CSI 克隆 source pvc，只支持同一 namespace
+	copyMethodCSI = "csi"
+
+	condTypeCopy = "Copy"
+
+	copySourceMountPath = "/baize/dataset/source"
+)
+
+func referenceMode(ds *datasetv1alpha1.Dataset) string {
+	return lo.CoalesceOrEmpty(ds.Spec.Source.Options[referenceOptionMode], referenceModeVolume)
+}
+
+func copyMethod(ds *datasetv1alpha1.Dataset) string {
+	return lo.CoalesceOrEmpty(ds.Spec.Source.Options[referenceOptionCopyMethod], copyMethodJob)
+}
+
+// validateReferenceMode 校验 REFERENCE dataset 的 mode 和 copyMethod
+func validateReferenceMode(ds *datasetv1alpha1.Dataset, source *datasetv1alpha1.Dataset) error {
+	switch referenceMode(ds) {
+	case referenceModeVolume:
+		return nil
+	case referenceModeCopy:
+	default:
+		return fmt.Errorf("invalid option %s=%s, must be one of %s or %s", referenceOptionMode, referenceMode(ds), referenceModeVolume, referenceModeCopy)
+	}
+	switch copyMethod(ds) {
+	case copyMethodJob:
+		return nil
+	case copyMethodCSI:
+		if source.Namespace != ds.Namespace {
+			return fmt.Errorf("copy method %s requires the source dataset in the same namespace", copyMethodCSI)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid option %s=%s, must be one of %s or %s", referenceOptionCopyMethod, copyMethod(ds), copyMethodJob, copyMethodCSI)
+	}
+}
+
+func copyJobNamePrefix(dsName string) string {
+	return fmt.Sprintf("dataset-%s-copy-", dsName)
+}
+
+func genCopyJobName(dsName string, round, sourceRound int32) string {
+	return fmt.Sprintf("%s%d-%d", copyJobNamePrefix(dsName), round, sourceRound)
+}
+
+// stagingPVCName 返回 copy job 跨 namespace 读取 source dataset 时使用的 pvc，绑定克隆的 source pv
+func stagingPVCName(ds *datasetv1alpha1.Dataset) string {
+	return fmt.Sprintf("dataset-%s-source", ds.Name)
+}
+
+// reconcileCopy 在 source dataset 同步完成后把数据复制到 copy 模式的 REFERENCE dataset 的 pvc，
+// 每个 dataSyncRound 和 source round 的组合只复制一次，失败后递增 dataSyncRound 重试，
+// source dataset 同步期间不复制，超过并发 job 数量限制时排队
+func (r *DatasetReconciler) reconcileCopy(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
+	if ds.Spec.Source.Type != datasetv1alpha1.DatasetTypeReference || referenceMode(ds) != referenceModeCopy || ds.Status.PVCName == "" {
+		return nil
+	}
+	srcDs, err := r.getSourceDataset(ctx, ds)
+	if err != nil {
+		return err
+	}
+
+	if copyMethod(ds) == copyMethodCSI {
+		if ds.Status.CopiedSourceRound != 0 {
+			return nil
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: ds.Status.PVCName}, pvc); err != nil {
+			return err
+		}
+		if pvc.Status.Phase == corev1.ClaimBound {
+			ds.Status.CopiedSourceRound = srcDs.Status.LastSucceedRound
+			ds.Status.LastSucceedRound = ds.Spec.DataSyncRound
+		}
+		return nil
+	}
+
+	sourceRound := srcDs.Status.LastSucceedRound
+	upToDate := ds.Status.CopiedSourceRound == sourceRound && ds.Status.LastSucceedRound == ds.Spec.DataSyncRound
+	if upToDate && !ds.Status.InProcessing {
+		ds.Status.Queued = false
+		return nil
+	}
+
+	job := &batchv1.Job{}
+	err = r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: genCopyJobName(ds.Name, ds.Spec.DataSyncRound, sourceRound)}, job)
+	if k8serrors.IsNotFound(err) {
+		ds.Status.InProcessing = false
+		// 等待 source dataset 同步完成，避免复制同步了一部分的数据
+		if upToDate || sourceRound == 0 || srcDs.Status.Phase != datasetv1alpha1.DatasetStatusPhaseReady || srcDs.Status.InProcessing {
+			ds.Status.Queued = false
+			return nil
+		}
+		// copy job 与同步 job 一样受并发数量的限制
+		reason, err := r.queueReason(ctx, ds)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			datasetLogger(ds).Infof("copy of round %d of source dataset %s/%s is queued: %s", sourceRound, srcDs.Namespace, srcDs.Name, reason)
+			ds.Status.Queued = true
+			return nil
+		}
+		ds.Status.Queued = false
+		sourceClaim := srcDs.Status.PVCName
+		if srcDs.Namespace != ds.Namespace {
+			sourceClaim, err = r.ensureStagingPVC(ctx, ds, srcDs)
+			if err != nil {
+				return err
+			}
+		}
+		job, err = newCopyJob(ds, sourceClaim, srcDs.Status.SubPath, sourceRound)
+		if err != nil {
+			return err
+		}
+		if err = r.Create(ctx, job); err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		datasetLogger(ds).Infof("copying round %d of source dataset %s/%s", sourceRound, srcDs.Namespace, srcDs.Name)
+		ds.Status.InProcessing = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	ds.Status.InProcessing = !isJobFinished(job)
+	if ds.Status.InProcessing {
+		// source dataset 开始重新同步时停止复制，同步完成后再复制新的 round
+		if srcDs.Status.InProcessing {
+			if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+			datasetLogger(ds).Infof("stopped copy job %s, source dataset %s/%s is syncing", job.Name, srcDs.Namespace, srcDs.Name)
+			ds.Status.InProcessing = false
+		}
+		return nil
+	}
+	if job.Status.Succeeded > 0 {
+		ds.Status.CopiedSourceRound = sourceRound
+		ds.Status.LastSucceedRound = ds.Spec.DataSyncRound
+		meta.RemoveStatusCondition(&ds.Status.Conditions, condTypeCopy)
+		return nil
+	}
+	meta.SetStatusCondition(&ds.Status.Conditions, metav1.Condition{
+		Type:    condTypeCopy,
+		Status:  metav1.ConditionFalse,
+		Reason:  "JobFailed",
+		Message: fmt.Sprintf("copy job %s failed, increase dataSyncRound to retry", job.Name),
+	})
+
+	return nil
+}
+
+// ensureStagingPVC 在 REFERENCE dataset 的 namespace 中创建绑定克隆的 source pv 的只读 pvc，供 copy job 挂载
+func (r *DatasetReconciler) ensureStagingPVC(ctx context.Context, ds *datasetv1alpha1.Dataset, srcDs *datasetv1alpha1.Dataset) (string, error) {
+	name := stagingPVCName(ds)
+	err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: name}, &corev1.PersistentVolumeClaim{})
+	if err == nil || !k8serrors.IsNotFound(err) {
+		return name, err
+	}
+
+	spec, err := r.cloneSourceVolume(ctx, ds, srcDs)
+	if err != nil {
+		return "", err
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ds.Namespace,
+			Labels: map[string]string{
+				constants.DatasetNameLabel: ds.Name,
+			},
+			OwnerReferences: datasetOwnerRef(ds),
+		},
+		Spec: *spec,
+	}
+	if err := r.Create(ctx, pvc); err != nil && !k8serrors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return name, nil
+}
+
+// newCopyJob 基于 job 模板构造 copy job，只读挂载 source pvc，通过 data-loader 镜像中的 rclone 把其中 sourceSubPath 下的数据
+// 镜像到 pvc 中 mountOptions.path 下
+func newCopyJob(ds *datasetv1alpha1.Dataset, sourceClaim, sourceSubPath string, sourceRound int32) (*batchv1.Job, error) {
+	jobSpec := batchv1.JobSpec{}
+	err := yaml.Unmarshal([]byte(config.GetDatasetJobSpecYaml()), &jobSpec)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal dataset job spec yaml failed: %w", err)
+	}
+	if len(jobSpec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("dataset job spec yaml has no container")
+	}
+
+	podSpec := &jobSpec.Template.Spec
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+	podSpec.Volumes = append(podSpec.Volumes,
+		corev1.Volume{
+			Name: "dataset-pvc",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: ds.Status.PVCName,
+				},
+			},
+		},
+		corev1.Volume{
+			Name: "dataset-source",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: sourceClaim,
+					ReadOnly:  true,
+				},
+			},
+		},
+	)
+
+	// 镜像 source 目录，删除 source dataset 中已经不存在的文件
+	script := `mkdir -p "$2" && rclone sync --links --create-empty-src-dirs "$1" "$2"`
+	args := []string{"copy", path.Join(copySourceMountPath, sourceSubPath), path.Join(pvcMountPath, ds.Spec.MountOptions.Path)}
+	if ds.Spec.MountOptions.UID != 0 || ds.Spec.MountOptions.GID != 0 {
+		script += ` && chown -R "$3" "$2"`
+		args = append(args, fmt.Sprintf("%d:%d", ds.Spec.MountOptions.UID, ds.Spec.MountOptions.GID))
+	}
+
+	container := *podSpec.Containers[0].DeepCopy()
+	container.Name = constants.DatasetJobContainerName
+	container.Command = []string{"/bin/sh", "-c", script}
+	container.Args = args
+	container.VolumeMounts = append(container.VolumeMounts,
+		corev1.VolumeMount{
+			Name:      "dataset-pvc",
+			MountPath: pvcMountPath,
+		},
+		corev1.VolumeMount{
+			Name:      "dataset-source",
+			MountPath: copySourceMountPath,
+			ReadOnly:  true,
+		},
+	)
+	podSpec.Containers = []corev1.Container{container}
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      genCopyJobName(ds.Name, ds.Spec.DataSyncRound, sourceRound),
+			Namespace: ds.Namespace,
+			Labels: lo.Assign(ds.Labels, map[string]string{
+				constants.DatasetNameLabel: ds.Name,
+			}),
+			Annotations:     ds.Annotations,
+			OwnerReferences: datasetOwnerRef(ds),
+		},
+		Spec: jobSpec,
+	}, nil
+}
@@ -0,0 +1,144 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func testCopyDatasets() (*datasetv1alpha1.Dataset, *datasetv1alpha1.Dataset) {
+	sourceDs := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "corpus", Namespace: "ml-team"},
+		Spec:       datasetv1alpha1.DatasetSpec{Share: true},
+		Status: datasetv1alpha1.DatasetStatus{
+			Phase:            datasetv1alpha1.DatasetStatusPhaseReady,
+			PVCName:          "corpus",
+			SubPath:          "data",
+			LastSucceedRound: 2,
+		},
+	}
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "ml-team"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			DataSyncRound: 1,
+			Source: datasetv1alpha1.DatasetSource{
+				Type:    datasetv1alpha1.DatasetTypeReference,
+				URI:     "dataset://ml-team/corpus",
+				Options: map[string]string{referenceOptionMode: referenceModeCopy},
+			},
+		},
+		Status: datasetv1alpha1.DatasetStatus{PVCName: "train"},
+	}
+	return sourceDs, ds
+}
+
+func TestNewCopyJob(t *testing.T) {
+	_, ds := testCopyDatasets()
+	ds.Spec.MountOptions.Path = "/models"
+	ds.Spec.MountOptions.UID = 1000
+	ds.Spec.MountOptions.GID = 1000
+
+	job, err := newCopyJob(ds, "dataset-train-source", "data", 2)
+	require.NoError(t, err)
+	assert.Equal(t, genCopyJobName("train", 1, 2), job.Name)
+	podSpec := job.Spec.Template.Spec
+	container := podSpec.Containers[0]
+	assert.Contains(t, container.Command[2], "rclone sync")
+	assert.Contains(t, container.Command[2], `chown -R "$3" "$2"`)
+	assert.Equal(t, []string{"copy", copySourceMountPath + "/data", pvcMountPath + "/models", "1000:1000"}, container.Args)
+	source, ok := lo.Find(podSpec.Volumes, func(volume corev1.Volume) bool {
+		return volume.Name == "dataset-source"
+	})
+	require.True(t, ok)
+	assert.Equal(t, "dataset-train-source", source.PersistentVolumeClaim.ClaimName)
+	assert.True(t, source.PersistentVolumeClaim.ReadOnly)
+}
+
+func TestReconcileCopy(t *testing.T) {
+	ctx := context.Background()
+	jobKey := client.ObjectKey{Namespace: "ml-team", Name: genCopyJobName("train", 1, 2)}
+
+	t.Run("copy finished round of source", func(t *testing.T) {
+		sourceDs, ds := testCopyDatasets()
+		r := newTestReconciler(t, sourceDs, ds)
+
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.True(t, ds.Status.InProcessing)
+		assert.False(t, ds.Status.Queued)
+		job := &batchv1.Job{}
+		require.NoError(t, r.Get(ctx, jobKey, job))
+
+		// the copy is recorded once the job succeeded
+		job.Status.Succeeded = 1
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+		require.NoError(t, r.Status().Update(ctx, job))
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.False(t, ds.Status.InProcessing)
+		assert.Equal(t, int32(2), ds.Status.CopiedSourceRound)
+		assert.Equal(t, int32(1), ds.Status.LastSucceedRound)
+
+		// nothing to copy until the source syncs another round
+		require.NoError(t, r.Delete(ctx, job))
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.False(t, ds.Status.InProcessing)
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{})))
+	})
+
+	t.Run("wait for source to sync", func(t *testing.T) {
+		sourceDs, ds := testCopyDatasets()
+		sourceDs.Status.InProcessing = true
+		r := newTestReconciler(t, sourceDs, ds)
+
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.False(t, ds.Status.InProcessing)
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{})))
+	})
+
+	t.Run("stop copy when source starts syncing", func(t *testing.T) {
+		sourceDs, ds := testCopyDatasets()
+		r := newTestReconciler(t, sourceDs, ds)
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		require.True(t, ds.Status.InProcessing)
+
+		sourceDs.Status.InProcessing = true
+		require.NoError(t, r.Status().Update(ctx, sourceDs))
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.False(t, ds.Status.InProcessing)
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{})))
+	})
+
+	t.Run("queued over limit of namespace", func(t *testing.T) {
+		setTestConfig(t, "max_concurrent_jobs_per_namespace: 1\n")
+		sourceDs, ds := testCopyDatasets()
+		running := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      genJobName("other", 1),
+				Namespace: "ml-team",
+				Labels:    map[string]string{constants.DatasetNameLabel: "other"},
+			},
+		}
+		r := newTestReconciler(t, sourceDs, ds, running)
+
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.True(t, ds.Status.Queued)
+		assert.False(t, ds.Status.InProcessing)
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{})))
+
+		require.NoError(t, r.Delete(ctx, running))
+		require.NoError(t, r.reconcileCopy(ctx, ds))
+		assert.False(t, ds.Status.Queued)
+		assert.True(t, ds.Status.InProcessing)
+		require.NoError(t, r.Get(ctx, jobKey, &batchv1.Job{}))
+	})
+}
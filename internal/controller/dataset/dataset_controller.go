@@ -20,24 +20,23 @@ import (
 	"context"
 	"fmt"
 	"github.com/BaizeAI/dataset/config"
-	"net/url"
 	"reflect"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"k8s.io/apimachinery/pkg/labels"
-
 	"github.com/BaizeAI/dataset/pkg/kubeutils"
 
 	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
-	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"sigs.k8s.io/yaml"
 
 	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -45,7 +44,10 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
 )
@@ -61,6 +63,25 @@ const (
 type DatasetReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// APIReader 不经过缓存直接读取 apiserver，为空时使用 Client
+	APIReader client.Reader
+}
+
+func (r *DatasetReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// datasetLogger 返回带有 dataset、namespace、round 和 type 字段的 controller logger
+func datasetLogger(ds *datasetv1alpha1.Dataset) *logrus.Entry {
+	return log.Component("controller").WithFields(logrus.Fields{
+		log.FieldDataset:   ds.Name,
+		log.FieldNamespace: ds.Namespace,
+		log.FieldRound:     ds.Spec.DataSyncRound,
+		log.FieldType:      ds.Spec.Source.Type,
+	})
 }
 
 type reconciler struct {
@@ -71,6 +92,8 @@ type reconciler struct {
 //+kubebuilder:rbac:groups=dataset.baizeai.io,resources=datasets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=dataset.baizeai.io,resources=datasets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=dataset.baizeai.io,resources=datasets/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
 
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.16.3/pkg/reconcile
@@ -78,35 +101,44 @@ func (r *DatasetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	ds := &datasetv1alpha1.Dataset{}
 	err := r.Get(ctx, req.NamespacedName, ds)
 	if err != nil {
-		log.Errorf("error fetch dataset for %v: error: %v", req, err)
+		log.Component("controller").WithFields(logrus.Fields{
+			log.FieldDataset:   req.Name,
+			log.FieldNamespace: req.Namespace,
+		}).Errorf("error fetch dataset: %v", err)
 		return ctrl.Result{}, nil
 	}
 
 	status := ds.Status.DeepCopy()
+	ds.Status.SourcesSummary = sourcesSummary(ds)
 	var reconcilers []reconciler
 	if kubeutils.IsDeleted(ds) {
 		reconcilers = []reconciler{
 			// {typ: "Job", rec: r.reconcileJob},  // 同样可以加上清理 job 的逻辑
+			{typ: "Consumers", rec: r.reconcileConsumers},
 			{typ: "PVC", rec: r.reconcilePVC},
 			{typ: "", rec: r.reconcileFinalizer},
 		}
 	} else {
 		reconcilers = []reconciler{
+			// 不受其他 reconciler 出错的影响，总是更新 consumers
+			{typ: "", rec: r.reconcileConsumers},
 			{typ: condTypeConfig, rec: r.validate},
 			{typ: "", rec: r.reconcileFinalizer},
 			{typ: "PVC", rec: r.reconcilePVC},
 			{typ: "ConfigMap", rec: r.reconcileConfigMap},
 			{typ: "Job", rec: r.reconcileJob},
 			{typ: "JobStatus", rec: r.reconcileJobStatus},
+			{typ: "", rec: r.reconcileCopy},
+			{typ: "", rec: r.reconcilePlan},
 		}
 	}
 
 	for _, rr := range reconcilers {
-		log.Debugf("start reconciling dataset for %s/%s: %+v...", ds.Namespace, ds.Name, rr)
+		datasetLogger(ds).Debugf("start reconciling dataset: %+v...", rr)
 		err := rr.rec(ctx, ds)
 		ds.Status.Conditions = kubeutils.SetCondition(ds.Status.Conditions, rr.typ, err)
 		if err != nil {
-			log.Errorf("error reconciling dataset for %s/%s: %v", ds.Namespace, ds.Name, err)
+			datasetLogger(ds).Errorf("error reconciling dataset: %v", err)
 			break
 		}
 	}
@@ -123,11 +155,28 @@ func (r *DatasetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if !reflect.DeepEqual(ds.Status, *status) {
 		err := r.Status().Update(ctx, ds)
 		if err != nil {
-			log.Errorf("error update status for %s/%s: %v", ds.Namespace, ds.Name, err)
+			datasetLogger(ds).Errorf("error update status: %v", err)
 			return res30sec, err
 		}
 	}
 
+	// controller 不 watch job，等待 plan job 完成
+	if isPlanInProgress(ds) {
+		return res5sec, nil
+	}
+	// 等待 finalizer 被移除，例如 REFERENCE dataset 的 pv 被释放或者 consumers 被删除
+	if kubeutils.IsDeleted(ds) && len(ds.Finalizers) > 0 {
+		return res30sec, nil
+	}
+	// 等待宽限期结束后删除被撤销访问权限的 pv 和 pvc
+	if d := revokeRequeueAfter(ds); d > 0 {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	// 排队的 copy job 不影响已经 READY 的 REFERENCE dataset 的 phase，同样需要重试
+	if ds.Status.Queued {
+		return res30sec, nil
+	}
+
 	switch ds.Status.Phase {
 	case datasetv1alpha1.DatasetStatusPhaseReady, datasetv1alpha1.DatasetStatusPhaseFailed:
 		return resOk, nil
@@ -138,18 +187,12 @@ func (r *DatasetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 }
 
+// supportPreload 表示该类型需要通过 data-loader job 预加载数据，包括注册的 loader 以及配置的外部 loader
 func supportPreload(ds *datasetv1alpha1.Dataset) bool {
-	switch ds.Spec.Source.Type {
-	case datasetv1alpha1.DatasetTypeGit,
-		datasetv1alpha1.DatasetTypeS3,
-		datasetv1alpha1.DatasetTypeHTTP,
-		datasetv1alpha1.DatasetTypeConda,
-		datasetv1alpha1.DatasetTypeHuggingFace,
-		datasetv1alpha1.DatasetTypeModelScope:
+	if isMultiSource(ds) {
 		return true
-	default:
-		return false
 	}
+	return supportPreloadType(ds.Spec.Source.Type)
 }
 
 func genJobName(dsName string, round int32) string {
@@ -185,71 +228,91 @@ func (r *DatasetReconciler) reconcilePVC(ctx context.Context, ds *datasetv1alpha
 	forceStorageClass := ""
 	var spec *corev1.PersistentVolumeClaimSpec
 	volumeNameOverride := ""
+	// 需要同步到已存在 PVC 上的 annotation，值为空时删除
+	var pvcAnnotations map[string]string
+	var dataSource *corev1.TypedLocalObjectReference
 
 	switch ds.Spec.Source.Type {
 	case datasetv1alpha1.DatasetTypeReference:
 		if kubeutils.IsDeleted(ds) {
-			// OwnerReference 会将其自动回收，这里不做额外 Delete
-			return nil
+			// 集群级别的 pv 不会随 namespace 级别的 owner 回收，需要显式删除
+			return r.deleteReferenceVolumes(ctx, ds, pvcName)
 		}
 		srcDs, err := r.getSourceDataset(ctx, ds)
 		if err != nil {
 			return err
 		}
+		mirrorSourceStatus(ds, srcDs)
+		// 还没有创建 pvc 时等待 source dataset READY，避免使用同步了一部分的数据
+		if ds.Status.PVCName == "" && waitSourceReady(ds) && srcDs.Status.Phase != datasetv1alpha1.DatasetStatusPhaseReady {
+			datasetLogger(ds).Debugf("waiting for source dataset %s/%s to be ready", srcDs.Namespace, srcDs.Name)
+			return nil
+		}
 		if srcDs.Status.PVCName == "" {
 			return fmt.Errorf("source dataset %s/%s has no pvc", srcDs.Namespace, srcDs.Name)
 		}
-		// 先获取 source dataset 的 pvc
-		pvc := &corev1.PersistentVolumeClaim{}
-		err = r.Get(ctx, client.ObjectKey{Namespace: srcDs.Namespace, Name: srcDs.Status.PVCName}, pvc)
+
+		serviceAccounts, err := r.referenceServiceAccounts(ctx, ds, srcDs)
 		if err != nil {
-			return fmt.Errorf("get pvc %s/%s for source dataset %s/%s error: %v",
-				srcDs.Namespace, srcDs.Status.PVCName,
-				srcDs.Namespace, srcDs.Name, err)
+			return err
 		}
-		if pvc.Spec.VolumeName == "" {
-			return fmt.Errorf("pvc %s/%s has no volume", pvc.Namespace, pvc.Name)
+		pvcAnnotations = map[string]string{constants.DatasetServiceAccountsAnnotation: serviceAccounts}
+
+		if referenceMode(ds) == referenceModeCopy {
+			// 按 volumeClaimTemplate 创建新的 pvc，由 reconcileCopy 复制数据，或者由 CSI 从 source pvc 克隆
+			ds.Status.ReadOnly = false
+			ds.Status.SubPath = ""
+			if copyMethod(ds) == copyMethodCSI {
+				// CSI 克隆整个 source pvc
+				ds.Status.SubPath = srcDs.Status.SubPath
+				dataSource = &corev1.TypedLocalObjectReference{
+					Kind: "PersistentVolumeClaim",
+					Name: srcDs.Status.PVCName,
+				}
+			}
+			break
 		}
-		// 再获取 source dataset pvc 对应的 pv
-		pv := &corev1.PersistentVolume{}
-		err = r.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, pv)
+
+		spec, err = r.cloneSourceVolume(ctx, ds, srcDs)
 		if err != nil {
-			return fmt.Errorf("get pv %s for source dataset %s/%s error: %v",
-				pvc.Spec.VolumeName, srcDs.Namespace, srcDs.Name, err)
-		}
-		// 克隆一个新的 pv 给当前 ds
-		newPv := pv.DeepCopy()
-		newPv.OwnerReferences = datasetOwnerRef(ds)
-		newPv.Name = fmt.Sprintf("dataset-%s-pvc-%s", ds.Namespace, ds.Name)
-		if newPv.Labels == nil {
-			newPv.Labels = make(map[string]string)
-		}
-		newPv.Labels[constants.DatasetNameLabel] = ds.Name
-		newPv.ResourceVersion = ""
-		newPv.Spec.ClaimRef = nil
-		// 保留策略改为 Retain
-		newPv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
-		if err := r.Get(ctx, client.ObjectKey{Name: newPv.Name}, pv); err != nil {
-			if !k8serrors.IsNotFound(err) {
-				return err
-			}
-			if err := r.Create(ctx, newPv); err != nil {
-				return err
-			}
+			return err
 		}
-		spec = pvc.Spec.DeepCopy()
-		spec.VolumeName = newPv.Name
 
 		// 标记当前 dataset 状态
 		ds.Status.LastSucceedRound = ds.Spec.DataSyncRound
 		ds.Status.ReadOnly = true
+		ds.Status.SubPath = srcDs.Status.SubPath
 
 	case datasetv1alpha1.DatasetTypePVC:
-		u, err := url.Parse(ds.Spec.Source.URI)
+		if crossNamespacePVC(ds) {
+			if kubeutils.IsDeleted(ds) {
+				return r.deleteReferenceVolumes(ctx, ds, pvcName)
+			}
+			// 与 REFERENCE dataset 一样绑定克隆的 pv，只读
+			srcDs, err := r.checkPVCAccess(ctx, ds)
+			if err != nil {
+				return err
+			}
+			serviceAccounts, err := r.referenceServiceAccounts(ctx, ds, srcDs)
+			if err != nil {
+				return err
+			}
+			pvcAnnotations = map[string]string{constants.DatasetServiceAccountsAnnotation: serviceAccounts}
+			spec, err = r.cloneSourceVolume(ctx, ds, srcDs)
+			if err != nil {
+				return err
+			}
+			_, _, subPath, _ := pvcSource(ds)
+			ds.Status.ReadOnly = true
+			ds.Status.SubPath = subPath
+			break
+		}
+
+		_, name, subPath, err := pvcSource(ds)
 		if err != nil {
 			return err
 		}
-		pvcName = u.Host
+		pvcName = name
 
 		// 如果已经删除，尝试把 PVC 上的 label 清空
 		if kubeutils.IsDeleted(ds) {
@@ -259,8 +322,7 @@ func (r *DatasetReconciler) reconcilePVC(ctx context.Context, ds *datasetv1alpha
 				if dsName, exists := pvc.Labels[constants.DatasetNameLabel]; exists && dsName == ds.Name {
 					delete(pvc.Labels, constants.DatasetNameLabel)
 					if updateErr := r.Update(ctx, pvc); updateErr != nil {
-						log.Errorf("update pvc %s/%s for deletion %s error: %v",
-							ds.Namespace, pvcName, ds.Name, updateErr)
+						datasetLogger(ds).Errorf("update pvc %s for deletion error: %v", pvcName, updateErr)
 					}
 				}
 			}
@@ -273,22 +335,16 @@ func (r *DatasetReconciler) reconcilePVC(ctx context.Context, ds *datasetv1alpha
 		if err != nil {
 			return err
 		}
-		if dsName, exists := pvc.Labels[constants.DatasetNameLabel]; exists && dsName != ds.Name {
-			return fmt.Errorf("pvc %s is not belong to dataset %s/%s", pvcName, ds.Namespace, ds.Name)
-		} else if !exists {
-			if pvc.Labels == nil {
-				pvc.Labels = make(map[string]string)
-			}
-			pvc.Labels[constants.DatasetNameLabel] = ds.Name
-			if err = r.Update(ctx, pvc); err != nil {
-				return err
-			}
+		if err = r.bindPVC(ctx, ds, pvc); err != nil {
+			return err
 		}
 		ds.Status.PVCName = pvcName
+		ds.Status.SubPath = subPath
+		ds.Status.ReadOnly = pvcReadOnly(ds)
 		return nil
 
 	case datasetv1alpha1.DatasetTypeNFS:
-		pvName := fmt.Sprintf("dataset-%s-pvc-%s", ds.Namespace, pvcName)
+		pvName := nfsPVName(ds, pvcName)
 
 		if kubeutils.IsDeleted(ds) {
 			// 删除对应的 pv
@@ -299,8 +355,7 @@ func (r *DatasetReconciler) reconcilePVC(ctx context.Context, ds *datasetv1alpha
 			}
 			if err := r.Delete(ctx, pv); err != nil && !k8serrors.IsNotFound(err) {
 				if forceDelete(ds) {
-					log.Errorf("delete pv %s for %s/%s error: %v, but force delete",
-						pvName, ds.Namespace, ds.Name, err)
+					datasetLogger(ds).Errorf("delete pv %s error: %v, but force delete", pvName, err)
 					return nil
 				}
 				return err
@@ -308,30 +363,8 @@ func (r *DatasetReconciler) reconcilePVC(ctx context.Context, ds *datasetv1alpha
 			return nil
 		}
 
-		// NFS 需要先创建一个 PV
-		var pvTemp corev1.PersistentVolume
-		err := yaml.Unmarshal([]byte(`
-apiVersion: v1
-kind: PersistentVolume
-metadata:
-  annotations:
-    pv.kubernetes.io/provisioned-by: nfs.csi.k8s.io
-spec:
-  capacity:
-    storage: 100Ti
-  accessModes:
-    - ReadWriteMany
-  persistentVolumeReclaimPolicy: Retain
-  storageClassName: nfs-csi
-  mountOptions:
-    - nfsvers=4.1
-  csi:
-    driver: nfs.csi.k8s.io
-`), &pvTemp)
-		if err != nil {
-			return err
-		}
-		u, err := url.Parse(ds.Spec.Source.URI)
+		// NFS 需要先按配置的模板创建一个 PV
+		pvTemp, err := newNFSPV(ds, pvName, pvcName)
 		if err != nil {
 			return err
 		}
@@ -346,41 +379,15 @@ spec:
 				return fmt.Errorf("pv %s is not belong to dataset %s/%s", pvName, ds.Namespace, ds.Name)
 			}
 		} else {
-			// 需要新建
-			pvTemp.OwnerReferences = datasetOwnerRef(ds)
-			if pvTemp.Labels == nil {
-				pvTemp.Labels = make(map[string]string)
-			}
-			pvTemp.Labels[constants.DatasetNameLabel] = ds.Name
-			pvTemp.Name = pvName
-
-			if pvTemp.Spec.CSI == nil {
-				pvTemp.Spec.CSI = &corev1.CSIPersistentVolumeSource{}
-			}
-			if pvTemp.Spec.CSI.VolumeAttributes == nil {
-				pvTemp.Spec.CSI.VolumeAttributes = make(map[string]string)
-			}
-			pvTemp.Spec.CSI.VolumeAttributes["server"] = u.Host
-			pvTemp.Spec.CSI.VolumeAttributes["share"] = "/"
-			pvTemp.Spec.CSI.VolumeAttributes["subdir"] = u.Path
-			pvTemp.Spec.CSI.VolumeAttributes["onDelete"] = "retain"
-			pvTemp.Spec.CSI.VolumeAttributes["csi.storage.k8s.io/pv/name"] = pvName
-			pvTemp.Spec.CSI.VolumeAttributes["csi.storage.k8s.io/pvc/name"] = pvcName
-			pvTemp.Spec.CSI.VolumeAttributes["csi.storage.k8s.io/pvc/namespace"] = ds.Namespace
-
-			// 如果 mountPermissions 没配置，则默认用 ds.Spec.MountOptions.Mode
-			if pvTemp.Spec.CSI.VolumeAttributes["mountPermissions"] == "" {
-				pvTemp.Spec.CSI.VolumeAttributes["mountPermissions"] = ds.Spec.MountOptions.Mode
-			}
-			pvTemp.Spec.CSI.VolumeHandle = fmt.Sprintf("%s#%s#%s#", u.Host, u.Path, pvName)
-
-			if err := r.Create(ctx, &pvTemp); err != nil {
+			// 需要新建，已经存在的 pv 不会随 options 更新
+			if err := r.Create(ctx, pvTemp); err != nil {
 				return err
 			}
 		}
 		// 标记 ds.Status.LastSucceedRound = ds.Spec.DataSyncRound
 		ds.Status.LastSucceedRound = ds.Spec.DataSyncRound
-		volumeNameOverride = pvTemp.Name
+		ds.Status.ReadOnly = nfsReadOnly(ds)
+		volumeNameOverride = pvName
 	default:
 		// 其他类型先不做特殊逻辑
 	}
@@ -395,8 +402,7 @@ spec:
 		}
 		if err := r.Delete(ctx, pvc); err != nil && !k8serrors.IsNotFound(err) {
 			if forceDelete(ds) {
-				log.Errorf("delete pvc %s/%s for dataset %s error: %v, but force delete",
-					ds.Namespace, pvcName, ds.Name, err)
+				datasetLogger(ds).Errorf("delete pvc %s error: %v, but force delete", pvcName, err)
 				return nil
 			}
 			return err
@@ -428,8 +434,13 @@ spec:
 			spec.Resources.Requests = corev1.ResourceList{}
 		}
 		quantity := spec.Resources.Requests[corev1.ResourceStorage]
-		if quantity.IsZero() {
-			spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse("100Ti")
+		if quantity.IsZero() && k8serrors.IsNotFound(err) {
+			// 只在创建时决定大小，之后可能已经扩容
+			storage, err := r.initialPVCStorage(ctx, ds, spec)
+			if err != nil {
+				return err
+			}
+			spec.Resources.Requests[corev1.ResourceStorage] = storage
 		}
 		if forceStorageClass != "" {
 			// nfs 强制使用 nfs storageclass
@@ -439,6 +450,9 @@ spec:
 	if volumeNameOverride != "" {
 		spec.VolumeName = volumeNameOverride
 	}
+	if dataSource != nil {
+		spec.DataSource = dataSource
+	}
 
 	if k8serrors.IsNotFound(err) {
 		// 不存在就创建
@@ -449,7 +463,7 @@ spec:
 				Labels: lo.Assign(ds.Labels, map[string]string{
 					constants.DatasetNameLabel: ds.Name,
 				}),
-				Annotations:     ds.Annotations,
+				Annotations:     lo.OmitByValues(lo.Assign(ds.Annotations, pvcAnnotations), []string{""}),
 				OwnerReferences: datasetOwnerRef(ds),
 			},
 			Spec: *spec,
@@ -461,6 +475,23 @@ spec:
 		if pvc.Labels[constants.DatasetNameLabel] != ds.Name {
 			return fmt.Errorf("pvc %s already exists, but not belong to dataset %s", pvcName, ds.Name)
 		}
+		changed := false
+		for k, v := range pvcAnnotations {
+			if pvc.Annotations[k] == v {
+				continue
+			}
+			changed = true
+			if v == "" {
+				delete(pvc.Annotations, k)
+			} else {
+				pvc.Annotations = lo.Assign(pvc.Annotations, map[string]string{k: v})
+			}
+		}
+		if changed {
+			if err = r.Update(ctx, pvc); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -512,7 +543,7 @@ func (r *DatasetReconciler) reconcileJob(ctx context.Context, ds *datasetv1alpha
 			constants.DatasetNameLabel: ds.Name,
 		}); err != nil && !k8serrors.IsNotFound(err) {
 			if forceDelete(ds) {
-				log.Errorf("delete jobs for dataset %s/%s error: %v, but force delete", ds.Namespace, ds.Name, err)
+				datasetLogger(ds).Errorf("delete jobs error: %v, but force delete", err)
 				return nil
 			}
 			return err
@@ -520,8 +551,7 @@ func (r *DatasetReconciler) reconcileJob(ctx context.Context, ds *datasetv1alpha
 		for i := range jobList.Items {
 			if err := r.Delete(ctx, &jobList.Items[i]); err != nil && !k8serrors.IsNotFound(err) {
 				if forceDelete(ds) {
-					log.Errorf("delete job %s/%s for dataset %s/%s error: %v, but force delete",
-						jobList.Items[i].Namespace, jobList.Items[i].Name, ds.Namespace, ds.Name, err)
+					datasetLogger(ds).Errorf("delete job %s error: %v, but force delete", jobList.Items[i].Name, err)
 					return nil
 				}
 				return err
@@ -532,129 +562,50 @@ func (r *DatasetReconciler) reconcileJob(ctx context.Context, ds *datasetv1alpha
 
 	// 若 dataSyncRound > lastSucceedRound，则需要创建新的 job
 	if ds.Spec.DataSyncRound > ds.Status.LastSucceedRound {
-		ds.Status.InProcessing = true
-		ds.Status.InProcessingRound = ds.Spec.DataSyncRound
-		jobName := genJobName(ds.Name, ds.Status.InProcessingRound)
+		jobName := genJobName(ds.Name, ds.Spec.DataSyncRound)
 
-		jobSpec := batchv1.JobSpec{}
-		err := yaml.Unmarshal([]byte(config.GetDatasetJobSpecYaml()), &jobSpec)
-		if err != nil {
-			log.Errorf("unmarshal dataset job spec yaml failed: %v", err)
+		// 已经创建的 job 不受并发限制，否则超过限制时排队等待
+		err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: jobName}, &batchv1.Job{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
 		}
-
-		container := &jobSpec.Template.Spec.Containers[0]
-		container.Name = "dataset-loader"
-
-		// 预留资源请求
-		containerRequests := make(corev1.ResourceList)
-		containerLimits := make(corev1.ResourceList)
-
-		switch ds.Spec.Source.Type {
-		case datasetv1alpha1.DatasetTypeConda:
-			containerRequests[corev1.ResourceCPU] = resource.MustParse("2")
-			containerRequests[corev1.ResourceMemory] = resource.MustParse("2Gi")
-			containerLimits[corev1.ResourceCPU] = resource.MustParse("4")
-			containerLimits[corev1.ResourceMemory] = resource.MustParse("4Gi")
-
-		case datasetv1alpha1.DatasetTypeHuggingFace,
-			datasetv1alpha1.DatasetTypeModelScope:
-			containerRequests[corev1.ResourceCPU] = resource.MustParse("2")
-			containerRequests[corev1.ResourceMemory] = resource.MustParse("2Gi")
-			containerLimits[corev1.ResourceCPU] = resource.MustParse("4")
-			containerLimits[corev1.ResourceMemory] = resource.MustParse("8Gi")
-		}
-
-		// 如果有 GPU 需求
-		if gpuType, ok := ds.Spec.Source.Options["gpuType"]; ok {
-			switch gpuType {
-			case "nvidia-gpu":
-				containerRequests["nvidia.com/gpu"] = resource.MustParse("1")
-				containerLimits["nvidia.com/gpu"] = resource.MustParse("1")
-			case "nvidia-vgpu":
-				containerRequests["nvidia.com/vgpu"] = resource.MustParse("1")
-				containerRequests["nvidia.com/gpumem"] = resource.MustParse("500")
-				containerLimits["nvidia.com/vgpu"] = resource.MustParse("1")
-				containerLimits["nvidia.com/gpumem"] = resource.MustParse("500")
-			case "metax-gpu":
-				containerRequests["metax-tech.com/gpu"] = resource.MustParse("1")
-				containerLimits["metax-tech.com/gpu"] = resource.MustParse("1")
+		expansions := roundPVCExpansions(ds, ds.Spec.DataSyncRound)
+		if k8serrors.IsNotFound(err) && expansions > 0 {
+			// 等待扩容完成后再重试
+			resizing, err := r.isPVCResizing(ctx, ds)
+			if err != nil {
+				return err
+			}
+			if resizing {
+				datasetLogger(ds).Infof("waiting for pvc %s to be expanded", ds.Status.PVCName)
+				return nil
 			}
 		}
-
-		if len(containerRequests) > 0 {
-			container.Resources.Requests = containerRequests
-		}
-		if len(containerLimits) > 0 {
-			container.Resources.Limits = containerLimits
-		}
-
-		options := make(map[string]string)
-		for k, v := range ds.Spec.Source.Options {
-			options[k] = v
-		}
-
-		podSpec := &jobSpec.Template.Spec
-
-		// conda 类型需要将 ConfigMap mount 到容器
-		condaKeyItems := make([]corev1.KeyToPath, 0, 2)
-		condaPodVolumeName := "dataset-config-conda"
-
-		switch ds.Spec.Source.Type {
-		case datasetv1alpha1.DatasetTypeConda:
-			if yamlData, ok := options["condaEnvironmentYml"]; ok && strings.TrimSpace(yamlData) != "" {
-				delete(options, "condaEnvironmentYml")
-				condaKeyItems = append(condaKeyItems, corev1.KeyToPath{
-					Key:  constants.DatasetJobCondaCondaEnvironmentYAMLFilename,
-					Path: constants.DatasetJobCondaCondaEnvironmentYAMLFilename,
-				})
+		if k8serrors.IsNotFound(err) {
+			reason, err := r.queueReason(ctx, ds)
+			if err != nil {
+				return err
 			}
-			if txt, ok := options["pipRequirementsTxt"]; ok && strings.TrimSpace(txt) != "" {
-				delete(options, "pipRequirementsTxt")
-				condaKeyItems = append(condaKeyItems, corev1.KeyToPath{
-					Key:  constants.DatasetJobCondaPipRequirementsTxtFilename,
-					Path: constants.DatasetJobCondaPipRequirementsTxtFilename,
-				})
+			if reason != "" {
+				datasetLogger(ds).Infof("round %d is queued: %s", ds.Spec.DataSyncRound, reason)
+				ds.Status.Queued = true
+				return nil
 			}
 		}
+		ds.Status.Queued = false
 
-		if ds.Spec.Source.Type == datasetv1alpha1.DatasetTypeConda && len(condaKeyItems) > 0 {
-			podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
-				Name: condaPodVolumeName,
-				VolumeSource: corev1.VolumeSource{
-					ConfigMap: &corev1.ConfigMapVolumeSource{
-						LocalObjectReference: corev1.LocalObjectReference{
-							Name: datasetConfigMapName(ds),
-						},
-						Items: condaKeyItems,
-					},
-				},
-			})
-			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-				Name:      condaPodVolumeName,
-				MountPath: constants.DatasetJobCondaConfigDir,
-				ReadOnly:  true,
-			})
-		}
-
-		// 如果有 SecretRef
-		if ds.Spec.SecretRef != "" {
-			podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
-				Name: "dataset-secret",
-				VolumeSource: corev1.VolumeSource{
-					Secret: &corev1.SecretVolumeSource{
-						SecretName: ds.Spec.SecretRef,
-					},
-				},
-			})
-			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-				Name:      "dataset-secret",
-				MountPath: constants.DatasetJobSecretsMountPath,
-				ReadOnly:  true,
-			})
+		ds.Status.InProcessing = true
+		ds.Status.InProcessingRound = ds.Spec.DataSyncRound
+
+		jobSpec := batchv1.JobSpec{}
+		err = yaml.Unmarshal([]byte(config.GetDatasetJobSpecYaml()), &jobSpec)
+		if err != nil {
+			datasetLogger(ds).Errorf("unmarshal dataset job spec yaml failed: %v", err)
 		}
 
+		podSpec := &jobSpec.Template.Spec
+
 		// 绑定 PVC
-		pvcMountPath := "/baize/dataset/data"
 		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
 			Name: "dataset-pvc",
 			VolumeSource: corev1.VolumeSource{
@@ -663,40 +614,18 @@ func (r *DatasetReconciler) reconcileJob(ctx context.Context, ds *datasetv1alpha
 				},
 			},
 		})
-		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-			Name:      "dataset-pvc",
-			MountPath: pvcMountPath,
-		})
-
-		// 构造命令行参数
-		switch ds.Spec.Source.Type {
-		case datasetv1alpha1.DatasetTypeConda:
-			// 这里把 gpuType 拿掉，已经单独处理过
-			delete(options, "gpuType")
-		}
 
-		args := []string{
-			string(ds.Spec.Source.Type),
-			ds.Spec.Source.URI,
-		}
-		for k, v := range options {
-			if regexp.MustCompile(`\s`).MatchString(v) {
-				args = append(args, fmt.Sprintf("--options=%s=%q", k, v))
-			} else {
-				args = append(args, fmt.Sprintf("--options=%s=%s", k, v))
-			}
-		}
-		if ds.Spec.MountOptions.Path != "" {
-			args = append(args, fmt.Sprintf("--mount-path=%s", ds.Spec.MountOptions.Path))
-		}
-		if ds.Spec.MountOptions.Mode != "" {
-			args = append(args, fmt.Sprintf("--mount-mode=%s", ds.Spec.MountOptions.Mode))
+		// 每个 source 对应一个 data-loader 容器
+		baseContainer := jobSpec.Template.Spec.Containers[0]
+		containers := lo.Map(datasetSources(ds), func(source datasetv1alpha1.DatasetSourceItem, _ int) corev1.Container {
+			return newLoaderContainer(ds, baseContainer, source, podSpec)
+		})
+		if ds.Spec.SourcesPolicy == datasetv1alpha1.DatasetSourcesPolicySequential {
+			// init containers 按顺序执行，最后一个 source 作为主容器
+			podSpec.InitContainers = append(podSpec.InitContainers, containers[:len(containers)-1]...)
+			containers = containers[len(containers)-1:]
 		}
-		args = append(args, fmt.Sprintf("--mount-uid=%d", ds.Spec.MountOptions.UID))
-		args = append(args, fmt.Sprintf("--mount-gid=%d", ds.Spec.MountOptions.GID))
-		args = append(args, fmt.Sprintf("--mount-root=%s", pvcMountPath))
-
-		container.Args = args
+		podSpec.Containers = append(containers, podSpec.Containers[1:]...)
 
 		// 最终创建 Job
 		job := &batchv1.Job{
@@ -706,7 +635,10 @@ func (r *DatasetReconciler) reconcileJob(ctx context.Context, ds *datasetv1alpha
 				Labels: lo.Assign(ds.Labels, map[string]string{
 					constants.DatasetNameLabel: ds.Name,
 				}),
-				Annotations:     ds.Annotations,
+				Annotations: lo.Assign(ds.Annotations, map[string]string{
+					constants.DatasetSourceHostsAnnotation:   strings.Join(datasetSourceHosts(ds), ","),
+					constants.DatasetPVCExpansionsAnnotation: strconv.Itoa(int(expansions)),
+				}),
 				OwnerReferences: datasetOwnerRef(ds),
 			},
 			Spec: jobSpec,
@@ -721,7 +653,10 @@ func (r *DatasetReconciler) reconcileJob(ctx context.Context, ds *datasetv1alpha
 
 func (r *DatasetReconciler) reconcileJobStatus(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
 	if !supportPreload(ds) {
-		ds.Status.LastSyncTime = ds.CreationTimestamp
+		// REFERENCE dataset 的 lastSyncTime 与 source dataset 一致
+		if ds.Spec.Source.Type != datasetv1alpha1.DatasetTypeReference {
+			ds.Status.LastSyncTime = ds.CreationTimestamp
+		}
 		return nil
 	}
 	if !ds.Status.InProcessing {
@@ -742,6 +677,10 @@ func (r *DatasetReconciler) reconcileJobStatus(ctx context.Context, ds *datasetv
 	jobName := genJobName(ds.Name, ds.Status.InProcessingRound)
 	job := &batchv1.Job{}
 	if err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: jobName}, job); err != nil {
+		// 扩容 pvc 后等待重新创建 job
+		if k8serrors.IsNotFound(err) && roundPVCExpansions(ds, ds.Status.InProcessingRound) > 0 {
+			return nil
+		}
 		return err
 	}
 
@@ -759,6 +698,14 @@ func (r *DatasetReconciler) reconcileJobStatus(ctx context.Context, ds *datasetv
 	}
 	loader := &ds.Status.SyncRoundStatuses[index]
 
+	jobFailed := lo.ContainsBy(job.Status.Conditions, func(item batchv1.JobCondition) bool {
+		return item.Type == batchv1.JobFailed && item.Status == corev1.ConditionTrue
+	})
+	// 需要在清空 InProcessingRound 前更新
+	if err := r.reconcileSourcesStatus(ctx, ds, job, jobFailed); err != nil {
+		datasetLogger(ds).Warnf("get sources status of job %s error: %v", job.Name, err)
+	}
+
 	if job.Status.Succeeded > 0 {
 		loader.StartTime = lo.FromPtrOr(job.Status.StartTime, loader.StartTime)
 		loader.EndTime = lo.FromPtrOr(job.Status.CompletionTime, metav1.Time{Time: time.Now()})
@@ -767,12 +714,31 @@ func (r *DatasetReconciler) reconcileJobStatus(ctx context.Context, ds *datasetv
 		ds.Status.InProcessing = false
 		ds.Status.LastSucceedRound = ds.Status.InProcessingRound
 		ds.Status.InProcessingRound = 0
-	} else if lo.ContainsBy(job.Status.Conditions, func(item batchv1.JobCondition) bool {
-		return item.Type == batchv1.JobFailed && item.Status == corev1.ConditionTrue
-	}) {
-		ds.Status.InProcessing = false
-		ds.Status.InProcessingRound = 0
-		loader.Succeed = false
+
+		// 多个 source 的 revision 记录在 status.sources 中
+		if !isMultiSource(ds) {
+			result, err := r.getJobSyncResult(ctx, job, sourceContainerName(datasetSources(ds)[0]))
+			if err != nil {
+				datasetLogger(ds).Warnf("get sync result of job %s error: %v", job.Name, err)
+			} else if result != nil {
+				loader.Revision = result.Revision
+				ds.Status.Revision = result.Revision
+			}
+		}
+		if err := r.reconcileRoundSize(ctx, ds, job, loader); err != nil {
+			datasetLogger(ds).Warnf("get size of job %s error: %v", job.Name, err)
+		}
+	} else if jobFailed {
+		// 空间不足时扩容 pvc 后重试
+		retry, err := r.retryOutOfSpaceRound(ctx, ds, job, loader)
+		if err != nil {
+			datasetLogger(ds).Warnf("retry round %d of job %s after running out of space error: %v", loader.Round, job.Name, err)
+		}
+		if !retry {
+			ds.Status.InProcessing = false
+			ds.Status.InProcessingRound = 0
+			loader.Succeed = false
+		}
 	}
 
 	// 滚动清理过期的历史记录
@@ -782,7 +748,7 @@ func (r *DatasetReconciler) reconcileJobStatus(ctx context.Context, ds *datasetv
 	return nil
 }
 
-func (r *DatasetReconciler) reconcilePhase(_ context.Context, ds *datasetv1alpha1.Dataset) error {
+func (r *DatasetReconciler) reconcilePhase(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
 	var phase datasetv1alpha1.DatasetStatusPhase
 	switch ds.Spec.Source.Type {
 	case datasetv1alpha1.DatasetTypeReference:
@@ -792,10 +758,32 @@ func (r *DatasetReconciler) reconcilePhase(_ context.Context, ds *datasetv1alpha
 			ds.Status.Phase = datasetv1alpha1.DatasetStatusPhaseFailed
 			return nil
 		}
+		if referenceMode(ds) == referenceModeCopy {
+			// 复制到本地的数据不受 source dataset 重新同步的影响
+			switch {
+			case ds.Status.InProcessing:
+				ds.Status.Phase = datasetv1alpha1.DatasetStatusPhaseProcessing
+			case ds.Status.CopiedSourceRound == 0:
+				ds.Status.Phase = datasetv1alpha1.DatasetStatusPhasePending
+			default:
+				ds.Status.Phase = datasetv1alpha1.DatasetStatusPhaseReady
+			}
+			return nil
+		}
+		// 与 source dataset 的 phase 保持一致，source dataset 重新同步时 REFERENCE dataset 也不是 READY
+		ds.Status.Phase = r.referencePhase(ctx, ds)
+		return nil
 	}
 
 	if ds.Spec.Source.Type == datasetv1alpha1.DatasetTypePVC {
-		phase = datasetv1alpha1.DatasetStatusPhaseReady
+		// 等待 PVC Bound
+		if meta.IsStatusConditionFalse(ds.Status.Conditions, "PVC") {
+			phase = datasetv1alpha1.DatasetStatusPhasePending
+		} else {
+			phase = datasetv1alpha1.DatasetStatusPhaseReady
+		}
+	} else if ds.Status.Queued {
+		phase = datasetv1alpha1.DatasetStatusPhasePending
 	} else if ds.Status.InProcessing {
 		phase = datasetv1alpha1.DatasetStatusPhaseProcessing
 	} else if ds.Status.LastSucceedRound != ds.Spec.DataSyncRound {
@@ -811,47 +799,126 @@ func (r *DatasetReconciler) reconcilePhase(_ context.Context, ds *datasetv1alpha
 }
 
 func (r *DatasetReconciler) getSourceDataset(ctx context.Context, ds *datasetv1alpha1.Dataset) (*datasetv1alpha1.Dataset, error) {
-	u, err := url.Parse(ds.Spec.Source.URI)
+	key, err := referenceSource(ds)
 	if err != nil {
 		return nil, err
 	}
 	sourceDs := &datasetv1alpha1.Dataset{}
-	if err := r.Get(ctx, client.ObjectKey{Namespace: u.Host, Name: strings.Trim(u.Path, "/")}, sourceDs); err != nil {
+	if err := r.Get(ctx, key, sourceDs); err != nil {
 		return nil, fmt.Errorf("fetch source dataset %s error: %v", ds.Spec.Source.URI, err)
 	}
 	return sourceDs, nil
 }
 
 func (r *DatasetReconciler) validate(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
+	if err := r.validateSource(ctx, ds); err != nil {
+		return err
+	}
 	if ds.Spec.Source.Type == datasetv1alpha1.DatasetTypeReference {
 		sourceDs, err := r.getSourceDataset(ctx, ds)
 		if err != nil {
 			return err
 		}
-		if !sourceDs.Spec.Share {
-			return fmt.Errorf("source dataset %s is not shared", ds.Spec.Source.URI)
+		if err := validateReferenceMode(ds, sourceDs); err != nil {
+			return err
 		}
-		if sourceDs.Spec.ShareToNamespaceSelector != nil {
-			// 获取当前 Dataset 所在的 Namespace
-			currNS := &corev1.Namespace{}
-			if err := r.Get(ctx, client.ObjectKey{Name: ds.Namespace}, currNS); err != nil {
-				return fmt.Errorf("fetch current namespace %s error: %v", ds.Namespace, err)
-			}
-			s, err := metav1.LabelSelectorAsSelector(sourceDs.Spec.ShareToNamespaceSelector)
-			if err != nil {
-				return fmt.Errorf("parse share to namespace selector error: %v", err)
-			}
-			if !s.Matches(labels.Set(currNS.Labels)) {
-				return fmt.Errorf("source dataset %s is not shared to current namespace", ds.Spec.Source.URI)
-			}
+		// 每次 reconcile 都重新检查，source dataset 取消共享后撤销访问权限
+		if err := r.checkReferenceAccess(ctx, ds, sourceDs); err != nil {
+			return r.revokeReferenceAccess(ctx, ds, err)
+		}
+		meta.RemoveStatusCondition(&ds.Status.Conditions, condTypeAccessRevoked)
+	}
+	if crossNamespacePVC(ds) {
+		if _, err := r.checkPVCAccess(ctx, ds); err != nil {
+			return r.revokeReferenceAccess(ctx, ds, err)
+		}
+		meta.RemoveStatusCondition(&ds.Status.Conditions, condTypeAccessRevoked)
+	}
+	return nil
+}
+
+func (r *DatasetReconciler) validateSource(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
+	if !isMultiSource(ds) {
+		switch ds.Spec.Source.Type {
+		case datasetv1alpha1.DatasetTypeNFS:
+			return validateNFSOptions(ds)
+		case datasetv1alpha1.DatasetTypePVC:
+			return validatePVCSource(ds)
+		case datasetv1alpha1.DatasetTypeReference:
+			return nil
+		}
+		return r.validateSourceItem(ctx, ds, datasetSources(ds)[0])
+	}
+
+	// 多个 source 只支持由 data-loader 同步的类型，并且各自同步到不同的子目录
+	mountPaths := make(map[string]string, len(ds.Spec.Sources))
+	for _, source := range ds.Spec.Sources {
+		if source.Type == datasetv1alpha1.DatasetTypeConda {
+			return fmt.Errorf("dataset source type %s of source %s can not be composed with other sources", source.Type, source.Name)
+		}
+		if err := validateSourcePath(source); err != nil {
+			return err
+		}
+		mountPath := sourceMountPath(ds, source)
+		if other, ok := mountPaths[mountPath]; ok {
+			return fmt.Errorf("sources %s and %s are synced to the same path %s", other, source.Name, mountPath)
+		}
+		mountPaths[mountPath] = source.Name
+		if err := r.validateSourceItem(ctx, ds, source); err != nil {
+			return fmt.Errorf("source %s: %w", source.Name, err)
 		}
 	}
 	return nil
 }
 
+func (r *DatasetReconciler) validateSourceItem(ctx context.Context, ds *datasetv1alpha1.Dataset, source datasetv1alpha1.DatasetSourceItem) error {
+	if !supportPreloadType(source.Type) {
+		return fmt.Errorf("dataset source type %s is not supported", source.Type)
+	}
+
+	typ := datasources.Type(source.Type)
+	if err := datasources.ValidateSource(typ, source.URI, source.Options); err != nil {
+		return err
+	}
+
+	registration, ok := datasources.Lookup(typ)
+	if !ok || len(registration.RequiredSecretKeys) == 0 {
+		return nil
+	}
+	if source.SecretRef == "" {
+		return fmt.Errorf("dataset source type %s requires secretRef", source.Type)
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: source.SecretRef}, secret); err != nil {
+		return fmt.Errorf("fetch secret %s error: %v", source.SecretRef, err)
+	}
+	return datasources.ValidateSecretKeys(typ, append(lo.Keys(secret.Data), lo.Keys(secret.StringData)...))
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *DatasetReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &datasetv1alpha1.Dataset{}, referenceSourceIndex, indexReferenceSource)
+	if err != nil {
+		return err
+	}
+
+	// source dataset 的共享设置或同步状态、namespace 的 label 或者 DatasetShareGrant 变化时，重新检查 REFERENCE dataset，
+	// REFERENCE dataset 变化时更新 source dataset 的 consumers
+	if err := ctrl.NewControllerManagedBy(mgr).
 		For(&datasetv1alpha1.Dataset{}).
-		Complete(r)
+		Watches(&datasetv1alpha1.Dataset{},
+			handler.EnqueueRequestsFromMapFunc(r.referencesOfDataset),
+			builder.WithPredicates(predicate.Or[client.Object](predicate.GenerationChangedPredicate{}, sourceStatusChanged))).
+		Watches(&datasetv1alpha1.Dataset{},
+			handler.EnqueueRequestsFromMapFunc(r.sourcesOfReference)).
+		Watches(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.referencesInNamespace),
+			builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Watches(&datasetv1alpha1.DatasetShareGrant{},
+			handler.EnqueueRequestsFromMapFunc(r.referencesOfShareGrant)).
+		Complete(r); err != nil {
+		return err
+	}
+
+	return mgr.Add(&orphanPVSweeper{Client: mgr.GetClient()})
 }
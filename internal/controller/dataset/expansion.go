@@ -0,0 +1,199 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+const (
+	// defaultPVCStorage 为 volumeClaimTemplate 没有申请 storage 时 pvc 的大小
+	defaultPVCStorage = "100Ti"
+	// maxPVCExpansionsPerRound 限制每个 round 因空间不足扩容 pvc 并重试的次数
+	maxPVCExpansionsPerRound = 3
+
+	isDefaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+)
+
+// getStorageClass 返回 pvc 使用的 storageclass，没有指定时为默认的 storageclass，找不到时返回 nil
+func (r *DatasetReconciler) getStorageClass(ctx context.Context, name *string) (*storagev1.StorageClass, error) {
+	if name != nil {
+		if *name == "" {
+			// 显式指定为空表示不使用 storageclass
+			return nil, nil
+		}
+		sc := &storagev1.StorageClass{}
+		err := r.Get(ctx, client.ObjectKey{Name: *name}, sc)
+		return sc, client.IgnoreNotFound(err)
+	}
+
+	scList := &storagev1.StorageClassList{}
+	if err := r.List(ctx, scList); err != nil {
+		return nil, err
+	}
+	sc, ok := lo.Find(scList.Items, func(sc storagev1.StorageClass) bool {
+		return sc.Annotations[isDefaultStorageClassAnnotation] == "true"
+	})
+	if !ok {
+		return nil, nil
+	}
+	return &sc, nil
+}
+
+// allowVolumeExpansion 表示 pvc 使用的 storageclass 是否支持扩容
+func (r *DatasetReconciler) allowVolumeExpansion(ctx context.Context, storageClassName *string) (bool, error) {
+	sc, err := r.getStorageClass(ctx, storageClassName)
+	if err != nil || sc == nil {
+		return false, err
+	}
+	return lo.FromPtr(sc.AllowVolumeExpansion), nil
+}
+
+// initialPVCStorage 返回 volumeClaimTemplate 没有申请 storage 的 pvc 的初始大小：开启自动扩容且 storageclass 支持扩容时
+// 使用配置的初始大小，由第一个 round 按 data-loader 估算的大小扩容，否则为 100Ti
+func (r *DatasetReconciler) initialPVCStorage(ctx context.Context, ds *datasetv1alpha1.Dataset, spec *corev1.PersistentVolumeClaimSpec) (resource.Quantity, error) {
+	defaultStorage := resource.MustParse(defaultPVCStorage)
+	expansion := config.GetPVCExpansionConfig()
+	if !expansion.Enabled || expansion.InitialSize == "" || !supportPreload(ds) {
+		return defaultStorage, nil
+	}
+	initialSize, err := resource.ParseQuantity(expansion.InitialSize)
+	if err != nil {
+		datasetLogger(ds).Warnf("invalid initial size %s of pvc expansion: %v", expansion.InitialSize, err)
+		return defaultStorage, nil
+	}
+
+	allowed, err := r.allowVolumeExpansion(ctx, spec.StorageClassName)
+	if err != nil {
+		return defaultStorage, err
+	}
+	if !allowed {
+		return defaultStorage, nil
+	}
+	return initialSize, nil
+}
+
+// expandedPVCStorage 返回扩容后的大小：data-loader 估算需要的大小加上余量，估算的大小未知或者不超过当前大小时
+// 在当前大小上加上余量，按 Gi 向上取整
+func expandedPVCStorage(current resource.Quantity, requiredBytes int64, headroomPercent int) resource.Quantity {
+	size := max(requiredBytes, current.Value())
+	size += size / 100 * int64(headroomPercent)
+	size = (size + 1<<30 - 1) >> 30 << 30
+	return *resource.NewQuantity(size, resource.BinarySI)
+}
+
+// expandPVC 按 data-loader 估算需要的大小加上余量扩容 dataset 的 pvc，返回是否扩容
+func (r *DatasetReconciler) expandPVC(ctx context.Context, ds *datasetv1alpha1.Dataset, requiredBytes int64) (bool, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: ds.Status.PVCName}, pvc); err != nil {
+		return false, err
+	}
+	allowed, err := r.allowVolumeExpansion(ctx, pvc.Spec.StorageClassName)
+	if err != nil {
+		return false, err
+	}
+	if !allowed {
+		datasetLogger(ds).Warnf("storage class of pvc %s does not allow volume expansion", pvc.Name)
+		return false, nil
+	}
+
+	expansion := config.GetPVCExpansionConfig()
+	current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	target := expandedPVCStorage(current, requiredBytes, expansion.HeadroomPercent)
+	if expansion.MaxSize != "" {
+		maxSize, err := resource.ParseQuantity(expansion.MaxSize)
+		if err != nil {
+			return false, fmt.Errorf("invalid max size %s of pvc expansion: %v", expansion.MaxSize, err)
+		}
+		if target.Cmp(maxSize) > 0 {
+			target = maxSize
+		}
+	}
+	if target.Cmp(current) <= 0 {
+		datasetLogger(ds).Warnf("pvc %s of %s can not be expanded any further", pvc.Name, current.String())
+		return false, nil
+	}
+
+	if pvc.Spec.Resources.Requests == nil {
+		pvc.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = target
+	if err := r.Update(ctx, pvc); err != nil {
+		return false, err
+	}
+	datasetLogger(ds).Infof("expanded pvc %s from %s to %s, %d bytes required", pvc.Name, current.String(), target.String(), requiredBytes)
+	return true, nil
+}
+
+// isPVCResizing 表示 pvc 是否还在扩容，需要 pod 挂载后才能扩容文件系统的 pvc 不需要等待
+func (r *DatasetReconciler) isPVCResizing(ctx context.Context, ds *datasetv1alpha1.Dataset) (bool, error) {
+	pvc := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: ds.Status.PVCName}, pvc); err != nil {
+		return false, err
+	}
+	if lo.ContainsBy(pvc.Status.Conditions, func(cond corev1.PersistentVolumeClaimCondition) bool {
+		return cond.Type == corev1.PersistentVolumeClaimFileSystemResizePending && cond.Status == corev1.ConditionTrue
+	}) {
+		return false, nil
+	}
+	capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+	request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	return capacity.Cmp(request) < 0, nil
+}
+
+// roundPVCExpansions 返回 round 已经扩容 pvc 的次数
+func roundPVCExpansions(ds *datasetv1alpha1.Dataset, round int32) int32 {
+	status, _ := lo.Find(ds.Status.SyncRoundStatuses, func(s datasetv1alpha1.DataLoadStatus) bool {
+		return s.Round == round
+	})
+	return status.PVCExpansions
+}
+
+// jobPVCExpansions 返回创建 job 时 round 已经扩容 pvc 的次数
+func jobPVCExpansions(job *batchv1.Job) int32 {
+	expansions, _ := strconv.ParseInt(job.Annotations[constants.DatasetPVCExpansionsAnnotation], 10, 32)
+	return int32(expansions)
+}
+
+// retryOutOfSpaceRound 在 round 因 pvc 空间不足失败时扩容 pvc，然后删除失败的 job，由 reconcileJob 重新创建以重试，
+// 返回 round 是否重试
+func (r *DatasetReconciler) retryOutOfSpaceRound(ctx context.Context, ds *datasetv1alpha1.Dataset, job *batchv1.Job, loader *datasetv1alpha1.DataLoadStatus) (bool, error) {
+	if !config.GetPVCExpansionConfig().Enabled {
+		return false, nil
+	}
+
+	// 扩容后 job 已经删除，但缓存中还没有更新时不再重复扩容
+	if jobPVCExpansions(job) >= loader.PVCExpansions {
+		if loader.PVCExpansions >= maxPVCExpansionsPerRound {
+			return false, nil
+		}
+		result, err := r.getJobFailureResult(ctx, job)
+		if err != nil || result == nil || result.Reason != datasources.SyncFailureReasonOutOfSpace {
+			return false, err
+		}
+		expanded, err := r.expandPVC(ctx, ds, result.RequiredBytes)
+		if err != nil || !expanded {
+			return false, err
+		}
+		loader.PVCExpansions++
+	}
+
+	if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		return true, err
+	}
+	datasetLogger(ds).Infof("retrying round %d after expanding pvc %s", loader.Round, ds.Status.PVCName)
+	return true, nil
+}
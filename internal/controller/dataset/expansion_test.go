@@ -0,0 +1,228 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+const testPVCExpansionConfig = `
+pvc_expansion:
+  enabled: true
+  initial_size: 10Gi
+  headroom_percent: 10
+`
+
+func TestExpandedPVCStorage(t *testing.T) {
+	cases := []struct {
+		name     string
+		current  string
+		required int64
+		headroom int
+		want     string
+	}{
+		{name: "required", current: "10Gi", required: 20 << 30, headroom: 10, want: "22Gi"},
+		{name: "round up to Gi", current: "10Gi", required: 20<<30 + 1, headroom: 0, want: "21Gi"},
+		{name: "unknown required", current: "10Gi", required: 0, headroom: 20, want: "12Gi"},
+		{name: "required less than current", current: "100Gi", required: 50 << 30, headroom: 20, want: "120Gi"},
+		{name: "no headroom on current", current: "10Gi", required: 0, headroom: 0, want: "10Gi"},
+		{name: "zero current", current: "0", required: 1 << 20, headroom: 20, want: "1Gi"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := expandedPVCStorage(resource.MustParse(c.current), c.required, c.headroom)
+			want := resource.MustParse(c.want)
+			assert.Equal(t, want.Value(), got.Value(), got.String())
+		})
+	}
+}
+
+func testStorageClass(name string, isDefault, allowExpansion bool) *storagev1.StorageClass {
+	sc := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: name},
+		AllowVolumeExpansion: lo.ToPtr(allowExpansion),
+	}
+	if isDefault {
+		sc.Annotations = map[string]string{isDefaultStorageClassAnnotation: "true"}
+	}
+	return sc
+}
+
+func TestInitialPVCStorage(t *testing.T) {
+	ctx := context.Background()
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeHTTP, URI: "https://example.com/corpus/"},
+		},
+	}
+	r := newTestReconciler(t, testStorageClass("standard", true, true), testStorageClass("fixed", false, false))
+
+	cases := []struct {
+		name         string
+		config       string
+		storageClass *string
+		want         string
+	}{
+		{name: "disabled", config: "{}", want: defaultPVCStorage},
+		{name: "default storage class", config: testPVCExpansionConfig, want: "10Gi"},
+		{name: "storage class without expansion", config: testPVCExpansionConfig, storageClass: lo.ToPtr("fixed"), want: defaultPVCStorage},
+		{name: "no storage class", config: testPVCExpansionConfig, storageClass: lo.ToPtr(""), want: defaultPVCStorage},
+		{name: "missing storage class", config: testPVCExpansionConfig, storageClass: lo.ToPtr("missing"), want: defaultPVCStorage},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setTestConfig(t, c.config)
+			got, err := r.initialPVCStorage(ctx, ds, &corev1.PersistentVolumeClaimSpec{StorageClassName: c.storageClass})
+			require.NoError(t, err)
+			assert.Equal(t, c.want, got.String())
+		})
+	}
+}
+
+func TestRetryOutOfSpaceRound(t *testing.T) {
+	ctx := context.Background()
+
+	type testCase struct {
+		name string
+		// config 为空时使用 testPVCExpansionConfig
+		config         string
+		allowExpansion bool
+		reason         string
+		// jobExpansions 为创建 job 时 round 已经扩容的次数，expansions 为 status 中记录的次数
+		jobExpansions int32
+		expansions    int32
+
+		wRetry      bool
+		wExpansions int32
+		wStorage    string
+	}
+	cases := []testCase{
+		{name: "expand and retry", allowExpansion: true, reason: datasources.SyncFailureReasonOutOfSpace,
+			wRetry: true, wExpansions: 1, wStorage: "22Gi"},
+		{name: "capped by max size", config: testPVCExpansionConfig + "  max_size: 15Gi\n", allowExpansion: true, reason: datasources.SyncFailureReasonOutOfSpace,
+			wRetry: true, wExpansions: 1, wStorage: "15Gi"},
+		{name: "already at max size", config: testPVCExpansionConfig + "  max_size: 10Gi\n", allowExpansion: true, reason: datasources.SyncFailureReasonOutOfSpace,
+			wRetry: false, wExpansions: 0, wStorage: "10Gi"},
+		{name: "expanded but job not deleted in cache", allowExpansion: true, reason: datasources.SyncFailureReasonOutOfSpace, jobExpansions: 0, expansions: 1,
+			wRetry: true, wExpansions: 1, wStorage: "10Gi"},
+		{name: "max expansions per round", allowExpansion: true, reason: datasources.SyncFailureReasonOutOfSpace, jobExpansions: maxPVCExpansionsPerRound, expansions: maxPVCExpansionsPerRound,
+			wRetry: false, wExpansions: maxPVCExpansionsPerRound, wStorage: "10Gi"},
+		{name: "other failure", allowExpansion: true, reason: "",
+			wRetry: false, wExpansions: 0, wStorage: "10Gi"},
+		{name: "storage class without expansion", allowExpansion: false, reason: datasources.SyncFailureReasonOutOfSpace,
+			wRetry: false, wExpansions: 0, wStorage: "10Gi"},
+		{name: "disabled", config: "{}", allowExpansion: true, reason: datasources.SyncFailureReasonOutOfSpace,
+			wRetry: false, wExpansions: 0, wStorage: "10Gi"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setTestConfig(t, lo.CoalesceOrEmpty(c.config, testPVCExpansionConfig))
+
+			ds := &datasetv1alpha1.Dataset{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+				Status:     datasetv1alpha1.DatasetStatus{PVCName: "corpus"},
+			}
+			pvc := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: lo.ToPtr("standard"),
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")},
+					},
+				},
+			}
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "ml-team",
+					Name:        genJobName(ds.Name, 1),
+					Annotations: map[string]string{constants.DatasetPVCExpansionsAnnotation: strconv.Itoa(int(c.jobExpansions))},
+				},
+			}
+			message, err := json.Marshal(datasources.SyncResult{Reason: c.reason, RequiredBytes: 20 << 30})
+			require.NoError(t, err)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "ml-team",
+					Name:      job.Name + "-x",
+					Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+				},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodFailed,
+					ContainerStatuses: []corev1.ContainerStatus{{
+						Name:  "data-loader",
+						State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Message: string(message)}},
+					}},
+				},
+			}
+			r := newTestReconciler(t, testStorageClass("standard", true, c.allowExpansion), pvc, job, pod)
+			loader := &datasetv1alpha1.DataLoadStatus{Round: 1, PVCExpansions: c.expansions}
+
+			retry, err := r.retryOutOfSpaceRound(ctx, ds, job, loader)
+			require.NoError(t, err)
+			assert.Equal(t, c.wRetry, retry)
+			assert.Equal(t, c.wExpansions, loader.PVCExpansions)
+
+			require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pvc), pvc))
+			storage := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			assert.Equal(t, c.wStorage, storage.String())
+
+			err = r.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+			assert.Equal(t, c.wRetry, k8serrors.IsNotFound(err), "job should be deleted only when retried")
+		})
+	}
+}
+
+func TestIsPVCResizing(t *testing.T) {
+	ctx := context.Background()
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+		Status:     datasetv1alpha1.DatasetStatus{PVCName: "corpus"},
+	}
+	pvc := func(capacity string, conditions ...corev1.PersistentVolumeClaimCondition) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				Resources: corev1.VolumeResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+				},
+			},
+			Status: corev1.PersistentVolumeClaimStatus{
+				Capacity:   corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+				Conditions: conditions,
+			},
+		}
+	}
+
+	resizing, err := newTestReconciler(t, pvc("10Gi")).isPVCResizing(ctx, ds)
+	require.NoError(t, err)
+	assert.True(t, resizing)
+
+	resizing, err = newTestReconciler(t, pvc("20Gi")).isPVCResizing(ctx, ds)
+	require.NoError(t, err)
+	assert.False(t, resizing)
+
+	// 等待 pod 挂载后扩容文件系统
+	resizing, err = newTestReconciler(t, pvc("10Gi", corev1.PersistentVolumeClaimCondition{
+		Type:   corev1.PersistentVolumeClaimFileSystemResizePending,
+		Status: corev1.ConditionTrue,
+	})).isPVCResizing(ctx, ds)
+	require.NoError(t, err)
+	assert.False(t, resizing)
+}
@@ -0,0 +1,43 @@
+package dataset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, datasetv1alpha1.AddToScheme(scheme))
+	return scheme
+}
+
+func newTestClient(t *testing.T, objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(newTestScheme(t)).
+		WithObjects(objs...).
+		WithStatusSubresource(&datasetv1alpha1.Dataset{}, &datasetv1alpha1.DatasetShareGrant{}).
+		WithIndex(&datasetv1alpha1.Dataset{}, referenceSourceIndex, indexReferenceSource).
+		Build()
+}
+
+func newTestReconciler(t *testing.T, objs ...client.Object) *DatasetReconciler {
+	c := newTestClient(t, objs...)
+	return &DatasetReconciler{Client: c, Scheme: c.Scheme()}
+}
+
+// setTestConfig 使用 content 作为 controller 的配置，测试结束后恢复默认配置
+func setTestConfig(t *testing.T, content string) {
+	require.NoError(t, config.ParseConfigFromFileContent(content))
+	t.Cleanup(func() {
+		require.NoError(t, config.ParseConfigFromFileContent("{}"))
+	})
+}
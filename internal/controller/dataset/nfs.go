@@ -0,0 +1,136 @@
+package dataset
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+const (
+	// nfsOptionMountOptions 为逗号分隔的挂载参数，替换 pv 模板中的 mountOptions
+	nfsOptionMountOptions = "mountOptions"
+	// nfsOptionVersion 替换挂载参数中的 nfsvers
+	nfsOptionVersion = "nfsVersion"
+	// nfsOptionShare 为 NFS server 导出的根目录，uri 中剩下的路径作为 subdir
+	nfsOptionShare = "share"
+	// nfsOptionReadOnly 为 true 时以只读方式挂载
+	nfsOptionReadOnly = "readOnly"
+)
+
+func nfsPVName(ds *datasetv1alpha1.Dataset, pvcName string) string {
+	return fmt.Sprintf("dataset-%s-pvc-%s", ds.Namespace, pvcName)
+}
+
+func nfsReadOnly(ds *datasetv1alpha1.Dataset) bool {
+	readOnly, _ := strconv.ParseBool(ds.Spec.Source.Options[nfsOptionReadOnly])
+	return readOnly
+}
+
+// nfsShareAndSubdir 按 share 选项把 uri 的路径拆分为 NFS server 导出的 share 和其中的 subdir
+func nfsShareAndSubdir(ds *datasetv1alpha1.Dataset) (server, share, subdir string, err error) {
+	u, err := url.Parse(ds.Spec.Source.URI)
+	if err != nil {
+		return "", "", "", err
+	}
+	share = path.Clean("/" + lo.CoalesceOrEmpty(ds.Spec.Source.Options[nfsOptionShare], "/"))
+	if share == "/" {
+		return u.Host, share, u.Path, nil
+	}
+	p := path.Clean("/" + u.Path)
+	if p != share && !strings.HasPrefix(p, share+"/") {
+		return "", "", "", fmt.Errorf("path %s of uri is not under share %s", p, share)
+	}
+	return u.Host, share, strings.TrimPrefix(strings.TrimPrefix(p, share), "/"), nil
+}
+
+// validateNFSOptions 校验 NFS dataset 的 options
+func validateNFSOptions(ds *datasetv1alpha1.Dataset) error {
+	if _, _, _, err := nfsShareAndSubdir(ds); err != nil {
+		return err
+	}
+	if v, ok := ds.Spec.Source.Options[nfsOptionReadOnly]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid option %s=%s: %v", nfsOptionReadOnly, v, err)
+		}
+	}
+	if v := ds.Spec.Source.Options[nfsOptionVersion]; v != "" {
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("invalid option %s=%s, must be a version like 4.1", nfsOptionVersion, v)
+		}
+	}
+	return nil
+}
+
+// nfsMountOptions 返回 pv 的挂载参数，options 中的 mountOptions 和 nfsVersion 覆盖模板中的配置
+func nfsMountOptions(ds *datasetv1alpha1.Dataset, templateOptions []string) []string {
+	mountOptions := templateOptions
+	if v := ds.Spec.Source.Options[nfsOptionMountOptions]; v != "" {
+		mountOptions = lo.Compact(lo.Map(strings.Split(v, ","), func(o string, _ int) string {
+			return strings.TrimSpace(o)
+		}))
+	}
+	if v := ds.Spec.Source.Options[nfsOptionVersion]; v != "" {
+		mountOptions = append(lo.Reject(mountOptions, func(o string, _ int) bool {
+			return strings.HasPrefix(o, "nfsvers=") || strings.HasPrefix(o, "vers=")
+		}), "nfsvers="+v)
+	}
+	return mountOptions
+}
+
+// newNFSPV 按 controller 配置的 pv 模板构造 NFS dataset 的 pv
+func newNFSPV(ds *datasetv1alpha1.Dataset, pvName, pvcName string) (*corev1.PersistentVolume, error) {
+	pv := &corev1.PersistentVolume{}
+	if err := yaml.Unmarshal([]byte(config.GetNFSPVTemplateYaml()), pv); err != nil {
+		return nil, fmt.Errorf("unmarshal nfs pv template yaml failed: %w", err)
+	}
+	server, share, subdir, err := nfsShareAndSubdir(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	pv.OwnerReferences = datasetOwnerRef(ds)
+	pv.Labels = lo.Assign(pv.Labels, map[string]string{
+		constants.DatasetNameLabel: ds.Name,
+	})
+	pv.Name = pvName
+	pv.Spec.MountOptions = nfsMountOptions(ds, pv.Spec.MountOptions)
+
+	if pv.Spec.CSI == nil {
+		pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{}
+	}
+	if pv.Spec.CSI.VolumeAttributes == nil {
+		pv.Spec.CSI.VolumeAttributes = make(map[string]string)
+	}
+	pv.Spec.CSI.VolumeAttributes["server"] = server
+	pv.Spec.CSI.VolumeAttributes["share"] = share
+	pv.Spec.CSI.VolumeAttributes["subdir"] = subdir
+	pv.Spec.CSI.VolumeAttributes["onDelete"] = "retain"
+	pv.Spec.CSI.VolumeAttributes["csi.storage.k8s.io/pv/name"] = pvName
+	pv.Spec.CSI.VolumeAttributes["csi.storage.k8s.io/pvc/name"] = pvcName
+	pv.Spec.CSI.VolumeAttributes["csi.storage.k8s.io/pvc/namespace"] = ds.Namespace
+
+	// 如果 mountPermissions 没配置，则默认用 ds.Spec.MountOptions.Mode
+	if pv.Spec.CSI.VolumeAttributes["mountPermissions"] == "" {
+		pv.Spec.CSI.VolumeAttributes["mountPermissions"] = ds.Spec.MountOptions.Mode
+	}
+	handlePath := subdir
+	if share != "/" {
+		handlePath = path.Join(share, subdir)
+	}
+	pv.Spec.CSI.VolumeHandle = fmt.Sprintf("%s#%s#%s#", server, handlePath, pvName)
+	if nfsReadOnly(ds) {
+		pv.Spec.CSI.ReadOnly = true
+	}
+
+	return pv, nil
+}
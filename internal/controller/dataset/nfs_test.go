@@ -0,0 +1,161 @@
+package dataset
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func testNFSDataset(uri string, options map[string]string) *datasetv1alpha1.Dataset {
+	return &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{
+				Type:    datasetv1alpha1.DatasetTypeNFS,
+				URI:     uri,
+				Options: options,
+			},
+			MountOptions: datasetv1alpha1.MountOptions{Mode: "0755"},
+		},
+	}
+}
+
+func TestNFSShareAndSubdir(t *testing.T) {
+	cases := []struct {
+		name    string
+		uri     string
+		share   string
+		server  string
+		wShare  string
+		wSubdir string
+		wErr    bool
+	}{
+		{name: "no share", uri: "nfs://10.0.0.1/exports/corpus", server: "10.0.0.1", wShare: "/", wSubdir: "/exports/corpus"},
+		{name: "share", uri: "nfs://10.0.0.1/exports/corpus/v1", share: "/exports", server: "10.0.0.1", wShare: "/exports", wSubdir: "corpus/v1"},
+		{name: "share without leading slash", uri: "nfs://10.0.0.1/exports/corpus", share: "exports/", server: "10.0.0.1", wShare: "/exports", wSubdir: "corpus"},
+		{name: "uri is share", uri: "nfs://nfs.local/exports", share: "/exports", server: "nfs.local", wShare: "/exports", wSubdir: ""},
+		{name: "not under share", uri: "nfs://10.0.0.1/data/corpus", share: "/exports", wErr: true},
+		{name: "share prefix of another dir", uri: "nfs://10.0.0.1/exports2/corpus", share: "/exports", wErr: true},
+		{name: "escape share", uri: "nfs://10.0.0.1/exports/../etc", share: "/exports", wErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ds := testNFSDataset(c.uri, map[string]string{nfsOptionShare: c.share})
+			server, share, subdir, err := nfsShareAndSubdir(ds)
+			if c.wErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.server, server)
+			assert.Equal(t, c.wShare, share)
+			assert.Equal(t, c.wSubdir, subdir)
+		})
+	}
+}
+
+func TestValidateNFSOptions(t *testing.T) {
+	cases := []struct {
+		name    string
+		options map[string]string
+		wErr    bool
+	}{
+		{name: "empty"},
+		{name: "valid", options: map[string]string{nfsOptionShare: "/exports", nfsOptionReadOnly: "true", nfsOptionVersion: "4.1"}},
+		{name: "invalid readOnly", options: map[string]string{nfsOptionReadOnly: "yes"}, wErr: true},
+		{name: "invalid version", options: map[string]string{nfsOptionVersion: "v4"}, wErr: true},
+		{name: "invalid share", options: map[string]string{nfsOptionShare: "/data"}, wErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateNFSOptions(testNFSDataset("nfs://10.0.0.1/exports/corpus", c.options))
+			if c.wErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNFSMountOptions(t *testing.T) {
+	template := []string{"nfsvers=4.1", "hard"}
+	cases := []struct {
+		name    string
+		options map[string]string
+		want    []string
+	}{
+		{name: "template", want: template},
+		{name: "mountOptions", options: map[string]string{nfsOptionMountOptions: "nfsvers=3, nolock,,"}, want: []string{"nfsvers=3", "nolock"}},
+		{name: "nfsVersion", options: map[string]string{nfsOptionVersion: "4.2"}, want: []string{"hard", "nfsvers=4.2"}},
+		{name: "nfsVersion replaces vers", options: map[string]string{nfsOptionMountOptions: "vers=3,ro", nfsOptionVersion: "4"}, want: []string{"ro", "nfsvers=4"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, nfsMountOptions(testNFSDataset("nfs://10.0.0.1/exports", c.options), template))
+		})
+	}
+	assert.Equal(t, []string{"nfsvers=4.1", "hard"}, template, "template options should not be modified")
+}
+
+func TestNewNFSPV(t *testing.T) {
+	ds := testNFSDataset("nfs://10.0.0.1/exports/corpus", map[string]string{
+		nfsOptionShare:    "/exports",
+		nfsOptionVersion:  "4.2",
+		nfsOptionReadOnly: "true",
+	})
+	pvName := nfsPVName(ds, "corpus")
+
+	t.Run("default template", func(t *testing.T) {
+		pv, err := newNFSPV(ds, pvName, "corpus")
+		require.NoError(t, err)
+		assert.Equal(t, "dataset-ml-team-pvc-corpus", pv.Name)
+		assert.Equal(t, ds.Name, pv.Labels[constants.DatasetNameLabel])
+		assert.Equal(t, "nfs-csi", pv.Spec.StorageClassName)
+		assert.Equal(t, []string{"nfsvers=4.2"}, pv.Spec.MountOptions)
+		assert.Equal(t, corev1.PersistentVolumeReclaimRetain, pv.Spec.PersistentVolumeReclaimPolicy)
+		require.NotNil(t, pv.Spec.CSI)
+		assert.True(t, pv.Spec.CSI.ReadOnly)
+		assert.Equal(t, "10.0.0.1#/exports/corpus#dataset-ml-team-pvc-corpus#", pv.Spec.CSI.VolumeHandle)
+		assert.Equal(t, map[string]string{
+			"server":                           "10.0.0.1",
+			"share":                            "/exports",
+			"subdir":                           "corpus",
+			"onDelete":                         "retain",
+			"mountPermissions":                 "0755",
+			"csi.storage.k8s.io/pv/name":       pvName,
+			"csi.storage.k8s.io/pvc/name":      "corpus",
+			"csi.storage.k8s.io/pvc/namespace": "ml-team",
+		}, pv.Spec.CSI.VolumeAttributes)
+	})
+
+	t.Run("configured template", func(t *testing.T) {
+		setTestConfig(t, `
+nfs_pv_template_yaml: |
+  apiVersion: v1
+  kind: PersistentVolume
+  metadata:
+    labels:
+      team: storage
+  spec:
+    storageClassName: fast-nfs
+    csi:
+      driver: nfs.csi.k8s.io
+      volumeAttributes:
+        mountPermissions: "0777"
+`)
+		pv, err := newNFSPV(ds, pvName, "corpus")
+		require.NoError(t, err)
+		assert.Equal(t, "storage", pv.Labels["team"])
+		assert.Equal(t, ds.Name, pv.Labels[constants.DatasetNameLabel])
+		assert.Equal(t, "fast-nfs", pv.Spec.StorageClassName)
+		assert.Equal(t, "0777", pv.Spec.CSI.VolumeAttributes["mountPermissions"])
+		assert.Equal(t, []string{"nfsvers=4.2"}, pv.Spec.MountOptions)
+	})
+}
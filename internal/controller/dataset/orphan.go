@@ -0,0 +1,150 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/pkg/log"
+)
+
+// deleteReferenceVolumes 删除 REFERENCE dataset 的 pvc，等到克隆的 pv 被释放后再删除 pv，
+// 超过强制删除的时间后不再等待，仍未释放的 pv 由 orphanPVSweeper 清理
+func (r *DatasetReconciler) deleteReferenceVolumes(ctx context.Context, ds *datasetv1alpha1.Dataset, pvcName string) error {
+	// copy 模式下克隆的 pv 绑定的是 copy job 使用的 pvc
+	return r.deleteClonedVolume(ctx, ds, pvcName, stagingPVCName(ds))
+}
+
+// deleteClonedVolume 删除 pvcNames，等到为 ds 克隆的 pv 被释放后再删除 pv
+func (r *DatasetReconciler) deleteClonedVolume(ctx context.Context, ds *datasetv1alpha1.Dataset, pvcNames ...string) error {
+	for _, name := range pvcNames {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ds.Namespace,
+			},
+		}
+		if err := r.Delete(ctx, pvc); err != nil && !k8serrors.IsNotFound(err) {
+			return fmt.Errorf("delete pvc %s error: %v", name, err)
+		}
+	}
+
+	pv := &corev1.PersistentVolume{}
+	err := r.Get(ctx, client.ObjectKey{Name: clonedPVName(ds)}, pv)
+	if k8serrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if pv.Labels[constants.DatasetNameLabel] != ds.Name {
+		return nil
+	}
+
+	if pv.Status.Phase == corev1.VolumeBound {
+		// pvc 还在被 pod 使用
+		if forceDelete(ds) {
+			datasetLogger(ds).Errorf("pv %s is still bound, leaking it until it is released and swept", pv.Name)
+			return nil
+		}
+		return fmt.Errorf("waiting for pv %s to be released", pv.Name)
+	}
+	if err := deleteClonedPV(ctx, r.Client, pv); err != nil {
+		return err
+	}
+	datasetLogger(ds).Infof("deleted pvc %s and pv %s", strings.Join(pvcNames, ", "), pv.Name)
+
+	return nil
+}
+
+// deleteClonedPV 删除克隆的 pv，只删除 Retain 的 pv，避免删除 source dataset 的数据
+func deleteClonedPV(ctx context.Context, c client.Client, pv *corev1.PersistentVolume) error {
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		return fmt.Errorf("pv %s has reclaim policy %s, refusing to delete it", pv.Name, pv.Spec.PersistentVolumeReclaimPolicy)
+	}
+	if err := c.Delete(ctx, pv, client.Preconditions{UID: &pv.UID}); err != nil && !k8serrors.IsNotFound(err) {
+		return fmt.Errorf("delete pv %s error: %v", pv.Name, err)
+	}
+	return nil
+}
+
+// orphanPVSweeper 定期删除 dataset 已经不存在的克隆 pv，例如强制删除 dataset 时还未释放的 pv
+type orphanPVSweeper struct {
+	client.Client
+}
+
+// NeedLeaderElection 只在 leader 上清理
+func (s *orphanPVSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+func (s *orphanPVSweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(config.GetReferenceOrphanSweepInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *orphanPVSweeper) sweep(ctx context.Context) {
+	logger := log.Component("controller")
+
+	pvList := &corev1.PersistentVolumeList{}
+	err := s.List(ctx, pvList, client.HasLabels{constants.DatasetNameLabel, constants.DatasetNamespaceLabel})
+	if err != nil {
+		logger.Errorf("list cloned pvs error: %v", err)
+		return
+	}
+
+	for i := range pvList.Items {
+		pv := &pvList.Items[i]
+		key := client.ObjectKey{
+			Namespace: pv.Labels[constants.DatasetNamespaceLabel],
+			Name:      pv.Labels[constants.DatasetNameLabel],
+		}
+		ds := &datasetv1alpha1.Dataset{}
+		err := s.Get(ctx, key, ds)
+		if err == nil && isClonedPVOwner(pv, ds) {
+			continue
+		}
+		if err != nil && !k8serrors.IsNotFound(err) {
+			logger.Errorf("fetch dataset %s of pv %s error: %v", key, pv.Name, err)
+			continue
+		}
+
+		if pv.Status.Phase == corev1.VolumeBound {
+			logger.Warnf("pv %s of deleted dataset %s is still bound, leaked until it is released", pv.Name, key)
+			continue
+		}
+		if err := deleteClonedPV(ctx, s.Client, pv); err != nil {
+			logger.Errorf("delete orphan pv of dataset %s error: %v", key, err)
+			continue
+		}
+		logger.Infof("deleted orphan pv %s of deleted dataset %s", pv.Name, key)
+	}
+}
+
+// isClonedPVOwner 表示 pv 是否是为 ds 克隆的，同名 dataset 被删除后重建时不是
+func isClonedPVOwner(pv *corev1.PersistentVolume, ds *datasetv1alpha1.Dataset) bool {
+	for _, ref := range pv.OwnerReferences {
+		if ref.Kind == "Dataset" {
+			return ref.UID == ds.UID
+		}
+	}
+	return true
+}
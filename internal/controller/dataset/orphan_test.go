@@ -0,0 +1,140 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func testClonedPV(namespace, name string, uid types.UID, phase corev1.PersistentVolumePhase, reclaimPolicy corev1.PersistentVolumeReclaimPolicy) *corev1.PersistentVolume {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "dataset-" + namespace + "-pvc-" + name,
+			Labels: map[string]string{constants.DatasetNameLabel: name, constants.DatasetNamespaceLabel: namespace},
+		},
+		Spec:   corev1.PersistentVolumeSpec{PersistentVolumeReclaimPolicy: reclaimPolicy},
+		Status: corev1.PersistentVolumeStatus{Phase: phase},
+	}
+	if uid != "" {
+		pv.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: datasetv1alpha1.GroupVersion.String(),
+			Kind:       "Dataset",
+			Name:       name,
+			UID:        uid,
+		}}
+	}
+	return pv
+}
+
+func TestOrphanPVSweeper(t *testing.T) {
+	ctx := context.Background()
+	live := &datasetv1alpha1.Dataset{ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "live", UID: "live-uid"}}
+	recreated := &datasetv1alpha1.Dataset{ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "recreated", UID: "new-uid"}}
+	retain := corev1.PersistentVolumeReclaimRetain
+
+	pvs := map[string]struct {
+		pv      *corev1.PersistentVolume
+		deleted bool
+	}{
+		"owned by live dataset":  {pv: testClonedPV("ml-team", "live", "live-uid", corev1.VolumeBound, retain)},
+		"without owner of live":  {pv: testClonedPV("ml-team", "live", "", corev1.VolumeReleased, retain)},
+		"dataset deleted":        {pv: testClonedPV("ml-team", "gone", "gone-uid", corev1.VolumeReleased, retain), deleted: true},
+		"dataset recreated":      {pv: testClonedPV("ml-team", "recreated", "old-uid", corev1.VolumeAvailable, retain), deleted: true},
+		"still bound":            {pv: testClonedPV("cv-team", "bound", "bound-uid", corev1.VolumeBound, retain)},
+		"not retained":           {pv: testClonedPV("cv-team", "deleting", "deleting-uid", corev1.VolumeReleased, corev1.PersistentVolumeReclaimDelete)},
+		"without dataset labels": {pv: &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}}},
+	}
+	// "without owner of live" 与 "owned by live dataset" 同名，使用不同的 pv 名字
+	pvs["without owner of live"].pv.Name += "-legacy"
+
+	objs := []client.Object{live, recreated}
+	for _, c := range pvs {
+		objs = append(objs, c.pv)
+	}
+	c := newTestClient(t, objs...)
+
+	(&orphanPVSweeper{Client: c}).sweep(ctx)
+
+	for name, want := range pvs {
+		err := c.Get(ctx, client.ObjectKeyFromObject(want.pv), &corev1.PersistentVolume{})
+		if want.deleted {
+			assert.True(t, k8serrors.IsNotFound(err), name)
+		} else {
+			assert.NoError(t, err, name)
+		}
+	}
+}
+
+func TestIsClonedPVOwner(t *testing.T) {
+	ds := &datasetv1alpha1.Dataset{ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "train", UID: "uid"}}
+
+	assert.True(t, isClonedPVOwner(testClonedPV("ml-team", "train", "uid", corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain), ds))
+	assert.False(t, isClonedPVOwner(testClonedPV("ml-team", "train", "old-uid", corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain), ds))
+	// 没有 owner 的 pv 无法区分，当作属于 ds
+	assert.True(t, isClonedPVOwner(testClonedPV("ml-team", "train", "", corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain), ds))
+}
+
+func TestDeleteReferenceVolumes(t *testing.T) {
+	ctx := context.Background()
+	deleting := func(since time.Duration) *datasetv1alpha1.Dataset {
+		ds := testReferenceDataset("ml-team", "train", "train")
+		ds.UID = "uid"
+		ds.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-since)}
+		return ds
+	}
+	pvc := func(name string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: name}}
+	}
+
+	t.Run("released", func(t *testing.T) {
+		ds := deleting(0)
+		pv := testClonedPV("ml-team", "train", "uid", corev1.VolumeReleased, corev1.PersistentVolumeReclaimRetain)
+		r := newTestReconciler(t, pvc("train"), pvc(stagingPVCName(ds)), pv)
+
+		require.NoError(t, r.deleteReferenceVolumes(ctx, ds, "train"))
+		for _, obj := range []client.Object{pvc("train"), pvc(stagingPVCName(ds)), pv} {
+			assert.True(t, k8serrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(obj), obj)), obj.GetName())
+		}
+	})
+
+	t.Run("still bound", func(t *testing.T) {
+		ds := deleting(0)
+		pv := testClonedPV("ml-team", "train", "uid", corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain)
+		r := newTestReconciler(t, pvc("train"), pv)
+
+		assert.ErrorContains(t, r.deleteReferenceVolumes(ctx, ds, "train"), "waiting for pv")
+		assert.True(t, k8serrors.IsNotFound(r.Get(ctx, client.ObjectKeyFromObject(pvc("train")), &corev1.PersistentVolumeClaim{})))
+		assert.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pv), &corev1.PersistentVolume{}))
+	})
+
+	t.Run("still bound after force delete timeout", func(t *testing.T) {
+		ds := deleting(10 * time.Minute)
+		pv := testClonedPV("ml-team", "train", "uid", corev1.VolumeBound, corev1.PersistentVolumeReclaimRetain)
+		r := newTestReconciler(t, pv)
+
+		// 留给 orphanPVSweeper 清理
+		require.NoError(t, r.deleteReferenceVolumes(ctx, ds, "train"))
+		assert.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pv), &corev1.PersistentVolume{}))
+	})
+
+	t.Run("pv of another dataset", func(t *testing.T) {
+		ds := deleting(0)
+		pv := testClonedPV("ml-team", "train", "uid", corev1.VolumeReleased, corev1.PersistentVolumeReclaimRetain)
+		pv.Labels[constants.DatasetNameLabel] = "other"
+		r := newTestReconciler(t, pv)
+
+		require.NoError(t, r.deleteReferenceVolumes(ctx, ds, "train"))
+		assert.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pv), &corev1.PersistentVolume{}))
+	})
+}
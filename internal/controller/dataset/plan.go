@@ -0,0 +1,254 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+func genPlanJobName(dsName string) string {
+	return fmt.Sprintf("dataset-%s-plan", dsName)
+}
+
+// isPlanInProgress 表示 status.plan 是否还在等待 plan job 完成
+func isPlanInProgress(ds *datasetv1alpha1.Dataset) bool {
+	if ds.Status.Plan == nil {
+		return false
+	}
+	return ds.Status.Plan.Phase == datasetv1alpha1.DatasetStatusPhasePending ||
+		ds.Status.Plan.Phase == datasetv1alpha1.DatasetStatusPhaseProcessing
+}
+
+// reconcilePlan 在 baize.io/dataset-plan annotation 变化时创建 plan job，以只读方式挂载 PVC
+// 运行 data-loader plan，完成后将各 source 的 plan 汇总到 status.plan
+func (r *DatasetReconciler) reconcilePlan(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
+	request := ds.Annotations[constants.DatasetPlanAnnotation]
+	if request == "" {
+		return nil
+	}
+	if ds.Status.Plan == nil || ds.Status.Plan.Request != request {
+		ds.Status.Plan = &datasetv1alpha1.DatasetPlan{
+			Request: request,
+			Phase:   datasetv1alpha1.DatasetStatusPhasePending,
+		}
+	}
+	plan := ds.Status.Plan
+	if !isPlanInProgress(ds) {
+		return nil
+	}
+	if !supportPreload(ds) {
+		plan.Phase = datasetv1alpha1.DatasetStatusPhaseFailed
+		plan.Message = fmt.Sprintf("plan is not supported for dataset source type %s", ds.Spec.Source.Type)
+		return nil
+	}
+
+	jobName := genPlanJobName(ds.Name)
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: ds.Namespace, Name: jobName}, job)
+	if k8serrors.IsNotFound(err) {
+		job, err = newPlanJob(ds, request)
+		if err != nil {
+			return err
+		}
+		err = r.Create(ctx, job)
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// 上一次请求的 job，删除后在之后的 reconcile 中重新创建
+	if job.Annotations[constants.DatasetPlanAnnotation] != request {
+		if job.DeletionTimestamp != nil {
+			return nil
+		}
+		datasetLogger(ds).Infof("deleting plan job %s of the previous plan request", job.Name)
+		err = r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	plan.Phase = datasetv1alpha1.DatasetStatusPhaseProcessing
+	if !isJobFinished(job) {
+		return nil
+	}
+
+	pod, err := r.getJobPod(ctx, job)
+	if err != nil {
+		return err
+	}
+	completionTime := lo.FromPtrOr(job.Status.CompletionTime, metav1.Time{Time: time.Now()})
+	ds.Status.Plan = planFromPod(ds, request, pod)
+	ds.Status.Plan.CompletionTime = &completionTime
+
+	return nil
+}
+
+// newPlanJob 基于 job 模板构造 plan job，每个 source 一个容器并行执行，plan 不会写入数据，不需要排队
+func newPlanJob(ds *datasetv1alpha1.Dataset, request string) (*batchv1.Job, error) {
+	jobSpec := batchv1.JobSpec{}
+	err := yaml.Unmarshal([]byte(config.GetDatasetJobSpecYaml()), &jobSpec)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal dataset job spec yaml failed: %w", err)
+	}
+	if len(jobSpec.Template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("dataset job spec yaml has no container")
+	}
+
+	jobSpec.BackoffLimit = lo.ToPtr(int32(0))
+	podSpec := &jobSpec.Template.Spec
+	podSpec.RestartPolicy = corev1.RestartPolicyNever
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: "dataset-pvc",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: ds.Status.PVCName,
+				ReadOnly:  true,
+			},
+		},
+	})
+
+	baseContainer := podSpec.Containers[0]
+	podSpec.Containers = lo.Map(datasetSources(ds), func(source datasetv1alpha1.DatasetSourceItem, _ int) corev1.Container {
+		container := newLoaderContainer(ds, baseContainer, source, podSpec)
+		container.Args = append([]string{"plan"}, container.Args...)
+		for i := range container.VolumeMounts {
+			if container.VolumeMounts[i].Name == "dataset-pvc" {
+				container.VolumeMounts[i].ReadOnly = true
+			}
+		}
+		// 失败时没有 plan summary，使用日志的最后一行作为失败原因
+		container.TerminationMessagePolicy = corev1.TerminationMessageFallbackToLogsOnError
+		return container
+	})
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      genPlanJobName(ds.Name),
+			Namespace: ds.Namespace,
+			Labels: lo.Assign(ds.Labels, map[string]string{
+				constants.DatasetNameLabel: ds.Name,
+			}),
+			Annotations: lo.Assign(ds.Annotations, map[string]string{
+				constants.DatasetPlanAnnotation:        request,
+				constants.DatasetSourceHostsAnnotation: strings.Join(datasetSourceHosts(ds), ","),
+			}),
+			OwnerReferences: datasetOwnerRef(ds),
+		},
+		Spec: jobSpec,
+	}, nil
+}
+
+// planFromPod 汇总 plan job pod 中各个容器写入的 plan summary
+func planFromPod(ds *datasetv1alpha1.Dataset, request string, pod *corev1.Pod) *datasetv1alpha1.DatasetPlan {
+	plan := &datasetv1alpha1.DatasetPlan{
+		Request: request,
+		Phase:   datasetv1alpha1.DatasetStatusPhaseReady,
+	}
+
+	sources := datasetSources(ds)
+	messages := make([]string, 0, len(sources))
+	for _, source := range sources {
+		var sourcePlan datasetv1alpha1.DatasetSourcePlan
+		var ok bool
+		if pod != nil {
+			sourcePlan, ok = sourcePlanFromContainer(findContainerStatus(pod, sourceContainerName(source)))
+		} else {
+			sourcePlan, ok = sourcePlanFromContainer(corev1.ContainerStatus{}, false)
+		}
+		sourcePlan.Name = source.Name
+
+		if sourcePlan.Message != "" {
+			if source.Name != "" {
+				messages = append(messages, fmt.Sprintf("%s: %s", source.Name, sourcePlan.Message))
+			} else {
+				messages = append(messages, sourcePlan.Message)
+			}
+		}
+		if !ok {
+			plan.Phase = datasetv1alpha1.DatasetStatusPhaseFailed
+		}
+		plan.TransferFiles += sourcePlan.TransferFiles
+		plan.TransferBytes += sourcePlan.TransferBytes
+		plan.DeleteFiles += sourcePlan.DeleteFiles
+		plan.DeleteBytes += sourcePlan.DeleteBytes
+		plan.SkipFiles += sourcePlan.SkipFiles
+		plan.SkipBytes += sourcePlan.SkipBytes
+
+		// 多个 source 的 plan 记录在 status.plan.sources 中
+		if isMultiSource(ds) {
+			plan.Sources = append(plan.Sources, sourcePlan)
+		} else {
+			plan.Revision = sourcePlan.Revision
+		}
+	}
+	plan.Message = strings.Join(messages, "; ")
+
+	return plan
+}
+
+// sourcePlanFromContainer derives the plan of a source from the status of the
+// finished container planning it, ok is false if planning the source failed.
+func sourcePlanFromContainer(status corev1.ContainerStatus, found bool) (sourcePlan datasetv1alpha1.DatasetSourcePlan, ok bool) {
+	switch {
+	case !found || status.State.Terminated == nil:
+		sourcePlan.Message = "data-loader plan did not finish"
+	case status.State.Terminated.ExitCode != 0:
+		sourcePlan.Message = fmt.Sprintf("data-loader plan exited with code %d: %s",
+			status.State.Terminated.ExitCode, lastLine(status.State.Terminated.Message))
+	default:
+		summary, err := parsePlanSummary(status.State.Terminated)
+		if err != nil {
+			sourcePlan.Message = fmt.Sprintf("failed to parse plan summary: %v", err)
+			break
+		}
+		ok = true
+		sourcePlan.DatasetPlanSummary = datasetv1alpha1.DatasetPlanSummary{
+			Revision:      summary.Revision,
+			TransferFiles: int64(summary.TransferFiles),
+			TransferBytes: summary.TransferBytes,
+			DeleteFiles:   int64(summary.DeleteFiles),
+			DeleteBytes:   summary.DeleteBytes,
+			SkipFiles:     int64(summary.SkipFiles),
+			SkipBytes:     summary.SkipBytes,
+		}
+		sourcePlan.Message = summary.DeleteError
+	}
+
+	return sourcePlan, ok
+}
+
+func parsePlanSummary(terminated *corev1.ContainerStateTerminated) (*datasources.PlanSummary, error) {
+	summary := &datasources.PlanSummary{}
+	err := json.Unmarshal([]byte(strings.TrimSpace(terminated.Message)), summary)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+func lastLine(message string) string {
+	lines := strings.Split(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,167 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func newPlanDataset(request string) *datasetv1alpha1.Dataset {
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source:       datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeS3, URI: "s3://corpus/v1"},
+			MountOptions: datasetv1alpha1.MountOptions{Path: "/data"},
+		},
+		Status: datasetv1alpha1.DatasetStatus{PVCName: "dataset-corpus"},
+	}
+	if request != "" {
+		ds.Annotations = map[string]string{constants.DatasetPlanAnnotation: request}
+	}
+	return ds
+}
+
+func finishPlanJob(t *testing.T, r *DatasetReconciler, job *batchv1.Job, statuses ...corev1.ContainerStatus) {
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	job.Status.CompletionTime = &metav1.Time{Time: metav1.Now().Rfc3339Copy().Time}
+	require.NoError(t, r.Status().Update(context.Background(), job))
+	require.NoError(t, r.Create(context.Background(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: job.Namespace,
+			Name:      job.Name + "-x7k2p",
+			Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+		},
+		Status: corev1.PodStatus{ContainerStatuses: statuses},
+	}))
+}
+
+func TestReconcilePlan(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("no request", func(t *testing.T) {
+		ds := newPlanDataset("")
+		r := newTestReconciler(t, ds)
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		assert.Nil(t, ds.Status.Plan)
+
+		err := r.Get(ctx, client.ObjectKey{Namespace: "ml-team", Name: genPlanJobName("corpus")}, &batchv1.Job{})
+		assert.True(t, k8serrors.IsNotFound(err))
+	})
+
+	t.Run("request creates a read-only plan job and reads the result", func(t *testing.T) {
+		ds := newPlanDataset("1")
+		r := newTestReconciler(t, ds)
+
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhasePending, ds.Status.Plan.Phase)
+		assert.True(t, isPlanInProgress(ds))
+
+		job := &batchv1.Job{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ml-team", Name: genPlanJobName("corpus")}, job))
+		assert.Equal(t, "1", job.Annotations[constants.DatasetPlanAnnotation])
+		podSpec := job.Spec.Template.Spec
+		require.Len(t, podSpec.Containers, 1)
+		assert.Equal(t, "plan", podSpec.Containers[0].Args[0])
+		pvcVolume, ok := lo.Find(podSpec.Volumes, func(v corev1.Volume) bool { return v.Name == "dataset-pvc" })
+		require.True(t, ok)
+		assert.True(t, pvcVolume.PersistentVolumeClaim.ReadOnly)
+		assert.True(t, lo.EveryBy(podSpec.Containers[0].VolumeMounts, func(m corev1.VolumeMount) bool {
+			return m.Name != "dataset-pvc" || m.ReadOnly
+		}))
+
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseProcessing, ds.Status.Plan.Phase)
+
+		finishPlanJob(t, r, job, corev1.ContainerStatus{
+			Name: constants.DatasetJobContainerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				Message: `{"revision":"v1","transferFiles":2,"transferBytes":2048,"deleteFiles":1,"deleteBytes":10,"skipFiles":3,"skipBytes":30}`,
+			}},
+		})
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		plan := ds.Status.Plan
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseReady, plan.Phase)
+		assert.Equal(t, "1", plan.Request)
+		assert.Equal(t, "v1", plan.Revision)
+		assert.Equal(t, datasetv1alpha1.DatasetPlanSummary{
+			Revision: "v1", TransferFiles: 2, TransferBytes: 2048, DeleteFiles: 1, DeleteBytes: 10, SkipFiles: 3, SkipBytes: 30,
+		}, plan.DatasetPlanSummary)
+		assert.Equal(t, job.Status.CompletionTime, plan.CompletionTime)
+		assert.False(t, isPlanInProgress(ds))
+	})
+
+	t.Run("failed plan", func(t *testing.T) {
+		ds := newPlanDataset("1")
+		r := newTestReconciler(t, ds)
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		job := &batchv1.Job{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ml-team", Name: genPlanJobName("corpus")}, job))
+
+		finishPlanJob(t, r, job, corev1.ContainerStatus{
+			Name: constants.DatasetJobContainerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				ExitCode: 1,
+				Message:  "listing s3://corpus/v1\nAccessDenied: access denied\n",
+			}},
+		})
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseFailed, ds.Status.Plan.Phase)
+		assert.Equal(t, "data-loader plan exited with code 1: AccessDenied: access denied", ds.Status.Plan.Message)
+	})
+
+	t.Run("new request replaces the previous plan", func(t *testing.T) {
+		ds := newPlanDataset("2")
+		ds.Status.Plan = &datasetv1alpha1.DatasetPlan{Request: "1", Phase: datasetv1alpha1.DatasetStatusPhaseReady}
+		previous, err := newPlanJob(newPlanDataset("1"), "1")
+		require.NoError(t, err)
+		r := newTestReconciler(t, ds, previous)
+
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		assert.Equal(t, &datasetv1alpha1.DatasetPlan{Request: "2", Phase: datasetv1alpha1.DatasetStatusPhasePending}, ds.Status.Plan)
+		err = r.Get(ctx, client.ObjectKeyFromObject(previous), &batchv1.Job{})
+		assert.True(t, k8serrors.IsNotFound(err), "the job of the previous request is deleted")
+
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		job := &batchv1.Job{}
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(previous), job))
+		assert.Equal(t, "2", job.Annotations[constants.DatasetPlanAnnotation])
+	})
+
+	t.Run("unsupported source", func(t *testing.T) {
+		ds := newPlanDataset("1")
+		ds.Spec.Source = datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypePVC, URI: "pvc://data"}
+		r := newTestReconciler(t, ds)
+		require.NoError(t, r.reconcilePlan(ctx, ds))
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseFailed, ds.Status.Plan.Phase)
+		assert.Equal(t, "plan is not supported for dataset source type PVC", ds.Status.Plan.Message)
+	})
+}
+
+func TestPlanFromPodMultiSource(t *testing.T) {
+	ds := newMultiSourceDataset(datasetv1alpha1.DatasetSourcesPolicyParallel)
+	pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+		{Name: "dataset-loader-code", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: `{"revision":"8f2c1e0","transferFiles":1,"transferBytes":100}`}}},
+		{Name: "dataset-loader-weights", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: `{"transferFiles":2,"transferBytes":200,"deleteError":"too many deletions"}`}}},
+		{Name: "dataset-loader-docs", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+	}}}
+
+	plan := planFromPod(ds, "1", pod)
+	assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseFailed, plan.Phase)
+	assert.Equal(t, int64(3), plan.TransferFiles)
+	assert.Equal(t, int64(300), plan.TransferBytes)
+	assert.Empty(t, plan.Revision)
+	require.Len(t, plan.Sources, 3)
+	assert.Equal(t, "8f2c1e0", plan.Sources[0].Revision)
+	assert.Equal(t, "weights: too many deletions; docs: data-loader plan did not finish", plan.Message)
+}
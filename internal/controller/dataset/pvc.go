@@ -0,0 +1,204 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+// pvcOptionReadOnly 为 true 时只读绑定 PVC，不修改用户的 PVC，绑定关系记录在 dataset 的 annotation 上
+const pvcOptionReadOnly = "readOnly"
+
+func pvcReadOnly(ds *datasetv1alpha1.Dataset) bool {
+	readOnly, _ := strconv.ParseBool(ds.Spec.Source.Options[pvcOptionReadOnly])
+	return readOnly
+}
+
+// pvcOptionNamespaced 为 true 时 uri 为 pvc://<namespace>/<name>/<path/to/directory>，可以使用其他 namespace 中的 PVC，
+// 前提是该 PVC 中的 dataset 共享给了当前 namespace 或者有授权的 DatasetShareGrant，与 REFERENCE dataset 一样绑定克隆的 pv
+const pvcOptionNamespaced = "namespaced"
+
+func pvcNamespaced(ds *datasetv1alpha1.Dataset) bool {
+	namespaced, _ := strconv.ParseBool(ds.Spec.Source.Options[pvcOptionNamespaced])
+	return namespaced
+}
+
+// pvcSource 返回 pvc://<name>/<path/to/directory> 或 pvc://<namespace>/<name>/<path/to/directory> 中 PVC 所在的 namespace、
+// 名字和子目录
+func pvcSource(ds *datasetv1alpha1.Dataset) (namespace, name, subPath string, err error) {
+	u, err := url.Parse(ds.Spec.Source.URI)
+	if err != nil {
+		return "", "", "", err
+	}
+	subPath = strings.TrimPrefix(path.Clean("/"+u.Path), "/")
+	if !pvcNamespaced(ds) {
+		return ds.Namespace, u.Host, subPath, nil
+	}
+	name, subPath, _ = strings.Cut(subPath, "/")
+	return u.Host, name, subPath, nil
+}
+
+// crossNamespacePVC 表示 PVC dataset 是否使用其他 namespace 中的 PVC
+func crossNamespacePVC(ds *datasetv1alpha1.Dataset) bool {
+	if ds.Spec.Source.Type != datasetv1alpha1.DatasetTypePVC || !pvcNamespaced(ds) {
+		return false
+	}
+	namespace, _, _, err := pvcSource(ds)
+	return err == nil && namespace != ds.Namespace
+}
+
+// bindsClonedVolume 表示 dataset 的 pvc 是否绑定克隆自其他 dataset 的 pv
+func bindsClonedVolume(ds *datasetv1alpha1.Dataset) bool {
+	return ds.Spec.Source.Type == datasetv1alpha1.DatasetTypeReference || crossNamespacePVC(ds)
+}
+
+// pvcSourceIndexKey 返回其他 namespace 中的 PVC 在 referenceSourceIndex 中的 key
+func pvcSourceIndexKey(namespace, name string) string {
+	return fmt.Sprintf("pvc://%s/%s", namespace, name)
+}
+
+// isWithinSubPath 表示 subPath 是否是 parent 或者其中的子目录，parent 为空表示 pvc 的根目录
+func isWithinSubPath(subPath, parent string) bool {
+	return parent == "" || subPath == parent || strings.HasPrefix(subPath, parent+"/")
+}
+
+// datasetsOfPVC 返回 namespace 中数据保存在名为 pvcName 的 pvc 中的 dataset，不包括绑定克隆 pv 的 dataset
+func (r *DatasetReconciler) datasetsOfPVC(ctx context.Context, namespace, pvcName string) ([]datasetv1alpha1.Dataset, error) {
+	dsList := &datasetv1alpha1.DatasetList{}
+	if err := r.List(ctx, dsList, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	datasets := lo.Filter(dsList.Items, func(ds datasetv1alpha1.Dataset, _ int) bool {
+		return ds.Status.PVCName == pvcName && !bindsClonedVolume(&ds)
+	})
+	sort.Slice(datasets, func(i, j int) bool {
+		return datasets[i].Name < datasets[j].Name
+	})
+	return datasets, nil
+}
+
+// pvcSourceDataset 返回其他 namespace 中的 PVC 里包含 uri 子目录的 dataset，优先返回允许当前 namespace 访问的 dataset，
+// 由其共享设置和 DatasetShareGrant 决定能否访问该 PVC，没有时返回 nil
+func (r *DatasetReconciler) pvcSourceDataset(ctx context.Context, ds *datasetv1alpha1.Dataset) (*datasetv1alpha1.Dataset, error) {
+	namespace, name, subPath, err := pvcSource(ds)
+	if err != nil {
+		return nil, err
+	}
+	datasets, err := r.datasetsOfPVC(ctx, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("list datasets of pvc %s/%s error: %v", namespace, name, err)
+	}
+	datasets = lo.Filter(datasets, func(sourceDs datasetv1alpha1.Dataset, _ int) bool {
+		return isWithinSubPath(subPath, sourceDs.Status.SubPath)
+	})
+	if len(datasets) == 0 {
+		return nil, nil
+	}
+	for i := range datasets {
+		if r.checkReferenceAccess(ctx, ds, &datasets[i]) == nil {
+			return &datasets[i], nil
+		}
+	}
+	return &datasets[0], nil
+}
+
+// checkPVCAccess 检查是否可以访问其他 namespace 中的 PVC，返回授权访问的 dataset
+func (r *DatasetReconciler) checkPVCAccess(ctx context.Context, ds *datasetv1alpha1.Dataset) (*datasetv1alpha1.Dataset, error) {
+	sourceDs, err := r.pvcSourceDataset(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+	if sourceDs == nil {
+		return nil, fmt.Errorf("%s is not in any dataset of its namespace", ds.Spec.Source.URI)
+	}
+	if err := r.checkReferenceAccess(ctx, ds, sourceDs); err != nil {
+		return nil, err
+	}
+	return sourceDs, nil
+}
+
+// validatePVCSource 校验 PVC dataset 的 uri 和 options
+func validatePVCSource(ds *datasetv1alpha1.Dataset) error {
+	u, err := url.Parse(ds.Spec.Source.URI)
+	if err != nil {
+		return err
+	}
+	if lo.Contains(strings.Split(u.Path, "/"), "..") {
+		return fmt.Errorf("path %s of uri must not contain ..", u.Path)
+	}
+	if v, ok := ds.Spec.Source.Options[pvcOptionNamespaced]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid option %s=%s: %v", pvcOptionNamespaced, v, err)
+		}
+	}
+	_, name, _, err := pvcSource(ds)
+	if err != nil {
+		return err
+	}
+	if pvcNamespaced(ds) && (u.Host == "" || name == "") {
+		return fmt.Errorf("invalid uri %s, must be pvc://<namespace>/<name>/<path/to/directory>", ds.Spec.Source.URI)
+	}
+	if name == "" {
+		return fmt.Errorf("invalid uri %s, must be pvc://<name>/<path/to/directory>", ds.Spec.Source.URI)
+	}
+	if v, ok := ds.Spec.Source.Options[pvcOptionReadOnly]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid option %s=%s: %v", pvcOptionReadOnly, v, err)
+		}
+	}
+	return nil
+}
+
+// bindPVC 检查用户的 PVC 已经 Bound，并记录 dataset 与 PVC 的绑定关系：
+// 默认在 PVC 上添加 dataset label，只读绑定时只在 dataset 上记录 PVC 的 UID
+func (r *DatasetReconciler) bindPVC(ctx context.Context, ds *datasetv1alpha1.Dataset, pvc *corev1.PersistentVolumeClaim) error {
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return fmt.Errorf("pvc %s is %s, not Bound", pvc.Name, lo.CoalesceOrEmpty(string(pvc.Status.Phase), "not bound"))
+	}
+
+	if !pvcReadOnly(ds) {
+		if dsName, exists := pvc.Labels[constants.DatasetNameLabel]; exists && dsName != ds.Name {
+			return fmt.Errorf("pvc %s is not belong to dataset %s/%s", pvc.Name, ds.Namespace, ds.Name)
+		} else if !exists {
+			if pvc.Labels == nil {
+				pvc.Labels = make(map[string]string)
+			}
+			pvc.Labels[constants.DatasetNameLabel] = ds.Name
+			if err := r.Update(ctx, pvc); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if ds.Annotations[constants.DatasetBoundPVCAnnotation] == string(pvc.UID) {
+		return nil
+	}
+	if v := ds.Annotations[constants.DatasetBoundPVCAnnotation]; v != "" {
+		datasetLogger(ds).Warnf("pvc %s has been recreated, was %s and is now %s", pvc.Name, v, pvc.UID)
+	}
+	// 使用单独的对象 patch，避免覆盖 ds 上还未写回的 status
+	orig := ds.DeepCopy()
+	modified := ds.DeepCopy()
+	modified.Annotations = lo.Assign(modified.Annotations, map[string]string{
+		constants.DatasetBoundPVCAnnotation: string(pvc.UID),
+	})
+	if err := r.Patch(ctx, modified, client.MergeFrom(orig)); err != nil {
+		return err
+	}
+	ds.Annotations = modified.Annotations
+	ds.ResourceVersion = modified.ResourceVersion
+
+	return nil
+}
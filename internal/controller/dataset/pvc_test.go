@@ -0,0 +1,201 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func testPVCDataset(namespace, name, uri string, options map[string]string) *datasetv1alpha1.Dataset {
+	return &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{
+				Type:    datasetv1alpha1.DatasetTypePVC,
+				URI:     uri,
+				Options: options,
+			},
+		},
+	}
+}
+
+func TestPVCSource(t *testing.T) {
+	testCases := []struct {
+		uri       string
+		options   map[string]string
+		namespace string
+		name      string
+		subPath   string
+		cross     bool
+		invalid   bool
+	}{
+		{uri: "pvc://data", namespace: "ml-team", name: "data"},
+		{uri: "pvc://data/train/", namespace: "ml-team", name: "data", subPath: "train"},
+		{uri: "pvc://data-team/data/train", namespace: "ml-team", name: "data-team", subPath: "data/train"},
+		{uri: "pvc://data-team/data/train", options: map[string]string{"namespaced": "true"}, namespace: "data-team", name: "data", subPath: "train", cross: true},
+		{uri: "pvc://data-team/data", options: map[string]string{"namespaced": "true"}, namespace: "data-team", name: "data", cross: true},
+		{uri: "pvc://ml-team/data/train", options: map[string]string{"namespaced": "true"}, namespace: "ml-team", name: "data", subPath: "train"},
+		{uri: "pvc://data-team", options: map[string]string{"namespaced": "true"}, namespace: "data-team", cross: true, invalid: true},
+		{uri: "pvc://data/../train", namespace: "ml-team", name: "data", subPath: "train", invalid: true},
+		{uri: "pvc://data", options: map[string]string{"namespaced": "yes"}, namespace: "ml-team", name: "data", invalid: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.uri, func(t *testing.T) {
+			ds := testPVCDataset("ml-team", "train", tc.uri, tc.options)
+			namespace, name, subPath, err := pvcSource(ds)
+			require.NoError(t, err)
+			assert.Equal(t, tc.namespace, namespace)
+			assert.Equal(t, tc.name, name)
+			assert.Equal(t, tc.subPath, subPath)
+			assert.Equal(t, tc.cross, crossNamespacePVC(ds))
+			assert.Equal(t, tc.cross, bindsClonedVolume(ds))
+			assert.Equal(t, tc.invalid, validatePVCSource(ds) != nil)
+		})
+	}
+}
+
+func TestIsWithinSubPath(t *testing.T) {
+	assert.True(t, isWithinSubPath("", ""))
+	assert.True(t, isWithinSubPath("train", ""))
+	assert.True(t, isWithinSubPath("train", "train"))
+	assert.True(t, isWithinSubPath("train/a", "train"))
+	assert.False(t, isWithinSubPath("trainer", "train"))
+	assert.False(t, isWithinSubPath("", "train"))
+	assert.False(t, isWithinSubPath("eval", "train"))
+}
+
+func TestBindPVCReadOnly(t *testing.T) {
+	ctx := context.Background()
+	ds := testPVCDataset("ml-team", "train", "pvc://data/train", map[string]string{"readOnly": "true"})
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "ml-team", UID: types.UID("uid-1")},
+	}
+	r := newTestReconciler(t, ds, pvc)
+
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(ds), ds))
+	assert.ErrorContains(t, r.reconcilePVC(ctx, ds), "not Bound")
+
+	pvc.Status.Phase = corev1.ClaimBound
+	require.NoError(t, r.Status().Update(ctx, pvc))
+	require.NoError(t, r.reconcilePVC(ctx, ds))
+	assert.Equal(t, "data", ds.Status.PVCName)
+	assert.Equal(t, "train", ds.Status.SubPath)
+	assert.True(t, ds.Status.ReadOnly)
+	assert.Equal(t, "uid-1", ds.Annotations[constants.DatasetBoundPVCAnnotation])
+
+	// the pvc of the user is left untouched
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(pvc), pvc))
+	assert.NotContains(t, pvc.Labels, constants.DatasetNameLabel)
+	stored := &datasetv1alpha1.Dataset{}
+	require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(ds), stored))
+	assert.Equal(t, "uid-1", stored.Annotations[constants.DatasetBoundPVCAnnotation])
+}
+
+func TestCrossNamespacePVC(t *testing.T) {
+	ctx := context.Background()
+
+	newObjects := func(share bool) []client.Object {
+		sourceDs := &datasetv1alpha1.Dataset{
+			ObjectMeta: metav1.ObjectMeta{Name: "corpus", Namespace: "data-team"},
+			Spec: datasetv1alpha1.DatasetSpec{
+				Share: share,
+				Source: datasetv1alpha1.DatasetSource{
+					Type: datasetv1alpha1.DatasetTypePVC,
+					URI:  "pvc://data/corpus",
+				},
+			},
+			Status: datasetv1alpha1.DatasetStatus{PVCName: "data", SubPath: "corpus"},
+		}
+		sourcePVC := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "data-team"},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				VolumeName:  "pv-data",
+			},
+		}
+		sourcePV := &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+				ClaimRef:                      &corev1.ObjectReference{Namespace: "data-team", Name: "data"},
+			},
+		}
+		return []client.Object{sourceDs, sourcePVC, sourcePV}
+	}
+
+	t.Run("bind clone of pv", func(t *testing.T) {
+		ds := testPVCDataset("ml-team", "train", "pvc://data-team/data/corpus/en", map[string]string{"namespaced": "true"})
+		r := newTestReconciler(t, append(newObjects(true), ds)...)
+
+		require.NoError(t, r.validate(ctx, ds))
+		require.NoError(t, r.reconcilePVC(ctx, ds))
+		assert.Equal(t, "train", ds.Status.PVCName)
+		assert.Equal(t, "corpus/en", ds.Status.SubPath)
+		assert.True(t, ds.Status.ReadOnly)
+
+		pvc := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ml-team", Name: "train"}, pvc))
+		assert.Equal(t, clonedPVName(ds), pvc.Spec.VolumeName)
+		pv := &corev1.PersistentVolume{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Name: clonedPVName(ds)}, pv))
+		assert.Equal(t, corev1.PersistentVolumeReclaimRetain, pv.Spec.PersistentVolumeReclaimPolicy)
+		assert.Nil(t, pv.Spec.ClaimRef)
+
+		// the dataset holding the pvc tracks it as a consumer
+		sourceDs := &datasetv1alpha1.Dataset{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "data-team", Name: "corpus"}, sourceDs))
+		stored := &datasetv1alpha1.Dataset{}
+		require.NoError(t, r.Get(ctx, client.ObjectKeyFromObject(ds), stored))
+		stored.Status = ds.Status
+		require.NoError(t, r.Status().Update(ctx, stored))
+		require.NoError(t, r.reconcileConsumers(ctx, sourceDs))
+		assert.Equal(t, []datasetv1alpha1.DatasetReference{{Namespace: "ml-team", Name: "train"}}, sourceDs.Status.Consumers)
+		assert.Equal(t, datasetRequests([]datasetv1alpha1.Dataset{*sourceDs}), r.sourcesOfReference(ctx, ds))
+		assert.Len(t, r.referencesOfDataset(ctx, sourceDs), 1)
+	})
+
+	t.Run("not shared", func(t *testing.T) {
+		ds := testPVCDataset("ml-team", "train", "pvc://data-team/data/corpus", map[string]string{"namespaced": "true"})
+		r := newTestReconciler(t, append(newObjects(false), ds)...)
+
+		assert.ErrorContains(t, r.validate(ctx, ds), "not shared")
+		assert.Error(t, r.reconcilePVC(ctx, ds))
+		assert.Empty(t, ds.Status.PVCName)
+	})
+
+	t.Run("granted", func(t *testing.T) {
+		ds := testPVCDataset("ml-team", "train", "pvc://data-team/data/corpus", map[string]string{"namespaced": "true"})
+		grant := &datasetv1alpha1.DatasetShareGrant{
+			ObjectMeta: metav1.ObjectMeta{Name: "ml-team", Namespace: "data-team"},
+			Spec: datasetv1alpha1.DatasetShareGrantSpec{
+				Dataset:         "corpus",
+				Namespace:       "ml-team",
+				ServiceAccounts: []string{"trainer"},
+			},
+		}
+		r := newTestReconciler(t, append(newObjects(false), ds, grant)...)
+
+		require.NoError(t, r.validate(ctx, ds))
+		require.NoError(t, r.reconcilePVC(ctx, ds))
+		pvc := &corev1.PersistentVolumeClaim{}
+		require.NoError(t, r.Get(ctx, client.ObjectKey{Namespace: "ml-team", Name: "train"}, pvc))
+		assert.Equal(t, "trainer", pvc.Annotations[constants.DatasetServiceAccountsAnnotation])
+	})
+
+	t.Run("outside of the shared dataset", func(t *testing.T) {
+		ds := testPVCDataset("ml-team", "train", "pvc://data-team/data/private", map[string]string{"namespaced": "true"})
+		r := newTestReconciler(t, append(newObjects(true), ds)...)
+
+		assert.ErrorContains(t, r.validate(ctx, ds), "is not in any dataset")
+		assert.Error(t, r.reconcilePVC(ctx, ds))
+	})
+}
@@ -0,0 +1,104 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+// defaultS3Region 为没有指定 region 时 data-loader 使用的 region
+const defaultS3Region = "us-east-1"
+
+// sourceHost 返回 source 实际访问的 host，用于按 host 限制并发的 job 数量
+func sourceHost(source datasetv1alpha1.DatasetSourceItem) string {
+	if endpoint := source.Options["endpoint"]; endpoint != "" {
+		if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+			return u.Host
+		}
+	}
+	switch source.Type {
+	case datasetv1alpha1.DatasetTypeHuggingFace:
+		return "huggingface.co"
+	case datasetv1alpha1.DatasetTypeModelScope:
+		return "www.modelscope.cn"
+	case datasetv1alpha1.DatasetTypeS3:
+		// 与 rclone 一样使用 virtual-hosted 风格的 endpoint，不同 region 和 bucket 的请求互不影响
+		u, err := url.Parse(source.URI)
+		if err != nil || u.Host == "" {
+			return ""
+		}
+		region := lo.CoalesceOrEmpty(source.Options["region"], defaultS3Region)
+		return fmt.Sprintf("%s.s3.%s.amazonaws.com", u.Host, region)
+	}
+	u, err := url.Parse(source.URI)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func datasetSourceHosts(ds *datasetv1alpha1.Dataset) []string {
+	hosts := lo.Uniq(lo.Compact(lo.Map(datasetSources(ds), func(source datasetv1alpha1.DatasetSourceItem, _ int) string {
+		return sourceHost(source)
+	})))
+	sort.Strings(hosts)
+	return hosts
+}
+
+func isJobFinished(job *batchv1.Job) bool {
+	return lo.ContainsBy(job.Status.Conditions, func(item batchv1.JobCondition) bool {
+		return (item.Type == batchv1.JobComplete || item.Type == batchv1.JobFailed) && item.Status == corev1.ConditionTrue
+	})
+}
+
+// queueReason 返回当前 round 需要排队的原因，为空表示可以创建 job。
+// 缓存中可能还没有刚创建的 job，因此直接从 apiserver 列出 job，dataset 由单个 worker 串行 reconcile，
+// 不会有两个 reconcile 同时通过检查后各自创建 job
+func (r *DatasetReconciler) queueReason(ctx context.Context, ds *datasetv1alpha1.Dataset) (string, error) {
+	maxPerNamespace := config.GetMaxConcurrentJobsPerNamespace()
+	maxPerSourceHost := config.GetMaxConcurrentJobsPerSourceHost()
+	if maxPerNamespace <= 0 && maxPerSourceHost <= 0 {
+		return "", nil
+	}
+
+	jobList := &batchv1.JobList{}
+	if err := r.apiReader().List(ctx, jobList, client.HasLabels{constants.DatasetNameLabel}); err != nil {
+		return "", err
+	}
+	activeJobs := lo.Filter(jobList.Items, func(job batchv1.Job, _ int) bool {
+		return !isJobFinished(&job)
+	})
+
+	if maxPerNamespace > 0 {
+		count := lo.CountBy(activeJobs, func(job batchv1.Job) bool {
+			return job.Namespace == ds.Namespace
+		})
+		if count >= maxPerNamespace {
+			return fmt.Sprintf("%d sync jobs are running in namespace %s, the limit is %d", count, ds.Namespace, maxPerNamespace), nil
+		}
+	}
+
+	if maxPerSourceHost > 0 {
+		for _, host := range datasetSourceHosts(ds) {
+			count := lo.CountBy(activeJobs, func(job batchv1.Job) bool {
+				return lo.Contains(strings.Split(job.Annotations[constants.DatasetSourceHostsAnnotation], ","), host)
+			})
+			if count >= maxPerSourceHost {
+				return fmt.Sprintf("%d sync jobs are running against %s, the limit is %d", count, host, maxPerSourceHost), nil
+			}
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,124 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+func TestSourceHost(t *testing.T) {
+	cases := []struct {
+		name    string
+		typ     datasetv1alpha1.DatasetType
+		uri     string
+		options map[string]string
+		want    string
+	}{
+		{name: "http", typ: datasetv1alpha1.DatasetTypeHTTP, uri: "https://example.com/corpus/", want: "example.com"},
+		{name: "git", typ: datasetv1alpha1.DatasetTypeGit, uri: "https://github.com/BaizeAI/dataset.git", want: "github.com"},
+		{name: "huggingface", typ: datasetv1alpha1.DatasetTypeHuggingFace, uri: "huggingface://openai/gsm8k", want: "huggingface.co"},
+		{name: "huggingface mirror", typ: datasetv1alpha1.DatasetTypeHuggingFace, uri: "huggingface://openai/gsm8k", options: map[string]string{"endpoint": "https://hf-mirror.com"}, want: "hf-mirror.com"},
+		{name: "modelscope", typ: datasetv1alpha1.DatasetTypeModelScope, uri: "modelscope://qwen/Qwen2-7B", want: "www.modelscope.cn"},
+		{name: "s3", typ: datasetv1alpha1.DatasetTypeS3, uri: "s3://corpus/v1", options: map[string]string{"region": "eu-west-1"}, want: "corpus.s3.eu-west-1.amazonaws.com"},
+		{name: "s3 default region", typ: datasetv1alpha1.DatasetTypeS3, uri: "s3://corpus/v1", want: "corpus.s3.us-east-1.amazonaws.com"},
+		{name: "s3 endpoint", typ: datasetv1alpha1.DatasetTypeS3, uri: "s3://corpus/v1", options: map[string]string{"endpoint": "http://minio.storage:9000", "region": "eu-west-1"}, want: "minio.storage:9000"},
+		{name: "invalid endpoint", typ: datasetv1alpha1.DatasetTypeHTTP, uri: "https://example.com/corpus/", options: map[string]string{"endpoint": "minio"}, want: "example.com"},
+		{name: "invalid uri", typ: datasetv1alpha1.DatasetTypeHTTP, uri: "://", want: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source := datasetv1alpha1.DatasetSourceItem{
+				DatasetSource: datasetv1alpha1.DatasetSource{Type: c.typ, URI: c.uri, Options: c.options},
+			}
+			assert.Equal(t, c.want, sourceHost(source))
+		})
+	}
+}
+
+func testRunningJob(namespace, dsName string, hosts string, finished bool) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        genJobName(dsName, 1),
+			Labels:      map[string]string{constants.DatasetNameLabel: dsName},
+			Annotations: map[string]string{constants.DatasetSourceHostsAnnotation: hosts},
+		},
+	}
+	if finished {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	}
+	return job
+}
+
+func TestQueueReason(t *testing.T) {
+	ctx := context.Background()
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ml-team", Name: "corpus"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeHTTP, URI: "https://example.com/corpus/"},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		config string
+		jobs   []*batchv1.Job
+		want   string
+	}{
+		{name: "unlimited", config: "{}", jobs: []*batchv1.Job{testRunningJob("ml-team", "a", "example.com", false)}},
+		{
+			name:   "namespace limit",
+			config: "max_concurrent_jobs_per_namespace: 2\n",
+			jobs: []*batchv1.Job{
+				testRunningJob("ml-team", "a", "github.com", false),
+				testRunningJob("ml-team", "b", "github.com", false),
+			},
+			want: "2 sync jobs are running in namespace ml-team, the limit is 2",
+		},
+		{
+			name:   "under namespace limit",
+			config: "max_concurrent_jobs_per_namespace: 2\n",
+			jobs: []*batchv1.Job{
+				testRunningJob("ml-team", "a", "github.com", false),
+				testRunningJob("ml-team", "b", "github.com", true),
+				testRunningJob("cv-team", "c", "github.com", false),
+			},
+		},
+		{
+			name:   "source host limit",
+			config: "max_concurrent_jobs_per_source_host: 1\n",
+			jobs:   []*batchv1.Job{testRunningJob("cv-team", "a", "github.com,example.com", false)},
+			want:   "1 sync jobs are running against example.com, the limit is 1",
+		},
+		{
+			name:   "other source host",
+			config: "max_concurrent_jobs_per_source_host: 1\n",
+			jobs:   []*batchv1.Job{testRunningJob("cv-team", "a", "example.com.cn", false)},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			setTestConfig(t, c.config)
+			objs := make([]client.Object, 0, len(c.jobs))
+			for _, job := range c.jobs {
+				objs = append(objs, job)
+			}
+			// 缓存中还没有这些 job，只有 apiserver 中有
+			r := newTestReconciler(t)
+			r.APIReader = newTestClient(t, objs...)
+
+			reason, err := r.queueReason(ctx, ds)
+			require.NoError(t, err)
+			assert.Equal(t, c.want, reason)
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package dataset
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/pkg/log"
+)
+
+const (
+	// referenceSourceIndex 为 REFERENCE dataset 按其 source dataset 的 namespace/name 建立的索引
+	referenceSourceIndex = "spec.source.referenceSource"
+
+	// condTypeAccessRevoked 表示 source dataset 已不再共享给该 REFERENCE dataset
+	condTypeAccessRevoked = "AccessRevoked"
+
+	reasonAccessRevoked = "SourceNotShared"
+	reasonVolumeDeleted = "VolumeDeleted"
+)
+
+// referenceOptionWaitSourceReady 为 true 时，在 source dataset READY 之前不创建 REFERENCE dataset 的 pvc
+const referenceOptionWaitSourceReady = "waitSourceReady"
+
+func waitSourceReady(ds *datasetv1alpha1.Dataset) bool {
+	return ds.Spec.Source.Options[referenceOptionWaitSourceReady] == "true"
+}
+
+// mirrorSourceStatus 将 source dataset 的同步状态和大小同步到 REFERENCE dataset
+func mirrorSourceStatus(ds *datasetv1alpha1.Dataset, sourceDs *datasetv1alpha1.Dataset) {
+	ds.Status.SourceRound = sourceDs.Status.LastSucceedRound
+	ds.Status.Revision = sourceDs.Status.Revision
+	ds.Status.LastSyncTime = sourceDs.Status.LastSyncTime
+	ds.Status.Size = sourceDs.Status.Size
+	ds.Status.FileCount = sourceDs.Status.FileCount
+	ds.Status.LargestFiles = sourceDs.Status.LargestFiles
+}
+
+// referencePhase 返回 REFERENCE dataset 的 phase，与 source dataset 一致，source dataset 不存在或者还没有 phase 时为 PENDING
+func (r *DatasetReconciler) referencePhase(ctx context.Context, ds *datasetv1alpha1.Dataset) datasetv1alpha1.DatasetStatusPhase {
+	sourceDs, err := r.getSourceDataset(ctx, ds)
+	if err != nil || sourceDs.Status.Phase == "" {
+		return datasetv1alpha1.DatasetStatusPhasePending
+	}
+	if ds.Status.PVCName == "" && sourceDs.Status.Phase == datasetv1alpha1.DatasetStatusPhaseReady {
+		return datasetv1alpha1.DatasetStatusPhasePending
+	}
+	return sourceDs.Status.Phase
+}
+
+// sourceStatusChanged 在 source dataset 的同步状态变化时重新 reconcile REFERENCE dataset
+var sourceStatusChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldDs, ok := e.ObjectOld.(*datasetv1alpha1.Dataset)
+		if !ok {
+			return false
+		}
+		newDs, ok := e.ObjectNew.(*datasetv1alpha1.Dataset)
+		if !ok {
+			return false
+		}
+		return oldDs.Status.Phase != newDs.Status.Phase ||
+			oldDs.Status.LastSucceedRound != newDs.Status.LastSucceedRound ||
+			oldDs.Status.Revision != newDs.Status.Revision ||
+			!oldDs.Status.LastSyncTime.Equal(&newDs.Status.LastSyncTime)
+	},
+}
+
+// referenceSource 返回 REFERENCE dataset 的 source dataset，uri 格式为 dataset://<namespace>/<name>
+func referenceSource(ds *datasetv1alpha1.Dataset) (client.ObjectKey, error) {
+	u, err := url.Parse(ds.Spec.Source.URI)
+	if err != nil {
+		return client.ObjectKey{}, err
+	}
+	return client.ObjectKey{Namespace: u.Host, Name: strings.Trim(u.Path, "/")}, nil
+}
+
+// clonedPVName 返回为 REFERENCE dataset 克隆的 pv 的名字
+func clonedPVName(ds *datasetv1alpha1.Dataset) string {
+	return fmt.Sprintf("dataset-%s-pvc-%s", ds.Namespace, ds.Name)
+}
+
+// indexReferenceSource 按 source dataset 的 namespace/name 索引 REFERENCE dataset，按 pvc://<namespace>/<name>
+// 索引使用其他 namespace 中 PVC 的 PVC dataset
+func indexReferenceSource(obj client.Object) []string {
+	ds, ok := obj.(*datasetv1alpha1.Dataset)
+	if !ok {
+		return nil
+	}
+	if crossNamespacePVC(ds) {
+		namespace, name, _, _ := pvcSource(ds)
+		return []string{pvcSourceIndexKey(namespace, name)}
+	}
+	if ds.Spec.Source.Type != datasetv1alpha1.DatasetTypeReference {
+		return nil
+	}
+	key, err := referenceSource(ds)
+	if err != nil {
+		return nil
+	}
+	return []string{key.String()}
+}
+
+// listReferences 返回引用 source dataset 的 REFERENCE dataset 以及使用其 PVC 的其他 namespace 中的 PVC dataset
+func (r *DatasetReconciler) listReferences(ctx context.Context, sourceDs *datasetv1alpha1.Dataset, opts ...client.ListOption) ([]datasetv1alpha1.Dataset, error) {
+	keys := []string{client.ObjectKeyFromObject(sourceDs).String()}
+	if sourceDs.Status.PVCName != "" && !bindsClonedVolume(sourceDs) {
+		keys = append(keys, pvcSourceIndexKey(sourceDs.Namespace, sourceDs.Status.PVCName))
+	}
+
+	var datasets []datasetv1alpha1.Dataset
+	for _, key := range keys {
+		dsList := &datasetv1alpha1.DatasetList{}
+		if err := r.List(ctx, dsList, append([]client.ListOption{client.MatchingFields{referenceSourceIndex: key}}, opts...)...); err != nil {
+			return nil, err
+		}
+		datasets = append(datasets, dsList.Items...)
+	}
+	return datasets, nil
+}
+
+func datasetRequests(datasets []datasetv1alpha1.Dataset) []reconcile.Request {
+	return lo.Map(datasets, func(ds datasetv1alpha1.Dataset, _ int) reconcile.Request {
+		return reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&ds)}
+	})
+}
+
+// referencesOfDataset 在 source dataset 变化时重新检查引用它的 REFERENCE dataset 的访问权限
+func (r *DatasetReconciler) referencesOfDataset(ctx context.Context, obj client.Object) []reconcile.Request {
+	sourceDs, ok := obj.(*datasetv1alpha1.Dataset)
+	if !ok {
+		return nil
+	}
+	datasets, err := r.listReferences(ctx, sourceDs)
+	if err != nil {
+		log.Component("controller").Errorf("list references of dataset %s/%s error: %v", obj.GetNamespace(), obj.GetName(), err)
+		return nil
+	}
+	return datasetRequests(datasets)
+}
+
+// referencesInNamespace 在 namespace 的 label 变化时重新检查其中 REFERENCE dataset 的访问权限
+func (r *DatasetReconciler) referencesInNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	dsList := &datasetv1alpha1.DatasetList{}
+	err := r.List(ctx, dsList, client.InNamespace(obj.GetName()))
+	if err != nil {
+		log.Component("controller").Errorf("list datasets in namespace %s error: %v", obj.GetName(), err)
+		return nil
+	}
+	return datasetRequests(lo.Filter(dsList.Items, func(ds datasetv1alpha1.Dataset, _ int) bool {
+		return bindsClonedVolume(&ds)
+	}))
+}
+
+// cloneSourceVolume 克隆 source dataset pvc 对应的 pv，返回绑定克隆的 pv 的 pvc spec
+func (r *DatasetReconciler) cloneSourceVolume(ctx context.Context, ds *datasetv1alpha1.Dataset, srcDs *datasetv1alpha1.Dataset) (*corev1.PersistentVolumeClaimSpec, error) {
+	// 先获取 source dataset 的 pvc
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: srcDs.Namespace, Name: srcDs.Status.PVCName}, pvc)
+	if err != nil {
+		return nil, fmt.Errorf("get pvc %s/%s for source dataset %s/%s error: %v",
+			srcDs.Namespace, srcDs.Status.PVCName,
+			srcDs.Namespace, srcDs.Name, err)
+	}
+	if pvc.Spec.VolumeName == "" {
+		return nil, fmt.Errorf("pvc %s/%s has no volume", pvc.Namespace, pvc.Name)
+	}
+	// 再获取 source dataset pvc 对应的 pv
+	pv := &corev1.PersistentVolume{}
+	err = r.Get(ctx, client.ObjectKey{Name: pvc.Spec.VolumeName}, pv)
+	if err != nil {
+		return nil, fmt.Errorf("get pv %s for source dataset %s/%s error: %v",
+			pvc.Spec.VolumeName, srcDs.Namespace, srcDs.Name, err)
+	}
+	// 克隆一个新的 pv 给当前 ds
+	newPv := pv.DeepCopy()
+	newPv.OwnerReferences = datasetOwnerRef(ds)
+	newPv.Name = clonedPVName(ds)
+	if newPv.Labels == nil {
+		newPv.Labels = make(map[string]string)
+	}
+	newPv.Labels[constants.DatasetNameLabel] = ds.Name
+	newPv.Labels[constants.DatasetNamespaceLabel] = ds.Namespace
+	newPv.ResourceVersion = ""
+	newPv.Spec.ClaimRef = nil
+	// 保留策略改为 Retain
+	newPv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+	if err := r.Get(ctx, client.ObjectKey{Name: newPv.Name}, pv); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		if err := r.Create(ctx, newPv); err != nil {
+			return nil, err
+		}
+	} else if pv.Labels[constants.DatasetNamespaceLabel] != ds.Namespace {
+		// 之前克隆的 pv 没有 namespace label，补上以便清理孤立的 pv
+		pv.Labels = lo.Assign(pv.Labels, map[string]string{constants.DatasetNamespaceLabel: ds.Namespace})
+		if err := r.Update(ctx, pv); err != nil {
+			return nil, err
+		}
+	}
+	spec := pvc.Spec.DeepCopy()
+	spec.VolumeName = newPv.Name
+	return spec, nil
+}
+
+// checkReferenceAccess 检查 source dataset 是否共享给了 REFERENCE dataset 所在的 namespace，
+// 或者 source dataset 的所有者在 source dataset 所在的 namespace 中创建了授予该 namespace 访问且未过期的 DatasetShareGrant
+func (r *DatasetReconciler) checkReferenceAccess(ctx context.Context, ds *datasetv1alpha1.Dataset, sourceDs *datasetv1alpha1.Dataset) error {
+	shareErr := r.checkShared(ctx, ds, sourceDs)
+	if shareErr == nil {
+		return nil
+	}
+	grants, err := r.activeShareGrants(ctx, client.ObjectKeyFromObject(sourceDs), ds.Namespace)
+	if err != nil {
+		return fmt.Errorf("list dataset share grants error: %v", err)
+	}
+	if len(grants) == 0 {
+		return fmt.Errorf("%v, and no active dataset share grant exists", shareErr)
+	}
+	return nil
+}
+
+// referenceServiceAccounts 返回允许使用 REFERENCE dataset pvc 的 service account，通过共享访问时不限制
+func (r *DatasetReconciler) referenceServiceAccounts(ctx context.Context, ds *datasetv1alpha1.Dataset, sourceDs *datasetv1alpha1.Dataset) (string, error) {
+	if r.checkShared(ctx, ds, sourceDs) == nil {
+		return "", nil
+	}
+	grants, err := r.activeShareGrants(ctx, client.ObjectKeyFromObject(sourceDs), ds.Namespace)
+	if err != nil {
+		return "", fmt.Errorf("list dataset share grants error: %v", err)
+	}
+	return allowedServiceAccounts(grants), nil
+}
+
+// checkShared 检查 source dataset 的 share 和 shareToNamespaceSelector 是否允许 REFERENCE dataset 所在的 namespace 访问
+func (r *DatasetReconciler) checkShared(ctx context.Context, ds *datasetv1alpha1.Dataset, sourceDs *datasetv1alpha1.Dataset) error {
+	if !sourceDs.Spec.Share {
+		return fmt.Errorf("source dataset %s is not shared", ds.Spec.Source.URI)
+	}
+	if sourceDs.Spec.ShareToNamespaceSelector != nil {
+		// 获取当前 Dataset 所在的 Namespace
+		currNS := &corev1.Namespace{}
+		if err := r.Get(ctx, client.ObjectKey{Name: ds.Namespace}, currNS); err != nil {
+			return fmt.Errorf("fetch current namespace %s error: %v", ds.Namespace, err)
+		}
+		s, err := metav1.LabelSelectorAsSelector(sourceDs.Spec.ShareToNamespaceSelector)
+		if err != nil {
+			return fmt.Errorf("parse share to namespace selector error: %v", err)
+		}
+		if !s.Matches(labels.Set(currNS.Labels)) {
+			return fmt.Errorf("source dataset %s is not shared to current namespace", ds.Spec.Source.URI)
+		}
+	}
+	return nil
+}
+
+// revokeReferenceAccess 将已经挂载了 source dataset 的 REFERENCE dataset 标记为 AccessRevoked 并停止复制，
+// 按配置的策略在宽限期之后删除克隆的 pv 和绑定它的 pvc，返回的仍是无权访问的错误。
+// copy 模式下只删除 copy job 使用的 pvc 和克隆的 pv，保留已经复制到 REFERENCE dataset 自己的 pvc 中的数据
+func (r *DatasetReconciler) revokeReferenceAccess(ctx context.Context, ds *datasetv1alpha1.Dataset, accessErr error) error {
+	// 从未获得过访问权限
+	if ds.Status.PVCName == "" {
+		return accessErr
+	}
+
+	cond := meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked)
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		datasetLogger(ds).Warnf("access to source dataset %s is revoked: %v", ds.Spec.Source.URI, accessErr)
+		meta.SetStatusCondition(&ds.Status.Conditions, metav1.Condition{
+			Type:    condTypeAccessRevoked,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonAccessRevoked,
+			Message: accessErr.Error(),
+		})
+		cond = meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked)
+	}
+
+	copyMode := ds.Spec.Source.Type == datasetv1alpha1.DatasetTypeReference && referenceMode(ds) == referenceModeCopy
+	if copyMode {
+		if err := r.stopCopyJobs(ctx, ds); err != nil {
+			return fmt.Errorf("%v, stop copy jobs error: %v", accessErr, err)
+		}
+	}
+
+	if cond.Reason == reasonVolumeDeleted ||
+		config.GetReferenceRevokePolicy() != config.ReferenceRevokePolicyDelete ||
+		time.Since(cond.LastTransitionTime.Time) < config.GetReferenceRevokeGracePeriod() {
+		return accessErr
+	}
+
+	pvcName := ds.Status.PVCName
+	if copyMode {
+		pvcName = stagingPVCName(ds)
+	}
+	if err := r.deleteClonedVolume(ctx, ds, pvcName); err != nil {
+		return fmt.Errorf("%v, delete volumes of revoked dataset error: %v", accessErr, err)
+	}
+
+	if !copyMode {
+		ds.Status.PVCName = ""
+	}
+	cond.Reason = reasonVolumeDeleted
+	cond.Message = fmt.Sprintf("%s, pvc %s and pv %s have been deleted", accessErr.Error(), pvcName, clonedPVName(ds))
+
+	return accessErr
+}
+
+// stopCopyJobs 删除 REFERENCE dataset 正在运行的 copy job
+func (r *DatasetReconciler) stopCopyJobs(ctx context.Context, ds *datasetv1alpha1.Dataset) error {
+	jobList := &batchv1.JobList{}
+	if err := r.List(ctx, jobList, client.InNamespace(ds.Namespace), client.MatchingLabels{constants.DatasetNameLabel: ds.Name}); err != nil {
+		return err
+	}
+	for i := range jobList.Items {
+		job := &jobList.Items[i]
+		if !strings.HasPrefix(job.Name, copyJobNamePrefix(ds.Name)) || isJobFinished(job) {
+			continue
+		}
+		if err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		datasetLogger(ds).Infof("stopped copy job %s of revoked dataset", job.Name)
+	}
+	ds.Status.InProcessing = false
+	return nil
+}
+
+// revokeRequeueAfter 返回距离删除被撤销访问权限的 REFERENCE dataset 的 pv 和 pvc 的时间，0 表示不需要等待，
+// 宽限期之后还在等待 pv 被释放时每 30 秒重试
+func revokeRequeueAfter(ds *datasetv1alpha1.Dataset) time.Duration {
+	if !bindsClonedVolume(ds) || ds.Status.PVCName == "" ||
+		config.GetReferenceRevokePolicy() != config.ReferenceRevokePolicyDelete {
+		return 0
+	}
+	cond := meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked)
+	if cond == nil || cond.Status != metav1.ConditionTrue || cond.Reason == reasonVolumeDeleted {
+		return 0
+	}
+	if d := config.GetReferenceRevokeGracePeriod() - time.Since(cond.LastTransitionTime.Time); d > 0 {
+		return max(d, time.Second)
+	}
+	return 30 * time.Second
+}
@@ -0,0 +1,367 @@
+package dataset
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+)
+
+const testRevokeDeleteConfig = `
+reference:
+  revoke_policy: Delete
+  revoke_grace_period: 1h
+`
+
+// testRevokedReference 返回 access 在 revokedFor 之前被撤销的 REFERENCE dataset 以及其 pvc 和克隆的 pv
+func testRevokedReference(mode string, revokedFor time.Duration, pvPhase corev1.PersistentVolumePhase) (*datasetv1alpha1.Dataset, []client.Object) {
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "ml-team", UID: "ds-uid"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{
+				Type:    datasetv1alpha1.DatasetTypeReference,
+				URI:     "dataset://data-team/corpus",
+				Options: map[string]string{referenceOptionMode: mode},
+			},
+		},
+		Status: datasetv1alpha1.DatasetStatus{PVCName: "train"},
+	}
+	if revokedFor > 0 {
+		ds.Status.Conditions = []metav1.Condition{{
+			Type:               condTypeAccessRevoked,
+			Status:             metav1.ConditionTrue,
+			Reason:             reasonAccessRevoked,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-revokedFor)),
+		}}
+	}
+
+	pvcName := ds.Status.PVCName
+	if mode == referenceModeCopy {
+		pvcName = stagingPVCName(ds)
+	}
+	objs := []client.Object{
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "ml-team"}},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   clonedPVName(ds),
+				Labels: map[string]string{constants.DatasetNameLabel: ds.Name, constants.DatasetNamespaceLabel: ds.Namespace},
+			},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+				ClaimRef:                      &corev1.ObjectReference{Namespace: ds.Namespace, Name: pvcName},
+			},
+			Status: corev1.PersistentVolumeStatus{Phase: pvPhase},
+		},
+	}
+	if mode == referenceModeCopy {
+		objs = append(objs, &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: "ml-team"}})
+	}
+	return ds, objs
+}
+
+func exists(t *testing.T, r *DatasetReconciler, key client.ObjectKey, obj client.Object) bool {
+	err := r.Get(context.Background(), key, obj)
+	if k8serrors.IsNotFound(err) {
+		return false
+	}
+	require.NoError(t, err)
+	return true
+}
+
+func TestRevokeReferenceAccess(t *testing.T) {
+	ctx := context.Background()
+	accessErr := errors.New("source dataset dataset://data-team/corpus is not shared")
+	pvcKey := client.ObjectKey{Namespace: "ml-team", Name: "train"}
+
+	t.Run("never had access", func(t *testing.T) {
+		ds, _ := testRevokedReference(referenceModeVolume, 0, corev1.VolumeReleased)
+		ds.Status.PVCName = ""
+		r := newTestReconciler(t)
+		assert.Equal(t, accessErr, r.revokeReferenceAccess(ctx, ds, accessErr))
+		assert.Empty(t, ds.Status.Conditions)
+	})
+
+	t.Run("retain", func(t *testing.T) {
+		ds, objs := testRevokedReference(referenceModeVolume, 0, corev1.VolumeReleased)
+		r := newTestReconciler(t, objs...)
+
+		assert.Equal(t, accessErr, r.revokeReferenceAccess(ctx, ds, accessErr))
+		cond := meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked)
+		require.NotNil(t, cond)
+		assert.Equal(t, reasonAccessRevoked, cond.Reason)
+		assert.Equal(t, "train", ds.Status.PVCName)
+		assert.True(t, exists(t, r, pvcKey, &corev1.PersistentVolumeClaim{}))
+		assert.Zero(t, revokeRequeueAfter(ds))
+	})
+
+	t.Run("delete within grace period", func(t *testing.T) {
+		setTestConfig(t, testRevokeDeleteConfig)
+		ds, objs := testRevokedReference(referenceModeVolume, time.Minute, corev1.VolumeReleased)
+		r := newTestReconciler(t, objs...)
+
+		assert.Equal(t, accessErr, r.revokeReferenceAccess(ctx, ds, accessErr))
+		assert.Equal(t, "train", ds.Status.PVCName)
+		assert.True(t, exists(t, r, pvcKey, &corev1.PersistentVolumeClaim{}))
+		d := revokeRequeueAfter(ds)
+		assert.Greater(t, d, 58*time.Minute)
+		assert.LessOrEqual(t, d, 59*time.Minute)
+	})
+
+	t.Run("delete after grace period", func(t *testing.T) {
+		setTestConfig(t, testRevokeDeleteConfig)
+		ds, objs := testRevokedReference(referenceModeVolume, 2*time.Hour, corev1.VolumeReleased)
+		r := newTestReconciler(t, objs...)
+
+		assert.Equal(t, accessErr, r.revokeReferenceAccess(ctx, ds, accessErr))
+		assert.Empty(t, ds.Status.PVCName)
+		assert.Equal(t, reasonVolumeDeleted, meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked).Reason)
+		assert.False(t, exists(t, r, pvcKey, &corev1.PersistentVolumeClaim{}))
+		assert.False(t, exists(t, r, client.ObjectKey{Name: clonedPVName(ds)}, &corev1.PersistentVolume{}))
+		assert.Zero(t, revokeRequeueAfter(ds))
+	})
+
+	t.Run("wait for pv to be released", func(t *testing.T) {
+		setTestConfig(t, testRevokeDeleteConfig)
+		ds, objs := testRevokedReference(referenceModeVolume, 2*time.Hour, corev1.VolumeBound)
+		r := newTestReconciler(t, objs...)
+
+		err := r.revokeReferenceAccess(ctx, ds, accessErr)
+		assert.ErrorContains(t, err, "waiting for pv")
+		assert.Equal(t, "train", ds.Status.PVCName)
+		assert.Equal(t, reasonAccessRevoked, meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked).Reason)
+		assert.True(t, exists(t, r, client.ObjectKey{Name: clonedPVName(ds)}, &corev1.PersistentVolume{}))
+		assert.Equal(t, 30*time.Second, revokeRequeueAfter(ds))
+	})
+
+	t.Run("copy mode keeps copied data", func(t *testing.T) {
+		setTestConfig(t, testRevokeDeleteConfig)
+		ds, objs := testRevokedReference(referenceModeCopy, 2*time.Hour, corev1.VolumeReleased)
+		copyJob := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      genCopyJobName(ds.Name, 1, 2),
+				Namespace: ds.Namespace,
+				Labels:    map[string]string{constants.DatasetNameLabel: ds.Name},
+			},
+		}
+		ds.Status.InProcessing = true
+		r := newTestReconciler(t, append(objs, copyJob)...)
+
+		assert.Equal(t, accessErr, r.revokeReferenceAccess(ctx, ds, accessErr))
+		assert.False(t, ds.Status.InProcessing)
+		assert.False(t, exists(t, r, client.ObjectKeyFromObject(copyJob), &batchv1.Job{}))
+		assert.Equal(t, "train", ds.Status.PVCName)
+		assert.True(t, exists(t, r, pvcKey, &corev1.PersistentVolumeClaim{}))
+		assert.False(t, exists(t, r, client.ObjectKey{Namespace: "ml-team", Name: stagingPVCName(ds)}, &corev1.PersistentVolumeClaim{}))
+		assert.False(t, exists(t, r, client.ObjectKey{Name: clonedPVName(ds)}, &corev1.PersistentVolume{}))
+		assert.Equal(t, reasonVolumeDeleted, meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked).Reason)
+		assert.Zero(t, revokeRequeueAfter(ds))
+
+		// nothing left to delete on the following reconciles
+		assert.Equal(t, accessErr, r.revokeReferenceAccess(ctx, ds, accessErr))
+		assert.True(t, exists(t, r, pvcKey, &corev1.PersistentVolumeClaim{}))
+	})
+}
+
+func TestValidateRestoresAccess(t *testing.T) {
+	ctx := context.Background()
+	sourceDs := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "corpus", Namespace: "data-team"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeHTTP, URI: "https://example.com/corpus/"},
+		},
+	}
+	ds, objs := testRevokedReference(referenceModeVolume, 0, corev1.VolumeBound)
+	r := newTestReconciler(t, append(objs, sourceDs)...)
+
+	assert.ErrorContains(t, r.validate(ctx, ds), "not shared")
+	assert.True(t, meta.IsStatusConditionTrue(ds.Status.Conditions, condTypeAccessRevoked))
+
+	sourceDs.Spec.Share = true
+	require.NoError(t, r.Update(ctx, sourceDs))
+	require.NoError(t, r.validate(ctx, ds))
+	assert.Nil(t, meta.FindStatusCondition(ds.Status.Conditions, condTypeAccessRevoked))
+}
+
+func TestReferencesOfDataset(t *testing.T) {
+	reference := func(namespace, name, uri string) *datasetv1alpha1.Dataset {
+		return &datasetv1alpha1.Dataset{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: datasetv1alpha1.DatasetSpec{
+				Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeReference, URI: uri},
+			},
+		}
+	}
+	sourceDs := &datasetv1alpha1.Dataset{ObjectMeta: metav1.ObjectMeta{Namespace: "data-team", Name: "corpus"}}
+	r := newTestReconciler(t,
+		sourceDs,
+		reference("ml-team", "train", "dataset://data-team/corpus"),
+		reference("eval-team", "eval", "dataset://data-team/corpus"),
+		reference("ml-team", "other", "dataset://data-team/images"),
+	)
+
+	requests := r.referencesOfDataset(context.Background(), sourceDs)
+	assert.ElementsMatch(t, datasetRequests([]datasetv1alpha1.Dataset{
+		*reference("ml-team", "train", ""),
+		*reference("eval-team", "eval", ""),
+	}), requests)
+}
+
+func testSourceDataset(phase datasetv1alpha1.DatasetStatusPhase) *datasetv1alpha1.Dataset {
+	return &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "corpus", Namespace: "data-team"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Share:         true,
+			Source:        datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeHTTP, URI: "https://example.com/corpus/"},
+			DataSyncRound: 3,
+		},
+		Status: datasetv1alpha1.DatasetStatus{
+			Phase:            phase,
+			PVCName:          "corpus",
+			LastSucceedRound: 2,
+			Revision:         "etag-2",
+			LastSyncTime:     metav1.NewTime(time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)),
+		},
+	}
+}
+
+func TestMirrorSourceStatus(t *testing.T) {
+	ds, _ := testRevokedReference(referenceModeVolume, 0, corev1.VolumeBound)
+	sourceDs := testSourceDataset(datasetv1alpha1.DatasetStatusPhaseProcessing)
+	sourceDs.Status.Size = 1 << 30
+	sourceDs.Status.FileCount = 1200
+	sourceDs.Status.LargestFiles = []datasetv1alpha1.DatasetFile{{Path: "shard-000.parquet", Size: 1 << 28}}
+
+	mirrorSourceStatus(ds, sourceDs)
+	assert.EqualValues(t, 2, ds.Status.SourceRound)
+	assert.Equal(t, "etag-2", ds.Status.Revision)
+	assert.Equal(t, sourceDs.Status.LastSyncTime, ds.Status.LastSyncTime)
+	assert.EqualValues(t, 1<<30, ds.Status.Size)
+	assert.EqualValues(t, 1200, ds.Status.FileCount)
+	assert.Equal(t, sourceDs.Status.LargestFiles, ds.Status.LargestFiles)
+}
+
+func TestReferencePhase(t *testing.T) {
+	cases := []struct {
+		name        string
+		sourcePhase datasetv1alpha1.DatasetStatusPhase
+		noSource    bool
+		pvcName     string
+		want        datasetv1alpha1.DatasetStatusPhase
+	}{
+		{name: "source not found", noSource: true, pvcName: "train", want: datasetv1alpha1.DatasetStatusPhasePending},
+		{name: "source without phase", pvcName: "train", want: datasetv1alpha1.DatasetStatusPhasePending},
+		{name: "source ready w/o pvc", sourcePhase: datasetv1alpha1.DatasetStatusPhaseReady, want: datasetv1alpha1.DatasetStatusPhasePending},
+		{name: "source ready", sourcePhase: datasetv1alpha1.DatasetStatusPhaseReady, pvcName: "train", want: datasetv1alpha1.DatasetStatusPhaseReady},
+		{name: "source syncing", sourcePhase: datasetv1alpha1.DatasetStatusPhaseProcessing, pvcName: "train", want: datasetv1alpha1.DatasetStatusPhaseProcessing},
+		{name: "source failed", sourcePhase: datasetv1alpha1.DatasetStatusPhaseFailed, pvcName: "train", want: datasetv1alpha1.DatasetStatusPhaseFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ds, _ := testRevokedReference(referenceModeVolume, 0, corev1.VolumeBound)
+			ds.Status.PVCName = c.pvcName
+			var objs []client.Object
+			if !c.noSource {
+				objs = append(objs, testSourceDataset(c.sourcePhase))
+			}
+			r := newTestReconciler(t, objs...)
+
+			assert.Equal(t, c.want, r.referencePhase(context.Background(), ds))
+			require.NoError(t, r.reconcilePhase(context.Background(), ds))
+			assert.Equal(t, c.want, ds.Status.Phase)
+		})
+	}
+
+	t.Run("failed condition", func(t *testing.T) {
+		ds, _ := testRevokedReference(referenceModeVolume, 0, corev1.VolumeBound)
+		ds.Status.Conditions = []metav1.Condition{{Type: condTypeConfig, Status: metav1.ConditionFalse}}
+		r := newTestReconciler(t, testSourceDataset(datasetv1alpha1.DatasetStatusPhaseReady))
+		require.NoError(t, r.reconcilePhase(context.Background(), ds))
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseFailed, ds.Status.Phase)
+	})
+}
+
+func TestSourceStatusChanged(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(ds *datasetv1alpha1.Dataset)
+		want   bool
+	}{
+		{name: "phase", mutate: func(ds *datasetv1alpha1.Dataset) { ds.Status.Phase = datasetv1alpha1.DatasetStatusPhaseReady }, want: true},
+		{name: "round", mutate: func(ds *datasetv1alpha1.Dataset) { ds.Status.LastSucceedRound = 3 }, want: true},
+		{name: "revision", mutate: func(ds *datasetv1alpha1.Dataset) { ds.Status.Revision = "etag-3" }, want: true},
+		{name: "sync time", mutate: func(ds *datasetv1alpha1.Dataset) {
+			ds.Status.LastSyncTime = metav1.NewTime(ds.Status.LastSyncTime.Add(time.Hour))
+		}, want: true},
+		{name: "other status", mutate: func(ds *datasetv1alpha1.Dataset) { ds.Status.InProcessingRound = 3 }},
+		{name: "labels", mutate: func(ds *datasetv1alpha1.Dataset) { ds.Labels = map[string]string{"team": "data"} }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			oldDs := testSourceDataset(datasetv1alpha1.DatasetStatusPhaseProcessing)
+			newDs := oldDs.DeepCopy()
+			c.mutate(newDs)
+			assert.Equal(t, c.want, sourceStatusChanged.Update(event.UpdateEvent{ObjectOld: oldDs, ObjectNew: newDs}))
+		})
+	}
+
+	t.Run("not a dataset", func(t *testing.T) {
+		assert.False(t, sourceStatusChanged.Update(event.UpdateEvent{
+			ObjectOld: &corev1.Namespace{},
+			ObjectNew: &corev1.Namespace{},
+		}))
+	})
+}
+
+func TestWaitSourceReady(t *testing.T) {
+	ctx := context.Background()
+	newReference := func(wait bool) *datasetv1alpha1.Dataset {
+		ds, _ := testRevokedReference(referenceModeVolume, 0, corev1.VolumeBound)
+		ds.Status.PVCName = ""
+		if wait {
+			ds.Spec.Source.Options = map[string]string{referenceOptionWaitSourceReady: "true"}
+		}
+		return ds
+	}
+
+	t.Run("waiting for the source", func(t *testing.T) {
+		ds := newReference(true)
+		assert.True(t, waitSourceReady(ds))
+		r := newTestReconciler(t, testSourceDataset(datasetv1alpha1.DatasetStatusPhaseProcessing))
+
+		require.NoError(t, r.reconcilePVC(ctx, ds))
+		assert.Empty(t, ds.Status.PVCName)
+		assert.EqualValues(t, 2, ds.Status.SourceRound)
+		// 等待期间与 source dataset 的 phase 保持一致
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseProcessing, r.referencePhase(ctx, ds))
+		assert.False(t, exists(t, r, client.ObjectKey{Namespace: "ml-team", Name: "train"}, &corev1.PersistentVolumeClaim{}))
+	})
+
+	t.Run("source ready", func(t *testing.T) {
+		ds := newReference(true)
+		r := newTestReconciler(t, testSourceDataset(datasetv1alpha1.DatasetStatusPhaseReady))
+
+		// 不再等待，继续克隆 source dataset 的 pvc
+		assert.ErrorContains(t, r.reconcilePVC(ctx, ds), "get pvc data-team/corpus")
+	})
+
+	t.Run("not waiting", func(t *testing.T) {
+		ds := newReference(false)
+		assert.False(t, waitSourceReady(ds))
+		r := newTestReconciler(t, testSourceDataset(datasetv1alpha1.DatasetStatusPhaseProcessing))
+
+		assert.ErrorContains(t, r.reconcilePVC(ctx, ds), "get pvc data-team/corpus")
+	})
+}
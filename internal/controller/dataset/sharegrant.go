@@ -0,0 +1,128 @@
+package dataset
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/pkg/log"
+)
+
+// DatasetShareGrantReconciler 根据过期时间更新 DatasetShareGrant 的 phase，并在过期时重新 reconcile，
+// 由此触发引用 source dataset 的 REFERENCE dataset 重新检查访问权限
+type DatasetShareGrantReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=dataset.baizeai.io,resources=datasetsharegrants,verbs=get;list;watch
+//+kubebuilder:rbac:groups=dataset.baizeai.io,resources=datasetsharegrants/status,verbs=get;update;patch
+
+func (r *DatasetShareGrantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.Component("controller").WithFields(logrus.Fields{
+		"shareGrant":       req.Name,
+		log.FieldNamespace: req.Namespace,
+	})
+
+	grant := &datasetv1alpha1.DatasetShareGrant{}
+	err := r.Get(ctx, req.NamespacedName, grant)
+	if err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Errorf("error fetch dataset share grant: %v", err)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	now := time.Now()
+	phase := shareGrantPhase(grant, now)
+	if phase != grant.Status.Phase {
+		logger.Infof("access to dataset %s granted to namespace %s is %s", grant.Spec.Dataset, grant.Spec.Namespace, phase)
+		grant.Status.Phase = phase
+		err = r.Status().Update(ctx, grant)
+		if err != nil {
+			logger.Errorf("error update status: %v", err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// 到期后更新为 EXPIRED，引用 source dataset 的 REFERENCE dataset 随之失去访问权限
+	if phase == datasetv1alpha1.DatasetShareGrantPhaseActive && grant.Spec.ExpirationTime != nil {
+		return ctrl.Result{RequeueAfter: max(grant.Spec.ExpirationTime.Sub(now), time.Second)}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// shareGrantPhase 根据过期时间计算 DatasetShareGrant 的 phase
+func shareGrantPhase(grant *datasetv1alpha1.DatasetShareGrant, now time.Time) datasetv1alpha1.DatasetShareGrantPhase {
+	if grant.Spec.ExpirationTime != nil && !now.Before(grant.Spec.ExpirationTime.Time) {
+		return datasetv1alpha1.DatasetShareGrantPhaseExpired
+	}
+	return datasetv1alpha1.DatasetShareGrantPhaseActive
+}
+
+// activeShareGrants 返回 source dataset 所在 namespace 中授予 namespace 访问 source dataset 且未过期的 DatasetShareGrant
+func (r *DatasetReconciler) activeShareGrants(ctx context.Context, source client.ObjectKey, namespace string) ([]datasetv1alpha1.DatasetShareGrant, error) {
+	grantList := &datasetv1alpha1.DatasetShareGrantList{}
+	if err := r.List(ctx, grantList, client.InNamespace(source.Namespace)); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return lo.Filter(grantList.Items, func(grant datasetv1alpha1.DatasetShareGrant, _ int) bool {
+		return grant.Spec.Dataset == source.Name &&
+			grant.Spec.Namespace == namespace &&
+			shareGrantPhase(&grant, now) == datasetv1alpha1.DatasetShareGrantPhaseActive
+	}), nil
+}
+
+// allowedServiceAccounts 返回未过期的 DatasetShareGrant 允许使用 dataset 的 service account，为空表示不限制
+func allowedServiceAccounts(grants []datasetv1alpha1.DatasetShareGrant) string {
+	if len(grants) == 0 || lo.ContainsBy(grants, func(grant datasetv1alpha1.DatasetShareGrant) bool {
+		return len(grant.Spec.ServiceAccounts) == 0
+	}) {
+		return ""
+	}
+
+	serviceAccounts := lo.Uniq(lo.FlatMap(grants, func(grant datasetv1alpha1.DatasetShareGrant, _ int) []string {
+		return grant.Spec.ServiceAccounts
+	}))
+	sort.Strings(serviceAccounts)
+	return strings.Join(serviceAccounts, ",")
+}
+
+// referencesOfShareGrant 在 DatasetShareGrant 变化时重新检查被授权的 namespace 中引用其 dataset 的 REFERENCE dataset
+func (r *DatasetReconciler) referencesOfShareGrant(ctx context.Context, obj client.Object) []reconcile.Request {
+	grant, ok := obj.(*datasetv1alpha1.DatasetShareGrant)
+	if !ok {
+		return nil
+	}
+
+	// source dataset 不存在时仍然按 namespace/name 查找引用它的 REFERENCE dataset
+	sourceDs := &datasetv1alpha1.Dataset{}
+	source := client.ObjectKey{Namespace: grant.Namespace, Name: grant.Spec.Dataset}
+	if err := r.Get(ctx, source, sourceDs); err != nil {
+		sourceDs.Namespace, sourceDs.Name = source.Namespace, source.Name
+	}
+	datasets, err := r.listReferences(ctx, sourceDs, client.InNamespace(grant.Spec.Namespace))
+	if err != nil {
+		log.Component("controller").Errorf("list references of dataset %s error: %v", source, err)
+		return nil
+	}
+	return datasetRequests(datasets)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DatasetShareGrantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&datasetv1alpha1.DatasetShareGrant{}).
+		Complete(r)
+}
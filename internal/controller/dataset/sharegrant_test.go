@@ -0,0 +1,184 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+)
+
+func testShareGrant(name string, expiration *time.Time, serviceAccounts ...string) datasetv1alpha1.DatasetShareGrant {
+	grant := datasetv1alpha1.DatasetShareGrant{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "data-team"},
+		Spec: datasetv1alpha1.DatasetShareGrantSpec{
+			Dataset:         "train",
+			Namespace:       "ml-team",
+			ServiceAccounts: serviceAccounts,
+		},
+	}
+	if expiration != nil {
+		grant.Spec.ExpirationTime = &metav1.Time{Time: *expiration}
+	}
+	return grant
+}
+
+func TestShareGrantPhase(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	testCases := []struct {
+		name       string
+		expiration *time.Time
+		expected   datasetv1alpha1.DatasetShareGrantPhase
+	}{
+		{name: "never expires", expected: datasetv1alpha1.DatasetShareGrantPhaseActive},
+		{name: "expires later", expiration: &future, expected: datasetv1alpha1.DatasetShareGrantPhaseActive},
+		{name: "expired", expiration: &past, expected: datasetv1alpha1.DatasetShareGrantPhaseExpired},
+		{name: "expires now", expiration: &now, expected: datasetv1alpha1.DatasetShareGrantPhaseExpired},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			grant := testShareGrant("grant", tc.expiration)
+			assert.Equal(t, tc.expected, shareGrantPhase(&grant, now))
+		})
+	}
+}
+
+func TestAllowedServiceAccounts(t *testing.T) {
+	assert.Empty(t, allowedServiceAccounts(nil))
+	assert.Equal(t, "a,b,trainer", allowedServiceAccounts([]datasetv1alpha1.DatasetShareGrant{
+		testShareGrant("x", nil, "trainer", "b"),
+		testShareGrant("y", nil, "a", "trainer"),
+	}))
+	// 有一个 grant 不限制 service account 时整体不限制
+	assert.Empty(t, allowedServiceAccounts([]datasetv1alpha1.DatasetShareGrant{
+		testShareGrant("x", nil, "trainer"),
+		testShareGrant("y", nil),
+	}))
+}
+
+func TestCheckReferenceAccess(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	sourceDs := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "data-team"},
+	}
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Name: "train", Namespace: "ml-team"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{
+				Type: datasetv1alpha1.DatasetTypeReference,
+				URI:  "dataset://data-team/train",
+			},
+		},
+	}
+	// 被授权的 namespace 不能给自己授权
+	inConsumerNamespace := testShareGrant("self-granted", nil)
+	inConsumerNamespace.Namespace = "ml-team"
+	otherNamespace := testShareGrant("other-namespace", nil)
+	otherNamespace.Spec.Namespace = "other-team"
+	otherDataset := testShareGrant("other-dataset", nil)
+	otherDataset.Spec.Dataset = "eval"
+	expired := testShareGrant("expired", &past)
+	active := testShareGrant("active", &future, "trainer")
+
+	testCases := []struct {
+		name            string
+		share           bool
+		grants          []datasetv1alpha1.DatasetShareGrant
+		allowed         bool
+		serviceAccounts string
+	}{
+		{name: "shared", share: true, allowed: true},
+		{name: "shared regardless of grants", share: true, grants: []datasetv1alpha1.DatasetShareGrant{active}, allowed: true},
+		{name: "not shared"},
+		{name: "grant in the namespace of the reference", grants: []datasetv1alpha1.DatasetShareGrant{inConsumerNamespace}},
+		{name: "grant to another namespace", grants: []datasetv1alpha1.DatasetShareGrant{otherNamespace}},
+		{name: "grant of another dataset", grants: []datasetv1alpha1.DatasetShareGrant{otherDataset}},
+		{name: "expired grant", grants: []datasetv1alpha1.DatasetShareGrant{expired}},
+		{
+			name:            "active grant",
+			grants:          []datasetv1alpha1.DatasetShareGrant{expired, active},
+			allowed:         true,
+			serviceAccounts: "trainer",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sourceDs := sourceDs.DeepCopy()
+			sourceDs.Spec.Share = tc.share
+			objs := []client.Object{sourceDs, ds.DeepCopy()}
+			for i := range tc.grants {
+				objs = append(objs, tc.grants[i].DeepCopy())
+			}
+			r := newTestReconciler(t, objs...)
+
+			err := r.checkReferenceAccess(ctx, ds, sourceDs)
+			if !tc.allowed {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			serviceAccounts, err := r.referenceServiceAccounts(ctx, ds, sourceDs)
+			require.NoError(t, err)
+			assert.Equal(t, tc.serviceAccounts, serviceAccounts)
+		})
+	}
+}
+
+func TestDatasetShareGrantReconcile(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	active := testShareGrant("active", &future)
+	expired := testShareGrant("expired", &past)
+	c := newTestClient(t, &active, &expired)
+	r := &DatasetShareGrantReconciler{Client: c, Scheme: c.Scheme()}
+
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&active)}
+	result, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Positive(t, result.RequeueAfter)
+	assert.LessOrEqual(t, result.RequeueAfter, time.Hour)
+	require.NoError(t, c.Get(ctx, req.NamespacedName, &active))
+	assert.Equal(t, datasetv1alpha1.DatasetShareGrantPhaseActive, active.Status.Phase)
+
+	req = ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&expired)}
+	result, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+	require.NoError(t, c.Get(ctx, req.NamespacedName, &expired))
+	assert.Equal(t, datasetv1alpha1.DatasetShareGrantPhaseExpired, expired.Status.Phase)
+}
+
+func TestReferencesOfShareGrant(t *testing.T) {
+	ctx := context.Background()
+	reference := func(namespace, name, uri string) *datasetv1alpha1.Dataset {
+		return &datasetv1alpha1.Dataset{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: datasetv1alpha1.DatasetSpec{
+				Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeReference, URI: uri},
+			},
+		}
+	}
+	r := newTestReconciler(t,
+		reference("ml-team", "train", "dataset://data-team/train"),
+		reference("ml-team", "eval", "dataset://data-team/eval"),
+		reference("other-team", "train", "dataset://data-team/train"),
+	)
+
+	grant := testShareGrant("grant", nil)
+	requests := r.referencesOfShareGrant(ctx, &grant)
+	require.Len(t, requests, 1)
+	assert.Equal(t, client.ObjectKey{Namespace: "ml-team", Name: "train"}, requests[0].NamespacedName)
+}
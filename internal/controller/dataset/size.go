@@ -0,0 +1,54 @@
+package dataset
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	batchv1 "k8s.io/api/batch/v1"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+)
+
+// maxLargestFiles 为 status 中记录的最大的文件数
+const maxLargestFiles = 5
+
+// reconcileRoundSize 汇总成功的 round 中每个 source 的 data-loader 统计的大小、文件数和最大的文件，并记录 round 带来的变化，
+// 有 source 没有统计结果时不更新
+func (r *DatasetReconciler) reconcileRoundSize(ctx context.Context, ds *datasetv1alpha1.Dataset, job *batchv1.Job, loader *datasetv1alpha1.DataLoadStatus) error {
+	var size, fileCount int64
+	var largestFiles []datasetv1alpha1.DatasetFile
+	for _, source := range datasetSources(ds) {
+		result, err := r.getJobSyncResult(ctx, job, sourceContainerName(source))
+		if err != nil {
+			return err
+		}
+		if result == nil || result.Stats == nil {
+			datasetLogger(ds).Debugf("source %s of job %s reported no size", source.Name, job.Name)
+			return nil
+		}
+
+		size += result.Stats.Size
+		fileCount += result.Stats.FileCount
+		for _, file := range result.Stats.LargestFiles {
+			largestFiles = append(largestFiles, datasetv1alpha1.DatasetFile{
+				// 多个 source 时路径相对于 dataset 的目录
+				Path: path.Join(sourceSubPath(source), file.Path),
+				Size: file.Size,
+			})
+		}
+	}
+	sort.SliceStable(largestFiles, func(i, j int) bool {
+		return largestFiles[i].Size > largestFiles[j].Size
+	})
+	if len(largestFiles) > maxLargestFiles {
+		largestFiles = largestFiles[:maxLargestFiles]
+	}
+
+	loader.SizeDelta = size - ds.Status.Size
+	loader.FileCountDelta = fileCount - ds.Status.FileCount
+	ds.Status.Size = size
+	ds.Status.FileCount = fileCount
+	ds.Status.LargestFiles = largestFiles
+	return nil
+}
@@ -0,0 +1,165 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+// testSucceededPod 返回 job 成功的 pod，results 为各个容器写入的 termination message
+func testSucceededPod(t *testing.T, job *batchv1.Job, results map[string]*datasources.SyncResult) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: job.Namespace,
+			Name:      job.Name + "-x7k2p",
+			Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+	}
+	for name, result := range results {
+		message := ""
+		if result != nil {
+			content, err := json.Marshal(result)
+			require.NoError(t, err)
+			message = string(content)
+		}
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name:  name,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+		})
+	}
+	return pod
+}
+
+func TestReconcileRoundSize(t *testing.T) {
+	ctx := context.Background()
+	ds := newMultiSourceDataset(datasetv1alpha1.DatasetSourcesPolicyParallel)
+	ds.Status.Size = 1000
+	ds.Status.FileCount = 10
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: genJobName("bundle", 1)}}
+	stats := func(size, fileCount int64, largest ...datasources.PlanFile) *datasources.SyncResult {
+		return &datasources.SyncResult{Stats: &datasources.DirStats{Size: size, FileCount: fileCount, LargestFiles: largest}}
+	}
+
+	t.Run("sums the sources", func(t *testing.T) {
+		ds := ds.DeepCopy()
+		r := newTestReconciler(t, testSucceededPod(t, job, map[string]*datasources.SyncResult{
+			"dataset-loader-code": stats(300, 20,
+				datasources.PlanFile{Path: "go.sum", Size: 200},
+				datasources.PlanFile{Path: "README.md", Size: 50},
+			),
+			"dataset-loader-weights": stats(5000, 4,
+				datasources.PlanFile{Path: "model-00001.safetensors", Size: 3000},
+				datasources.PlanFile{Path: "model-00002.safetensors", Size: 1900},
+				datasources.PlanFile{Path: "config.json", Size: 60},
+				datasources.PlanFile{Path: "tokenizer.json", Size: 40},
+			),
+			"dataset-loader-docs": stats(100, 1, datasources.PlanFile{Path: "index.html", Size: 100}),
+		}))
+
+		loader := &datasetv1alpha1.DataLoadStatus{Round: 1}
+		require.NoError(t, r.reconcileRoundSize(ctx, ds, job, loader))
+		assert.EqualValues(t, 5400, ds.Status.Size)
+		assert.EqualValues(t, 25, ds.Status.FileCount)
+		assert.EqualValues(t, 4400, loader.SizeDelta)
+		assert.EqualValues(t, 15, loader.FileCountDelta)
+		// 路径相对于 dataset 的目录，只保留最大的几个文件
+		assert.Equal(t, []datasetv1alpha1.DatasetFile{
+			{Path: "models/qwen/model-00001.safetensors", Size: 3000},
+			{Path: "models/qwen/model-00002.safetensors", Size: 1900},
+			{Path: "code/go.sum", Size: 200},
+			{Path: "docs/index.html", Size: 100},
+			{Path: "models/qwen/config.json", Size: 60},
+		}, ds.Status.LargestFiles)
+	})
+
+	t.Run("single source", func(t *testing.T) {
+		single := &datasetv1alpha1.Dataset{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "bundle"},
+			Spec: datasetv1alpha1.DatasetSpec{
+				Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeS3, URI: "s3://models/qwen"},
+			},
+			Status: datasetv1alpha1.DatasetStatus{Size: 1000, FileCount: 10},
+		}
+		r := newTestReconciler(t, testSucceededPod(t, job, map[string]*datasources.SyncResult{
+			"dataset-loader": stats(800, 8, datasources.PlanFile{Path: "model.safetensors", Size: 700}),
+		}))
+
+		loader := &datasetv1alpha1.DataLoadStatus{Round: 1}
+		require.NoError(t, r.reconcileRoundSize(ctx, single, job, loader))
+		assert.EqualValues(t, 800, single.Status.Size)
+		assert.EqualValues(t, -200, loader.SizeDelta)
+		assert.EqualValues(t, -2, loader.FileCountDelta)
+		assert.Equal(t, []datasetv1alpha1.DatasetFile{{Path: "model.safetensors", Size: 700}}, single.Status.LargestFiles)
+	})
+
+	t.Run("source without stats", func(t *testing.T) {
+		ds := ds.DeepCopy()
+		r := newTestReconciler(t, testSucceededPod(t, job, map[string]*datasources.SyncResult{
+			"dataset-loader-code":    stats(300, 20),
+			"dataset-loader-weights": {Revision: "v1"},
+			"dataset-loader-docs":    nil,
+		}))
+
+		loader := &datasetv1alpha1.DataLoadStatus{Round: 1}
+		require.NoError(t, r.reconcileRoundSize(ctx, ds, job, loader))
+		assert.EqualValues(t, 1000, ds.Status.Size)
+		assert.EqualValues(t, 10, ds.Status.FileCount)
+		assert.Zero(t, loader.SizeDelta)
+	})
+}
+
+func TestReconcileJobStatusSize(t *testing.T) {
+	ctx := context.Background()
+	ds := &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "corpus"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source:        datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeS3, URI: "s3://corpus/v1"},
+			DataSyncRound: 2,
+		},
+		Status: datasetv1alpha1.DatasetStatus{
+			InProcessing:      true,
+			InProcessingRound: 2,
+			LastSucceedRound:  1,
+			Size:              4096,
+			FileCount:         3,
+		},
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: genJobName("corpus", 2)},
+		Status:     batchv1.JobStatus{Succeeded: 1},
+	}
+	pod := testSucceededPod(t, job, map[string]*datasources.SyncResult{
+		"dataset-loader": {
+			Revision: "etag-2",
+			Stats: &datasources.DirStats{
+				Size:         6144,
+				FileCount:    4,
+				LargestFiles: []datasources.PlanFile{{Path: "train.parquet", Size: 4096}},
+			},
+		},
+	})
+	r := newTestReconciler(t, job, pod)
+
+	require.NoError(t, r.reconcileJobStatus(ctx, ds))
+	assert.False(t, ds.Status.InProcessing)
+	assert.EqualValues(t, 2, ds.Status.LastSucceedRound)
+	assert.Equal(t, "etag-2", ds.Status.Revision)
+	assert.EqualValues(t, 6144, ds.Status.Size)
+	assert.EqualValues(t, 4, ds.Status.FileCount)
+	assert.Equal(t, []datasetv1alpha1.DatasetFile{{Path: "train.parquet", Size: 4096}}, ds.Status.LargestFiles)
+	require.Len(t, ds.Status.SyncRoundStatuses, 1)
+	round := ds.Status.SyncRoundStatuses[0]
+	assert.True(t, round.Succeed)
+	assert.EqualValues(t, 2048, round.SizeDelta)
+	assert.EqualValues(t, 1, round.FileCountDelta)
+}
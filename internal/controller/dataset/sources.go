@@ -0,0 +1,280 @@
+package dataset
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/config"
+	"github.com/BaizeAI/dataset/internal/pkg/constants"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+const pvcMountPath = "/baize/dataset/data"
+
+// dataLoaderLogComponent 为日志配置 levels 中设置 data-loader 日志级别的组件名
+const dataLoaderLogComponent = "data-loader"
+
+// isMultiSource 表示 dataset 是否由 spec.sources 组合而成
+func isMultiSource(ds *datasetv1alpha1.Dataset) bool {
+	return len(ds.Spec.Sources) > 0
+}
+
+// datasetSources 返回 round job 需要同步的 source，只有 spec.source 时视为一个没有名字的 source，同步到 mount path 本身
+func datasetSources(ds *datasetv1alpha1.Dataset) []datasetv1alpha1.DatasetSourceItem {
+	if isMultiSource(ds) {
+		return ds.Spec.Sources
+	}
+	return []datasetv1alpha1.DatasetSourceItem{{
+		DatasetSource: ds.Spec.Source,
+		SecretRef:     ds.Spec.SecretRef,
+	}}
+}
+
+// sourcesSummary 返回 spec.sources 中每个 source 的 name 和 type，用于 kubectl get 显示，只有 spec.source 时为空
+func sourcesSummary(ds *datasetv1alpha1.Dataset) string {
+	if !isMultiSource(ds) {
+		return ""
+	}
+	return strings.Join(lo.Map(ds.Spec.Sources, func(source datasetv1alpha1.DatasetSourceItem, _ int) string {
+		return fmt.Sprintf("%s:%s", source.Name, source.Type)
+	}), ",")
+}
+
+func supportPreloadType(typ datasetv1alpha1.DatasetType) bool {
+	if _, ok := datasources.Lookup(datasources.Type(typ)); ok {
+		return true
+	}
+	return lo.Contains(config.GetExternalLoaderTypes(), string(typ))
+}
+
+func sourceContainerName(source datasetv1alpha1.DatasetSourceItem) string {
+	if source.Name == "" {
+		return constants.DatasetJobContainerName
+	}
+	return fmt.Sprintf("%s-%s", constants.DatasetJobContainerName, source.Name)
+}
+
+func sourceSecretVolumeName(source datasetv1alpha1.DatasetSourceItem) string {
+	if source.Name == "" {
+		return "dataset-secret"
+	}
+	return fmt.Sprintf("dataset-secret-%s", source.Name)
+}
+
+// sourceMountPath 返回 source 在 dataset 中的路径，spec.sources 中的 source 默认同步到以 name 命名的子目录
+func sourceMountPath(ds *datasetv1alpha1.Dataset, source datasetv1alpha1.DatasetSourceItem) string {
+	if source.Name == "" {
+		return ds.Spec.MountOptions.Path
+	}
+	return path.Join("/", ds.Spec.MountOptions.Path, sourceSubPath(source))
+}
+
+// sourceSubPath 返回 source 同步到的 dataset 中的子目录，单个 source 时为空
+func sourceSubPath(source datasetv1alpha1.DatasetSourceItem) string {
+	if source.Name == "" {
+		return ""
+	}
+	if source.Path == "" {
+		return source.Name
+	}
+	return source.Path
+}
+
+// validateSourcePath 校验 source 的 path 不会逃逸出 dataset
+func validateSourcePath(source datasetv1alpha1.DatasetSourceItem) error {
+	if source.Path == "" {
+		return nil
+	}
+	if path.IsAbs(source.Path) || lo.Contains(strings.Split(source.Path, "/"), "..") {
+		return fmt.Errorf("path %s of source %s must be a relative path within the dataset", source.Path, source.Name)
+	}
+	return nil
+}
+
+// newLoaderContainer 基于 job 模板中的容器构造同步 source 的 data-loader 容器，source 所需的 volume 会加入 podSpec
+func newLoaderContainer(ds *datasetv1alpha1.Dataset, base corev1.Container, source datasetv1alpha1.DatasetSourceItem, podSpec *corev1.PodSpec) corev1.Container {
+	container := *base.DeepCopy()
+	container.Name = sourceContainerName(source)
+
+	// 预留资源请求
+	containerRequests := make(corev1.ResourceList)
+	containerLimits := make(corev1.ResourceList)
+
+	switch source.Type {
+	case datasetv1alpha1.DatasetTypeConda:
+		containerRequests[corev1.ResourceCPU] = resource.MustParse("2")
+		containerRequests[corev1.ResourceMemory] = resource.MustParse("2Gi")
+		containerLimits[corev1.ResourceCPU] = resource.MustParse("4")
+		containerLimits[corev1.ResourceMemory] = resource.MustParse("4Gi")
+
+	case datasetv1alpha1.DatasetTypeHuggingFace,
+		datasetv1alpha1.DatasetTypeModelScope:
+		containerRequests[corev1.ResourceCPU] = resource.MustParse("2")
+		containerRequests[corev1.ResourceMemory] = resource.MustParse("2Gi")
+		containerLimits[corev1.ResourceCPU] = resource.MustParse("4")
+		containerLimits[corev1.ResourceMemory] = resource.MustParse("8Gi")
+	}
+
+	// 如果有 GPU 需求
+	if gpuType, ok := source.Options["gpuType"]; ok {
+		switch gpuType {
+		case "nvidia-gpu":
+			containerRequests["nvidia.com/gpu"] = resource.MustParse("1")
+			containerLimits["nvidia.com/gpu"] = resource.MustParse("1")
+		case "nvidia-vgpu":
+			containerRequests["nvidia.com/vgpu"] = resource.MustParse("1")
+			containerRequests["nvidia.com/gpumem"] = resource.MustParse("500")
+			containerLimits["nvidia.com/vgpu"] = resource.MustParse("1")
+			containerLimits["nvidia.com/gpumem"] = resource.MustParse("500")
+		case "metax-gpu":
+			containerRequests["metax-tech.com/gpu"] = resource.MustParse("1")
+			containerLimits["metax-tech.com/gpu"] = resource.MustParse("1")
+		}
+	}
+
+	if len(containerRequests) > 0 {
+		container.Resources.Requests = containerRequests
+	}
+	if len(containerLimits) > 0 {
+		container.Resources.Limits = containerLimits
+	}
+
+	options := make(map[string]string)
+	for k, v := range source.Options {
+		options[k] = v
+	}
+
+	// conda 类型需要将 ConfigMap mount 到容器
+	condaKeyItems := make([]corev1.KeyToPath, 0, 2)
+	condaPodVolumeName := "dataset-config-conda"
+
+	switch source.Type {
+	case datasetv1alpha1.DatasetTypeConda:
+		if yamlData, ok := options["condaEnvironmentYml"]; ok && strings.TrimSpace(yamlData) != "" {
+			delete(options, "condaEnvironmentYml")
+			condaKeyItems = append(condaKeyItems, corev1.KeyToPath{
+				Key:  constants.DatasetJobCondaCondaEnvironmentYAMLFilename,
+				Path: constants.DatasetJobCondaCondaEnvironmentYAMLFilename,
+			})
+		}
+		if txt, ok := options["pipRequirementsTxt"]; ok && strings.TrimSpace(txt) != "" {
+			delete(options, "pipRequirementsTxt")
+			condaKeyItems = append(condaKeyItems, corev1.KeyToPath{
+				Key:  constants.DatasetJobCondaPipRequirementsTxtFilename,
+				Path: constants.DatasetJobCondaPipRequirementsTxtFilename,
+			})
+		}
+	}
+
+	if source.Type == datasetv1alpha1.DatasetTypeConda && len(condaKeyItems) > 0 {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: condaPodVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: datasetConfigMapName(ds),
+					},
+					Items: condaKeyItems,
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      condaPodVolumeName,
+			MountPath: constants.DatasetJobCondaConfigDir,
+			ReadOnly:  true,
+		})
+	}
+
+	// 如果有 SecretRef
+	if source.SecretRef != "" {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: sourceSecretVolumeName(source),
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: source.SecretRef,
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      sourceSecretVolumeName(source),
+			MountPath: constants.DatasetJobSecretsMountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "dataset-pvc",
+		MountPath: pvcMountPath,
+	})
+
+	// 构造命令行参数
+	switch source.Type {
+	case datasetv1alpha1.DatasetTypeConda:
+		// 这里把 gpuType 拿掉，已经单独处理过
+		delete(options, "gpuType")
+	}
+
+	args := []string{
+		string(source.Type),
+		source.URI,
+	}
+	for k, v := range options {
+		if regexp.MustCompile(`\s`).MatchString(v) {
+			args = append(args, fmt.Sprintf("--options=%s=%q", k, v))
+		} else {
+			args = append(args, fmt.Sprintf("--options=%s=%s", k, v))
+		}
+	}
+	if mountPath := sourceMountPath(ds, source); mountPath != "" {
+		args = append(args, fmt.Sprintf("--mount-path=%s", mountPath))
+	}
+	if ds.Spec.MountOptions.Mode != "" {
+		args = append(args, fmt.Sprintf("--mount-mode=%s", ds.Spec.MountOptions.Mode))
+	}
+	if ds.Spec.MountOptions.DirMode != "" {
+		args = append(args, fmt.Sprintf("--mount-dir-mode=%s", ds.Spec.MountOptions.DirMode))
+	}
+	args = append(args, fmt.Sprintf("--mount-uid=%d", ds.Spec.MountOptions.UID))
+	args = append(args, fmt.Sprintf("--mount-gid=%d", ds.Spec.MountOptions.GID))
+	args = append(args, fmt.Sprintf("--mount-root=%s", pvcMountPath))
+
+	limits := config.GetLoaderLimits()
+	if limits.BandwidthLimit != "" {
+		args = append(args, fmt.Sprintf("--bandwidth-limit=%s", limits.BandwidthLimit))
+	}
+	if limits.MaxConcurrency > 0 {
+		args = append(args, fmt.Sprintf("--max-concurrency=%d", limits.MaxConcurrency))
+	}
+	if limits.MaxRetries > 0 {
+		args = append(args, fmt.Sprintf("--max-retries=%d", limits.MaxRetries))
+	}
+	// 开启 pvc 自动扩容时由 data-loader 估算需要的空间
+	if config.GetPVCExpansionConfig().Enabled {
+		args = append(args, "--check-free-space")
+	}
+
+	// data-loader 的日志与 controller 使用同一份日志配置，并带上 dataset 相关字段
+	logConfig := config.GetLogConfig()
+	if logConfig.Format != "" {
+		args = append(args, fmt.Sprintf("--log-format=%s", logConfig.Format))
+	}
+	if level, ok := logConfig.Levels[dataLoaderLogComponent]; ok {
+		args = append(args, fmt.Sprintf("--log-level=%s", level))
+	}
+	args = append(args,
+		fmt.Sprintf("--dataset-namespace=%s", ds.Namespace),
+		fmt.Sprintf("--dataset-name=%s", ds.Name),
+		fmt.Sprintf("--dataset-round=%d", ds.Spec.DataSyncRound),
+	)
+
+	container.Args = args
+
+	return container
+}
@@ -0,0 +1,267 @@
+package dataset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+)
+
+func newMultiSourceDataset(policy datasetv1alpha1.DatasetSourcesPolicy) *datasetv1alpha1.Dataset {
+	return &datasetv1alpha1.Dataset{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "bundle"},
+		Spec: datasetv1alpha1.DatasetSpec{
+			Sources: []datasetv1alpha1.DatasetSourceItem{
+				{Name: "code", DatasetSource: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeGit, URI: "https://github.com/BaizeAI/dataset.git"}},
+				{Name: "weights", Path: "models/qwen", DatasetSource: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeS3, URI: "s3://models/qwen"}},
+				{Name: "docs", DatasetSource: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeHTTP, URI: "https://example.com/docs"}},
+			},
+			SourcesPolicy: policy,
+			MountOptions:  datasetv1alpha1.MountOptions{Path: "/data"},
+			DataSyncRound: 1,
+		},
+		Status: datasetv1alpha1.DatasetStatus{PVCName: "dataset-bundle"},
+	}
+}
+
+func TestNewLoaderContainer(t *testing.T) {
+	ds := newMultiSourceDataset(datasetv1alpha1.DatasetSourcesPolicyParallel)
+	base := corev1.Container{Name: "base", Image: "data-loader"}
+
+	t.Run("source w/ secret", func(t *testing.T) {
+		source := ds.Spec.Sources[1]
+		source.SecretRef = "s3-credentials"
+		podSpec := &corev1.PodSpec{}
+
+		container := newLoaderContainer(ds, base, source, podSpec)
+		assert.Equal(t, "dataset-loader-weights", container.Name)
+		assert.Equal(t, "data-loader", container.Image)
+		assert.Equal(t, []string{"S3", "s3://models/qwen"}, container.Args[:2])
+		assert.Contains(t, container.Args, "--mount-path=/data/models/qwen")
+		assert.Contains(t, container.Args, "--mount-root="+pvcMountPath)
+
+		require.Len(t, podSpec.Volumes, 1)
+		assert.Equal(t, "dataset-secret-weights", podSpec.Volumes[0].Name)
+		assert.Equal(t, "s3-credentials", podSpec.Volumes[0].Secret.SecretName)
+		assert.Equal(t, []string{"dataset-secret-weights", "dataset-pvc"}, lo.Map(container.VolumeMounts, func(m corev1.VolumeMount, _ int) string {
+			return m.Name
+		}))
+		assert.Equal(t, "base", base.Name, "base container must not be modified")
+	})
+
+	t.Run("source defaults to its name as path", func(t *testing.T) {
+		container := newLoaderContainer(ds, base, ds.Spec.Sources[0], &corev1.PodSpec{})
+		assert.Equal(t, "dataset-loader-code", container.Name)
+		assert.Contains(t, container.Args, "--mount-path=/data/code")
+	})
+
+	t.Run("single source", func(t *testing.T) {
+		single := &datasetv1alpha1.Dataset{
+			Spec: datasetv1alpha1.DatasetSpec{
+				Source: datasetv1alpha1.DatasetSource{
+					Type:    datasetv1alpha1.DatasetTypeHuggingFace,
+					URI:     "huggingface://Qwen/Qwen2-7B",
+					Options: map[string]string{"gpuType": "nvidia-gpu"},
+				},
+				MountOptions: datasetv1alpha1.MountOptions{Path: "/data"},
+			},
+		}
+		container := newLoaderContainer(single, base, datasetSources(single)[0], &corev1.PodSpec{})
+		assert.Equal(t, "dataset-loader", container.Name)
+		assert.Contains(t, container.Args, "--mount-path=/data")
+		assert.Equal(t, "1", container.Resources.Limits.Name("nvidia.com/gpu", "").String())
+	})
+}
+
+func TestReconcileJobSourcesPolicy(t *testing.T) {
+	names := func(containers []corev1.Container) []string {
+		return lo.Map(containers, func(c corev1.Container, _ int) string { return c.Name })
+	}
+
+	cases := []struct {
+		policy         datasetv1alpha1.DatasetSourcesPolicy
+		initContainers []string
+		containers     []string
+	}{
+		{
+			policy:     datasetv1alpha1.DatasetSourcesPolicyParallel,
+			containers: []string{"dataset-loader-code", "dataset-loader-weights", "dataset-loader-docs"},
+		},
+		{
+			policy:         datasetv1alpha1.DatasetSourcesPolicySequential,
+			initContainers: []string{"dataset-loader-code", "dataset-loader-weights"},
+			containers:     []string{"dataset-loader-docs"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(string(c.policy), func(t *testing.T) {
+			ds := newMultiSourceDataset(c.policy)
+			r := newTestReconciler(t, ds)
+
+			require.NoError(t, r.reconcileJob(context.Background(), ds))
+
+			job := &batchv1.Job{}
+			require.NoError(t, r.Get(context.Background(), client.ObjectKey{Namespace: "ns", Name: genJobName("bundle", 1)}, job))
+			podSpec := job.Spec.Template.Spec
+			assert.Equal(t, c.initContainers, lo.Ternary(len(podSpec.InitContainers) == 0, nil, names(podSpec.InitContainers)))
+			assert.Equal(t, c.containers, names(podSpec.Containers))
+		})
+	}
+}
+
+func TestValidateMultiSource(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(ds *datasetv1alpha1.Dataset)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			mutate: func(*datasetv1alpha1.Dataset) {},
+		},
+		{
+			name: "conda can not be composed",
+			mutate: func(ds *datasetv1alpha1.Dataset) {
+				ds.Spec.Sources[2].Type = datasetv1alpha1.DatasetTypeConda
+			},
+			wantErr: "can not be composed with other sources",
+		},
+		{
+			name: "absolute path",
+			mutate: func(ds *datasetv1alpha1.Dataset) {
+				ds.Spec.Sources[1].Path = "/models"
+			},
+			wantErr: "must be a relative path within the dataset",
+		},
+		{
+			name: "path escapes the dataset",
+			mutate: func(ds *datasetv1alpha1.Dataset) {
+				ds.Spec.Sources[1].Path = "models/../../etc"
+			},
+			wantErr: "must be a relative path within the dataset",
+		},
+		{
+			name: "same path",
+			mutate: func(ds *datasetv1alpha1.Dataset) {
+				ds.Spec.Sources[1].Path = "code"
+			},
+			wantErr: "sources code and weights are synced to the same path /data/code",
+		},
+		{
+			name: "unsupported type",
+			mutate: func(ds *datasetv1alpha1.Dataset) {
+				ds.Spec.Sources[2].Type = datasetv1alpha1.DatasetTypePVC
+			},
+			wantErr: "source docs: dataset source type PVC is not supported",
+		},
+		{
+			name: "invalid uri",
+			mutate: func(ds *datasetv1alpha1.Dataset) {
+				ds.Spec.Sources[0].URI = "ftp://github.com/BaizeAI/dataset.git"
+			},
+			wantErr: "source code: invalid scheme ftp",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ds := newMultiSourceDataset(datasetv1alpha1.DatasetSourcesPolicyParallel)
+			c.mutate(ds)
+			err := newTestReconciler(t).validateSource(context.Background(), ds)
+			if c.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), c.wantErr)
+		})
+	}
+}
+
+func TestReconcileSourcesStatus(t *testing.T) {
+	ds := newMultiSourceDataset(datasetv1alpha1.DatasetSourcesPolicySequential)
+	ds.Status.InProcessingRound = 1
+	job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: genJobName("bundle", 1)}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "ns",
+			Name:      genJobName("bundle", 1) + "-x7k2p",
+			Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "dataset-loader-code",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 0,
+						Message:  `{"revision":"8f2c1e0"}`,
+					}},
+				},
+				{
+					Name:                 "dataset-loader-weights",
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+				},
+			},
+		},
+	}
+
+	t.Run("in progress", func(t *testing.T) {
+		r := newTestReconciler(t, pod)
+		require.NoError(t, r.reconcileSourcesStatus(context.Background(), ds, job, false))
+
+		require.Len(t, ds.Status.Sources, 3)
+		assert.Equal(t, datasetv1alpha1.DatasetSourceStatus{
+			Name: "code", Round: 1, Phase: datasetv1alpha1.DatasetStatusPhaseReady, Revision: "8f2c1e0",
+		}, ds.Status.Sources[0])
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhaseProcessing, ds.Status.Sources[1].Phase)
+		assert.Equal(t, "data-loader exited with code 1: Error, restarting", ds.Status.Sources[1].Message)
+		assert.Equal(t, datasetv1alpha1.DatasetStatusPhasePending, ds.Status.Sources[2].Phase)
+	})
+
+	t.Run("job failed", func(t *testing.T) {
+		r := newTestReconciler(t, pod)
+		require.NoError(t, r.reconcileSourcesStatus(context.Background(), ds, job, true))
+
+		assert.Equal(t, []datasetv1alpha1.DatasetStatusPhase{
+			datasetv1alpha1.DatasetStatusPhaseReady,
+			datasetv1alpha1.DatasetStatusPhaseFailed,
+			datasetv1alpha1.DatasetStatusPhaseFailed,
+		}, lo.Map(ds.Status.Sources, func(s datasetv1alpha1.DatasetSourceStatus, _ int) datasetv1alpha1.DatasetStatusPhase {
+			return s.Phase
+		}))
+	})
+
+	t.Run("no pod yet", func(t *testing.T) {
+		r := newTestReconciler(t)
+		require.NoError(t, r.reconcileSourcesStatus(context.Background(), ds, job, false))
+		for _, s := range ds.Status.Sources {
+			assert.Equal(t, datasetv1alpha1.DatasetStatusPhasePending, s.Phase, s.Name)
+		}
+	})
+
+	t.Run("single source", func(t *testing.T) {
+		single := &datasetv1alpha1.Dataset{Status: datasetv1alpha1.DatasetStatus{Sources: ds.Status.Sources}}
+		require.NoError(t, newTestReconciler(t).reconcileSourcesStatus(context.Background(), single, job, false))
+		assert.Nil(t, single.Status.Sources)
+	})
+}
+
+func TestSourcesSummary(t *testing.T) {
+	single := &datasetv1alpha1.Dataset{
+		Spec: datasetv1alpha1.DatasetSpec{
+			Source: datasetv1alpha1.DatasetSource{Type: datasetv1alpha1.DatasetTypeGit, URI: "https://github.com/BaizeAI/dataset.git"},
+		},
+	}
+	assert.Empty(t, sourcesSummary(single))
+
+	multi := newMultiSourceDataset(datasetv1alpha1.DatasetSourcesPolicyParallel)
+	assert.Equal(t, "code:GIT,weights:S3,docs:HTTP", sourcesSummary(multi))
+}
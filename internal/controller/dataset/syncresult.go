@@ -0,0 +1,178 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	datasetv1alpha1 "github.com/BaizeAI/dataset/api/dataset/v1alpha1"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources"
+)
+
+// getJobPod returns the most recently created pod of the job, which reflects
+// the latest attempt of the round. A nil pod without error means no pod has
+// been created yet.
+func (r *DatasetReconciler) getJobPod(ctx context.Context, job *batchv1.Job) (*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	err := r.List(ctx, podList, client.InNamespace(job.Namespace), client.MatchingLabels{
+		batchv1.JobNameLabel: job.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(podList.Items) == 0 {
+		return nil, nil
+	}
+
+	pod := lo.MaxBy(podList.Items, func(a, b corev1.Pod) bool {
+		return a.CreationTimestamp.After(b.CreationTimestamp.Time)
+	})
+
+	return &pod, nil
+}
+
+// getJobSyncResult reads the sync result data-loader wrote as the termination
+// message of the container of the succeeded pod of the job. A nil result
+// without error means the loader reported nothing.
+func (r *DatasetReconciler) getJobSyncResult(ctx context.Context, job *batchv1.Job, containerName string) (*datasources.SyncResult, error) {
+	podList := &corev1.PodList{}
+	err := r.List(ctx, podList, client.InNamespace(job.Namespace), client.MatchingLabels{
+		batchv1.JobNameLabel: job.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+
+		status, ok := findContainerStatus(&pod, containerName)
+		if !ok || status.State.Terminated == nil {
+			continue
+		}
+
+		return parseSyncResult(status.State.Terminated)
+	}
+
+	return nil, nil
+}
+
+// getJobFailureResult reads the sync result data-loader wrote as the
+// termination message of the failed container of the latest pod of the job,
+// telling why the round failed. A nil result without error means the loader
+// reported nothing.
+func (r *DatasetReconciler) getJobFailureResult(ctx context.Context, job *batchv1.Job) (*datasources.SyncResult, error) {
+	pod, err := r.getJobPod(ctx, job)
+	if err != nil || pod == nil {
+		return nil, err
+	}
+
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if status.State.Terminated == nil || status.State.Terminated.ExitCode == 0 {
+			continue
+		}
+
+		result, err := parseSyncResult(status.State.Terminated)
+		if err != nil || result != nil {
+			return result, err
+		}
+	}
+
+	return nil, nil
+}
+
+// findContainerStatus looks up the status of the container among both the
+// containers and the init containers, sources synced sequentially run as
+// init containers.
+func findContainerStatus(pod *corev1.Pod, containerName string) (corev1.ContainerStatus, bool) {
+	return lo.Find(append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...), func(status corev1.ContainerStatus) bool {
+		return status.Name == containerName
+	})
+}
+
+func parseSyncResult(terminated *corev1.ContainerStateTerminated) (*datasources.SyncResult, error) {
+	message := strings.TrimSpace(terminated.Message)
+	if message == "" {
+		return nil, nil
+	}
+
+	result := &datasources.SyncResult{}
+	err := json.Unmarshal([]byte(message), result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// sourceStatusFromContainer derives the status of a source from the status of
+// the container syncing it.
+func sourceStatusFromContainer(status corev1.ContainerStatus, found bool) datasetv1alpha1.DatasetSourceStatus {
+	sourceStatus := datasetv1alpha1.DatasetSourceStatus{
+		Phase: datasetv1alpha1.DatasetStatusPhasePending,
+	}
+
+	switch {
+	case !found:
+	case status.State.Terminated != nil && status.State.Terminated.ExitCode == 0:
+		sourceStatus.Phase = datasetv1alpha1.DatasetStatusPhaseReady
+		result, err := parseSyncResult(status.State.Terminated)
+		if err != nil {
+			sourceStatus.Message = fmt.Sprintf("failed to parse sync result: %v", err)
+		} else if result != nil {
+			sourceStatus.Revision = result.Revision
+		}
+	case status.State.Terminated != nil:
+		sourceStatus.Phase = datasetv1alpha1.DatasetStatusPhaseFailed
+		sourceStatus.Message = fmt.Sprintf("data-loader exited with code %d: %s", status.State.Terminated.ExitCode, status.State.Terminated.Reason)
+	case status.State.Running != nil:
+		sourceStatus.Phase = datasetv1alpha1.DatasetStatusPhaseProcessing
+	case status.State.Waiting != nil && status.LastTerminationState.Terminated != nil:
+		// 容器失败后等待重启
+		sourceStatus.Phase = datasetv1alpha1.DatasetStatusPhaseProcessing
+		sourceStatus.Message = fmt.Sprintf("data-loader exited with code %d: %s, restarting",
+			status.LastTerminationState.Terminated.ExitCode, status.LastTerminationState.Terminated.Reason)
+	}
+
+	return sourceStatus
+}
+
+// reconcileSourcesStatus 根据 round job 最新 pod 中各个容器的状态更新每个 source 的状态
+func (r *DatasetReconciler) reconcileSourcesStatus(ctx context.Context, ds *datasetv1alpha1.Dataset, job *batchv1.Job, jobFailed bool) error {
+	if !isMultiSource(ds) {
+		ds.Status.Sources = nil
+		return nil
+	}
+
+	pod, err := r.getJobPod(ctx, job)
+	if err != nil {
+		return err
+	}
+
+	sources := make([]datasetv1alpha1.DatasetSourceStatus, 0, len(ds.Spec.Sources))
+	for _, source := range ds.Spec.Sources {
+		var sourceStatus datasetv1alpha1.DatasetSourceStatus
+		if pod != nil {
+			sourceStatus = sourceStatusFromContainer(findContainerStatus(pod, sourceContainerName(source)))
+		} else {
+			sourceStatus = sourceStatusFromContainer(corev1.ContainerStatus{}, false)
+		}
+		if jobFailed && sourceStatus.Phase != datasetv1alpha1.DatasetStatusPhaseReady {
+			sourceStatus.Phase = datasetv1alpha1.DatasetStatusPhaseFailed
+		}
+		sourceStatus.Name = source.Name
+		sourceStatus.Round = ds.Status.InProcessingRound
+		sources = append(sources, sourceStatus)
+	}
+	ds.Status.Sources = sources
+
+	return nil
+}
@@ -12,6 +12,11 @@ const (
 
 	DatasetJobCondaMountDir = "/opt/baize-runtime-env"
 
+	// DatasetJobTerminationMessagePath is where data-loader writes the
+	// result of a round for the controller to pick up from the pod status.
+	DatasetJobTerminationMessagePath string = "/dev/termination-log"
+	DatasetJobContainerName          string = "dataset-loader"
+
 	HamiVGPUTypeAnnotationName = "nvidia.com/use-gputype"
 )
 
@@ -24,4 +29,27 @@ const (
 	CondaEnvBaizeBaseBin string = CondaEnvBaizeBase + "/bin"
 
 	DatasetNameLabel = "baize.io/dataset-name"
+	// DatasetNamespaceLabel is set on the pvs cloned for REFERENCE datasets
+	// to the namespace of the dataset, which the pv can not be owned by.
+	DatasetNamespaceLabel = "baize.io/dataset-namespace"
+	// DatasetSourceHostsAnnotation lists the comma separated hosts a sync
+	// job fetches from, used to cap the concurrent jobs per host.
+	DatasetSourceHostsAnnotation = "baize.io/dataset-source-hosts"
+	// DatasetPlanAnnotation requests a plan of the next sync of the dataset,
+	// a new plan is made every time the value changes. It is also set on the
+	// plan job to the request the job plans for.
+	DatasetPlanAnnotation = "baize.io/dataset-plan"
+	// DatasetServiceAccountsAnnotation lists the comma separated service
+	// accounts the active DatasetShareGrants allow to use the pvc of a
+	// REFERENCE dataset, for admission policies to enforce.
+	DatasetServiceAccountsAnnotation = "baize.io/dataset-service-accounts"
+	// DatasetForceDeleteAnnotation set to true allows deleting a dataset
+	// that is still referenced by REFERENCE datasets.
+	DatasetForceDeleteAnnotation = "baize.io/dataset-force-delete"
+	// DatasetBoundPVCAnnotation records on a read-only PVC dataset the UID
+	// of the PVC it binds, instead of labeling the PVC of the user.
+	DatasetBoundPVCAnnotation = "baize.io/dataset-bound-pvc"
+	// DatasetPVCExpansionsAnnotation is set on a sync job to how many times
+	// the pvc had been expanded for the round when the job was created.
+	DatasetPVCExpansionsAnnotation = "baize.io/dataset-pvc-expansions"
 )
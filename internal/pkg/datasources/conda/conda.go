@@ -2,6 +2,7 @@ package conda
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -27,8 +28,8 @@ func NewMambaCLI() *MambaCLI {
 	}
 }
 
-func (c *MambaCLI) newCommand(args ...string) *exec.Cmd {
-	cmd := exec.Command("mamba", args...)
+func (c *MambaCLI) newCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := utils.CommandContext(ctx, "mamba", args...)
 	cmd.Env = os.Environ()
 	cmd.Env = append(cmd.Env, c.GetEnvs()...)
 
@@ -47,12 +48,12 @@ func (c *MambaCLI) GetEnvs() []string {
 
 // Version returns the version of conda
 // Equivalent to `conda --version`
-func (c *MambaCLI) Version(logger *logrus.Entry) (string, error) {
+func (c *MambaCLI) Version(ctx context.Context, logger *logrus.Entry) (string, error) {
 	args := []string{
 		"--version",
 	}
 
-	cmd := c.newCommand(args...)
+	cmd := c.newCommand(ctx, args...)
 	output, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
 	if err != nil {
 		return "", err
@@ -66,13 +67,13 @@ func (c *MambaCLI) Version(logger *logrus.Entry) (string, error) {
 
 // Info returns the conda info
 // Equivalent to `conda info --json`
-func (c *MambaCLI) Info(logger *logrus.Entry) (*CondaInfoOutputRaw, error) {
+func (c *MambaCLI) Info(ctx context.Context, logger *logrus.Entry) (*CondaInfoOutputRaw, error) {
 	args := []string{
 		"info",
 		"--json",
 	}
 
-	cmd := c.newCommand(args...)
+	cmd := c.newCommand(ctx, args...)
 	output, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
 	if err != nil {
 		return nil, err
@@ -91,14 +92,14 @@ func (c *MambaCLI) Info(logger *logrus.Entry) (*CondaInfoOutputRaw, error) {
 
 // EnvList returns the list of conda environments
 // Equivalent to `conda env list`
-func (c *MambaCLI) EnvList(logger *logrus.Entry) ([]CondaEnvListOutputEnv, error) {
+func (c *MambaCLI) EnvList(ctx context.Context, logger *logrus.Entry) ([]CondaEnvListOutputEnv, error) {
 	args := []string{
 		"env",
 		"list",
 		"--json",
 	}
 
-	cmd := c.newCommand(args...)
+	cmd := c.newCommand(ctx, args...)
 	output, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
 	if err != nil {
 		return make([]CondaEnvListOutputEnv, 0), err
@@ -147,7 +148,7 @@ func (c *MambaCLI) IsPrefixAlreadyExistsError(errBuffer *bytes.Buffer) bool {
 
 // CreateEnvFromFile creates a new conda environment from a file
 // Equivalent to `conda env create --file <file> --verbose -y`
-func (c *MambaCLI) CreateEnvFromFile(logger *logrus.Entry, file string) error {
+func (c *MambaCLI) CreateEnvFromFile(ctx context.Context, logger *logrus.Entry, file string) error {
 	args := []string{
 		"env",
 		"create",
@@ -156,7 +157,7 @@ func (c *MambaCLI) CreateEnvFromFile(logger *logrus.Entry, file string) error {
 		"--verbose",
 	}
 
-	cmd := c.newCommand(args...)
+	cmd := c.newCommand(ctx, args...)
 	_, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, []string{})
 	if err != nil {
 		if c.IsPrefixAlreadyExistsError(errBuffer) {
@@ -171,14 +172,14 @@ func (c *MambaCLI) CreateEnvFromFile(logger *logrus.Entry, file string) error {
 
 // CleanAll cleans all conda packages
 // Equivalent to `conda clean --all -y`
-func (c *MambaCLI) CleanAll(logger *logrus.Entry) error {
+func (c *MambaCLI) CleanAll(ctx context.Context, logger *logrus.Entry) error {
 	args := []string{
 		"clean",
 		"--all",
 		"-y",
 	}
 
-	cmd := c.newCommand(args...)
+	cmd := c.newCommand(ctx, args...)
 	err := utils.ExecuteCommand(logger, cmd, []string{})
 	if err != nil {
 		return err
@@ -18,6 +18,8 @@ type Secrets struct {
 
 	AKSKAccessKeyID     string `json:"-"`
 	AKSKSecretAccessKey string `json:"-"`
+
+	CABundle string `json:"-"`
 }
 
 type SecretKey string
@@ -30,6 +32,7 @@ const (
 	SecretKeyToken                SecretKey = "token"
 	SecretKeyAccessKey            SecretKey = "access-key"
 	SecretKeySecretKey            SecretKey = "secret-key"
+	SecretKeyCABundle             SecretKey = "ca.crt"
 )
 
 var (
@@ -41,6 +44,7 @@ var (
 		SecretKeyToken,
 		SecretKeyAccessKey,
 		SecretKeySecretKey,
+		SecretKeyCABundle,
 	}
 )
 
@@ -67,5 +71,6 @@ func ReadAndParseSecrets(name string) (Secrets, error) {
 		Token:                   mSecrets[SecretKeyToken],
 		AKSKAccessKeyID:         mSecrets[SecretKeyAccessKey],
 		AKSKSecretAccessKey:     mSecrets[SecretKeySecretKey],
+		CABundle:                mSecrets[SecretKeyCABundle],
 	}, nil
 }
@@ -1,6 +1,7 @@
 package datasources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -92,6 +93,17 @@ func (o *CondaLoaderOptions) extraIndexURLs() []string {
 
 var _ Loader = &CondaLoader{}
 
+func init() {
+	Register(Registration{
+		Type: TypeConda,
+		Factory: func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+			return NewCondaLoader(datasourceOptions, options, secrets)
+		},
+		Schemes: []string{"conda"},
+		Options: append(OptionKeysOf(CondaLoaderOptions{}), "condaEnvironmentYml", "pipRequirementsTxt"),
+	})
+}
+
 type CondaLoader struct {
 	Options Options
 
@@ -436,7 +448,7 @@ func (l *CondaLoader) cleanupNonExistingSymlinks(logger *logrus.Entry) error {
 	return nil
 }
 
-func (l *CondaLoader) moveToMountRoot(logger *logrus.Entry) error {
+func (l *CondaLoader) moveToMountRoot(ctx context.Context, logger *logrus.Entry) error {
 	err := os.MkdirAll(filepath.Dir(l.loaderOptions.finalPkgsDir), 0755)
 	if err != nil {
 		logger.WithError(err).Error("Failed to create conda dir")
@@ -455,7 +467,7 @@ func (l *CondaLoader) moveToMountRoot(logger *logrus.Entry) error {
 		return err
 	}
 
-	cmd := exec.Command("rclone",
+	cmd := utils.CommandContext(ctx, "rclone",
 		"copyto",
 		l.loaderOptions.prefixingPkgsDir,
 		l.loaderOptions.finalPkgsDir,
@@ -468,7 +480,7 @@ func (l *CondaLoader) moveToMountRoot(logger *logrus.Entry) error {
 		return err
 	}
 
-	cmd = exec.Command("rclone",
+	cmd = utils.CommandContext(ctx, "rclone",
 		"copyto",
 		l.loaderOptions.prefixingEnvsDir,
 		l.loaderOptions.finalEnvsDir,
@@ -510,7 +522,7 @@ func (l *CondaLoader) moveToMountRoot(logger *logrus.Entry) error {
 // finalize the conda environment:
 //   - mv /opt/baize-runtime-env/conda/pkgs ${mount-root}/conda/pkgs
 //   - mv /opt/baize-runtime-env/conda/envs ${mount-root}/conda/envs
-func (l *CondaLoader) Sync(_ string, _ string) error {
+func (l *CondaLoader) Sync(ctx context.Context, _ string, _ string) error {
 	logger := log.WithFields(logrus.Fields{
 		"type":                        TypeConda,
 		"applicationWorkingDirectory": lo.Must(os.Getwd()),
@@ -519,7 +531,7 @@ func (l *CondaLoader) Sync(_ string, _ string) error {
 	})
 
 	// Check if conda is installed
-	condaVersion, err := l.mamba.Version(logger)
+	condaVersion, err := l.mamba.Version(ctx, logger)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get conda version")
 		return err
@@ -538,14 +550,14 @@ func (l *CondaLoader) Sync(_ string, _ string) error {
 	}
 
 	// Get conda info
-	_, err = l.mamba.Info(logger)
+	_, err = l.mamba.Info(ctx, logger)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get conda info")
 		return err
 	}
 
 	// Check env lists before creating and configuring
-	_, err = l.mamba.EnvList(logger)
+	_, err = l.mamba.EnvList(ctx, logger)
 	if err != nil {
 		logger.WithError(err).Error("Failed to get conda env list")
 		return err
@@ -593,7 +605,7 @@ func (l *CondaLoader) Sync(_ string, _ string) error {
 	}
 	defer cleanup()
 
-	err = l.mamba.CreateEnvFromFile(logger, environmentFilePath)
+	err = l.mamba.CreateEnvFromFile(ctx, logger, environmentFilePath)
 	if err != nil {
 		logger.WithError(err).Error("Failed to create conda env from file")
 		return err
@@ -628,14 +640,14 @@ func (l *CondaLoader) Sync(_ string, _ string) error {
 		defer cleanup()
 
 		// Install requirements
-		err = l.pip.InstallWithRequirementsTxt(logger, requirementsFilePath)
+		err = l.pip.InstallWithRequirementsTxt(ctx, logger, requirementsFilePath)
 		if err != nil {
 			logger.WithError(err).Error("Failed to install requirements")
 			return err
 		}
 	}
 
-	err = l.mamba.CleanAll(logger)
+	err = l.mamba.CleanAll(ctx, logger)
 	if err != nil {
 		logger.WithError(err).Error("Failed to cleanup all packages, index cache, and tarballs, etc.")
 		return err
@@ -647,7 +659,7 @@ func (l *CondaLoader) Sync(_ string, _ string) error {
 		return err
 	}
 
-	err = l.moveToMountRoot(logger)
+	err = l.moveToMountRoot(ctx, logger)
 	if err != nil {
 		logger.WithError(err).Error("Failed to move conda envs and pkgs to mount root")
 		return err
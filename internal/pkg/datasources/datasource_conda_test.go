@@ -1,6 +1,7 @@
 package datasources
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
@@ -128,7 +129,7 @@ func TestCondaSync(t *testing.T) {
 		fakeConda.WithContext(func() {
 			fakePip.WithContext(func() {
 				fakeRclone.WithContext(func() {
-					err = condaLoader.Sync("", "")
+					err = condaLoader.Sync(context.Background(), "", "")
 					assert.NoError(t, err)
 				})
 			})
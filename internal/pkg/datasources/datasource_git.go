@@ -2,13 +2,13 @@ package datasources
 
 import (
 	"bytes"
+	"context"
 	"crypto"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -24,10 +24,22 @@ import (
 
 var _ Loader = &GitLoader{}
 
+func init() {
+	Register(Registration{
+		Type: TypeGit,
+		Factory: func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+			return NewGitLoader(datasourceOptions, options, secrets)
+		},
+		Schemes: []string{"http", "https", "git", "ssh"},
+		Options: OptionKeysOf(GitLoaderOptions{}),
+	})
+}
+
 type GitLoader struct {
 	Options Options
 
 	gitOptions GitLoaderOptions
+	revision   string
 }
 
 func NewGitLoader(datasourceOption map[string]string, options Options, secrets Secrets) (*GitLoader, error) {
@@ -49,12 +61,27 @@ func NewGitLoader(datasourceOption map[string]string, options Options, secrets S
 }
 
 type GitLoaderOptions struct {
-	Branch     string `json:"branch"`
-	Commit     string `json:"commit"`
+	Branch string `json:"branch"`
+	Commit string `json:"commit"`
+	Tag    string `json:"tag"`
+	// TagPattern is a semantic version constraint, e.g. >=1.2 <2, the
+	// highest matching tag will be checked out on each round
+	TagPattern string `json:"tagPattern"`
 	Depth      string `json:"depth"`
 	Submodules string `json:"submodules"`
+	LFS        string `json:"lfs"`
+	LFSInclude string `json:"lfsInclude"`
+	LFSExclude string `json:"lfsExclude"`
+	// SparsePaths is a comma separated list of cone mode patterns,
+	// e.g. data/train,data/eval
+	SparsePaths string `json:"sparsePaths"`
+	// Filter is the partial clone filter spec, e.g. blob:none
+	Filter string `json:"filter"`
 
 	depth                   int64
+	lfs                     *bool
+	sparsePaths             []string
+	tagConstraint           *utils.SemverConstraint
 	username                string
 	password                string
 	sshPrivateKey           string
@@ -93,6 +120,34 @@ func (d *GitLoaderOptions) parseOptionsFromOptions(options map[string]string) (G
 			return GitLoaderOptions{}, fmt.Errorf("failed to parse depth, err: %s", err)
 		}
 	}
+	if gitOptions.LFS != "" {
+		lfs, err := strconv.ParseBool(gitOptions.LFS)
+		if err != nil {
+			return GitLoaderOptions{}, fmt.Errorf("failed to parse lfs, err: %s", err)
+		}
+
+		gitOptions.lfs = &lfs
+	}
+	if (gitOptions.LFSInclude != "" || gitOptions.LFSExclude != "") && !lo.FromPtr(gitOptions.lfs) {
+		return GitLoaderOptions{}, fmt.Errorf("--options lfsInclude and lfsExclude require --options lfs=true")
+	}
+	if len(lo.Compact([]string{gitOptions.Branch, gitOptions.Tag, gitOptions.TagPattern})) > 1 {
+		return GitLoaderOptions{}, fmt.Errorf("only one of --options branch, tag and tagPattern can be specified")
+	}
+	if gitOptions.Commit != "" && (gitOptions.Tag != "" || gitOptions.TagPattern != "") {
+		return GitLoaderOptions{}, fmt.Errorf("--options commit can not be specified together with tag or tagPattern")
+	}
+	if gitOptions.TagPattern != "" {
+		gitOptions.tagConstraint, err = utils.ParseSemverConstraint(gitOptions.TagPattern)
+		if err != nil {
+			return GitLoaderOptions{}, fmt.Errorf("failed to parse tagPattern, err: %s", err)
+		}
+	}
+	if gitOptions.SparsePaths != "" {
+		gitOptions.sparsePaths = lo.Compact(lo.Map(strings.Split(gitOptions.SparsePaths, ","), func(item string, _ int) string {
+			return strings.Trim(strings.TrimSpace(item), "/")
+		}))
+	}
 
 	return gitOptions, nil
 }
@@ -158,6 +213,17 @@ func preparePrivateKeyToSSHDir(sshPrivateKey, sshPrivateKeyPassphrase string) (s
 	return sshPrivateKeyFullPath, os.WriteFile(sshPrivateKeyFullPath, privateKeyBuffer.Bytes(), 0600)
 }
 
+func removePrivateKeyFromSSHDir(sshPrivateKeyFullPath string) {
+	if sshPrivateKeyFullPath == "" {
+		return
+	}
+
+	err := os.Remove(sshPrivateKeyFullPath)
+	if err != nil && !os.IsNotExist(err) {
+		log.Warnf("failed to remove ssh private key %s, err: %s", sshPrivateKeyFullPath, err)
+	}
+}
+
 func (d *GitLoader) alterFromURIForToken(fromURI string, token string) string {
 	parsedURL, err := url.Parse(fromURI)
 	if err != nil {
@@ -196,7 +262,7 @@ func (d *GitLoader) alterFromURIForUsernameAndPasswordAccess(fromURI string, use
 	return parsedURL.String()
 }
 
-func (d *GitLoader) checkoutCommit(logger *logrus.Entry, gitDir string) error {
+func (d *GitLoader) checkoutCommit(ctx context.Context, logger *logrus.Entry, gitDir string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 	})
@@ -206,14 +272,15 @@ func (d *GitLoader) checkoutCommit(logger *logrus.Entry, gitDir string) error {
 		d.gitOptions.Commit,
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) updateIndex(logger *logrus.Entry, gitDir string) error {
+func (d *GitLoader) updateIndex(ctx context.Context, logger *logrus.Entry, gitDir string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 	})
@@ -223,14 +290,14 @@ func (d *GitLoader) updateIndex(logger *logrus.Entry, gitDir string) error {
 		"--refresh",
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) addAll(logger *logrus.Entry, gitDir string) error {
+func (d *GitLoader) addAll(ctx context.Context, logger *logrus.Entry, gitDir string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": d.Options.Root,
 		"gitDirectory":     gitDir,
@@ -250,14 +317,14 @@ func (d *GitLoader) addAll(logger *logrus.Entry, gitDir string) error {
 		"-u",
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) stashPendingChanges(logger *logrus.Entry, gitDir string) error {
+func (d *GitLoader) stashPendingChanges(ctx context.Context, logger *logrus.Entry, gitDir string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 	})
@@ -266,14 +333,14 @@ func (d *GitLoader) stashPendingChanges(logger *logrus.Entry, gitDir string) err
 		"stash",
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) resetHardToTargetBranch(logger *logrus.Entry, gitDir string, branch string) error {
+func (d *GitLoader) resetHardToTargetBranch(ctx context.Context, logger *logrus.Entry, gitDir string, branch string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 	})
@@ -288,14 +355,14 @@ func (d *GitLoader) resetHardToTargetBranch(logger *logrus.Entry, gitDir string,
 		args = append(args, "origin/HEAD")
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) remoteAddURL(logger *logrus.Entry, fromURI string, gitDir string, name string) error {
+func (d *GitLoader) remoteAddURL(ctx context.Context, logger *logrus.Entry, fromURI string, gitDir string, name string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 		"alteredFromURI":   utils.ObscureString(fromURI, d.secrets()),
@@ -309,14 +376,14 @@ func (d *GitLoader) remoteAddURL(logger *logrus.Entry, fromURI string, gitDir st
 		fromURI,
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) remoteSetURL(logger *logrus.Entry, fromURI string, gitDir string, name string) error {
+func (d *GitLoader) remoteSetURL(ctx context.Context, logger *logrus.Entry, fromURI string, gitDir string, name string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 		"alteredFromURI":   utils.ObscureString(fromURI, d.secrets()),
@@ -330,14 +397,14 @@ func (d *GitLoader) remoteSetURL(logger *logrus.Entry, fromURI string, gitDir st
 		fromURI,
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) remoteRemove(logger *logrus.Entry, gitDir string, name string) error {
+func (d *GitLoader) remoteRemove(ctx context.Context, logger *logrus.Entry, gitDir string, name string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 		"remoteName":       name,
@@ -349,14 +416,14 @@ func (d *GitLoader) remoteRemove(logger *logrus.Entry, gitDir string, name strin
 		name,
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) configGlobalSetSafeDirectory(logger *logrus.Entry, gitDir string, directory string) error {
+func (d *GitLoader) configGlobalSetSafeDirectory(ctx context.Context, logger *logrus.Entry, gitDir string, directory string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 	})
@@ -368,14 +435,14 @@ func (d *GitLoader) configGlobalSetSafeDirectory(logger *logrus.Entry, gitDir st
 		directory,
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) configSetFileMode(logger *logrus.Entry, gitDir string, mode bool) error {
+func (d *GitLoader) configSetFileMode(ctx context.Context, logger *logrus.Entry, gitDir string, mode bool) error {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": gitDir,
 	})
@@ -387,14 +454,14 @@ func (d *GitLoader) configSetFileMode(logger *logrus.Entry, gitDir string, mode
 		fmt.Sprintf("%t", mode),
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) clone(logger *logrus.Entry, alteredFromURI string, cloneToPath string) error {
+func (d *GitLoader) clone(ctx context.Context, logger *logrus.Entry, alteredFromURI string, cloneToPath string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"alteredFromURI":   utils.ObscureString(alteredFromURI, d.secrets()),
 		"cloneToPath":      cloneToPath,
@@ -415,6 +482,8 @@ func (d *GitLoader) clone(logger *logrus.Entry, alteredFromURI string, cloneToPa
 	}
 	if d.gitOptions.Branch != "" {
 		args = append(args, "--branch", d.gitOptions.Branch)
+	} else if d.revision != "" {
+		args = append(args, "--branch", d.revision)
 	}
 	if d.gitOptions.depth > 0 {
 		args = append(args, "--depth", fmt.Sprintf("%d", d.gitOptions.depth))
@@ -426,11 +495,18 @@ func (d *GitLoader) clone(logger *logrus.Entry, alteredFromURI string, cloneToPa
 
 		args = append(args, "--recurse-submodules")
 	}
+	if d.gitOptions.Filter != "" {
+		args = append(args, fmt.Sprintf("--filter=%s", d.gitOptions.Filter))
+	}
+	if len(d.gitOptions.sparsePaths) > 0 {
+		args = append(args, "--sparse")
+	}
 
 	args = append(args, "-v")
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = d.Options.Root
 	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
 	if d.gitOptions.sshPrivateKey != "" {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
 	}
@@ -438,7 +514,7 @@ func (d *GitLoader) clone(logger *logrus.Entry, alteredFromURI string, cloneToPa
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) branch(logger *logrus.Entry, forPath string) (string, error) {
+func (d *GitLoader) branch(ctx context.Context, logger *logrus.Entry, forPath string) (string, error) {
 	logger = logger.WithFields(logrus.Fields{
 		"workingDirectory": forPath,
 	})
@@ -448,7 +524,7 @@ func (d *GitLoader) branch(logger *logrus.Entry, forPath string) (string, error)
 		"--show-current",
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := utils.CommandContext(ctx, "git", args...)
 	cmd.Dir = forPath
 	cmd.Env = os.Environ()
 
@@ -463,7 +539,7 @@ func (d *GitLoader) branch(logger *logrus.Entry, forPath string) (string, error)
 	return branch, nil
 }
 
-func (d *GitLoader) pull(logger *logrus.Entry, alteredFromURI string, pullForPath string, remoteName string) error {
+func (d *GitLoader) pull(ctx context.Context, logger *logrus.Entry, alteredFromURI string, pullForPath string, remoteName string, branch string) error {
 	logger = logger.WithFields(logrus.Fields{
 		"alteredFromURI":   utils.ObscureString(alteredFromURI, d.secrets()),
 		"pullForPath":      pullForPath,
@@ -480,21 +556,501 @@ func (d *GitLoader) pull(logger *logrus.Entry, alteredFromURI string, pullForPat
 	args := []string{
 		"pull",
 		remoteName,
+		branch,
+		"-v",
 	}
-	if d.gitOptions.Branch != "" {
-		args = append(args, d.gitOptions.Branch)
-	} else {
-		currentBranch, err := d.branch(logger, pullForPath)
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = pullForPath
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
+	if d.gitOptions.sshPrivateKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+func (d *GitLoader) sparseCheckoutSet(ctx context.Context, logger *logrus.Entry, gitDir string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"sparsePaths":      d.gitOptions.sparsePaths,
+	})
+
+	args := []string{
+		"sparse-checkout",
+		"set",
+		"--cone",
+	}
+	args = append(args, d.gitOptions.sparsePaths...)
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	if d.gitOptions.sshPrivateKey != "" {
+		// blobs of newly included paths are lazily fetched from the promisor
+		// remote when a filter is configured
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+func (d *GitLoader) sparseCheckoutDisable(ctx context.Context, logger *logrus.Entry, gitDir string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+	})
+
+	args := []string{
+		"sparse-checkout",
+		"disable",
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	if d.gitOptions.sshPrivateKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+func (d *GitLoader) isSparseCheckout(ctx context.Context, logger *logrus.Entry, gitDir string) (bool, error) {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+	})
+
+	args := []string{
+		"config",
+		"--local",
+		"--default",
+		"false",
+		"--type",
+		"bool",
+		"--get",
+		"core.sparseCheckout",
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+
+	outBuffer, err := utils.ExecuteCommandWithOutput(logger, cmd, d.secrets())
+	if err != nil {
+		return false, err
+	}
+
+	return strings.TrimSpace(outBuffer.String()) == "true", nil
+}
+
+func (d *GitLoader) configSetRemotePromisor(ctx context.Context, logger *logrus.Entry, gitDir string, name string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"remoteName":       name,
+		"filter":           d.gitOptions.Filter,
+	})
+
+	for _, entry := range []lo.Entry[string, string]{
+		{Key: "promisor", Value: "true"},
+		{Key: "partialclonefilter", Value: d.gitOptions.Filter},
+	} {
+		cmd := utils.CommandContext(ctx, "git", "config", "--local", fmt.Sprintf("remote.%s.%s", name, entry.Key), entry.Value)
+		cmd.Dir = gitDir
+		cmd.Env = os.Environ()
+
+		err := utils.ExecuteCommand(logger, cmd, d.secrets())
 		if err != nil {
 			return err
 		}
+	}
+
+	return nil
+}
 
-		args = append(args, currentBranch)
+// syncSparseCheckout applies the sparse checkout patterns to the working tree.
+// For an existing repository the patterns are re-applied on every round so
+// that changed sparsePaths take effect without a reclone, and a previously
+// sparse working tree is restored in full when sparsePaths is removed.
+func (d *GitLoader) syncSparseCheckout(ctx context.Context, logger *logrus.Entry, gitDir string, cloned bool) error {
+	if len(d.gitOptions.sparsePaths) > 0 {
+		return d.sparseCheckoutSet(ctx, logger, gitDir)
+	}
+	if cloned {
+		return nil
 	}
 
-	args = append(args, "-v")
-	cmd := exec.Command("git", args...)
-	cmd.Dir = pullForPath
+	// the patterns file only exists once sparse checkout has been set up,
+	// skip asking git about it for repositories that were never sparse
+	_, err := os.Stat(filepath.Join(gitDir, ".git", "info", "sparse-checkout"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to stat sparse checkout patterns for git repository, err: %s", err)
+	}
+
+	sparse, err := d.isSparseCheckout(ctx, logger, gitDir)
+	if err != nil {
+		return err
+	}
+	if !sparse {
+		return nil
+	}
+
+	return d.sparseCheckoutDisable(ctx, logger, gitDir)
+}
+
+// lfsEnv returns the environment variables that keep the LFS smudge filter
+// from fetching objects during clone, pull and checkout. When lfs=true the
+// objects are fetched afterwards by syncLFS with the include and exclude
+// patterns applied, when lfs=false they are never fetched at all.
+func (d *GitLoader) lfsEnv() []string {
+	if d.gitOptions.lfs == nil {
+		return nil
+	}
+
+	return []string{"GIT_LFS_SKIP_SMUDGE=1"}
+}
+
+func (d *GitLoader) lfsInstall(ctx context.Context, logger *logrus.Entry, gitDir string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+	})
+
+	args := []string{
+		"lfs",
+		"install",
+		"--local",
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+func (d *GitLoader) lfsPull(ctx context.Context, logger *logrus.Entry, gitDir string, remoteName string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"remoteName":       remoteName,
+		"lfsInclude":       d.gitOptions.LFSInclude,
+		"lfsExclude":       d.gitOptions.LFSExclude,
+	})
+	if d.gitOptions.sshPrivateKey != "" && d.gitOptions.sshPrivateKeyFullPath != "" {
+		logger = logger.WithFields(logrus.Fields{
+			"privateKeyFilePath": d.gitOptions.sshPrivateKeyFullPath,
+		})
+	}
+
+	logger.Debugf("performing git lfs pull command to fetch large files served by git server")
+
+	args := []string{
+		"lfs",
+		"pull",
+		remoteName,
+	}
+	if d.gitOptions.LFSInclude != "" {
+		args = append(args, "--include", d.gitOptions.LFSInclude)
+	}
+	if d.gitOptions.LFSExclude != "" {
+		args = append(args, "--exclude", d.gitOptions.LFSExclude)
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	if d.gitOptions.sshPrivateKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+// lfsPointerFiles lists the files tracked by LFS that are still pointer files
+// in the working tree, honouring the same include and exclude patterns as
+// lfsPull so that deliberately skipped files are not reported.
+func (d *GitLoader) lfsPointerFiles(ctx context.Context, logger *logrus.Entry, gitDir string) ([]string, error) {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+	})
+
+	args := []string{
+		"lfs",
+		"ls-files",
+	}
+	if d.gitOptions.LFSInclude != "" {
+		args = append(args, "--include", d.gitOptions.LFSInclude)
+	}
+	if d.gitOptions.LFSExclude != "" {
+		args = append(args, "--exclude", d.gitOptions.LFSExclude)
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+
+	outBuffer, err := utils.ExecuteCommandWithOutput(logger, cmd, d.secrets())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLFSPointerFiles(outBuffer.String()), nil
+}
+
+// parseLFSPointerFiles parses the output of git lfs ls-files, where each line
+// looks like
+//
+//	<oid> * <path>
+//
+// with "*" marking a fetched object and "-" marking a pointer file.
+func parseLFSPointerFiles(output string) []string {
+	pointerFiles := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), " ", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "-" {
+			pointerFiles = append(pointerFiles, fields[2])
+		}
+	}
+
+	return pointerFiles
+}
+
+func (d *GitLoader) syncLFS(ctx context.Context, logger *logrus.Entry, gitDir string, remoteName string) error {
+	if !lo.FromPtr(d.gitOptions.lfs) {
+		return nil
+	}
+
+	err := d.lfsInstall(ctx, logger, gitDir)
+	if err != nil {
+		return err
+	}
+
+	err = d.lfsPull(ctx, logger, gitDir, remoteName)
+	if err != nil {
+		return err
+	}
+
+	pointerFiles, err := d.lfsPointerFiles(ctx, logger, gitDir)
+	if err != nil {
+		return err
+	}
+	if len(pointerFiles) > 0 {
+		return fmt.Errorf("%d lfs files remain as pointer files after sync: %s", len(pointerFiles), strings.Join(pointerFiles, ", "))
+	}
+
+	return nil
+}
+
+func (d *GitLoader) lsRemoteTags(ctx context.Context, logger *logrus.Entry, alteredFromURI string) ([]string, error) {
+	logger = logger.WithFields(logrus.Fields{
+		"alteredFromURI":   utils.ObscureString(alteredFromURI, d.secrets()),
+		"workingDirectory": d.Options.Root,
+	})
+
+	args := []string{
+		"ls-remote",
+		"--tags",
+		"--refs",
+		alteredFromURI,
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = d.Options.Root
+	cmd.Env = os.Environ()
+	if d.gitOptions.sshPrivateKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+
+	outBuffer, err := utils.ExecuteCommandWithOutput(logger, cmd, d.secrets())
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLsRemoteTags(outBuffer.String()), nil
+}
+
+// parseLsRemoteTags parses the output of git ls-remote --tags --refs, where
+// each line looks like
+//
+//	<sha>	refs/tags/<tag>
+func parseLsRemoteTags(output string) []string {
+	tags := make([]string, 0)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/tags/") {
+			continue
+		}
+
+		tags = append(tags, strings.TrimPrefix(fields[1], "refs/tags/"))
+	}
+
+	return tags
+}
+
+// resolveTag decides which tag should be checked out for this round, either
+// the fixed tag option, or the highest tag on the remote that satisfies
+// tagPattern.
+func (d *GitLoader) resolveTag(ctx context.Context, logger *logrus.Entry, alteredFromURI string) error {
+	if d.gitOptions.Tag != "" {
+		d.revision = d.gitOptions.Tag
+		return nil
+	}
+	if d.gitOptions.tagConstraint == nil {
+		return nil
+	}
+
+	tags, err := d.lsRemoteTags(ctx, logger, alteredFromURI)
+	if err != nil {
+		return err
+	}
+
+	tag, ok := utils.HighestMatchingSemver(tags, d.gitOptions.tagConstraint)
+	if !ok {
+		return fmt.Errorf("no tag of git repository matches tagPattern %s", d.gitOptions.TagPattern)
+	}
+
+	logger.Infof("resolved tag %s from tagPattern %s", tag, d.gitOptions.TagPattern)
+	d.revision = tag
+
+	return nil
+}
+
+func (d *GitLoader) fetchTag(ctx context.Context, logger *logrus.Entry, gitDir string, remoteName string, tag string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"remoteName":       remoteName,
+		"tag":              tag,
+	})
+	if d.gitOptions.sshPrivateKey != "" && d.gitOptions.sshPrivateKeyFullPath != "" {
+		logger = logger.WithFields(logrus.Fields{
+			"privateKeyFilePath": d.gitOptions.sshPrivateKeyFullPath,
+		})
+	}
+
+	logger.Debugf("performing git fetch command to replicate tag served by git server")
+
+	args := []string{
+		"fetch",
+		// tags may be moved on the remote
+		"--force",
+		"--no-tags",
+	}
+	if d.gitOptions.depth > 0 {
+		args = append(args, "--depth", fmt.Sprintf("%d", d.gitOptions.depth))
+	}
+
+	args = append(args, remoteName, "tag", tag, "-v")
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	if d.gitOptions.sshPrivateKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+func (d *GitLoader) checkoutTag(ctx context.Context, logger *logrus.Entry, gitDir string, tag string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"tag":              tag,
+	})
+
+	args := []string{
+		"checkout",
+		"--force",
+		"refs/tags/" + tag,
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+// lsRemoteDefaultBranch asks the remote which branch its HEAD points to.
+func (d *GitLoader) lsRemoteDefaultBranch(ctx context.Context, logger *logrus.Entry, gitDir string, remoteName string) (string, error) {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"remoteName":       remoteName,
+	})
+
+	args := []string{
+		"ls-remote",
+		"--symref",
+		remoteName,
+		"HEAD",
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	if d.gitOptions.sshPrivateKey != "" {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
+	}
+
+	outBuffer, err := utils.ExecuteCommandWithOutput(logger, cmd, d.secrets())
+	if err != nil {
+		return "", err
+	}
+
+	branch := parseLsRemoteSymref(outBuffer.String())
+	if branch == "" {
+		return "", fmt.Errorf("failed to find the default branch of git repository from output: %s", outBuffer.String())
+	}
+
+	return branch, nil
+}
+
+// parseLsRemoteSymref parses the output of git ls-remote --symref <remote>
+// HEAD, where the first line looks like
+//
+//	ref: refs/heads/<branch>	HEAD
+func parseLsRemoteSymref(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD" {
+			return strings.TrimPrefix(fields[1], "refs/heads/")
+		}
+	}
+
+	return ""
+}
+
+func (d *GitLoader) fetchBranch(ctx context.Context, logger *logrus.Entry, gitDir string, remoteName string, branch string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"remoteName":       remoteName,
+		"branch":           branch,
+	})
+	if d.gitOptions.sshPrivateKey != "" && d.gitOptions.sshPrivateKeyFullPath != "" {
+		logger = logger.WithFields(logrus.Fields{
+			"privateKeyFilePath": d.gitOptions.sshPrivateKeyFullPath,
+		})
+	}
+
+	logger.Debugf("performing git fetch command to replicate branch served by git server")
+
+	args := []string{
+		"fetch",
+		"--no-tags",
+	}
+	if d.gitOptions.depth > 0 {
+		args = append(args, "--depth", fmt.Sprintf("%d", d.gitOptions.depth))
+	}
+
+	args = append(args, remoteName, branch, "-v")
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
 	cmd.Env = os.Environ()
 	if d.gitOptions.sshPrivateKey != "" {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -o StrictHostKeyChecking=no -i %s", d.gitOptions.sshPrivateKeyFullPath))
@@ -503,7 +1059,67 @@ func (d *GitLoader) pull(logger *logrus.Entry, alteredFromURI string, pullForPat
 	return utils.ExecuteCommand(logger, cmd, d.secrets())
 }
 
-func (d *GitLoader) configBeforeOperations(logger *logrus.Entry, finalizedGitDir string) error {
+// checkoutFetchedBranch points the local branch at the fetched branch and
+// checks it out, whatever HEAD was before.
+func (d *GitLoader) checkoutFetchedBranch(ctx context.Context, logger *logrus.Entry, gitDir string, branch string) error {
+	logger = logger.WithFields(logrus.Fields{
+		"workingDirectory": gitDir,
+		"branch":           branch,
+	})
+
+	args := []string{
+		"checkout",
+		"--force",
+		"-B",
+		branch,
+		"FETCH_HEAD",
+	}
+
+	cmd := utils.CommandContext(ctx, "git", args...)
+	cmd.Dir = gitDir
+	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, d.lfsEnv()...)
+
+	return utils.ExecuteCommand(logger, cmd, d.secrets())
+}
+
+// checkoutTargetBranch checks out the branch to pull, which is the branch
+// option, or the current branch, or the default branch of the remote when
+// HEAD is detached, e.g. by the tag checked out in a previous round. Pulling
+// on a detached HEAD would leave it detached.
+func (d *GitLoader) checkoutTargetBranch(ctx context.Context, logger *logrus.Entry, gitDir string, remoteName string) (string, error) {
+	currentBranch, err := d.branch(ctx, logger, gitDir)
+	if err != nil {
+		return "", err
+	}
+
+	branch := lo.CoalesceOrEmpty(d.gitOptions.Branch, currentBranch)
+	if branch == "" {
+		branch, err = d.lsRemoteDefaultBranch(ctx, logger, gitDir, remoteName)
+		if err != nil {
+			return "", err
+		}
+	}
+	if branch == currentBranch {
+		return branch, nil
+	}
+
+	logger.Infof("checking out branch %s instead of %s", branch, lo.CoalesceOrEmpty(currentBranch, "detached HEAD"))
+
+	err = d.fetchBranch(ctx, logger, gitDir, remoteName, branch)
+	if err != nil {
+		return "", err
+	}
+
+	err = d.checkoutFetchedBranch(ctx, logger, gitDir, branch)
+	if err != nil {
+		return "", err
+	}
+
+	return branch, nil
+}
+
+func (d *GitLoader) configBeforeOperations(ctx context.Context, logger *logrus.Entry, finalizedGitDir string) error {
 	// Since data-loader should always be run as root (uid: 0, gid: 0),
 	// while after clone and pull, chmod and chown will be executed in
 	// order to alter the file mode and owner of the files, or contains
@@ -513,7 +1129,7 @@ func (d *GitLoader) configBeforeOperations(logger *logrus.Entry, finalizedGitDir
 	// error when performing later on git commands.
 	// Hence, we should set the safe.directory to * to avoid
 	// further errors.
-	err := d.configGlobalSetSafeDirectory(logger, finalizedGitDir, "*")
+	err := d.configGlobalSetSafeDirectory(ctx, logger, finalizedGitDir, "*")
 	if err != nil {
 		return err
 	}
@@ -521,102 +1137,147 @@ func (d *GitLoader) configBeforeOperations(logger *logrus.Entry, finalizedGitDir
 	return nil
 }
 
-func (d *GitLoader) syncWithClone(logger *logrus.Entry, fromURI string, alteredFromURI string, toPath string, finalizedGitDir string) error {
+func (d *GitLoader) syncWithClone(ctx context.Context, logger *logrus.Entry, fromURI string, alteredFromURI string, toPath string, finalizedGitDir string) error {
 	defer func() {
 		if (d.gitOptions.username != "" || d.gitOptions.password != "") || (d.gitOptions.token != "") {
-			err := d.remoteSetURL(logger, fromURI, finalizedGitDir, "origin")
+			// credentials must not be left in .git/config even if interrupted
+			cleanupCtx, cancel := cleanupContext(ctx)
+			defer cancel()
+
+			err := d.remoteSetURL(cleanupCtx, logger, fromURI, finalizedGitDir, "origin")
 			if err != nil {
 				logger.Warnf("failed to set remote url for git repository, err: %s", err)
 			}
 		}
 	}()
 
-	err := d.clone(logger, alteredFromURI, toPath)
+	err := d.clone(ctx, logger, alteredFromURI, toPath)
 	if err != nil {
 		return err
 	}
 
-	err = d.configBeforeOperations(logger, finalizedGitDir)
+	err = d.configBeforeOperations(ctx, logger, finalizedGitDir)
 	if err != nil {
 		return err
 	}
 
 	// otherwise, after PostCopy stages, the file mode will be changed to d.Options.Mode and
 	// result in massive files being changed in the git repository
-	err = d.configSetFileMode(logger, finalizedGitDir, false)
+	err = d.configSetFileMode(ctx, logger, finalizedGitDir, false)
+	if err != nil {
+		return err
+	}
+
+	err = d.syncSparseCheckout(ctx, logger, finalizedGitDir, true)
 	if err != nil {
 		return err
 	}
 
 	if d.gitOptions.Commit != "" {
-		err = d.checkoutCommit(logger, finalizedGitDir)
+		err = d.checkoutCommit(ctx, logger, finalizedGitDir)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return d.syncLFS(ctx, logger, finalizedGitDir, "origin")
 }
 
-func (d *GitLoader) syncWithPull(logger *logrus.Entry, _ string, alteredFromURI string, _ string, finalizedGitDir string) error {
-	err := d.configBeforeOperations(logger, finalizedGitDir)
+func (d *GitLoader) syncWithPull(ctx context.Context, logger *logrus.Entry, _ string, alteredFromURI string, _ string, finalizedGitDir string) error {
+	err := d.configBeforeOperations(ctx, logger, finalizedGitDir)
 	if err != nil {
 		return err
 	}
 
-	err = d.updateIndex(logger, finalizedGitDir)
+	err = d.updateIndex(ctx, logger, finalizedGitDir)
 	if err != nil {
 		// update index is ignorable
 		logger.Warnf("failed to update index for git repository, err: %s", err)
 	}
 
-	err = d.addAll(logger, finalizedGitDir)
+	err = d.addAll(ctx, logger, finalizedGitDir)
 	if err != nil {
 		return err
 	}
 
-	err = d.stashPendingChanges(logger, finalizedGitDir)
+	err = d.stashPendingChanges(ctx, logger, finalizedGitDir)
 	if err != nil {
 		return err
 	}
 
-	err = d.resetHardToTargetBranch(logger, finalizedGitDir, d.gitOptions.Branch)
-	if err != nil {
-		return err
+	if d.revision == "" {
+		err = d.resetHardToTargetBranch(ctx, logger, finalizedGitDir, d.gitOptions.Branch)
+		if err != nil {
+			return err
+		}
 	}
 
 	pullRemoteName := fmt.Sprintf("dataset-pull-remote-%s", utils.RandomHashString(8))
 
 	defer func() {
 		if (d.gitOptions.username != "" || d.gitOptions.password != "") || (d.gitOptions.token != "") {
-			err := d.remoteRemove(logger, finalizedGitDir, pullRemoteName)
+			cleanupCtx, cancel := cleanupContext(ctx)
+			defer cancel()
+
+			err := d.remoteRemove(cleanupCtx, logger, finalizedGitDir, pullRemoteName)
 			if err != nil {
 				logger.Warnf("failed to remove remote for git repository, err: %s", err)
 			}
 		}
 	}()
 
-	err = d.remoteAddURL(logger, alteredFromURI, finalizedGitDir, pullRemoteName)
+	err = d.remoteAddURL(ctx, logger, alteredFromURI, finalizedGitDir, pullRemoteName)
 	if err != nil {
 		return err
 	}
 
-	err = d.pull(logger, alteredFromURI, finalizedGitDir, pullRemoteName)
+	if d.gitOptions.Filter != "" {
+		// the pull remote carries the credentials, so it has to be the one
+		// that missing objects are lazily fetched from
+		err = d.configSetRemotePromisor(ctx, logger, finalizedGitDir, pullRemoteName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.revision != "" {
+		err = d.fetchTag(ctx, logger, finalizedGitDir, pullRemoteName, d.revision)
+		if err != nil {
+			return err
+		}
+
+		err = d.checkoutTag(ctx, logger, finalizedGitDir, d.revision)
+		if err != nil {
+			return err
+		}
+	} else {
+		branch, err := d.checkoutTargetBranch(ctx, logger, finalizedGitDir, pullRemoteName)
+		if err != nil {
+			return err
+		}
+
+		err = d.pull(ctx, logger, alteredFromURI, finalizedGitDir, pullRemoteName, branch)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = d.syncSparseCheckout(ctx, logger, finalizedGitDir, false)
 	if err != nil {
 		return err
 	}
 
 	if d.gitOptions.Commit != "" {
-		err = d.checkoutCommit(logger, finalizedGitDir)
+		err = d.checkoutCommit(ctx, logger, finalizedGitDir)
 		if err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return d.syncLFS(ctx, logger, finalizedGitDir, pullRemoteName)
 }
 
-func (d *GitLoader) Sync(fromURI string, toPath string) error {
+func (d *GitLoader) Sync(ctx context.Context, fromURI string, toPath string) error {
 	var err error
 
 	alteredFromURI := fromURI
@@ -628,6 +1289,7 @@ func (d *GitLoader) Sync(fromURI string, toPath string) error {
 	}
 	if d.gitOptions.sshPrivateKey != "" {
 		d.gitOptions.sshPrivateKeyFullPath, err = preparePrivateKeyToSSHDir(d.gitOptions.sshPrivateKey, d.gitOptions.sshPrivateKeyPassphrase)
+		defer removePrivateKeyFromSSHDir(d.gitOptions.sshPrivateKeyFullPath)
 		if err != nil {
 			return err
 		}
@@ -639,13 +1301,23 @@ func (d *GitLoader) Sync(fromURI string, toPath string) error {
 		"type":                        TypeGit,
 		"branch":                      d.gitOptions.Branch,
 		"commit":                      d.gitOptions.Commit,
+		"tag":                         d.gitOptions.Tag,
+		"tagPattern":                  d.gitOptions.TagPattern,
 		"depth":                       d.gitOptions.Depth,
 		"submodules":                  d.gitOptions.Submodules,
+		"lfs":                         d.gitOptions.LFS,
+		"sparsePaths":                 d.gitOptions.SparsePaths,
+		"filter":                      d.gitOptions.Filter,
 		"applicationWorkingDirectory": lo.Must(os.Getwd()),
 		"root":                        d.Options.Root,
 		"path":                        toPath,
 	})
 
+	err = d.resolveTag(ctx, logger, alteredFromURI)
+	if err != nil {
+		return err
+	}
+
 	finalizedGitDir := filepath.Join(d.Options.Root, toPath)
 
 	checkingGitDir := filepath.Join(finalizedGitDir, ".git")
@@ -655,11 +1327,16 @@ func (d *GitLoader) Sync(fromURI string, toPath string) error {
 			return fmt.Errorf("failed to stat %s before pull or clone for git repository, err: %s", checkingGitDir, err)
 		}
 
-		return d.syncWithClone(logger, fromURI, alteredFromURI, toPath, finalizedGitDir)
+		return d.syncWithClone(ctx, logger, fromURI, alteredFromURI, toPath, finalizedGitDir)
 	}
 	if !stats.IsDir() {
 		return fmt.Errorf("failed to pull or clone for git repository, %s is not a directory", checkingGitDir)
 	}
 
-	return d.syncWithPull(logger, fromURI, alteredFromURI, toPath, finalizedGitDir)
+	return d.syncWithPull(ctx, logger, fromURI, alteredFromURI, toPath, finalizedGitDir)
+}
+
+// Revision returns the tag that has been checked out, if any.
+func (d *GitLoader) Revision() string {
+	return d.revision
 }
@@ -1,6 +1,7 @@
 package datasources
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"testing"
@@ -53,7 +54,7 @@ func TestGitLoader(t *testing.T) {
 		}()
 		assert.NoError(t, err)
 		fakeGit.WithContext(func() {
-			err = git.Sync("git://github.com/ndx-baize/baize.git", gitDir)
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
 			assert.NoError(t, err)
 		})
 		bbs := fakeGit.GetAllInputs()
@@ -105,7 +106,7 @@ func TestGitLoader(t *testing.T) {
 		}()
 		assert.NoError(t, err)
 		fakeGit.WithContext(func() {
-			err = git.Sync("git://github.com/ndx-baize/baize.git", gitDir)
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
 			assert.NoError(t, err)
 		})
 		bbs := fakeGit.GetAllInputs()
@@ -116,6 +117,315 @@ func TestGitLoader(t *testing.T) {
 			[]byte("checkout 12345\n"),
 		}, bbs)
 	})
+	t.Run("clone w/ lfs", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"branch":     "master",
+			"lfs":        "true",
+			"lfsInclude": "*.safetensors",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "clone", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "install", exit: 0},
+				{stdout: "pull", exit: 0},
+				{stdout: "4d7a214614 * model.safetensors\n", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		assert.Equal(t, [][]byte{
+			[]byte(fmt.Sprintf("clone git://github.com/ndx-baize/baize.git %s --branch master -v\n", gitDir)),
+			[]byte("config --global safe.directory *\n"),
+			[]byte("config --local core.fileMode false\n"),
+			[]byte("lfs install --local\n"),
+			[]byte("lfs pull origin --include *.safetensors\n"),
+			[]byte("lfs ls-files --include *.safetensors\n"),
+		}, bbs)
+	})
+	t.Run("clone w/ lfs pointer files remaining", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"lfs": "true",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "clone", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "install", exit: 0},
+				{stdout: "pull", exit: 0},
+				{stdout: "4d7a214614 * a.bin\n9e1b3c5d7f - b.bin\n", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.EqualError(t, err, "1 lfs files remain as pointer files after sync: b.bin")
+		})
+	})
+	t.Run("lfs patterns w/o lfs", func(t *testing.T) {
+		_, err := NewGitLoader(map[string]string{
+			"lfsInclude": "*.bin",
+		}, Options{}, Secrets{})
+		assert.Error(t, err)
+	})
+	t.Run("clone w/ sparse paths and filter", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"branch":      "master",
+			"sparsePaths": "data/train, /data/eval/",
+			"filter":      "blob:none",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "clone", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "sparse-checkout", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		assert.Equal(t, [][]byte{
+			[]byte(fmt.Sprintf("clone git://github.com/ndx-baize/baize.git %s --branch master --filter=blob:none --sparse -v\n", gitDir)),
+			[]byte("config --global safe.directory *\n"),
+			[]byte("config --local core.fileMode false\n"),
+			[]byte("sparse-checkout set --cone data/train data/eval\n"),
+		}, bbs)
+	})
+	t.Run("pull w/ sparse paths and filter", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"branch":      "master",
+			"sparsePaths": "data",
+			"filter":      "blob:none",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "config", exit: 0},
+				{stdout: "update", exit: 0},
+				{stdout: "add", exit: 0},
+				{stdout: "stash", exit: 0},
+				{stdout: "reset", exit: 0},
+				{stdout: "remote", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "master\n", exit: 0},
+				{stdout: "pull", exit: 0},
+				{stdout: "sparse-checkout", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		require.NoError(t, os.Mkdir(gitDir+"/.git", 0755))
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		require.Len(t, bbs, 11)
+		assert.Contains(t, string(bbs[5]), "remote add")
+		assert.Regexp(t, `^config --local remote\.dataset-pull-remote-\w+\.promisor true\n$`, string(bbs[6]))
+		assert.Regexp(t, `^config --local remote\.dataset-pull-remote-\w+\.partialclonefilter blob:none\n$`, string(bbs[7]))
+		assert.Equal(t, "branch --show-current\n", string(bbs[8]))
+		assert.Contains(t, string(bbs[9]), "pull")
+		assert.Equal(t, "sparse-checkout set --cone data\n", string(bbs[10]))
+	})
+	t.Run("pull w/o sparse paths on sparse repository", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"branch": "master",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "config", exit: 0},
+				{stdout: "update", exit: 0},
+				{stdout: "add", exit: 0},
+				{stdout: "stash", exit: 0},
+				{stdout: "reset", exit: 0},
+				{stdout: "remote", exit: 0},
+				{stdout: "master\n", exit: 0},
+				{stdout: "pull", exit: 0},
+				{stdout: "true\n", exit: 0},
+				{stdout: "sparse-checkout", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		require.NoError(t, os.MkdirAll(gitDir+"/.git/info", 0755))
+		require.NoError(t, os.WriteFile(gitDir+"/.git/info/sparse-checkout", []byte("/*\n!/*/\n/data/\n"), 0600))
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		require.Len(t, bbs, 10)
+		assert.Equal(t, "config --local --default false --type bool --get core.sparseCheckout\n", string(bbs[8]))
+		assert.Equal(t, "sparse-checkout disable\n", string(bbs[9]))
+	})
+	t.Run("clone w/ tagPattern", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"tagPattern": ">=1.2 <2",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "a1\trefs/tags/v1.1.0\na2\trefs/tags/v1.2.0\na3\trefs/tags/v1.10.0\na4\trefs/tags/v2.0.0\n", exit: 0},
+				{stdout: "clone", exit: 0},
+				{stdout: "config", exit: 0},
+				{stdout: "config", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		assert.Equal(t, [][]byte{
+			[]byte("ls-remote --tags --refs git://github.com/ndx-baize/baize.git\n"),
+			[]byte(fmt.Sprintf("clone git://github.com/ndx-baize/baize.git %s --branch v1.10.0 -v\n", gitDir)),
+			[]byte("config --global safe.directory *\n"),
+			[]byte("config --local core.fileMode false\n"),
+		}, bbs)
+		assert.Equal(t, "v1.10.0", git.Revision())
+	})
+	t.Run("clone w/ tagPattern w/o matching tags", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"tagPattern": ">=3",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "a1\trefs/tags/v1.1.0\n", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.EqualError(t, err, "no tag of git repository matches tagPattern >=3")
+		})
+	})
+	t.Run("pull w/ tag", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"tag": "v1.0.0",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "config", exit: 0},
+				{stdout: "update", exit: 0},
+				{stdout: "add", exit: 0},
+				{stdout: "stash", exit: 0},
+				{stdout: "remote", exit: 0},
+				{stdout: "fetch", exit: 0},
+				{stdout: "checkout", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir, _ := os.MkdirTemp("", "git-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(gitDir))
+		}()
+		require.NoError(t, os.Mkdir(gitDir+"/.git", 0755))
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		require.Len(t, bbs, 7)
+		assert.Contains(t, string(bbs[4]), "remote add")
+		assert.Regexp(t, `^fetch --force --no-tags dataset-pull-remote-\w+ tag v1\.0\.0 -v\n$`, string(bbs[5]))
+		assert.Equal(t, "checkout --force refs/tags/v1.0.0\n", string(bbs[6]))
+		assert.Equal(t, "v1.0.0", git.Revision())
+	})
+	t.Run("conflicting refs", func(t *testing.T) {
+		_, err := NewGitLoader(map[string]string{
+			"branch": "main",
+			"tag":    "v1.0.0",
+		}, Options{}, Secrets{})
+		assert.Error(t, err)
+
+		_, err = NewGitLoader(map[string]string{
+			"commit":     "12345",
+			"tagPattern": ">=1",
+		}, Options{}, Secrets{})
+		assert.Error(t, err)
+
+		_, err = NewGitLoader(map[string]string{
+			"tagPattern": "latest",
+		}, Options{}, Secrets{})
+		assert.Error(t, err)
+	})
 	t.Run("pull w/ branch", func(t *testing.T) {
 		git, err := NewGitLoader(map[string]string{
 			"branch": "master",
@@ -155,6 +465,11 @@ func TestGitLoader(t *testing.T) {
 					stderr: "",
 					exit:   0,
 				},
+				{
+					stdout: "master\n",
+					stderr: "",
+					exit:   0,
+				},
 				{
 					stdout: "ok",
 					stderr: "",
@@ -172,14 +487,14 @@ func TestGitLoader(t *testing.T) {
 		require.NoError(t, os.Mkdir(gitDir+"/.git", 0755))
 		assert.NoError(t, err)
 		fakeGit.WithContext(func() {
-			err = git.Sync("git://github.com/ndx-baize/baize.git", gitDir)
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
 			assert.NoError(t, err)
 		})
 		bbs := fakeGit.GetAllInputs()
 		assert.Contains(t, string(bbs[5]), "remote add")
-		assert.Contains(t, string(bbs[6]), "pull")
+		assert.Regexp(t, `^pull dataset-pull-remote-\w+ master -v\n$`, string(bbs[7]))
 		bbs[5] = []byte{}
-		bbs[6] = []byte{}
+		bbs[7] = []byte{}
 		assert.Equal(t, [][]byte{
 			[]byte("config --global safe.directory *\n"),
 			[]byte("update-index --refresh\n"),
@@ -187,6 +502,7 @@ func TestGitLoader(t *testing.T) {
 			[]byte("stash\n"),
 			[]byte("reset --hard master\n"),
 			{},
+			[]byte("branch --show-current\n"),
 			{},
 		}, bbs)
 	})
@@ -249,7 +565,7 @@ func TestGitLoader(t *testing.T) {
 		require.NoError(t, os.Mkdir(gitDir+"/.git", 0755))
 		assert.NoError(t, err)
 		fakeGit.WithContext(func() {
-			err = git.Sync("git://github.com/ndx-baize/baize.git", gitDir)
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
 			assert.NoError(t, err)
 		})
 		bbs := fakeGit.GetAllInputs()
@@ -268,4 +584,87 @@ func TestGitLoader(t *testing.T) {
 			{},
 		}, bbs)
 	})
+	t.Run("pull w/ branch after tag", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"branch": "master",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "config", exit: 0},
+				{stdout: "update", exit: 0},
+				{stdout: "add", exit: 0},
+				{stdout: "stash", exit: 0},
+				{stdout: "reset", exit: 0},
+				{stdout: "remote", exit: 0},
+				// detached HEAD left by the tag of the previous round
+				{stdout: "\n", exit: 0},
+				{stdout: "fetch", exit: 0},
+				{stdout: "checkout", exit: 0},
+				{stdout: "pull", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir := t.TempDir()
+		require.NoError(t, os.Mkdir(gitDir+"/.git", 0755))
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		require.Len(t, bbs, 10)
+		assert.Equal(t, "branch --show-current\n", string(bbs[6]))
+		assert.Regexp(t, `^fetch --no-tags dataset-pull-remote-\w+ master -v\n$`, string(bbs[7]))
+		assert.Equal(t, "checkout --force -B master FETCH_HEAD\n", string(bbs[8]))
+		assert.Regexp(t, `^pull dataset-pull-remote-\w+ master -v\n$`, string(bbs[9]))
+	})
+	t.Run("pull w/o branch after tag", func(t *testing.T) {
+		git, err := NewGitLoader(map[string]string{
+			"depth": "1",
+		}, Options{}, Secrets{})
+		assert.NoError(t, err)
+		fakeGit := fakeCommand{
+			t:   t,
+			cmd: "git",
+			outputs: []out{
+				{stdout: "config", exit: 0},
+				{stdout: "update", exit: 0},
+				{stdout: "add", exit: 0},
+				{stdout: "stash", exit: 0},
+				{stdout: "reset", exit: 0},
+				{stdout: "remote", exit: 0},
+				{stdout: "\n", exit: 0},
+				{stdout: "ref: refs/heads/main\tHEAD\n4d7a214614ab2935c943f9e0ff69d22eadbb8f32\tHEAD\n", exit: 0},
+				{stdout: "fetch", exit: 0},
+				{stdout: "checkout", exit: 0},
+				{stdout: "pull", exit: 0},
+			},
+		}
+		defer func() {
+			assert.NoError(t, fakeGit.Clean())
+		}()
+		gitDir := t.TempDir()
+		require.NoError(t, os.Mkdir(gitDir+"/.git", 0755))
+		fakeGit.WithContext(func() {
+			err = git.Sync(context.Background(), "git://github.com/ndx-baize/baize.git", gitDir)
+			assert.NoError(t, err)
+		})
+		bbs := fakeGit.GetAllInputs()
+		require.Len(t, bbs, 11)
+		assert.Regexp(t, `^ls-remote --symref dataset-pull-remote-\w+ HEAD\n$`, string(bbs[7]))
+		assert.Regexp(t, `^fetch --no-tags --depth 1 dataset-pull-remote-\w+ main -v\n$`, string(bbs[8]))
+		assert.Equal(t, "checkout --force -B main FETCH_HEAD\n", string(bbs[9]))
+		assert.Regexp(t, `^pull dataset-pull-remote-\w+ main -v\n$`, string(bbs[10]))
+	})
+}
+
+func TestParseLsRemoteSymref(t *testing.T) {
+	assert.Equal(t, "main", parseLsRemoteSymref("ref: refs/heads/main\tHEAD\n4d7a214614ab2935c943f9e0ff69d22eadbb8f32\tHEAD\n"))
+	assert.Equal(t, "release/v1", parseLsRemoteSymref("ref: refs/heads/release/v1\tHEAD\n"))
+	assert.Equal(t, "", parseLsRemoteSymref("4d7a214614ab2935c943f9e0ff69d22eadbb8f32\tHEAD\n"))
+	assert.Equal(t, "", parseLsRemoteSymref(""))
 }
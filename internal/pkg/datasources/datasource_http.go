@@ -1,25 +1,76 @@
 package datasources
 
 import (
-	"encoding/base64"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/time/rate"
 
 	"github.com/BaizeAI/dataset/pkg/log"
 	"github.com/BaizeAI/dataset/pkg/utils"
 )
 
 var _ Loader = &HTTPLoader{}
+var _ Planner = &HTTPLoader{}
+var _ ChangeReporter = &HTTPLoader{}
+
+func init() {
+	Register(Registration{
+		Type: TypeHTTP,
+		Factory: func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+			return NewHTTPLoader(datasourceOptions, options, secrets)
+		},
+		Schemes: []string{"http", "https"},
+		Options: OptionKeysOf(HTTPLoaderOptions{}),
+		// any other options are sent as http headers
+		AnyOptions: true,
+	})
+}
+
+type HTTPChecksum string
+
+const (
+	// HTTPChecksumAuto verifies the files that have a sidecar .sha256 file.
+	HTTPChecksumAuto HTTPChecksum = "auto"
+	// HTTPChecksumRequired fails the files without a sidecar .sha256 file.
+	HTTPChecksumRequired HTTPChecksum = "required"
+	HTTPChecksumNone     HTTPChecksum = "none"
+)
+
+const (
+	httpUserAgent          = "baize-data-loader"
+	httpDefaultConcurrency = 4
+)
 
 type HTTPLoader struct {
+	changeRecorder
+
 	Options Options
 
 	httpOptions HTTPLoaderOptions
+	filter      *rcloneFilter
+	client      *http.Client
+	limiter     *rate.Limiter
 }
 
 func NewHTTPLoader(datasourceOptions map[string]string, options Options, secrets Secrets) (*HTTPLoader, error) {
@@ -32,117 +83,692 @@ func NewHTTPLoader(datasourceOptions map[string]string, options Options, secrets
 		return nil, fmt.Errorf("failed to parse uri %s: %w", options.URI, err)
 	}
 
-	h.httpOptions.basicAuthUsername = secrets.Username
-	h.httpOptions.basicAuthPassword = secrets.Password
+	h.httpOptions, err = h.parseOptionsFromOptions(datasourceOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	h.httpOptions.basicAuthUsername = strings.TrimSpace(secrets.Username)
+	h.httpOptions.basicAuthPassword = strings.TrimSpace(secrets.Password)
+	h.httpOptions.token = strings.TrimSpace(secrets.Token)
+
+	err = h.httpOptions.RcloneFilterOptions.validate()
+	if err != nil {
+		return nil, err
+	}
+	h.filter, err = h.httpOptions.RcloneFilterOptions.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if secrets.CABundle != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(secrets.CABundle)) {
+			return nil, fmt.Errorf("no certificates found in the %s of the secret", SecretKeyCABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	h.client = &http.Client{Transport: transport}
+
+	if options.BandwidthLimit != "" {
+		bandwidthLimit, err := utils.ParseBandwidth(options.BandwidthLimit)
+		if err != nil {
+			return nil, err
+		}
+		h.limiter = utils.NewBandwidthLimiter(bandwidthLimit)
+	}
 
 	return h, nil
 }
 
 type HTTPLoaderOptions struct {
+	RcloneFilterOptions
+
+	// MaxDepth is how many levels of sub directories of a directory index
+	// are crawled, unlimited if empty, 0 only syncs the files listed in the
+	// index of the URI itself.
+	MaxDepth string `json:"maxDepth"`
+	// Checksum is one of auto (default), required or none, see HTTPChecksum.
+	Checksum string `json:"checksum"`
+
+	maxDepth int
+	checksum HTTPChecksum
+	headers  http.Header
+
 	basicAuthUsername string
 	basicAuthPassword string
+	token             string
+}
 
-	fromURI string
+func (d *HTTPLoader) parseOptionsFromOptions(options map[string]string) (HTTPLoaderOptions, error) {
+	jsonContent, err := json.Marshal(options)
+	if err != nil {
+		return HTTPLoaderOptions{}, err
+	}
+
+	var httpOptions HTTPLoaderOptions
+	err = json.Unmarshal(jsonContent, &httpOptions)
+	if err != nil {
+		return HTTPLoaderOptions{}, err
+	}
+
+	httpOptions.maxDepth = -1
+	if httpOptions.MaxDepth != "" {
+		httpOptions.maxDepth, err = strconv.Atoi(httpOptions.MaxDepth)
+		if err != nil || httpOptions.maxDepth < 0 {
+			return HTTPLoaderOptions{}, fmt.Errorf("invalid maxDepth %s, must be a non-negative integer", httpOptions.MaxDepth)
+		}
+	}
+
+	httpOptions.checksum = HTTPChecksum(httpOptions.Checksum)
+	switch httpOptions.checksum {
+	case "":
+		httpOptions.checksum = HTTPChecksumAuto
+	case HTTPChecksumAuto, HTTPChecksumRequired, HTTPChecksumNone:
+	default:
+		return HTTPLoaderOptions{}, fmt.Errorf("invalid checksum %s, must be one of auto, required, none", httpOptions.Checksum)
+	}
+
+	// the options that are neither of the loader nor common to all types are
+	// the http headers
+	httpOptions.headers = make(http.Header)
+	knownKeys := append(OptionKeysOf(HTTPLoaderOptions{}), commonOptionKeys...)
+	for k, v := range options {
+		if lo.Contains(knownKeys, k) {
+			continue
+		}
+		httpOptions.headers.Set(k, v)
+	}
+
+	return httpOptions, nil
 }
 
-func (d *HTTPLoader) configTouch() error {
-	return rcloneCliConfigTouch()
+// httpFile is a file to sync, Path is slash separated and relative to the
+// directory synced to.
+type httpFile struct {
+	Path string
+	URL  *url.URL
 }
 
-func (d *HTTPLoader) configCreate(configName string) error {
-	logger := log.WithFields(logrus.Fields{
-		"configName": configName,
-		"type":       TypeHTTP,
-		"fromURI":    d.httpOptions.fromURI,
-	})
+type httpStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d of %s", e.StatusCode, e.URL)
+}
+
+func isHTTPStatus(err error, statusCode int) bool {
+	var statusErr *httpStatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == statusCode
+}
+
+func (d *HTTPLoader) newRequest(ctx context.Context, method string, u *url.URL) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range d.httpOptions.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	if d.httpOptions.token != "" {
+		req.Header.Set("Authorization", "Bearer "+d.httpOptions.token)
+	} else if d.httpOptions.basicAuthUsername != "" || d.httpOptions.basicAuthPassword != "" {
+		req.SetBasicAuth(d.httpOptions.basicAuthUsername, d.httpOptions.basicAuthPassword)
+	}
+
+	return req, nil
+}
+
+// do sends the request and fails unless the response has one of the status
+// codes, the body of the returned response has to be closed by the caller.
+func (d *HTTPLoader) do(req *http.Request, statusCodes ...int) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !lo.Contains(statusCodes, resp.StatusCode) {
+		_ = resp.Body.Close()
+		return nil, &httpStatusError{URL: req.URL.Redacted(), StatusCode: resp.StatusCode}
+	}
+
+	return resp, nil
+}
+
+// resolveSource tells whether the URI is a directory index, which is the
+// case if its path ends with a slash, possibly after being redirected.
+func (d *HTTPLoader) resolveSource(ctx context.Context, u *url.URL) (*url.URL, bool, error) {
+	if strings.HasSuffix(u.Path, "/") {
+		return u, true, nil
+	}
+
+	req, err := d.newRequest(ctx, http.MethodHead, u)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	_ = resp.Body.Close()
+
+	if strings.HasSuffix(resp.Request.URL.Path, "/") {
+		return resp.Request.URL, true, nil
+	}
+
+	return u, false, nil
+}
+
+// crawl lists the files of the directory index served at root, e.g. by the
+// autoindex of nginx or Apache, following the links to the sub directories
+// up to maxDepth levels.
+func (d *HTTPLoader) crawl(ctx context.Context, logger *logrus.Entry, root *url.URL) ([]httpFile, error) {
+	type dir struct {
+		url    *url.URL
+		prefix string
+		depth  int
+	}
+
+	files := make([]httpFile, 0)
+	visited := map[string]struct{}{root.String(): {}}
+	queue := []dir{{url: root}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		logger.Debugf("crawling directory index %s", current.url.Redacted())
+
+		dirURL, links, err := d.listLinks(ctx, current.url)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, link := range links {
+			// only the direct children are listed, which rules out the links
+			// to the parent directory, to other hosts or sorting the index
+			if link.Host != dirURL.Host || link.RawQuery != "" || !strings.HasPrefix(link.Path, dirURL.Path) {
+				continue
+			}
+			name := strings.TrimPrefix(link.Path, dirURL.Path)
+			if name == "" || strings.Contains(strings.TrimSuffix(name, "/"), "/") {
+				continue
+			}
+
+			link.Fragment = ""
+			if strings.HasSuffix(name, "/") {
+				if d.httpOptions.maxDepth >= 0 && current.depth >= d.httpOptions.maxDepth {
+					continue
+				}
+				if _, ok := visited[link.String()]; ok {
+					continue
+				}
+				visited[link.String()] = struct{}{}
+				queue = append(queue, dir{url: link, prefix: current.prefix + name, depth: current.depth + 1})
+				continue
+			}
+
+			files = append(files, httpFile{Path: current.prefix + name, URL: link})
+		}
+	}
+
+	return lo.UniqBy(files, func(file httpFile) string {
+		return file.Path
+	}), nil
+}
+
+// listLinks returns the url of the directory after the redirects, if any,
+// and the links of its index resolved against it.
+func (d *HTTPLoader) listLinks(ctx context.Context, u *url.URL) (*url.URL, []*url.URL, error) {
+	req, err := d.newRequest(ctx, http.MethodGet, u)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := d.do(req, http.StatusOK)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get directory index %s: %w", u.Redacted(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	dirURL := resp.Request.URL
+	links := make([]*url.URL, 0)
+	tokenizer := html.NewTokenizer(resp.Body)
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if errors.Is(tokenizer.Err(), io.EOF) {
+				return dirURL, links, nil
+			}
+			return nil, nil, fmt.Errorf("failed to parse directory index %s: %w", u.Redacted(), tokenizer.Err())
+		}
+		if tokenType != html.StartTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		if token.Data != "a" {
+			continue
+		}
+		for _, attr := range token.Attr {
+			if attr.Key != "href" {
+				continue
+			}
+			link, err := dirURL.Parse(attr.Val)
+			if err == nil {
+				links = append(links, link)
+			}
+		}
+	}
+}
+
+// fileInfo returns the size and the modification time of the file, -1 and
+// the zero time if the server does not tell.
+func (d *HTTPLoader) fileInfo(ctx context.Context, u *url.URL) (int64, time.Time, error) {
+	req, err := d.newRequest(ctx, http.MethodHead, u)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	_ = resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return -1, time.Time{}, nil
+	default:
+		return 0, time.Time{}, &httpStatusError{URL: u.Redacted(), StatusCode: resp.StatusCode}
+	}
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+
+	return resp.ContentLength, modTime, nil
+}
+
+// expectedChecksum reads the sha256 checksum from the sidecar file next to
+// the file, if any.
+func (d *HTTPLoader) expectedChecksum(ctx context.Context, u *url.URL) (string, error) {
+	if d.httpOptions.checksum == HTTPChecksumNone {
+		return "", nil
+	}
+
+	checksumURL := *u
+	checksumURL.Path += ".sha256"
+	checksumURL.RawPath = ""
+
+	req, err := d.newRequest(ctx, http.MethodGet, &checksumURL)
+	if err != nil {
+		return "", err
+	}
+	resp, err := d.do(req, http.StatusOK)
+	if err != nil {
+		if isHTTPStatus(err, http.StatusNotFound) && d.httpOptions.checksum == HTTPChecksumAuto {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get checksum %s: %w", checksumURL.Redacted(), err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// the content is either the checksum itself or the output of sha256sum
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum %s", checksumURL.Redacted())
+	}
+	checksum := strings.ToLower(fields[0])
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid sha256 checksum %s in %s", fields[0], checksumURL.Redacted())
+	}
+
+	return checksum, nil
+}
 
-	args := []string{
-		"config",
-		"create",
-		configName,
-		"http",
-		strings.Join([]string{"url", d.httpOptions.fromURI}, "="),
+// download downloads the file to toFile, resuming from the end of toFile with
+// a Range request if it exists.
+func (d *HTTPLoader) download(ctx context.Context, u *url.URL, toFile string) error {
+	var offset int64
+	if stat, err := os.Stat(toFile); err == nil {
+		offset = stat.Size()
 	}
 
-	cmd := exec.Command("rclone", args...)
+	req, err := d.newRequest(ctx, http.MethodGet, u)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 
-	logger = logger.WithField("command", cmd.String())
-	logger.Debug("executing command to create a new rclone config")
+	resp, err := d.do(req, http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is not a prefix of the file any more, start over
+		_ = os.Remove(toFile)
+		return d.download(ctx, u, toFile)
+	default:
+		flags |= os.O_TRUNC
+	}
 
-	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, nil)
+	f, err := os.OpenFile(toFile, flags, 0644) // #nosec G302
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
 
+	_, err = io.Copy(f, utils.NewRateLimitedReader(ctx, resp.Body, d.limiter))
 	if err != nil {
-		logger.Errorf("rclone config create command error: %s", errBuffer)
 		return err
 	}
-	logger.Debugf("rclone config create command output: %s", outBuffer.String())
 
-	return nil
+	return f.Close()
 }
 
-// From https://cs.opensource.google/go/go/+/refs/tags/go1.21.5:src/net/http/client.go;l=426
-func basicAuth(username, password string) string {
-	auth := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(auth))
+func sha256File(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// httpFileUpToDate tells whether the local file has the same size and
+// modification time as reported by the server, files the server reports
+// neither for are always downloaded.
+func httpFileUpToDate(target string, size int64, modTime time.Time) bool {
+	return size >= 0 && !modTime.IsZero() && localFileUpToDate(target, size, modTime, 0)
+}
+
+// redactURI hides the password of the uri in the logs.
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	return u.Redacted()
 }
 
-func (d *HTTPLoader) Sync(fromURI string, toPath string) error {
-	_, err := url.Parse(fromURI)
+// syncFile downloads the file unless it is filtered out or the local copy has
+// the same size and modification time, and tells whether the file passed the
+// filters.
+func (d *HTTPLoader) syncFile(ctx context.Context, logger *logrus.Entry, file httpFile, dir string) (bool, error) {
+	size, modTime, err := d.fileInfo(ctx, file.URL)
+	if err != nil {
+		return false, err
+	}
+	if !d.filter.matchFile(file.Path, size, modTime) {
+		logger.Debug("file skipped by the filters")
+		return false, nil
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(file.Path))
+	rel, err := filepath.Rel(dir, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false, fmt.Errorf("invalid file path %s", file.Path)
+	}
+
+	if httpFileUpToDate(target, size, modTime) {
+		logger.Debug("file is up to date")
+		if needsPostCopy(target, d.Options) {
+			d.record(file.Path)
+		}
+		return true, nil
+	}
+
+	checksum, err := d.expectedChecksum(ctx, file.URL)
+	if err != nil {
+		return false, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return false, err
+	}
+
+	incomplete := target + ".incomplete"
+	err = d.download(ctx, file.URL, incomplete)
 	if err != nil {
-		return fmt.Errorf("failed to parse uri %s: %w", fromURI, err)
+		return false, fmt.Errorf("failed to download %s: %w", file.URL.Redacted(), err)
 	}
 
-	logger := log.WithFields(logrus.Fields{
+	if checksum != "" {
+		actual, err := sha256File(incomplete)
+		if err != nil {
+			return false, err
+		}
+		if actual != checksum {
+			_ = os.Remove(incomplete)
+			return false, fmt.Errorf("sha256 checksum mismatch of %s, expected %s, got %s", file.URL.Redacted(), checksum, actual)
+		}
+		logger.Debugf("sha256 checksum %s verified", checksum)
+	}
+
+	err = os.Rename(incomplete, target)
+	if err != nil {
+		return false, err
+	}
+	d.record(file.Path)
+	if !modTime.IsZero() {
+		err = os.Chtimes(target, modTime, modTime)
+		if err != nil {
+			logger.Warnf("failed to set the modification time of %s, err: %s", target, err)
+		}
+	}
+
+	return true, nil
+}
+
+// syncFileWithRetries retries up to MaxRetries times if syncing the file
+// fails, partially downloaded files are resumed by the retries.
+func (d *HTTPLoader) syncFileWithRetries(ctx context.Context, logger *logrus.Entry, file httpFile, dir string) (bool, error) {
+	for attempt := 0; ; attempt++ {
+		logger.Debug("syncing file")
+
+		included, err := d.syncFile(ctx, logger, file, dir)
+		if err == nil || attempt >= d.Options.MaxRetries || ctx.Err() != nil {
+			return included, err
+		}
+
+		logger.Warnf("failed to sync file, retrying, attempt %d of %d, err: %v", attempt+1, d.Options.MaxRetries, err)
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		}
+	}
+}
+
+func (d *HTTPLoader) logger(fromURI string, toPath string) *logrus.Entry {
+	return log.WithFields(logrus.Fields{
 		"fromURI":          fromURI,
 		"type":             TypeHTTP,
 		"toPath":           toPath,
 		"workingDirectory": d.Options.Root,
+		"maxDepth":         d.httpOptions.MaxDepth,
+		"checksum":         d.httpOptions.checksum,
 	})
+}
 
-	basicAuthUsername := strings.TrimSpace(d.httpOptions.basicAuthUsername)
-	basicAuthPassword := strings.TrimSpace(d.httpOptions.basicAuthPassword)
-	basicAuthBase64 := basicAuth(basicAuthUsername, basicAuthPassword)
-
-	logger.Debugf("performing rclone copy command to copy data served by HTTP")
+// listFiles lists the files under fromURI whose paths pass the filters, a
+// single file if fromURI is not a directory.
+func (d *HTTPLoader) listFiles(ctx context.Context, logger *logrus.Entry, fromURI string) (*url.URL, []httpFile, error) {
+	sourceURL, err := url.Parse(fromURI)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse uri %s: %w", fromURI, err)
+	}
 
-	err = d.configTouch()
+	sourceURL, isDir, err := d.resolveSource(ctx, sourceURL)
 	if err != nil {
-		return err
+		return nil, nil, fmt.Errorf("failed to reach %s: %w", fromURI, err)
+	}
+
+	var files []httpFile
+	if isDir {
+		files, err = d.crawl(ctx, logger, sourceURL)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		files = []httpFile{{Path: path.Base(sourceURL.Path), URL: sourceURL}}
+	}
+	files = lo.Filter(files, func(file httpFile, _ int) bool {
+		return d.filter.matchPath(file.Path)
+	})
+
+	return sourceURL, files, nil
+}
+
+// localFiles lists the local files under dir that pass the filters, which are
+// the files mirror mode may delete.
+func (d *HTTPLoader) localFiles(dir string) ([]string, error) {
+	return listLocalFiles(dir, func(filePath string) bool {
+		stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(filePath)))
+		if err != nil {
+			return false
+		}
+		return d.filter.matchFile(filePath, stat.Size(), stat.ModTime())
+	})
+}
+
+func (d *HTTPLoader) concurrency() int {
+	if d.Options.MaxConcurrency <= 0 {
+		return httpDefaultConcurrency
 	}
+	return d.Options.MaxConcurrency
+}
 
-	configName := fmt.Sprintf("baize-data-loader-copy-config-%s", utils.RandomHashString(8))
-	d.httpOptions.fromURI = fromURI
+func (d *HTTPLoader) Plan(ctx context.Context, fromURI string, toPath string) (*Plan, error) {
+	dir := resolveDir(d.Options.Root, toPath)
+	logger := d.logger(redactURI(fromURI), toPath)
 
-	err = d.configCreate(configName)
+	_, files, err := d.listFiles(ctx, logger, fromURI)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	args := []string{
-		"sync",
-		fmt.Sprintf("%s:", configName),
-		toPath,
+	var mu sync.Mutex
+	plan := newPlan("")
+	upstream := make([]string, 0, len(files))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(d.concurrency())
+	for _, file := range files {
+		group.Go(func() error {
+			size, modTime, err := d.fileInfo(groupCtx, file.URL)
+			if err != nil {
+				return fmt.Errorf("failed to get %s: %w", file.URL.Redacted(), err)
+			}
+			if !d.filter.matchFile(file.Path, size, modTime) {
+				return nil
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			upstream = append(upstream, file.Path)
+			plan.add(file.Path, max(size, 0), httpFileUpToDate(filepath.Join(dir, filepath.FromSlash(file.Path)), size, modTime))
+			return nil
+		})
+	}
+	err = group.Wait()
+	if err != nil {
+		return nil, err
 	}
 
-	args = append(args, "-vvv")
-	cmd := exec.Command("rclone", args...)
-	cmd.Dir = d.Options.Root
+	local, err := d.localFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	plan.planDelete(dir, local, upstream, d.Options)
+	plan.sort()
 
-	logger = logger.WithField("command", cmd.String())
-	logger.Debug("executing command to copy data")
+	return plan, nil
+}
+
+func (d *HTTPLoader) Sync(ctx context.Context, fromURI string, toPath string) error {
+	dir := resolveDir(d.Options.Root, toPath)
+	logger := d.logger(redactURI(fromURI), toPath)
 
-	cmd.Env = os.Environ()
+	sourceURL, files, err := d.listFiles(ctx, logger, fromURI)
+	if err != nil {
+		return err
+	}
 
-	if basicAuthUsername != "" && basicAuthPassword != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("RCLONE_HTTP_HEADERS=Authorization,Basic %s", basicAuthBase64))
+	logger.Debugf("syncing %d files from %s to %s", len(files), sourceURL.Redacted(), dir)
+
+	d.startRecording()
+
+	var mu sync.Mutex
+	upstream := make([]string, 0, len(files))
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(d.concurrency())
+	for _, file := range files {
+		group.Go(func() error {
+			included, err := d.syncFileWithRetries(groupCtx, logger.WithField("file", file.Path), file, dir)
+			if included {
+				mu.Lock()
+				upstream = append(upstream, file.Path)
+				mu.Unlock()
+			}
+			return err
+		})
 	}
 
-	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, []string{basicAuthBase64})
+	err = group.Wait()
 	if err != nil {
-		logger.Errorf("rclone copy command error: %s", errBuffer)
-		return fmt.Errorf("failed to copy data from %s to %s with rclone command %s, err: %s", fromURI, toPath, cmd.String(), err)
+		logger.Errorf("http download error: %v", err)
+		return fmt.Errorf("failed to copy data from %s to %s, err: %w", sourceURL.Redacted(), toPath, err)
+	}
+	d.stopRecording()
+
+	if d.Options.SyncMode != SyncModeMirror {
+		return nil
+	}
+
+	local, err := d.localFiles(dir)
+	if err != nil {
+		return err
 	}
-	logger.Debugf("rclone copy command output: %s", outBuffer.String())
 
-	return nil
+	return mirrorDelete(ctx, logger, dir, local, upstream, d.Options)
 }
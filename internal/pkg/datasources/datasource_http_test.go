@@ -1,65 +1,335 @@
-// nolint: dupl
 package datasources
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+var httpTestModTime = time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+// httpTestServer serves the files with an index in the format of nginx
+// autoindex for every directory, and records the requests it received.
+type httpTestServer struct {
+	files map[string]string
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func (s *httpTestServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, req.Clone(context.Background()))
+	s.mu.Unlock()
+
+	if strings.HasSuffix(req.URL.Path, "/") {
+		var children []string
+		for name := range s.files {
+			if rest, ok := strings.CutPrefix(name, req.URL.Path); ok {
+				child, _, isDir := strings.Cut(rest, "/")
+				if isDir {
+					child += "/"
+				}
+				children = append(children, child)
+			}
+		}
+		if len(children) == 0 {
+			http.NotFound(rw, req)
+			return
+		}
+
+		index := bytes.NewBufferString(fmt.Sprintf("<html><head><title>Index of %s</title></head><body><h1>Index of %s</h1><hr><pre><a href=\"../\">../</a>\n", req.URL.Path, req.URL.Path))
+		index.WriteString("<a href=\"?C=N;O=D\">Name</a>\n<a href=\"https://nginx.org/\">nginx</a>\n")
+		for _, child := range children {
+			fmt.Fprintf(index, "<a href=\"%s\">%s</a> 02-Jan-2024 15:04 -\n", child, child)
+		}
+		index.WriteString("</pre><hr></body></html>")
+		rw.Header().Set("Content-Type", "text/html")
+		_, _ = rw.Write(index.Bytes())
+		return
+	}
+
+	content, ok := s.files[req.URL.Path]
+	if !ok {
+		for name := range s.files {
+			if strings.HasPrefix(name, req.URL.Path+"/") {
+				http.Redirect(rw, req, req.URL.Path+"/", http.StatusMovedPermanently)
+				return
+			}
+		}
+		http.NotFound(rw, req)
+		return
+	}
+	http.ServeContent(rw, req, req.URL.Path, httpTestModTime, strings.NewReader(content))
+}
+
+func (s *httpTestServer) requestsOf(path string) []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var requests []*http.Request
+	for _, req := range s.requests {
+		if req.URL.Path == path {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
 func TestHTTPLoader(t *testing.T) {
-	httpLoader, err := NewHTTPLoader(map[string]string{
-		"branch": "master",
-	}, Options{
-		Type: "",
-		URI:  "https://test.com",
-		Path: "",
-		Mode: 0,
-		UID:  0,
-		GID:  0,
-		Root: "",
-	}, Secrets{
-		Username: "test-username",
-		Password: "test-password",
+	t.Run("directory index", func(t *testing.T) {
+		server := &httpTestServer{files: map[string]string{
+			"/data/a.txt":            "a",
+			"/data/app.log":          "log",
+			"/data/sub/b.txt":        "b",
+			"/data/sub/deeper/c.txt": "c",
+			"/other/d.txt":           "d",
+		}}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{
+			"X-Api-Key": "key",
+			"exclude":   "*.log",
+			"maxDepth":  "1",
+			"extract":   "none",
+		}, Options{
+			URI:            ts.URL + "/data",
+			BandwidthLimit: "10M",
+			MaxConcurrency: 2,
+		}, Secrets{
+			Token: "test-token",
+		})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		err = loader.Sync(context.Background(), ts.URL+"/data", dir)
+		require.NoError(t, err)
+
+		local, err := listLocalFiles(dir, nil)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.txt", "sub/b.txt"}, local)
+
+		stat, err := os.Stat(filepath.Join(dir, "sub", "b.txt"))
+		require.NoError(t, err)
+		assert.True(t, stat.ModTime().Equal(httpTestModTime))
+
+		for _, req := range server.requestsOf("/data/a.txt") {
+			assert.Equal(t, "key", req.Header.Get("X-Api-Key"))
+			assert.Equal(t, "Bearer test-token", req.Header.Get("Authorization"))
+			assert.Empty(t, req.Header.Get("Extract"))
+		}
+
+		changed, ok := loader.ChangedFiles()
+		assert.True(t, ok)
+		assert.ElementsMatch(t, []string{"a.txt", "sub/b.txt"}, changed)
+
+		// files that are up to date are not downloaded again, and only
+		// reported as changed if their mode or owner is still to be changed
+		loader.Options.Mode = 0640
+		loader.Options.UID = os.Getuid()
+		loader.Options.GID = os.Getgid()
+		require.NoError(t, os.Chmod(filepath.Join(dir, "a.txt"), 0640))
+		require.NoError(t, os.Chmod(filepath.Join(dir, "sub", "b.txt"), 0600))
+		err = loader.Sync(context.Background(), ts.URL+"/data", dir)
+		require.NoError(t, err)
+		changed, ok = loader.ChangedFiles()
+		assert.True(t, ok)
+		assert.Equal(t, []string{"sub/b.txt"}, changed)
+		gets := 0
+		for _, req := range server.requestsOf("/data/a.txt") {
+			if req.Method == http.MethodGet {
+				gets++
+			}
+		}
+		assert.Equal(t, 1, gets)
 	})
-	assert.NoError(t, err)
-	fakeHTTP := fakeCommand{
-		t:   t,
-		cmd: "rclone",
-		outputs: []out{
-			{
-				stdout: "clone",
-				stderr: "",
-				exit:   0,
-			},
-			{
-				stdout: "config",
-				stderr: "",
-				exit:   0,
-			},
-			{
-				stdout: "config",
-				stderr: "",
-				exit:   0,
-			},
-		},
-	}
-	defer func() {
-		assert.NoError(t, fakeHTTP.Clean())
-	}()
-	gitDir, _ := os.MkdirTemp("", "httpLoader-*")
-	defer func() {
-		assert.NoError(t, os.RemoveAll(gitDir))
-	}()
-	assert.NoError(t, err)
-	fakeHTTP.WithContext(func() {
-		err = httpLoader.Sync("http://test.com", gitDir)
-		assert.NoError(t, err)
+
+	t.Run("single file with resume and checksum", func(t *testing.T) {
+		content := strings.Repeat("0123456789", 100)
+		server := &httpTestServer{files: map[string]string{
+			"/models/model.bin":        content,
+			"/models/model.bin.sha256": sha256Hex(content) + "  model.bin\n",
+		}}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{
+			"checksum": "required",
+		}, Options{
+			URI: ts.URL + "/models/model.bin",
+		}, Secrets{
+			Username: "user",
+			Password: "pass",
+		})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "model.bin.incomplete"), []byte(content[:400]), 0600))
+
+		err = loader.Sync(context.Background(), ts.URL+"/models/model.bin", dir)
+		require.NoError(t, err)
+
+		downloaded, err := os.ReadFile(filepath.Join(dir, "model.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(downloaded))
+		assert.NoFileExists(t, filepath.Join(dir, "model.bin.incomplete"))
+
+		var ranges []string
+		for _, req := range server.requestsOf("/models/model.bin") {
+			if req.Method == http.MethodGet {
+				ranges = append(ranges, req.Header.Get("Range"))
+				username, password, ok := req.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "user", username)
+				assert.Equal(t, "pass", password)
+			}
+		}
+		assert.Equal(t, []string{"bytes=400-"}, ranges)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		ts := httptest.NewServer(&httpTestServer{files: map[string]string{
+			"/model.bin":        "content",
+			"/model.bin.sha256": sha256Hex("other"),
+		}})
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{}, Options{URI: ts.URL + "/model.bin"}, Secrets{})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		err = loader.Sync(context.Background(), ts.URL+"/model.bin", dir)
+		require.ErrorContains(t, err, "sha256 checksum mismatch")
+		assert.NoFileExists(t, filepath.Join(dir, "model.bin"))
+		assert.NoFileExists(t, filepath.Join(dir, "model.bin.incomplete"))
+	})
+
+	t.Run("checksum required", func(t *testing.T) {
+		ts := httptest.NewServer(&httpTestServer{files: map[string]string{
+			"/model.bin": "content",
+		}})
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{"checksum": "required"}, Options{URI: ts.URL + "/model.bin"}, Secrets{})
+		require.NoError(t, err)
+
+		err = loader.Sync(context.Background(), ts.URL+"/model.bin", t.TempDir())
+		require.ErrorContains(t, err, "failed to get checksum")
+	})
+
+	t.Run("custom ca bundle", func(t *testing.T) {
+		ts := httptest.NewTLSServer(&httpTestServer{files: map[string]string{
+			"/model.bin": "content",
+		}})
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{}, Options{URI: ts.URL + "/model.bin"}, Secrets{})
+		require.NoError(t, err)
+		err = loader.Sync(context.Background(), ts.URL+"/model.bin", t.TempDir())
+		require.Error(t, err)
+
+		caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+		loader, err = NewHTTPLoader(map[string]string{}, Options{URI: ts.URL + "/model.bin"}, Secrets{CABundle: string(caBundle)})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		err = loader.Sync(context.Background(), ts.URL+"/model.bin", dir)
+		require.NoError(t, err)
+		assert.FileExists(t, filepath.Join(dir, "model.bin"))
+
+		_, err = NewHTTPLoader(map[string]string{}, Options{URI: ts.URL}, Secrets{CABundle: "invalid"})
+		assert.Error(t, err)
+	})
+
+	t.Run("mirror", func(t *testing.T) {
+		ts := httptest.NewServer(&httpTestServer{files: map[string]string{
+			"/data/a.txt":     "a",
+			"/data/sub/b.txt": "b",
+			"/data/c.txt":     "c",
+		}})
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{"exclude": "keep/**"}, Options{
+			URI:      ts.URL + "/data/",
+			SyncMode: SyncModeMirror,
+		}, Secrets{})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		writeFiles(t, dir, "sub/stale.txt", "keep/local.txt")
+
+		err = loader.Sync(context.Background(), ts.URL+"/data/", dir)
+		require.NoError(t, err)
+
+		local, err := listLocalFiles(dir, nil)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.txt", "c.txt", "sub/b.txt", "keep/local.txt"}, local)
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		server := &httpTestServer{files: map[string]string{
+			"/data/a.txt":     "a",
+			"/data/sub/b.txt": "bb",
+			"/data/app.log":   "log",
+		}}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		loader, err := NewHTTPLoader(map[string]string{"exclude": "*.log"}, Options{
+			URI:      ts.URL + "/data/",
+			SyncMode: SyncModeMirror,
+		}, Secrets{})
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		writeFiles(t, dir, "a.txt", "stale.txt", "keep.log")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0600))
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "a.txt"), httpTestModTime, httpTestModTime))
+
+		plan, err := loader.Plan(context.Background(), ts.URL+"/data/", dir)
+		require.NoError(t, err)
+		assert.Equal(t, []PlanFile{{Path: "sub/b.txt", Size: 2}}, plan.Transfer)
+		assert.Equal(t, []PlanFile{{Path: "a.txt", Size: 1}}, plan.Skip)
+		assert.Equal(t, []PlanFile{{Path: "stale.txt", Size: 9}}, plan.Delete)
+		assert.Empty(t, plan.DeleteError)
+
+		// nothing is downloaded or deleted
+		for _, req := range server.requestsOf("/data/sub/b.txt") {
+			assert.Equal(t, http.MethodHead, req.Method)
+		}
+		local, err := listLocalFiles(dir, nil)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.txt", "stale.txt", "keep.log"}, local)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for name, options := range map[string]map[string]string{
+			"maxDepth": {"maxDepth": "-1"},
+			"checksum": {"checksum": "md5"},
+			"filter":   {"include": "data/[a-"},
+		} {
+			_, err := NewHTTPLoader(options, Options{URI: "https://test.com/"}, Secrets{})
+			assert.Error(t, err, name)
+		}
 	})
-	bbs := fakeHTTP.GetAllInputs()
-	assert.Equal(t, []byte("config touch\n"), bbs[0])
-	assert.True(t, strings.HasPrefix(string(bbs[1]), "config create"))
-	assert.True(t, strings.HasPrefix(string(bbs[2]), "sync"))
 }
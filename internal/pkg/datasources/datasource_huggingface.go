@@ -1,25 +1,42 @@
 package datasources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/BaizeAI/dataset/internal/pkg/datasources/huggingface"
 	"github.com/BaizeAI/dataset/pkg/log"
 	"github.com/BaizeAI/dataset/pkg/utils"
 )
 
 var _ Loader = &HuggingFaceLoader{}
+var _ Planner = &HuggingFaceLoader{}
+
+func init() {
+	Register(Registration{
+		Type: TypeHuggingFace,
+		Factory: func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+			return NewHuggingFaceLoader(datasourceOptions, options, secrets)
+		},
+		Schemes: []string{"huggingface"},
+		Options: append(OptionKeysOf(HuggingFaceLoaderOptions{}), "repo"),
+	})
+}
 
 type HuggingFaceLoader struct {
 	Options Options
 
 	huggingFaceOptions HuggingFaceLoaderOptions
+	hfAPI              huggingface.HfAPI
 }
 
 func NewHuggingFaceLoader(datasourceOptions map[string]string, options Options, secrets Secrets) (*HuggingFaceLoader, error) {
@@ -38,6 +55,8 @@ func NewHuggingFaceLoader(datasourceOptions map[string]string, options Options,
 		return nil, err
 	}
 
+	huggingFace.hfAPI = huggingface.NewHfAPIClient(huggingface.WithEndpoint(parsedOpts.Endpoint))
+
 	return huggingFace, nil
 }
 
@@ -89,12 +108,12 @@ func (d *HuggingFaceLoader) mapRepoTypeEnumStringToHuggingFaceRepoType(repoType
 	}
 }
 
-func (d *HuggingFaceLoader) env(logger *logrus.Entry) (string, error) {
+func (d *HuggingFaceLoader) env(ctx context.Context, logger *logrus.Entry) (string, error) {
 	args := []string{
 		"env",
 	}
 
-	cmd := exec.Command("huggingface-cli", args...)
+	cmd := utils.CommandContext(ctx, "huggingface-cli", args...)
 	cmd.Env = os.Environ()
 
 	output, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
@@ -108,14 +127,14 @@ func (d *HuggingFaceLoader) env(logger *logrus.Entry) (string, error) {
 	return outputString, nil
 }
 
-func (d *HuggingFaceLoader) login(logger *logrus.Entry, token string) error {
+func (d *HuggingFaceLoader) login(ctx context.Context, logger *logrus.Entry, token string) error {
 	args := []string{
 		"login",
 		"--token",
 		token,
 	}
 
-	cmd := exec.Command("huggingface-cli", args...)
+	cmd := utils.CommandContext(ctx, "huggingface-cli", args...)
 	cmd.Env = os.Environ()
 
 	_, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
@@ -126,12 +145,12 @@ func (d *HuggingFaceLoader) login(logger *logrus.Entry, token string) error {
 	return nil
 }
 
-func (d *HuggingFaceLoader) whoAmI(logger *logrus.Entry) (string, error) {
+func (d *HuggingFaceLoader) whoAmI(ctx context.Context, logger *logrus.Entry) (string, error) {
 	args := []string{
 		"whoami",
 	}
 
-	cmd := exec.Command("huggingface-cli", args...)
+	cmd := utils.CommandContext(ctx, "huggingface-cli", args...)
 	cmd.Env = os.Environ()
 
 	output, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
@@ -145,17 +164,63 @@ func (d *HuggingFaceLoader) whoAmI(logger *logrus.Entry) (string, error) {
 	return outputString, nil
 }
 
-func (d *HuggingFaceLoader) Sync(fromURI string, toPath string) error {
+// repo returns the name and the type of the repo in the uri.
+func (d *HuggingFaceLoader) repo() (string, string, error) {
 	parsedURL, err := url.Parse(d.Options.URI)
 	if err != nil {
-		return err
+		return "", "", err
 	}
 	if parsedURL.Scheme != "huggingface" {
-		return fmt.Errorf("invalid scheme %s, only huggingface is supported", parsedURL.Scheme)
+		return "", "", fmt.Errorf("invalid scheme %s, only huggingface is supported", parsedURL.Scheme)
+	}
+
+	return parsedURL.Host + parsedURL.Path, d.mapRepoTypeEnumStringToHuggingFaceRepoType(d.huggingFaceOptions.RepoType), nil
+}
+
+// Plan compares the files of the repo with those downloaded to toPath by
+// size, which is what huggingface-cli checks first as well.
+func (d *HuggingFaceLoader) Plan(ctx context.Context, fromURI string, toPath string) (*Plan, error) {
+	repoName, repoType, err := d.repo()
+	if err != nil {
+		return nil, err
+	}
+
+	repoInfo, err := d.hfAPI.GetRepoInfo(ctx, strings.TrimSpace(d.huggingFaceOptions.token), repoType, repoName, d.huggingFaceOptions.Revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get info of huggingface repo %s, err: %w", repoName, err)
 	}
 
-	repoName := parsedURL.Host + parsedURL.Path
-	repoType := d.mapRepoTypeEnumStringToHuggingFaceRepoType(d.huggingFaceOptions.RepoType)
+	dir := resolveDir(d.Options.Root, toPath)
+	plan := newPlan(repoInfo.SHA)
+	upstream := make([]string, 0, len(repoInfo.Siblings))
+	for _, sibling := range repoInfo.Siblings {
+		upstream = append(upstream, sibling.RFilename)
+		if !d.shouldMirror(sibling.RFilename) {
+			continue
+		}
+
+		size := sibling.Size
+		if sibling.LFS != nil {
+			size = sibling.LFS.Size
+		}
+		plan.add(sibling.RFilename, size, localFileUpToDate(filepath.Join(dir, filepath.FromSlash(sibling.RFilename)), size, time.Time{}, 0))
+	}
+
+	local, err := listLocalFiles(dir, d.shouldMirror)
+	if err != nil {
+		return nil, err
+	}
+	plan.planDelete(dir, local, upstream, d.Options)
+	plan.sort()
+
+	return plan, nil
+}
+
+func (d *HuggingFaceLoader) Sync(ctx context.Context, fromURI string, toPath string) error {
+	repoName, repoType, err := d.repo()
+	if err != nil {
+		return err
+	}
 
 	logger := log.WithFields(logrus.Fields{
 		"fromURI":          fromURI,
@@ -175,18 +240,18 @@ func (d *HuggingFaceLoader) Sync(fromURI string, toPath string) error {
 
 	logger.Debugf("performing huggingface-cli download command to pull data from %s to %s", fromURI, toPath)
 
-	_, err = d.env(logger)
+	_, err = d.env(ctx, logger)
 	if err != nil {
 		return err
 	}
 
 	if d.huggingFaceOptions.token != "" {
-		err = d.login(logger, token)
+		err = d.login(ctx, logger, token)
 		if err != nil {
 			return err
 		}
 
-		whoAmI, err := d.whoAmI(logger)
+		whoAmI, err := d.whoAmI(ctx, logger)
 		if err != nil {
 			return err
 		}
@@ -210,33 +275,78 @@ func (d *HuggingFaceLoader) Sync(fromURI string, toPath string) error {
 	if d.huggingFaceOptions.Exclude != "" {
 		args = append(args, "--exclude", d.huggingFaceOptions.Exclude)
 	}
+	if d.Options.MaxConcurrency > 0 {
+		args = append(args, "--max-workers", strconv.Itoa(d.Options.MaxConcurrency))
+	}
+	if d.Options.BandwidthLimit != "" {
+		logger.Warnf("bandwidth limit %s is not supported by huggingface-cli, ignored", d.Options.BandwidthLimit)
+	}
 
-	cmd := exec.Command("huggingface-cli", args...)
-	cmd.Dir = d.Options.Root
-
-	logger = logger.WithField("command", cmd.String())
-	logger.Debug("executing command to download data from huggingface-cli")
-
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "HF_HUB_VERBOSITY=debug")
-	// cmd.Env = append(cmd.Env, "HF_HUB_DISABLE_PROGRESS_BARS=1")
-	cmd.Env = append(cmd.Env, "HF_HUB_DOWNLOAD_TIMEOUT=60")
-	cmd.Env = append(cmd.Env, "DO_NOT_TRACK=1") // https://consoledonottrack.com/
+	env := os.Environ()
+	env = append(env, "HF_HUB_VERBOSITY=debug")
+	// env = append(env, "HF_HUB_DISABLE_PROGRESS_BARS=1")
+	env = append(env, "HF_HUB_DOWNLOAD_TIMEOUT=60")
+	env = append(env, "DO_NOT_TRACK=1") // https://consoledonottrack.com/
 
 	if d.huggingFaceOptions.Offline {
-		cmd.Env = append(cmd.Env, "HF_HUB_OFFLINE=1")
+		env = append(env, "HF_HUB_OFFLINE=1")
 	}
 	if d.huggingFaceOptions.Endpoint != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("HF_ENDPOINT=%s", d.huggingFaceOptions.Endpoint))
+		env = append(env, fmt.Sprintf("HF_ENDPOINT=%s", d.huggingFaceOptions.Endpoint))
 	}
 
-	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, []string{token})
+	// huggingface-cli does not retry failed downloads by itself, while
+	// --resume-download makes retries pick up where they stopped
+	for attempt := 0; ; attempt++ {
+		cmd := utils.CommandContext(ctx, "huggingface-cli", args...)
+		cmd.Dir = d.Options.Root
+		cmd.Env = env
+
+		cmdLogger := logger.WithField("command", cmd.String())
+		cmdLogger.Debug("executing command to download data from huggingface-cli")
+
+		outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(cmdLogger, cmd, []string{token})
+		if err == nil {
+			cmdLogger.Debugf("huggingface-cli download command output: %s", outBuffer.String())
+			break
+		}
+
+		cmdLogger.Errorf("huggingface-cli download command error: %s", errBuffer)
+		if attempt >= d.Options.MaxRetries || ctx.Err() != nil {
+			return fmt.Errorf("failed to copy data from %s to %s with huggingface-cli command %s, err: %s", fromURI, toPath, cmd.String(), err)
+		}
+
+		cmdLogger.Warnf("retrying huggingface-cli download, attempt %d of %d", attempt+1, d.Options.MaxRetries)
+	}
 
+	if d.Options.SyncMode != SyncModeMirror {
+		return nil
+	}
+
+	upstream, err := d.hfAPI.ListRepoFiles(ctx, token, repoType, repoName, d.huggingFaceOptions.Revision)
 	if err != nil {
-		logger.Errorf("huggingface-cli download command error: %s", errBuffer)
-		return fmt.Errorf("failed to copy data from %s to %s with huggingface-cli command %s, err: %s", fromURI, toPath, cmd.String(), err)
+		return fmt.Errorf("failed to list files of huggingface repo %s, err: %w", repoName, err)
 	}
-	logger.Debugf("huggingface-cli download command output: %s", outBuffer.String())
 
-	return nil
+	dir := resolveDir(d.Options.Root, toPath)
+	local, err := listLocalFiles(dir, d.shouldMirror)
+	if err != nil {
+		return err
+	}
+
+	return mirrorDelete(ctx, logger, dir, local, upstream, d.Options)
+}
+
+// shouldMirror tells whether the local file is managed by the download, i.e.
+// it matches the include and exclude patterns and is not in the metadata
+// directory huggingface-cli keeps in the local dir.
+func (d *HuggingFaceLoader) shouldMirror(filePath string) bool {
+	if strings.HasPrefix(filePath, ".cache/") {
+		return false
+	}
+	if include := splitPatterns(d.huggingFaceOptions.Include); len(include) > 0 && !matchAnyPattern(filePath, include) {
+		return false
+	}
+
+	return !matchAnyPattern(filePath, splitPatterns(d.huggingFaceOptions.Exclude))
 }
@@ -2,12 +2,17 @@
 package datasources
 
 import (
+	"context"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources/huggingface"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources/huggingface/fake"
 )
 
 func TestHuggingFaceLoader(t *testing.T) {
@@ -60,7 +65,7 @@ func TestHuggingFaceLoader(t *testing.T) {
 	}()
 	assert.NoError(t, err)
 	fakeHTTP.WithContext(func() {
-		err = loader.Sync("huggingface://ns/model", huggingFaceDir)
+		err = loader.Sync(context.Background(), "huggingface://ns/model", huggingFaceDir)
 		assert.NoError(t, err)
 	})
 	bbs := fakeHTTP.GetAllInputs()
@@ -70,3 +75,79 @@ func TestHuggingFaceLoader(t *testing.T) {
 	assert.Equal(t, string(bbs[2]), "whoami\n")
 	assert.Equal(t, string(bbs[3]), strings.Join([]string{"download", "ns/model", "--local-dir", huggingFaceDir, "--resume-download"}, " ")+"\n")
 }
+
+func TestHuggingFaceLoaderMirror(t *testing.T) {
+	loader, err := NewHuggingFaceLoader(map[string]string{
+		"repoType": "DATASET",
+		"revision": "v1",
+	}, Options{
+		URI:      "huggingface://ns/dataset",
+		SyncMode: SyncModeMirror,
+	}, Secrets{})
+	require.NoError(t, err)
+
+	fakeHub := new(fake.FakeHfAPI)
+	fakeHub.ListRepoFilesReturns([]string{"README.md", "data/train.parquet"}, nil)
+	loader.hfAPI = fakeHub
+
+	fakeHF := fakeCommand{
+		t:   t,
+		cmd: "huggingface-cli",
+		outputs: []out{
+			{stdout: "env", exit: 0},
+			{stdout: "download", exit: 0},
+		},
+	}
+	defer func() {
+		assert.NoError(t, fakeHF.Clean())
+	}()
+	huggingFaceDir := t.TempDir()
+	writeFiles(t, huggingFaceDir, "README.md", "data/train.parquet", "data/test.parquet", ".cache/huggingface/download/README.md.metadata")
+
+	fakeHF.WithContext(func() {
+		err = loader.Sync(context.Background(), "huggingface://ns/dataset", huggingFaceDir)
+		assert.NoError(t, err)
+	})
+
+	require.Equal(t, 1, fakeHub.ListRepoFilesCallCount())
+	_, _, repoType, repoID, revision := fakeHub.ListRepoFilesArgsForCall(0)
+	assert.Equal(t, "dataset", repoType)
+	assert.Equal(t, "ns/dataset", repoID)
+	assert.Equal(t, "v1", revision)
+
+	assert.NoFileExists(t, filepath.Join(huggingFaceDir, "data/test.parquet"))
+	assert.FileExists(t, filepath.Join(huggingFaceDir, "data/train.parquet"))
+	assert.FileExists(t, filepath.Join(huggingFaceDir, ".cache/huggingface/download/README.md.metadata"))
+}
+
+func TestHuggingFaceLoaderPlan(t *testing.T) {
+	loader, err := NewHuggingFaceLoader(map[string]string{
+		"exclude": "*.md",
+	}, Options{
+		URI: "huggingface://ns/model",
+	}, Secrets{})
+	require.NoError(t, err)
+
+	fakeHub := new(fake.FakeHfAPI)
+	fakeHub.GetRepoInfoReturns(&huggingface.HfAPIRepoInfoResponse{
+		ID:  "ns/model",
+		SHA: "abc123",
+		Siblings: []huggingface.HfAPIRepoSibling{
+			{RFilename: "README.md", Size: 100},
+			{RFilename: "config.json", Size: 11},
+			{RFilename: "model.safetensors", Size: 1, LFS: &huggingface.HfAPIRepoSiblingLFS{Size: 2048}},
+		},
+	}, nil)
+	loader.hfAPI = fakeHub
+
+	huggingFaceDir := t.TempDir()
+	writeFiles(t, huggingFaceDir, "config.json", "old.bin")
+
+	plan, err := loader.Plan(context.Background(), "huggingface://ns/model", huggingFaceDir)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", plan.Revision)
+	assert.Equal(t, []PlanFile{{Path: "model.safetensors", Size: 2048}}, plan.Transfer)
+	assert.Equal(t, []PlanFile{{Path: "config.json", Size: 11}}, plan.Skip)
+	// nothing is deleted in copy mode
+	assert.Empty(t, plan.Delete)
+}
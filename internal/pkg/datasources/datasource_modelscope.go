@@ -1,25 +1,45 @@
 package datasources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
-	"os"
-	"os/exec"
+	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/BaizeAI/dataset/internal/pkg/datasources/modelscope"
 	"github.com/BaizeAI/dataset/pkg/log"
 	"github.com/BaizeAI/dataset/pkg/utils"
 )
 
 var _ Loader = &ModelScopeLoader{}
+var _ Revisioner = &ModelScopeLoader{}
+var _ Planner = &ModelScopeLoader{}
+
+func init() {
+	Register(Registration{
+		Type: TypeModelScope,
+		Factory: func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+			return NewModelScopeLoader(datasourceOptions, options, secrets)
+		},
+		Schemes: []string{"modelscope"},
+		Options: append(OptionKeysOf(ModelScopeLoaderOptions{}), "repo"),
+	})
+}
 
 type ModelScopeLoader struct {
 	Options Options
 
 	modelScopeOptions ModelScopeLoaderOptions
+	hubAPI            modelscope.HubAPI
+	revision          string
 }
 
 func NewModelScopeLoader(datasourceOptions map[string]string, options Options, secrets Secrets) (*ModelScopeLoader, error) {
@@ -33,16 +53,39 @@ func NewModelScopeLoader(datasourceOptions map[string]string, options Options, s
 	modelScope.modelScopeOptions = parsedOpts
 	modelScope.modelScopeOptions.token = secrets.Token
 
+	err = modelScope.validateOptions(parsedOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	modelScope.modelScopeOptions.include = splitPatterns(parsedOpts.Include)
+	modelScope.modelScopeOptions.exclude = splitPatterns(parsedOpts.Exclude)
+
+	var bandwidthLimit int64
+	if options.BandwidthLimit != "" {
+		bandwidthLimit, err = utils.ParseBandwidth(options.BandwidthLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	modelScope.hubAPI = modelscope.NewHubAPIClient(
+		modelscope.WithEndpoint(parsedOpts.Endpoint),
+		modelscope.WithBandwidthLimit(bandwidthLimit),
+	)
+
 	return modelScope, nil
 }
 
 type ModelScopeLoaderOptions struct {
 	Revision string `json:"revision"`
 	RepoType string `json:"repoType"`
+	Endpoint string `json:"endpoint"`
 	Include  string `json:"include"`
 	Exclude  string `json:"exclude"`
 
-	token string
+	token   string
+	include []string
+	exclude []string
 }
 
 func (d *ModelScopeLoader) parseOptionsFromOptions(options map[string]string) (ModelScopeLoaderOptions, error) {
@@ -60,6 +103,17 @@ func (d *ModelScopeLoader) parseOptionsFromOptions(options map[string]string) (M
 	return msOptions, nil
 }
 
+func (d *ModelScopeLoader) validateOptions(options ModelScopeLoaderOptions) error {
+	if options.Endpoint != "" {
+		_, err := url.Parse(options.Endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid endpoint %s: %w", options.Endpoint, err)
+		}
+	}
+
+	return nil
+}
+
 func (d *ModelScopeLoader) mapRepoTypeEnumStringToModelScopeRepoType(repoType string) string {
 	switch repoType {
 	case "MODEL", "model":
@@ -71,35 +125,78 @@ func (d *ModelScopeLoader) mapRepoTypeEnumStringToModelScopeRepoType(repoType st
 	}
 }
 
-func (d *ModelScopeLoader) login(logger *logrus.Entry, token string) error {
-	args := []string{
-		"login",
-		"--token",
-		token,
+// repo returns the name, the type and the revision of the repo in the uri.
+func (d *ModelScopeLoader) repo() (string, string, string, error) {
+	parsedURL, err := url.Parse(d.Options.URI)
+	if err != nil {
+		return "", "", "", err
+	}
+	if parsedURL.Scheme != "modelscope" {
+		return "", "", "", fmt.Errorf("invalid scheme %s, only modelscope is supported", parsedURL.Scheme)
 	}
 
-	cmd := exec.Command("modelscope", args...)
-	cmd.Env = os.Environ()
+	repoType := d.mapRepoTypeEnumStringToModelScopeRepoType(d.modelScopeOptions.RepoType)
+	if repoType == "" {
+		repoType = modelscope.RepoTypeModel
+	}
 
-	_, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
-	if err != nil {
-		return err
+	revision := d.modelScopeOptions.Revision
+	if revision == "" {
+		revision = modelscope.DefaultRevision
 	}
 
-	return nil
+	return parsedURL.Host + parsedURL.Path, repoType, revision, nil
 }
 
-func (d *ModelScopeLoader) Sync(fromURI string, toPath string) error {
-	parsedURL, err := url.Parse(d.Options.URI)
+// Plan compares the files of the repo with those downloaded to toPath the
+// same way the download does, by size and sha256 if the hub reports it.
+func (d *ModelScopeLoader) Plan(ctx context.Context, fromURI string, toPath string) (*Plan, error) {
+	repoName, repoType, revision, err := d.repo()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if parsedURL.Scheme != "modelscope" {
-		return fmt.Errorf("invalid scheme %s, only modelscope is supported", parsedURL.Scheme)
+
+	if token := strings.TrimSpace(d.modelScopeOptions.token); token != "" {
+		_, err = d.hubAPI.Login(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("failed to login to modelscope: %w", err)
+		}
 	}
 
-	repoName := parsedURL.Host + parsedURL.Path
-	repoType := d.mapRepoTypeEnumStringToModelScopeRepoType(d.modelScopeOptions.RepoType)
+	files, err := d.hubAPI.ListRepoFiles(ctx, repoType, repoName, revision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of modelscope repo %s at revision %s: %w", repoName, revision, err)
+	}
+	files = lo.Filter(files, func(file modelscope.HubAPIRepoFile, _ int) bool {
+		return d.shouldDownload(file.Path)
+	})
+
+	plan := newPlan(revision)
+	for _, file := range files {
+		upToDate, err := modelscope.FileMatches(filepath.Join(toPath, filepath.FromSlash(file.Path)), file)
+		if err != nil {
+			return nil, err
+		}
+		plan.add(file.Path, file.Size, upToDate)
+	}
+
+	local, err := listLocalFiles(toPath, d.shouldDownload)
+	if err != nil {
+		return nil, err
+	}
+	plan.planDelete(toPath, local, lo.Map(files, func(file modelscope.HubAPIRepoFile, _ int) string {
+		return file.Path
+	}), d.Options)
+	plan.sort()
+
+	return plan, nil
+}
+
+func (d *ModelScopeLoader) Sync(ctx context.Context, fromURI string, toPath string) error {
+	repoName, repoType, revision, err := d.repo()
+	if err != nil {
+		return err
+	}
 
 	logger := log.WithFields(logrus.Fields{
 		"fromURI":          fromURI,
@@ -107,56 +204,141 @@ func (d *ModelScopeLoader) Sync(fromURI string, toPath string) error {
 		"toPath":           toPath,
 		"workingDirectory": d.Options.Root,
 		"repoName":         repoName,
-		"revision":         d.modelScopeOptions.Revision,
+		"revision":         revision,
 		"repoType":         repoType,
+		"endpoint":         d.modelScopeOptions.Endpoint,
 		"include":          d.modelScopeOptions.Include,
 		"exclude":          d.modelScopeOptions.Exclude,
 	})
 
 	token := strings.TrimSpace(d.modelScopeOptions.token)
 
-	logger.Debugf("performing modelscope download command to pull data from %s to %s", fromURI, toPath)
-
-	if d.modelScopeOptions.token != "" {
-		err = d.login(logger, token)
+	if token != "" {
+		loginResp, err := d.hubAPI.Login(ctx, token)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to login to modelscope: %w", err)
+		}
+		if loginResp.Data != nil {
+			logger.Debugf("modelscope logged in as: %s", loginResp.Data.Username)
 		}
 	}
 
-	args := []string{
-		"download",
-		repoName,
-		"--local_dir",
-		toPath,
+	files, err := d.hubAPI.ListRepoFiles(ctx, repoType, repoName, revision)
+	if err != nil {
+		return fmt.Errorf("failed to list files of modelscope repo %s at revision %s: %w", repoName, revision, err)
 	}
-	if repoType != "" {
-		args = append(args, "--repo-type", repoType)
+
+	files = lo.Filter(files, func(file modelscope.HubAPIRepoFile, _ int) bool {
+		return d.shouldDownload(file.Path)
+	})
+
+	logger.Debugf("downloading %d files from modelscope repo %s to %s", len(files), repoName, toPath)
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(max(d.Options.MaxConcurrency, 1))
+	for _, file := range files {
+		group.Go(func() error {
+			return d.downloadFile(groupCtx, logger.WithField("file", file.Path), repoType, repoName, revision, file, toPath)
+		})
 	}
-	if d.modelScopeOptions.Include != "" {
-		args = append(args, "--include", d.modelScopeOptions.Include)
+
+	err = group.Wait()
+	if err != nil {
+		logger.Errorf("modelscope download error: %v", err)
+		return fmt.Errorf("failed to copy data from %s to %s with modelscope, err: %w", fromURI, toPath, err)
 	}
-	if d.modelScopeOptions.Exclude != "" {
-		args = append(args, "--exclude", d.modelScopeOptions.Exclude)
+
+	if d.Options.SyncMode == SyncModeMirror {
+		local, err := listLocalFiles(toPath, d.shouldDownload)
+		if err != nil {
+			return err
+		}
+
+		upstream := lo.Map(files, func(file modelscope.HubAPIRepoFile, _ int) string {
+			return file.Path
+		})
+		err = mirrorDelete(ctx, logger, toPath, local, upstream, d.Options)
+		if err != nil {
+			return err
+		}
 	}
 
-	cmd := exec.Command("modelscope", args...)
-	cmd.Dir = d.Options.Root
+	d.revision = revision
 
-	logger = logger.WithField("command", cmd.String())
-	logger.Debug("executing command to download data from modelscope")
+	return nil
+}
+
+// downloadFile downloads the file and retries up to MaxRetries times if it
+// fails, partially downloaded files are resumed by the retries.
+func (d *ModelScopeLoader) downloadFile(ctx context.Context, logger *logrus.Entry, repoType, repoName, revision string, file modelscope.HubAPIRepoFile, toPath string) error {
+	for attempt := 0; ; attempt++ {
+		logger.Debug("downloading file from modelscope")
 
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "DO_NOT_TRACK=1") // https://consoledonottrack.com/
+		err := d.hubAPI.DownloadFile(ctx, repoType, repoName, revision, file, toPath)
+		if err == nil || attempt >= d.Options.MaxRetries || ctx.Err() != nil {
+			return err
+		}
 
-	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, []string{token})
+		logger.Warnf("failed to download file from modelscope, retrying, attempt %d of %d, err: %v", attempt+1, d.Options.MaxRetries, err)
 
-	if err != nil {
-		logger.Errorf("modelscope download command error: %s", errBuffer)
-		return fmt.Errorf("failed to copy data from %s to %s with modelscope command %s, err: %s", fromURI, toPath, cmd.String(), err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt+1) * time.Second):
+		}
 	}
+}
 
-	logger.Debugf("modelscope download command output: %s", outBuffer.String())
+// shouldDownload matches the path of the file against the comma separated
+// include and exclude glob patterns. Patterns without a slash are matched
+// against the base name of the file as well.
+func (d *ModelScopeLoader) shouldDownload(filePath string) bool {
+	if len(d.modelScopeOptions.include) > 0 && !matchAnyPattern(filePath, d.modelScopeOptions.include) {
+		return false
+	}
 
-	return nil
+	return !matchAnyPattern(filePath, d.modelScopeOptions.exclude)
+}
+
+func (d *ModelScopeLoader) Revision() string {
+	return d.revision
+}
+
+// splitPatterns splits the comma separated patterns, commas within braces
+// are the alternatives of a single glob, e.g. *.{json,txt}.
+func splitPatterns(patterns string) []string {
+	var split []string
+	depth, start := 0, 0
+	for i, c := range patterns {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			split = append(split, patterns[start:i])
+			start = i + 1
+		}
+	}
+	split = append(split, patterns[start:])
+
+	return lo.Compact(lo.Map(split, func(pattern string, _ int) string {
+		return strings.TrimSpace(pattern)
+	}))
+}
+
+func matchAnyPattern(filePath string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, filePath); matched {
+			return true
+		}
+		if strings.Contains(pattern, "/") {
+			continue
+		}
+		if matched, _ := path.Match(pattern, path.Base(filePath)); matched {
+			return true
+		}
+	}
+
+	return false
 }
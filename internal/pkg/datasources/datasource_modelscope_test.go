@@ -2,57 +2,235 @@
 package datasources
 
 import (
+	"context"
 	"os"
-	"strings"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/internal/pkg/datasources/modelscope"
+	"github.com/BaizeAI/dataset/internal/pkg/datasources/modelscope/fake"
 )
 
 func TestModelScopeLoader(t *testing.T) {
-	loader, err := NewModelScopeLoader(map[string]string{}, Options{
-		Type: "",
-		URI:  "modelscope://ns/model",
-		Path: "",
-		Mode: 0,
-		UID:  0,
-		GID:  0,
-		Root: "",
-	}, Secrets{
-		Token: "test-token",
+	t.Run("default", func(t *testing.T) {
+		loader, err := NewModelScopeLoader(map[string]string{}, Options{
+			Type: "",
+			URI:  "modelscope://ns/model",
+			Path: "",
+			Mode: 0,
+			UID:  0,
+			GID:  0,
+			Root: "",
+		}, Secrets{
+			Token: "test-token",
+		})
+		require.NoError(t, err)
+
+		fakeHub := new(fake.FakeHubAPI)
+		fakeHub.LoginReturns(&modelscope.HubAPIBaseResponse[modelscope.HubAPILoginResponse]{
+			Data:    &modelscope.HubAPILoginResponse{Username: "username"},
+			Success: true,
+		}, nil)
+		fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{
+			{Path: "config.json", Type: "blob"},
+			{Path: "model.safetensors", Type: "blob"},
+		}, nil)
+		loader.hubAPI = fakeHub
+
+		modelScopeDir, _ := os.MkdirTemp("", "modelScopeLoader-*")
+		defer func() {
+			assert.NoError(t, os.RemoveAll(modelScopeDir))
+		}()
+
+		err = loader.Sync(context.Background(), "modelscope://ns/model", modelScopeDir)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, fakeHub.LoginCallCount())
+		_, token := fakeHub.LoginArgsForCall(0)
+		assert.Equal(t, "test-token", token)
+
+		require.Equal(t, 1, fakeHub.ListRepoFilesCallCount())
+		_, repoType, repoID, revision := fakeHub.ListRepoFilesArgsForCall(0)
+		assert.Equal(t, modelscope.RepoTypeModel, repoType)
+		assert.Equal(t, "ns/model", repoID)
+		assert.Equal(t, modelscope.DefaultRevision, revision)
+
+		require.Equal(t, 2, fakeHub.DownloadFileCallCount())
+		_, _, _, _, file, toPath := fakeHub.DownloadFileArgsForCall(1)
+		assert.Equal(t, "model.safetensors", file.Path)
+		assert.Equal(t, modelScopeDir, toPath)
+		assert.Equal(t, modelscope.DefaultRevision, loader.Revision())
 	})
-	assert.NoError(t, err)
-	fakeHTTP := fakeCommand{
-		t:   t,
-		cmd: "modelscope",
-		outputs: []out{
-			{
-				stdout: "login",
-				stderr: "",
-				exit:   0,
-			},
-			{
-				stdout: "download",
-				stderr: "",
-				exit:   0,
-			},
-		},
-	}
-	defer func() {
-		assert.NoError(t, fakeHTTP.Clean())
-	}()
-	modelScopeDir, _ := os.MkdirTemp("", "modelScopeLoader-*")
-	defer func() {
-		assert.NoError(t, os.RemoveAll(modelScopeDir))
-	}()
-	assert.NoError(t, err)
-	fakeHTTP.WithContext(func() {
-		err = loader.Sync("modelscope://ns/model", modelScopeDir)
-		assert.NoError(t, err)
+
+	t.Run("dataset w/ revision, include and exclude", func(t *testing.T) {
+		loader, err := NewModelScopeLoader(map[string]string{
+			"repoType": "DATASET",
+			"revision": "v1.0.0",
+			"include":  "*.json, data/*",
+			"exclude":  "data/test-*",
+			"endpoint": "https://modelscope.example.com",
+		}, Options{
+			URI: "modelscope://ns/dataset",
+		}, Secrets{})
+		require.NoError(t, err)
+
+		fakeHub := new(fake.FakeHubAPI)
+		fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{
+			{Path: "README.md"},
+			{Path: "meta/info.json"},
+			{Path: "data/train-0.parquet"},
+			{Path: "data/test-0.parquet"},
+		}, nil)
+		loader.hubAPI = fakeHub
+
+		err = loader.Sync(context.Background(), "modelscope://ns/dataset", t.TempDir())
+		require.NoError(t, err)
+
+		assert.Equal(t, 0, fakeHub.LoginCallCount())
+		_, repoType, _, revision := fakeHub.ListRepoFilesArgsForCall(0)
+		assert.Equal(t, modelscope.RepoTypeDataset, repoType)
+		assert.Equal(t, "v1.0.0", revision)
+
+		downloaded := lo.Times(fakeHub.DownloadFileCallCount(), func(i int) string {
+			_, _, _, revision, file, _ := fakeHub.DownloadFileArgsForCall(i)
+			assert.Equal(t, "v1.0.0", revision)
+			return file.Path
+		})
+		assert.Equal(t, []string{"meta/info.json", "data/train-0.parquet"}, downloaded)
+		assert.Equal(t, "v1.0.0", loader.Revision())
+	})
+
+	t.Run("download error", func(t *testing.T) {
+		loader, err := NewModelScopeLoader(map[string]string{}, Options{
+			URI: "modelscope://ns/model",
+		}, Secrets{})
+		require.NoError(t, err)
+
+		fakeHub := new(fake.FakeHubAPI)
+		fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{{Path: "config.json"}}, nil)
+		fakeHub.DownloadFileStub = func(context.Context, string, string, string, modelscope.HubAPIRepoFile, string) error {
+			return assert.AnError
+		}
+		loader.hubAPI = fakeHub
+
+		err = loader.Sync(context.Background(), "modelscope://ns/model", t.TempDir())
+		require.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, loader.Revision())
 	})
-	bbs := fakeHTTP.GetAllInputs()
-	require.Len(t, bbs, 2)
-	assert.Equal(t, string(bbs[0]), "login --token test-token\n")
-	assert.Equal(t, string(bbs[1]), strings.Join([]string{"download", "ns/model", "--local_dir", modelScopeDir}, " ")+"\n")
+
+	t.Run("retry w/ concurrency", func(t *testing.T) {
+		loader, err := NewModelScopeLoader(map[string]string{}, Options{
+			URI:            "modelscope://ns/model",
+			MaxConcurrency: 2,
+			MaxRetries:     1,
+		}, Secrets{})
+		require.NoError(t, err)
+
+		var mu sync.Mutex
+		attempts := make(map[string]int)
+
+		fakeHub := new(fake.FakeHubAPI)
+		fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{{Path: "config.json"}, {Path: "model.safetensors"}}, nil)
+		fakeHub.DownloadFileStub = func(_ context.Context, _ string, _ string, _ string, file modelscope.HubAPIRepoFile, _ string) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			attempts[file.Path]++
+			if attempts[file.Path] == 1 {
+				return assert.AnError
+			}
+			return nil
+		}
+		loader.hubAPI = fakeHub
+
+		err = loader.Sync(context.Background(), "modelscope://ns/model", t.TempDir())
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"config.json": 2, "model.safetensors": 2}, attempts)
+	})
+
+	t.Run("mirror", func(t *testing.T) {
+		loader, err := NewModelScopeLoader(map[string]string{
+			"exclude": "*.md",
+		}, Options{
+			URI:      "modelscope://ns/model",
+			SyncMode: SyncModeMirror,
+		}, Secrets{})
+		require.NoError(t, err)
+
+		fakeHub := new(fake.FakeHubAPI)
+		fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{{Path: "config.json"}, {Path: "model.safetensors"}}, nil)
+		loader.hubAPI = fakeHub
+
+		modelScopeDir := t.TempDir()
+		writeFiles(t, modelScopeDir, "config.json", "model.safetensors", "old.bin", "README.md")
+
+		err = loader.Sync(context.Background(), "modelscope://ns/model", modelScopeDir)
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(modelScopeDir, "old.bin"))
+		// excluded files are not managed by the sync
+		assert.FileExists(t, filepath.Join(modelScopeDir, "README.md"))
+		assert.FileExists(t, filepath.Join(modelScopeDir, "config.json"))
+	})
+
+	t.Run("mirror refuses to delete most files", func(t *testing.T) {
+		loader, err := NewModelScopeLoader(map[string]string{}, Options{
+			URI:      "modelscope://ns/model",
+			SyncMode: SyncModeMirror,
+		}, Secrets{})
+		require.NoError(t, err)
+
+		fakeHub := new(fake.FakeHubAPI)
+		fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{{Path: "config.json"}}, nil)
+		loader.hubAPI = fakeHub
+
+		modelScopeDir := t.TempDir()
+		writeFiles(t, modelScopeDir, "config.json", "a.bin", "b.bin")
+
+		err = loader.Sync(context.Background(), "modelscope://ns/model", modelScopeDir)
+		require.ErrorContains(t, err, "refusing to delete 2 of 3 files")
+		assert.FileExists(t, filepath.Join(modelScopeDir, "a.bin"))
+		assert.Empty(t, loader.Revision())
+	})
+
+	t.Run("invalid bandwidth limit", func(t *testing.T) {
+		_, err := NewModelScopeLoader(map[string]string{}, Options{
+			URI:            "modelscope://ns/model",
+			BandwidthLimit: "fast",
+		}, Secrets{})
+		assert.Error(t, err)
+	})
+}
+
+func TestModelScopeLoaderPlan(t *testing.T) {
+	loader, err := NewModelScopeLoader(map[string]string{}, Options{
+		URI:      "modelscope://ns/model",
+		SyncMode: SyncModeMirror,
+	}, Secrets{})
+	require.NoError(t, err)
+
+	fakeHub := new(fake.FakeHubAPI)
+	fakeHub.ListRepoFilesReturns([]modelscope.HubAPIRepoFile{
+		{Path: "config.json", Size: 11},
+		{Path: "model.bin", Size: 9, Sha256: "0000"},
+		{Path: "tokenizer.json", Size: 14},
+	}, nil)
+	loader.hubAPI = fakeHub
+
+	modelScopeDir := t.TempDir()
+	writeFiles(t, modelScopeDir, "config.json", "model.bin", "a.bin", "b.bin", "c.bin")
+
+	plan, err := loader.Plan(context.Background(), "modelscope://ns/model", modelScopeDir)
+	require.NoError(t, err)
+	assert.Equal(t, "master", plan.Revision)
+	// model.bin has the same size but another sha256
+	assert.Equal(t, []PlanFile{{Path: "model.bin", Size: 9}, {Path: "tokenizer.json", Size: 14}}, plan.Transfer)
+	assert.Equal(t, []PlanFile{{Path: "config.json", Size: 11}}, plan.Skip)
+	assert.Equal(t, []PlanFile{{Path: "a.bin", Size: 5}, {Path: "b.bin", Size: 5}, {Path: "c.bin", Size: 5}}, plan.Delete)
+	assert.Contains(t, plan.DeleteError, "refusing to delete 3 of 5 files")
+	assert.FileExists(t, filepath.Join(modelScopeDir, "a.bin"))
 }
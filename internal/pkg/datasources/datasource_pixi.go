@@ -1,5 +1,7 @@
 package datasources
 
+import "context"
+
 var _ Loader = &PixiLoader{}
 
 type PixiLoader struct {
@@ -10,6 +12,6 @@ func NewPixiLoader(datasourceOption map[string]string, options Options, secrets
 	return &PixiLoader{}, nil
 }
 
-func (l *PixiLoader) Sync(fromURI string, toPath string) error {
+func (l *PixiLoader) Sync(_ context.Context, fromURI string, toPath string) error {
 	return nil
 }
@@ -1,6 +1,7 @@
 package datasources
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -16,8 +17,23 @@ import (
 )
 
 var _ Loader = &S3Loader{}
+var _ Planner = &S3Loader{}
+var _ ChangeReporter = &S3Loader{}
+
+func init() {
+	Register(Registration{
+		Type: TypeS3,
+		Factory: func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+			return NewS3Loader(datasourceOptions, options, secrets)
+		},
+		Schemes: []string{"s3"},
+		Options: OptionKeysOf(S3LoaderOptions{}),
+	})
+}
 
 type S3Loader struct {
+	changeRecorder
+
 	Options Options
 
 	s3Options S3LoaderOptions
@@ -48,6 +64,8 @@ type S3LoaderOptions struct {
 	Region   string `json:"region"`
 	Endpoint string `json:"endpoint"`
 
+	RcloneFilterOptions
+
 	accessKeyID     string
 	secretAccessKey string
 }
@@ -72,6 +90,11 @@ func (d *S3Loader) validateOptions(options S3LoaderOptions) error {
 		return fmt.Errorf("--options region <region> is required for AWS provider")
 	}
 
+	err := options.RcloneFilterOptions.validate()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -86,8 +109,8 @@ func (d *S3Loader) mapProviderEnumStringToRCloneProvider(provider string) string
 	}
 }
 
-func (d *S3Loader) configTouch() error {
-	cmd := exec.Command("rclone", "config", "touch")
+func (d *S3Loader) configTouch(ctx context.Context) error {
+	cmd := utils.CommandContext(ctx, "rclone", "config", "touch")
 
 	logger := log.WithField("command", cmd.String())
 	logger.Debug("executing command to touch rclone config")
@@ -102,7 +125,7 @@ func (d *S3Loader) configTouch() error {
 	return nil
 }
 
-func (d *S3Loader) configCreate(configName string) error {
+func (d *S3Loader) configCreate(ctx context.Context, configName string) error {
 	logger := log.WithFields(logrus.Fields{
 		"configName": configName,
 		"type":       TypeS3,
@@ -141,7 +164,7 @@ func (d *S3Loader) configCreate(configName string) error {
 		args = append(args, strings.Join([]string{"endpoint", d.s3Options.Endpoint}, "="))
 	}
 
-	cmd := exec.Command("rclone", args...)
+	cmd := utils.CommandContext(ctx, "rclone", args...)
 
 	logger = logger.WithField("command", cmd.String())
 	logger.Debug("executing command to create a new rclone config")
@@ -157,13 +180,13 @@ func (d *S3Loader) configCreate(configName string) error {
 	return nil
 }
 
-func (d *S3Loader) Sync(fromURI string, toPath string) error {
+func (d *S3Loader) logger(fromURI string, toPath string) (*logrus.Entry, string, string, error) {
 	parsedURL, err := url.Parse(d.Options.URI)
 	if err != nil {
-		return err
+		return nil, "", "", err
 	}
 	if parsedURL.Scheme != "s3" {
-		return fmt.Errorf("invalid scheme %s, only s3 is supported", parsedURL.Scheme)
+		return nil, "", "", fmt.Errorf("invalid scheme %s, only s3 is supported", parsedURL.Scheme)
 	}
 
 	bucket := parsedURL.Host
@@ -181,50 +204,125 @@ func (d *S3Loader) Sync(fromURI string, toPath string) error {
 		"objectDir":        objectDir,
 	})
 
+	return logger, bucket, objectDir, nil
+}
+
+// withRemote creates a temporary rclone config for the bucket and calls fn
+// with the remote path of the source and a function creating rclone commands
+// that run with the credentials, the config is deleted once fn returns.
+func (d *S3Loader) withRemote(ctx context.Context, bucket string, objectDir string, fn func(source string, newCommand func(args ...string) *exec.Cmd, secrets []string) error) error {
 	accessKeyID := strings.TrimSpace(d.s3Options.accessKeyID)
 	secretAccessKey := strings.TrimSpace(d.s3Options.secretAccessKey)
 
-	logger.Debugf("performing rclone copy command to copy data served by S3")
-
-	err = d.configTouch()
+	err := d.configTouch(ctx)
 	if err != nil {
 		return err
 	}
 
 	configName := fmt.Sprintf("baize-data-loader-copy-config-%s", utils.RandomHashString(8))
 
-	err = d.configCreate(configName)
+	err = d.configCreate(ctx, configName)
+	defer rcloneCliConfigDelete(ctx, configName)
 	if err != nil {
 		return err
 	}
 
-	args := []string{
-		"copy",
-		filepath.Join(fmt.Sprintf("%s:%s", configName, bucket), objectDir),
-		toPath,
+	source := filepath.Join(fmt.Sprintf("%s:%s", configName, bucket), objectDir)
+	secrets := []string{accessKeyID, secretAccessKey}
+
+	env := os.Environ()
+	if accessKeyID != "" && secretAccessKey != "" {
+		env = append(env, fmt.Sprintf("RCLONE_S3_ACCESS_KEY_ID=%s", accessKeyID))
+		env = append(env, fmt.Sprintf("RCLONE_S3_SECRET_ACCESS_KEY=%s", secretAccessKey))
 	}
 
-	args = append(args, "-vvv")
-	cmd := exec.Command("rclone", args...)
-	cmd.Dir = d.Options.Root
+	newCommand := func(args ...string) *exec.Cmd {
+		cmd := utils.CommandContext(ctx, "rclone", args...)
+		cmd.Dir = d.Options.Root
+		cmd.Env = env
+		return cmd
+	}
 
-	logger = logger.WithField("command", cmd.String())
-	logger.Debug("executing command to copy data")
+	return fn(source, newCommand, secrets)
+}
 
-	cmd.Env = os.Environ()
+func (d *S3Loader) Plan(ctx context.Context, fromURI string, toPath string) (*Plan, error) {
+	logger, bucket, objectDir, err := d.logger(fromURI, toPath)
+	if err != nil {
+		return nil, err
+	}
 
-	if accessKeyID != "" && secretAccessKey != "" {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("RCLONE_S3_ACCESS_KEY_ID=%s", accessKeyID))
-		cmd.Env = append(cmd.Env, fmt.Sprintf("RCLONE_S3_SECRET_ACCESS_KEY=%s", secretAccessKey))
+	var plan *Plan
+	err = d.withRemote(ctx, bucket, objectDir, func(source string, newCommand func(args ...string) *exec.Cmd, secrets []string) error {
+		plan, err = rclonePlan(logger, newCommand, source, toPath, d.s3Options.RcloneFilterOptions, d.Options, secrets)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, []string{accessKeyID, secretAccessKey})
+	return plan, nil
+}
 
+func (d *S3Loader) Sync(ctx context.Context, fromURI string, toPath string) error {
+	logger, bucket, objectDir, err := d.logger(fromURI, toPath)
 	if err != nil {
-		logger.Errorf("rclone copy command error: %s", errBuffer)
-		return fmt.Errorf("failed to copy data from %s to %s with rclone command %s, err: %s", fromURI, toPath, cmd.String(), err)
+		return err
 	}
-	logger.Debugf("rclone copy command output: %s", outBuffer.String())
 
-	return nil
+	logger.Debugf("performing rclone copy command to copy data served by S3")
+
+	d.startRecording()
+
+	return d.withRemote(ctx, bucket, objectDir, func(source string, newCommand func(args ...string) *exec.Cmd, secrets []string) error {
+		filterArgs := rcloneFilterArgs(d.s3Options.RcloneFilterOptions)
+
+		args := []string{
+			"copy",
+			source,
+			toPath,
+		}
+
+		args = append(args, filterArgs...)
+		args = append(args, rcloneLimitArgs(d.Options)...)
+
+		// the files rclone transfers are reported for the post copy stage
+		combined, err := os.CreateTemp("", "rclone-combined-*")
+		if err != nil {
+			return err
+		}
+		_ = combined.Close()
+		defer func() {
+			_ = os.Remove(combined.Name())
+		}()
+		args = append(args, "--combined", combined.Name())
+
+		args = append(args, "-vvv")
+		cmd := newCommand(args...)
+
+		cmdLogger := logger.WithField("command", cmd.String())
+		cmdLogger.Debug("executing command to copy data")
+
+		outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(cmdLogger, cmd, secrets)
+
+		if err != nil {
+			cmdLogger.Errorf("rclone copy command error: %s", errBuffer)
+			return fmt.Errorf("failed to copy data from %s to %s with rclone command %s, err: %s", fromURI, toPath, cmd.String(), err)
+		}
+		cmdLogger.Debugf("rclone copy command output: %s", outBuffer.String())
+
+		changed, err := rcloneReadChangedFiles(combined.Name(), resolveDir(d.Options.Root, toPath), d.Options)
+		if err != nil {
+			logger.Warnf("failed to read the files transferred by rclone, err: %s", err)
+		} else {
+			d.record(changed...)
+			d.stopRecording()
+		}
+
+		if d.Options.SyncMode == SyncModeMirror {
+			return rcloneMirrorDelete(ctx, logger, newCommand, source, toPath, filterArgs, d.Options, secrets)
+		}
+
+		return nil
+	})
 }
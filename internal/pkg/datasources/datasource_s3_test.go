@@ -2,16 +2,23 @@
 package datasources
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestS3Loader(t *testing.T) {
 	loader, err := NewS3Loader(map[string]string{
-		"region": "us-east-1",
+		"region":        "us-east-1",
+		"exclude":       "logs/**",
+		"modifiedAfter": "2024-01-02",
 	}, Options{
 		Type: "",
 		URI:  "s3://test-bucket",
@@ -44,6 +51,11 @@ func TestS3Loader(t *testing.T) {
 				stderr: "",
 				exit:   0,
 			},
+			{
+				stdout: "",
+				stderr: "",
+				exit:   0,
+			},
 		},
 	}
 	defer func() {
@@ -55,11 +67,113 @@ func TestS3Loader(t *testing.T) {
 	}()
 	assert.NoError(t, err)
 	fakeHTTP.WithContext(func() {
-		err = loader.Sync("s3://test-bucket", s3Dir)
+		err = loader.Sync(context.Background(), "s3://test-bucket", s3Dir)
 		assert.NoError(t, err)
 	})
 	bbs := fakeHTTP.GetAllInputs()
+	// the copy mode keeps the files removed from the bucket, nothing is listed
+	require.Len(t, bbs, 4)
 	assert.Equal(t, []byte("config touch\n"), bbs[0])
 	assert.True(t, strings.HasPrefix(string(bbs[1]), "config create"))
-	assert.True(t, strings.HasPrefix(string(bbs[2]), "sync"))
+	assert.True(t, strings.HasPrefix(string(bbs[2]), "copy "))
+	assert.Contains(t, string(bbs[2]), "--filter - logs/** --max-age 2024-01-02T00:00:00Z")
+	assert.Equal(t, []byte(fmt.Sprintf("config delete %s\n", strings.Fields(string(bbs[1]))[2])), bbs[3])
+}
+
+func TestS3LoaderMirror(t *testing.T) {
+	loader, err := NewS3Loader(map[string]string{
+		"region":  "us-east-1",
+		"exclude": "logs/**",
+	}, Options{
+		URI:      "s3://test-bucket",
+		SyncMode: SyncModeMirror,
+	}, Secrets{})
+	require.NoError(t, err)
+	fakeRclone := fakeCommand{
+		t:   t,
+		cmd: "rclone",
+		outputs: []out{
+			{stdout: "", exit: 0},
+			{stdout: "", exit: 0},
+			{stdout: "", exit: 0},
+			{stdout: "a.bin\nb.bin\nc.bin\n", exit: 0},
+			{stdout: "a.bin\nb.bin\nc.bin\nold.bin\n", exit: 0},
+			{stdout: "", exit: 0},
+		},
+	}
+	defer func() {
+		assert.NoError(t, fakeRclone.Clean())
+	}()
+
+	s3Dir := t.TempDir()
+	writeFiles(t, s3Dir, "a.bin", "b.bin", "c.bin", "old.bin")
+	fakeRclone.WithContext(func() {
+		err = loader.Sync(context.Background(), "s3://test-bucket", s3Dir)
+	})
+	require.NoError(t, err)
+
+	// the files removed from the bucket are deleted after copying
+	bbs := fakeRclone.GetAllInputs()
+	require.Len(t, bbs, 6)
+	assert.True(t, strings.HasPrefix(string(bbs[2]), "copy "))
+	assert.True(t, strings.HasPrefix(string(bbs[3]), "lsf -R --files-only baize-data-loader-copy-config-"))
+	assert.Equal(t, fmt.Sprintf("lsf -R --files-only %s --filter - logs/**\n", s3Dir), string(bbs[4]))
+	assert.True(t, strings.HasPrefix(string(bbs[5]), "config delete"))
+	assert.FileExists(t, filepath.Join(s3Dir, "a.bin"))
+	assert.NoFileExists(t, filepath.Join(s3Dir, "old.bin"))
+}
+
+func TestS3LoaderPlan(t *testing.T) {
+	loader, err := NewS3Loader(map[string]string{
+		"region":  "us-east-1",
+		"exclude": "logs/**",
+	}, Options{
+		URI:      "s3://test-bucket/models",
+		SyncMode: SyncModeMirror,
+	}, Secrets{})
+	require.NoError(t, err)
+
+	modTime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	fakeRclone := fakeCommand{
+		t:   t,
+		cmd: "rclone",
+		outputs: []out{
+			{stdout: "", exit: 0},
+			{stdout: "", exit: 0},
+			{stdout: `[{"Path":"a.bin","Name":"a.bin","Size":5,"ModTime":"2024-01-02T15:04:05.000000000Z","IsDir":false},{"Path":"sub/b.bin","Name":"b.bin","Size":10,"ModTime":"2024-01-02T15:04:05.000000000Z","IsDir":false}]`, exit: 0},
+			{stdout: "", exit: 0},
+		},
+	}
+	defer func() {
+		assert.NoError(t, fakeRclone.Clean())
+	}()
+
+	s3Dir := t.TempDir()
+	writeFiles(t, s3Dir, "a.bin", "old.bin", "logs/app.log")
+	require.NoError(t, os.Chtimes(filepath.Join(s3Dir, "a.bin"), modTime, modTime))
+
+	var plan *Plan
+	fakeRclone.WithContext(func() {
+		plan, err = loader.Plan(context.Background(), "s3://test-bucket/models", s3Dir)
+	})
+	require.NoError(t, err)
+
+	bbs := fakeRclone.GetAllInputs()
+	require.Len(t, bbs, 4)
+	assert.True(t, strings.HasPrefix(string(bbs[2]), "lsjson -R --files-only baize-data-loader-copy-config-"))
+	assert.Contains(t, string(bbs[2]), "test-bucket/models --filter - logs/")
+	assert.True(t, strings.HasPrefix(string(bbs[3]), "config delete"))
+
+	assert.Equal(t, []PlanFile{{Path: "sub/b.bin", Size: 10}}, plan.Transfer)
+	assert.Equal(t, []PlanFile{{Path: "a.bin", Size: 5}}, plan.Skip)
+	assert.Equal(t, []PlanFile{{Path: "old.bin", Size: 7}}, plan.Delete)
+	assert.Equal(t, PlanSummary{
+		TransferFiles: 1,
+		TransferBytes: 10,
+		DeleteFiles:   1,
+		DeleteBytes:   7,
+		SkipFiles:     1,
+		SkipBytes:     5,
+	}, plan.Summary())
+	assert.FileExists(t, filepath.Join(s3Dir, "old.bin"))
 }
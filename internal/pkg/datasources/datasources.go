@@ -1,7 +1,15 @@
 package datasources
 
 import (
+	"context"
+	"errors"
 	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/BaizeAI/dataset/pkg/utils"
 )
 
 type Options struct {
@@ -15,8 +23,142 @@ type Options struct {
 	UID  int
 	GID  int
 	Root string
+	// DirMode is the permission mode of the directories, Mode if zero.
+	DirMode os.FileMode
+
+	// Limits shared by all types, zero values leave the defaults of the
+	// loader in place.
+	//
+	// BandwidthLimit is in the format of rclone --bwlimit, e.g. 10M.
+	BandwidthLimit string
+	// MaxConcurrency is the number of files transferred in parallel.
+	MaxConcurrency int
+	// MaxRetries is how many times a failed transfer is retried.
+	MaxRetries int
+
+	// SyncMode decides whether the files removed from the source are deleted
+	// as well, an empty SyncMode is SyncModeCopy.
+	SyncMode SyncMode
+	// MaxDeletePercent bounds the share of the synced files SyncModeMirror
+	// may delete, DefaultMaxDeletePercent if zero, unless ForceDelete is set.
+	MaxDeletePercent int
+	ForceDelete      bool
 }
 
 type Loader interface {
-	Sync(fromURI string, toPath string) error
+	// Sync syncs the data from fromURI to toPath, commands spawned by the
+	// loader are stopped once ctx is done.
+	Sync(ctx context.Context, fromURI string, toPath string) error
+}
+
+// cleanupTimeout bounds how long cleanups, e.g. removing credentials from the
+// git remotes, may take after the sync has been interrupted.
+const cleanupTimeout = 10 * time.Second
+
+// cleanupContext returns a context for cleaning up after an operation which
+// may well have been interrupted, it is not canceled together with ctx.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// Revisioner is implemented by loaders that know which revision of the
+// source has been synced, e.g. the git tag resolved from tagPattern.
+type Revisioner interface {
+	Revision() string
+}
+
+// ChangeReporter is implemented by loaders that know which files the last
+// Sync transferred, or found up to date but with a mode or owner other than
+// the ones in Options, the post copy stage then only changes the mode and the
+// owner of those files instead of walking the whole directory.
+type ChangeReporter interface {
+	// ChangedFiles returns the slash separated paths relative to the synced
+	// directory, ok is false if the loader could not tell.
+	ChangedFiles() (files []string, ok bool)
+}
+
+// changeRecorder records the files transferred by Sync for ChangedFiles.
+type changeRecorder struct {
+	mu       sync.Mutex
+	files    []string
+	recorded bool
+}
+
+// needsPostCopy tells whether the mode or the owner of the local file differs
+// from the ones the post copy stage sets.
+func needsPostCopy(localPath string, options Options) bool {
+	stat, err := os.Stat(localPath)
+	if err != nil {
+		return true
+	}
+
+	return !utils.IsPermModeAndOwnerMatched(stat, options.Mode, options.UID, options.GID)
+}
+
+// startRecording forgets the files of the previous Sync.
+func (c *changeRecorder) startRecording() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.files = make([]string, 0)
+	c.recorded = false
+}
+
+func (c *changeRecorder) record(files ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.files = append(c.files, files...)
+}
+
+// stopRecording marks the recorded files complete, ChangedFiles reports
+// nothing unless it is called.
+func (c *changeRecorder) stopRecording() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.recorded = true
+}
+
+func (c *changeRecorder) ChangedFiles() ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.recorded {
+		return nil, false
+	}
+	return append([]string(nil), c.files...), true
+}
+
+// SyncFailureReasonOutOfSpace is the reason of a round that failed as the
+// volume ran out of space.
+const SyncFailureReasonOutOfSpace = "OutOfSpace"
+
+// SyncResult is written by data-loader as the termination message of its
+// container once the round succeeded, or failed for a reason the controller
+// is able to act on, and read back by the controller.
+type SyncResult struct {
+	Revision string `json:"revision,omitempty"`
+	// Reason is why the round failed, e.g. SyncFailureReasonOutOfSpace.
+	Reason string `json:"reason,omitempty"`
+	// RequiredBytes is the size the volume is estimated to need for the
+	// round to succeed, 0 if unknown.
+	RequiredBytes int64 `json:"requiredBytes,omitempty"`
+	// Stats is how big the synced directory is after the round, nil if
+	// unknown.
+	Stats *DirStats `json:"stats,omitempty"`
+}
+
+// IsOutOfSpace tells whether the error is caused by the volume running out of
+// space or quota, including the errors of commands only known by their output.
+func IsOutOfSpace(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, syscall.ENOSPC) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "no space left on device") || strings.Contains(message, "disk quota exceeded")
 }
@@ -0,0 +1,25 @@
+package datasources
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsOutOfSpace(t *testing.T) {
+	for err, expected := range map[error]bool{
+		nil:                            false,
+		errors.New("connection reset"): false,
+		syscall.ENOSPC:                 true,
+		&os.PathError{Op: "write", Path: "/data/a", Err: syscall.ENOSPC}:                  true,
+		fmt.Errorf("failed to sync: %w", &os.PathError{Op: "write", Err: syscall.ENOSPC}): true,
+		errors.New("rclone: write /data/a: no space left on device"):                      true,
+		errors.New("git: Disk quota exceeded"):                                            true,
+	} {
+		assert.Equal(t, expected, IsOutOfSpace(err), "%v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package datasources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/BaizeAI/dataset/pkg/log"
+	"github.com/BaizeAI/dataset/pkg/utils"
+)
+
+var _ Loader = &ExternalLoader{}
+var _ Revisioner = &ExternalLoader{}
+
+// ExternalLoaderRequest is written as a single JSON document to the stdin of
+// an external data-loader-<type> executable.
+type ExternalLoaderRequest struct {
+	Type string `json:"type"`
+	URI  string `json:"uri"`
+	// Path is the absolute directory the data should be synced to.
+	Path    string            `json:"path"`
+	Options map[string]string `json:"options,omitempty"`
+	// Secrets holds the content of the mounted secret by key, e.g. token.
+	Secrets map[SecretKey]string `json:"secrets,omitempty"`
+	// BandwidthLimit, MaxConcurrency and MaxRetries are the common limits
+	// that external loaders are expected to honor if set.
+	BandwidthLimit string `json:"bandwidthLimit,omitempty"`
+	MaxConcurrency int    `json:"maxConcurrency,omitempty"`
+	MaxRetries     int    `json:"maxRetries,omitempty"`
+	// SyncMode is either copy or mirror, external loaders running in mirror
+	// mode are expected to delete the files removed from the source, but no
+	// more than MaxDeletePercent (DefaultMaxDeletePercent if zero) of the
+	// synced files unless ForceDelete is set.
+	SyncMode         SyncMode `json:"syncMode,omitempty"`
+	MaxDeletePercent int      `json:"maxDeletePercent,omitempty"`
+	ForceDelete      bool     `json:"forceDelete,omitempty"`
+}
+
+// ExternalLoaderResponse is read as a single JSON document from the stdout of
+// an external loader once it exits, stderr is forwarded to the logs. A
+// non-zero exit code or a non-empty error fails the sync.
+type ExternalLoaderResponse struct {
+	Revision string `json:"revision,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ExternalLoader runs a third-party loader as an executable that speaks the
+// ExternalLoaderRequest/ExternalLoaderResponse protocol.
+type ExternalLoader struct {
+	Options Options
+
+	executable        string
+	datasourceOptions map[string]string
+	secrets           Secrets
+	revision          string
+}
+
+func NewExternalLoader(executable string, datasourceOptions map[string]string, options Options, secrets Secrets) (*ExternalLoader, error) {
+	return &ExternalLoader{
+		Options:           options,
+		executable:        executable,
+		datasourceOptions: datasourceOptions,
+		secrets:           secrets,
+	}, nil
+}
+
+func (d *ExternalLoader) secretsMap() map[SecretKey]string {
+	secrets := map[SecretKey]string{
+		SecretKeyUsername:             d.secrets.Username,
+		SecretKeyPassword:             d.secrets.Password,
+		SecretKeyPrivateKey:           d.secrets.SSHPrivateKey,
+		SecretKeyPrivateKeyPassphrase: d.secrets.SSHPrivateKeyPassphrase,
+		SecretKeyToken:                d.secrets.Token,
+		SecretKeyAccessKey:            d.secrets.AKSKAccessKeyID,
+		SecretKeySecretKey:            d.secrets.AKSKSecretAccessKey,
+		SecretKeyCABundle:             d.secrets.CABundle,
+	}
+	for k, v := range secrets {
+		if v == "" {
+			delete(secrets, k)
+		}
+	}
+
+	return secrets
+}
+
+func (d *ExternalLoader) Sync(ctx context.Context, fromURI string, toPath string) error {
+	logger := log.WithFields(logrus.Fields{
+		"fromURI":    fromURI,
+		"type":       d.Options.Type,
+		"toPath":     toPath,
+		"executable": d.executable,
+	})
+
+	path := resolveDir(d.Options.Root, toPath)
+
+	secrets := d.secretsMap()
+	request, err := json.Marshal(ExternalLoaderRequest{
+		Type:    string(d.Options.Type),
+		URI:     fromURI,
+		Path:    path,
+		Options: d.datasourceOptions,
+		Secrets: secrets,
+
+		BandwidthLimit: d.Options.BandwidthLimit,
+		MaxConcurrency: d.Options.MaxConcurrency,
+		MaxRetries:     d.Options.MaxRetries,
+
+		SyncMode:         d.Options.SyncMode,
+		MaxDeletePercent: d.Options.MaxDeletePercent,
+		ForceDelete:      d.Options.ForceDelete,
+	})
+	if err != nil {
+		return err
+	}
+
+	cmd := utils.CommandContext(ctx, d.executable) // #nosec G204
+	cmd.Dir = d.Options.Root
+	cmd.Env = os.Environ()
+	cmd.Stdin = bytes.NewReader(request)
+
+	logger = logger.WithField("command", cmd.String())
+	logger.Debug("executing external loader")
+
+	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, lo.Values(secrets))
+	if err != nil {
+		logger.Errorf("external loader error: %s", errBuffer)
+		return fmt.Errorf("failed to copy data from %s to %s with external loader %s, err: %s", fromURI, toPath, d.executable, err)
+	}
+
+	var response ExternalLoaderResponse
+	if output := bytes.TrimSpace(outBuffer.Bytes()); len(output) > 0 {
+		err = json.Unmarshal(output, &response)
+		if err != nil {
+			return fmt.Errorf("failed to parse response of external loader %s: %w", d.executable, err)
+		}
+	}
+	if response.Error != "" {
+		return fmt.Errorf("failed to copy data from %s to %s with external loader %s, err: %s", fromURI, toPath, d.executable, response.Error)
+	}
+
+	d.revision = response.Revision
+
+	return nil
+}
+
+func (d *ExternalLoader) Revision() string {
+	return d.revision
+}
@@ -9,6 +9,40 @@ import (
 )
 
 type FakeHfAPI struct {
+	GetRepoInfoStub        func(context.Context, string, string, string, string) (*huggingface.HfAPIRepoInfoResponse, error)
+	getRepoInfoMutex       sync.RWMutex
+	getRepoInfoArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 string
+	}
+	getRepoInfoReturns struct {
+		result1 *huggingface.HfAPIRepoInfoResponse
+		result2 error
+	}
+	getRepoInfoReturnsOnCall map[int]struct {
+		result1 *huggingface.HfAPIRepoInfoResponse
+		result2 error
+	}
+	ListRepoFilesStub        func(context.Context, string, string, string, string) ([]string, error)
+	listRepoFilesMutex       sync.RWMutex
+	listRepoFilesArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 string
+	}
+	listRepoFilesReturns struct {
+		result1 []string
+		result2 error
+	}
+	listRepoFilesReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
 	WhoAmIStub        func(context.Context, string) (*huggingface.HfAPIWhoAmIResponse, error)
 	whoAmIMutex       sync.RWMutex
 	whoAmIArgsForCall []struct {
@@ -27,6 +61,142 @@ type FakeHfAPI struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeHfAPI) GetRepoInfo(arg1 context.Context, arg2 string, arg3 string, arg4 string, arg5 string) (*huggingface.HfAPIRepoInfoResponse, error) {
+	fake.getRepoInfoMutex.Lock()
+	ret, specificReturn := fake.getRepoInfoReturnsOnCall[len(fake.getRepoInfoArgsForCall)]
+	fake.getRepoInfoArgsForCall = append(fake.getRepoInfoArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 string
+	}{arg1, arg2, arg3, arg4, arg5})
+	stub := fake.GetRepoInfoStub
+	fakeReturns := fake.getRepoInfoReturns
+	fake.recordInvocation("GetRepoInfo", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.getRepoInfoMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeHfAPI) GetRepoInfoCallCount() int {
+	fake.getRepoInfoMutex.RLock()
+	defer fake.getRepoInfoMutex.RUnlock()
+	return len(fake.getRepoInfoArgsForCall)
+}
+
+func (fake *FakeHfAPI) GetRepoInfoCalls(stub func(context.Context, string, string, string, string) (*huggingface.HfAPIRepoInfoResponse, error)) {
+	fake.getRepoInfoMutex.Lock()
+	defer fake.getRepoInfoMutex.Unlock()
+	fake.GetRepoInfoStub = stub
+}
+
+func (fake *FakeHfAPI) GetRepoInfoArgsForCall(i int) (context.Context, string, string, string, string) {
+	fake.getRepoInfoMutex.RLock()
+	defer fake.getRepoInfoMutex.RUnlock()
+	argsForCall := fake.getRepoInfoArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeHfAPI) GetRepoInfoReturns(result1 *huggingface.HfAPIRepoInfoResponse, result2 error) {
+	fake.getRepoInfoMutex.Lock()
+	defer fake.getRepoInfoMutex.Unlock()
+	fake.GetRepoInfoStub = nil
+	fake.getRepoInfoReturns = struct {
+		result1 *huggingface.HfAPIRepoInfoResponse
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeHfAPI) GetRepoInfoReturnsOnCall(i int, result1 *huggingface.HfAPIRepoInfoResponse, result2 error) {
+	fake.getRepoInfoMutex.Lock()
+	defer fake.getRepoInfoMutex.Unlock()
+	fake.GetRepoInfoStub = nil
+	if fake.getRepoInfoReturnsOnCall == nil {
+		fake.getRepoInfoReturnsOnCall = make(map[int]struct {
+			result1 *huggingface.HfAPIRepoInfoResponse
+			result2 error
+		})
+	}
+	fake.getRepoInfoReturnsOnCall[i] = struct {
+		result1 *huggingface.HfAPIRepoInfoResponse
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeHfAPI) ListRepoFiles(arg1 context.Context, arg2 string, arg3 string, arg4 string, arg5 string) ([]string, error) {
+	fake.listRepoFilesMutex.Lock()
+	ret, specificReturn := fake.listRepoFilesReturnsOnCall[len(fake.listRepoFilesArgsForCall)]
+	fake.listRepoFilesArgsForCall = append(fake.listRepoFilesArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 string
+	}{arg1, arg2, arg3, arg4, arg5})
+	stub := fake.ListRepoFilesStub
+	fakeReturns := fake.listRepoFilesReturns
+	fake.recordInvocation("ListRepoFiles", []interface{}{arg1, arg2, arg3, arg4, arg5})
+	fake.listRepoFilesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeHfAPI) ListRepoFilesCallCount() int {
+	fake.listRepoFilesMutex.RLock()
+	defer fake.listRepoFilesMutex.RUnlock()
+	return len(fake.listRepoFilesArgsForCall)
+}
+
+func (fake *FakeHfAPI) ListRepoFilesCalls(stub func(context.Context, string, string, string, string) ([]string, error)) {
+	fake.listRepoFilesMutex.Lock()
+	defer fake.listRepoFilesMutex.Unlock()
+	fake.ListRepoFilesStub = stub
+}
+
+func (fake *FakeHfAPI) ListRepoFilesArgsForCall(i int) (context.Context, string, string, string, string) {
+	fake.listRepoFilesMutex.RLock()
+	defer fake.listRepoFilesMutex.RUnlock()
+	argsForCall := fake.listRepoFilesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeHfAPI) ListRepoFilesReturns(result1 []string, result2 error) {
+	fake.listRepoFilesMutex.Lock()
+	defer fake.listRepoFilesMutex.Unlock()
+	fake.ListRepoFilesStub = nil
+	fake.listRepoFilesReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeHfAPI) ListRepoFilesReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.listRepoFilesMutex.Lock()
+	defer fake.listRepoFilesMutex.Unlock()
+	fake.ListRepoFilesStub = nil
+	if fake.listRepoFilesReturnsOnCall == nil {
+		fake.listRepoFilesReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.listRepoFilesReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeHfAPI) WhoAmI(arg1 context.Context, arg2 string) (*huggingface.HfAPIWhoAmIResponse, error) {
 	fake.whoAmIMutex.Lock()
 	ret, specificReturn := fake.whoAmIReturnsOnCall[len(fake.whoAmIArgsForCall)]
@@ -95,6 +265,10 @@ func (fake *FakeHfAPI) WhoAmIReturnsOnCall(i int, result1 *huggingface.HfAPIWhoA
 func (fake *FakeHfAPI) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.getRepoInfoMutex.RLock()
+	defer fake.getRepoInfoMutex.RUnlock()
+	fake.listRepoFilesMutex.RLock()
+	defer fake.listRepoFilesMutex.RUnlock()
 	fake.whoAmIMutex.RLock()
 	defer fake.whoAmIMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -6,7 +6,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,6 +18,10 @@ const (
 	HubAPIEndpointDomain = "huggingface.co"
 
 	hubAPIEndpointPathWhoAmI = "/api/whoami-v2"
+
+	RepoTypeModel   = "model"
+	RepoTypeDataset = "dataset"
+	DefaultRevision = "main"
 )
 
 type HfAPIAccessToken struct {
@@ -41,6 +48,23 @@ type HfAPIWhoAmIResponse struct {
 	Type          string                  `json:"type"`
 }
 
+type HfAPIRepoSiblingLFS struct {
+	Sha256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+type HfAPIRepoSibling struct {
+	RFilename string               `json:"rfilename"`
+	Size      int64                `json:"size"`
+	LFS       *HfAPIRepoSiblingLFS `json:"lfs,omitempty"`
+}
+
+type HfAPIRepoInfoResponse struct {
+	ID       string             `json:"id"`
+	SHA      string             `json:"sha"`
+	Siblings []HfAPIRepoSibling `json:"siblings"`
+}
+
 type HfAPIErrorResponse struct {
 	Error string `json:"error"`
 }
@@ -61,6 +85,8 @@ func IsHfAPIError(err error) bool {
 //counterfeiter:generate -o fake/hub.go --fake-name FakeHfAPI . HfAPI
 type HfAPI interface {
 	WhoAmI(ctx context.Context, token string) (*HfAPIWhoAmIResponse, error)
+	ListRepoFiles(ctx context.Context, token string, repoType string, repoID string, revision string) ([]string, error)
+	GetRepoInfo(ctx context.Context, token string, repoType string, repoID string, revision string) (*HfAPIRepoInfoResponse, error)
 }
 
 type HfAPIClient struct {
@@ -68,13 +94,27 @@ type HfAPIClient struct {
 	apiEndpoint string
 }
 
+type HfAPIClientOption func(*HfAPIClient)
+
+// WithEndpoint overrides the endpoint of the hub, e.g. a mirror of it.
+func WithEndpoint(endpoint string) HfAPIClientOption {
+	return func(c *HfAPIClient) {
+		c.apiEndpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
 // NewHfAPIClient creates a new HfAPIClient.
 //
 // Source code: https://github.com/huggingface/huggingface_hub/blob/8d1ffc6d78827aa18c4fec3f73843ac7bb64a153/src/huggingface_hub/hf_api.py#L1493-L1535
-func NewHfAPIClient() *HfAPIClient {
-	return &HfAPIClient{
+func NewHfAPIClient(opts ...HfAPIClientOption) *HfAPIClient {
+	c := &HfAPIClient{
 		client: &http.Client{},
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 func (c *HfAPIClient) endpoint() string {
@@ -130,6 +170,76 @@ func (c *HfAPIClient) WhoAmI(ctx context.Context, token string) (*HfAPIWhoAmIRes
 	return &whoAmIResponse, nil
 }
 
+// ListRepoFiles returns the paths of all the files of the repo at the revision.
+//
+// Source code: https://github.com/huggingface/huggingface_hub/blob/8d1ffc6d78827aa18c4fec3f73843ac7bb64a153/src/huggingface_hub/hf_api.py#L2380-L2462
+func (c *HfAPIClient) ListRepoFiles(ctx context.Context, token string, repoType string, repoID string, revision string) ([]string, error) {
+	repoInfo, err := c.GetRepoInfo(ctx, token, repoType, repoID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(repoInfo.Siblings))
+	for _, sibling := range repoInfo.Siblings {
+		files = append(files, sibling.RFilename)
+	}
+
+	return files, nil
+}
+
+// GetRepoInfo returns the info of the repo at the revision, including the
+// commit the revision resolves to and the sizes of all the files.
+//
+// Source code: https://github.com/huggingface/huggingface_hub/blob/8d1ffc6d78827aa18c4fec3f73843ac7bb64a153/src/huggingface_hub/hf_api.py#L2120-L2200
+func (c *HfAPIClient) GetRepoInfo(ctx context.Context, token string, repoType string, repoID string, revision string) (*HfAPIRepoInfoResponse, error) {
+	if repoType == "" {
+		repoType = RepoTypeModel
+	}
+	if revision == "" {
+		revision = DefaultRevision
+	}
+
+	reqURL := fmt.Sprintf("%s/api/%ss/%s/revision/%s?blobs=true", c.endpoint(), repoType, repoID, url.PathEscape(revision))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = c.buildHfHeaders(token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	bodyBuffer := new(bytes.Buffer)
+	_, err = bodyBuffer.ReadFrom(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResponse HfAPIErrorResponse
+		if json.Unmarshal(bodyBuffer.Bytes(), &errResponse) == nil && errResponse.Error != "" {
+			return nil, &HfAPIError{errResponse}
+		}
+
+		return nil, fmt.Errorf("failed to get info of repo %s, status code %d", repoID, resp.StatusCode)
+	}
+
+	var repoInfo HfAPIRepoInfoResponse
+	err = json.Unmarshal(bodyBuffer.Bytes(), &repoInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repoInfo, nil
+}
+
 // Documentations: https://huggingface.co/docs/huggingface_hub/quick-start#authentication
 // Source code: https://github.com/huggingface/huggingface_hub/blob/8d1ffc6d78827aa18c4fec3f73843ac7bb64a153/src/huggingface_hub/hf_api.py#L1609-L1629
 // References:
@@ -148,8 +258,12 @@ func (c *HfAPIClient) GetTokenPermission(ctx context.Context, token string) (str
 // Reference:
 // - https://github.com/huggingface/huggingface_hub/blob/8d1ffc6d78827aa18c4fec3f73843ac7bb64a153/src/huggingface_hub/hf_api.py#L9399-L9421
 func (c *HfAPIClient) buildHfHeaders(token string) http.Header {
-	return http.Header{
-		"Authorization": []string{"Bearer " + token},
-		"User-Agent":    []string{"hf_hub/4.0.0"},
+	header := http.Header{
+		"User-Agent": []string{"hf_hub/4.0.0"},
+	}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
 	}
+
+	return header
 }
@@ -107,3 +107,73 @@ func TestWhoAmI(t *testing.T) {
 		assert.Equal(t, "Invalid username or password.", errResp.HfAPIErrorResponse.Error)
 	})
 }
+
+func TestListRepoFiles(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/api/datasets/ns/dataset/revision/v1.0", req.URL.Path)
+			assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+			_, err := rw.Write(lo.Must(json.Marshal(&HfAPIRepoInfoResponse{
+				ID: "ns/dataset",
+				Siblings: []HfAPIRepoSibling{
+					{RFilename: "README.md"},
+					{RFilename: "data/train.parquet"},
+				},
+			})))
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		c := NewHfAPIClient(WithEndpoint(server.URL + "/"))
+		c.client = server.Client()
+
+		files, err := c.ListRepoFiles(context.Background(), "token", RepoTypeDataset, "ns/dataset", "v1.0")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"README.md", "data/train.parquet"}, files)
+	})
+
+	t.Run("Error - not found", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/api/models/ns/model/revision/main", req.URL.Path)
+			assert.Empty(t, req.Header.Get("Authorization"))
+
+			rw.WriteHeader(http.StatusNotFound)
+			_, err := rw.Write(lo.Must(json.Marshal(&HfAPIErrorResponse{
+				Error: "Repository not found",
+			})))
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		c := NewHfAPIClient(WithEndpoint(server.URL))
+		c.client = server.Client()
+
+		_, err := c.ListRepoFiles(context.Background(), "", "", "ns/model", "")
+		require.Error(t, err)
+		assert.True(t, IsHfAPIError(err))
+		assert.EqualError(t, err, "Repository not found")
+	})
+}
+
+func TestGetRepoInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "/api/models/ns/model/revision/main", req.URL.Path)
+		assert.Equal(t, "true", req.URL.Query().Get("blobs"))
+
+		_, err := rw.Write([]byte(`{"id":"ns/model","sha":"abc123","siblings":[{"rfilename":"config.json","size":42},{"rfilename":"model.safetensors","size":1024,"lfs":{"sha256":"def456","size":1024}}]}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	c := NewHfAPIClient(WithEndpoint(server.URL))
+	c.client = server.Client()
+
+	repoInfo, err := c.GetRepoInfo(context.Background(), "", "", "ns/model", "")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", repoInfo.SHA)
+	assert.Equal(t, []HfAPIRepoSibling{
+		{RFilename: "config.json", Size: 42},
+		{RFilename: "model.safetensors", Size: 1024, LFS: &HfAPIRepoSiblingLFS{Sha256: "def456", Size: 1024}},
+	}, repoInfo.Siblings)
+}
@@ -0,0 +1,164 @@
+package datasources
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
+)
+
+// SyncMode decides what happens to the synced files that have been removed
+// from the source.
+type SyncMode string
+
+const (
+	// SyncModeCopy only adds and updates files, it is the default.
+	SyncModeCopy SyncMode = "copy"
+	// SyncModeMirror deletes the files that are no longer in the source as
+	// well, GIT always mirrors the checked out revision regardless.
+	SyncModeMirror SyncMode = "mirror"
+)
+
+// DefaultMaxDeletePercent is the largest share of the synced files that mirror
+// mode deletes in a single sync unless forced to.
+const DefaultMaxDeletePercent = 50
+
+func ParseSyncMode(syncMode string) (SyncMode, error) {
+	switch SyncMode(syncMode) {
+	case "", SyncModeCopy:
+		return SyncModeCopy, nil
+	case SyncModeMirror:
+		return SyncModeMirror, nil
+	default:
+		return "", fmt.Errorf("invalid syncMode %s, must be one of copy or mirror", syncMode)
+	}
+}
+
+// resolveDir resolves the directory the same way as the commands run with
+// root as their working directory do.
+func resolveDir(root string, dir string) string {
+	if filepath.IsAbs(dir) {
+		return dir
+	}
+
+	return filepath.Join(root, dir)
+}
+
+// listLocalFiles returns the slash separated paths, relative to dir, of the
+// regular files under dir that match.
+func listLocalFiles(dir string, match func(filePath string) bool) ([]string, error) {
+	files := make([]string, 0)
+	err := filepath.WalkDir(dir, func(walkPath string, walkDirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && walkPath == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !walkDirEntry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, walkPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if match == nil || match(rel) {
+			files = append(files, rel)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// staleFiles returns the local files that are not upstream.
+func staleFiles(local []string, upstream []string) []string {
+	upstreamSet := lo.SliceToMap(upstream, func(filePath string) (string, struct{}) {
+		return filePath, struct{}{}
+	})
+	return lo.Filter(local, func(filePath string, _ int) bool {
+		_, ok := upstreamSet[filePath]
+		return !ok
+	})
+}
+
+func maxDeletePercent(options Options) int {
+	if options.MaxDeletePercent <= 0 {
+		return DefaultMaxDeletePercent
+	}
+	return options.MaxDeletePercent
+}
+
+// checkDeletePercent fails if deleting stale of the local files exceeds
+// MaxDeletePercent, regardless of ForceDelete.
+func checkDeletePercent(dir string, stale int, local int, options Options) error {
+	if stale*100 > local*maxDeletePercent(options) {
+		return fmt.Errorf("refusing to delete %d of %d files in %s which exceeds maxDeletePercent %d%%, set forceDelete to true to delete them anyway", stale, local, dir, maxDeletePercent(options))
+	}
+	return nil
+}
+
+// mirrorDelete deletes the local files under dir that are not upstream, both
+// given as slash separated paths relative to dir. Unless forced, nothing is
+// deleted if the files to delete exceed MaxDeletePercent of the local files,
+// which is more likely caused by a misconfigured source than by an upstream
+// change.
+func mirrorDelete(ctx context.Context, logger *logrus.Entry, dir string, local []string, upstream []string, options Options) error {
+	stale := staleFiles(local, upstream)
+	if len(stale) == 0 {
+		logger.Debugf("no files to delete in %s, all %d files are upstream", dir, len(local))
+		return nil
+	}
+
+	err := checkDeletePercent(dir, len(stale), len(local), options)
+	if err != nil {
+		if !options.ForceDelete {
+			return err
+		}
+		logger.Warnf("deleting %d of %d files in %s which exceeds maxDeletePercent %d%%, forced", len(stale), len(local), dir, maxDeletePercent(options))
+	}
+
+	dirs := make(map[string]struct{})
+	for _, filePath := range stale {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err = os.Remove(filepath.Join(dir, filepath.FromSlash(filePath)))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete %s which is no longer upstream, err: %w", filePath, err)
+		}
+		logger.Infof("deleted %s which is no longer upstream", filePath)
+
+		for parent := path.Dir(filePath); parent != "."; parent = path.Dir(parent) {
+			dirs[parent] = struct{}{}
+		}
+	}
+
+	// remove the directories left empty, the deepest first
+	emptyDirs := lo.Keys(dirs)
+	sort.Slice(emptyDirs, func(i, j int) bool {
+		return strings.Count(emptyDirs[i], "/") > strings.Count(emptyDirs[j], "/")
+	})
+	for _, emptyDir := range emptyDirs {
+		// fails as expected for directories that are not empty
+		_ = os.Remove(filepath.Join(dir, filepath.FromSlash(emptyDir)))
+	}
+
+	logger.Infof("deleted %d files in %s which are no longer upstream", len(stale), dir)
+
+	return nil
+}
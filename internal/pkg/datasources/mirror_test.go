@@ -0,0 +1,89 @@
+package datasources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/BaizeAI/dataset/pkg/log"
+)
+
+func writeFiles(t *testing.T, dir string, files ...string) {
+	for _, file := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(file), 0600))
+	}
+}
+
+func TestParseSyncMode(t *testing.T) {
+	for syncMode, expected := range map[string]SyncMode{
+		"":       SyncModeCopy,
+		"copy":   SyncModeCopy,
+		"mirror": SyncModeMirror,
+	} {
+		actual, err := ParseSyncMode(syncMode)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	_, err := ParseSyncMode("sync")
+	assert.Error(t, err)
+}
+
+func TestMirrorDelete(t *testing.T) {
+	logger := log.WithField("test", t.Name())
+
+	t.Run("delete stale files", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, "a.txt", "b/c.txt", "b/d/e.txt", "f.txt", ".cache/g")
+
+		local, err := listLocalFiles(dir, func(filePath string) bool {
+			return filePath != ".cache/g"
+		})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.txt", "b/c.txt", "b/d/e.txt", "f.txt"}, local)
+
+		err = mirrorDelete(context.Background(), logger, dir, local, []string{"a.txt", "b/c.txt", "f.txt", "new.txt"}, Options{})
+		require.NoError(t, err)
+
+		assert.NoFileExists(t, filepath.Join(dir, "b/d/e.txt"))
+		assert.NoDirExists(t, filepath.Join(dir, "b/d"))
+		assert.FileExists(t, filepath.Join(dir, "b/c.txt"))
+		assert.FileExists(t, filepath.Join(dir, ".cache/g"))
+	})
+
+	t.Run("refuse to delete more than max delete percent", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, "a.txt", "b.txt", "c.txt")
+		local := []string{"a.txt", "b.txt", "c.txt"}
+
+		err := mirrorDelete(context.Background(), logger, dir, local, []string{"a.txt"}, Options{})
+		require.ErrorContains(t, err, "refusing to delete 2 of 3 files")
+		assert.FileExists(t, filepath.Join(dir, "b.txt"))
+		assert.FileExists(t, filepath.Join(dir, "c.txt"))
+
+		err = mirrorDelete(context.Background(), logger, dir, local, []string{"a.txt"}, Options{MaxDeletePercent: 70})
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(dir, "b.txt"))
+	})
+
+	t.Run("force delete", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, "a.txt", "b.txt")
+
+		err := mirrorDelete(context.Background(), logger, dir, []string{"a.txt", "b.txt"}, nil, Options{ForceDelete: true})
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+		assert.NoFileExists(t, filepath.Join(dir, "b.txt"))
+	})
+
+	t.Run("missing dir", func(t *testing.T) {
+		local, err := listLocalFiles(filepath.Join(t.TempDir(), "missing"), nil)
+		require.NoError(t, err)
+		assert.Empty(t, local)
+	})
+}
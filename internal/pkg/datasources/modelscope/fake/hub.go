@@ -9,6 +9,38 @@ import (
 )
 
 type FakeHubAPI struct {
+	DownloadFileStub        func(context.Context, string, string, string, modelscope.HubAPIRepoFile, string) error
+	downloadFileMutex       sync.RWMutex
+	downloadFileArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 modelscope.HubAPIRepoFile
+		arg6 string
+	}
+	downloadFileReturns struct {
+		result1 error
+	}
+	downloadFileReturnsOnCall map[int]struct {
+		result1 error
+	}
+	ListRepoFilesStub        func(context.Context, string, string, string) ([]modelscope.HubAPIRepoFile, error)
+	listRepoFilesMutex       sync.RWMutex
+	listRepoFilesArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+	}
+	listRepoFilesReturns struct {
+		result1 []modelscope.HubAPIRepoFile
+		result2 error
+	}
+	listRepoFilesReturnsOnCall map[int]struct {
+		result1 []modelscope.HubAPIRepoFile
+		result2 error
+	}
 	LoginStub        func(context.Context, string) (*modelscope.HubAPIBaseResponse[modelscope.HubAPILoginResponse], error)
 	loginMutex       sync.RWMutex
 	loginArgsForCall []struct {
@@ -27,6 +59,139 @@ type FakeHubAPI struct {
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeHubAPI) DownloadFile(arg1 context.Context, arg2 string, arg3 string, arg4 string, arg5 modelscope.HubAPIRepoFile, arg6 string) error {
+	fake.downloadFileMutex.Lock()
+	ret, specificReturn := fake.downloadFileReturnsOnCall[len(fake.downloadFileArgsForCall)]
+	fake.downloadFileArgsForCall = append(fake.downloadFileArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+		arg5 modelscope.HubAPIRepoFile
+		arg6 string
+	}{arg1, arg2, arg3, arg4, arg5, arg6})
+	stub := fake.DownloadFileStub
+	fakeReturns := fake.downloadFileReturns
+	fake.recordInvocation("DownloadFile", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6})
+	fake.downloadFileMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4, arg5, arg6)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *FakeHubAPI) DownloadFileCallCount() int {
+	fake.downloadFileMutex.RLock()
+	defer fake.downloadFileMutex.RUnlock()
+	return len(fake.downloadFileArgsForCall)
+}
+
+func (fake *FakeHubAPI) DownloadFileCalls(stub func(context.Context, string, string, string, modelscope.HubAPIRepoFile, string) error) {
+	fake.downloadFileMutex.Lock()
+	defer fake.downloadFileMutex.Unlock()
+	fake.DownloadFileStub = stub
+}
+
+func (fake *FakeHubAPI) DownloadFileArgsForCall(i int) (context.Context, string, string, string, modelscope.HubAPIRepoFile, string) {
+	fake.downloadFileMutex.RLock()
+	defer fake.downloadFileMutex.RUnlock()
+	argsForCall := fake.downloadFileArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6
+}
+
+func (fake *FakeHubAPI) DownloadFileReturns(result1 error) {
+	fake.downloadFileMutex.Lock()
+	defer fake.downloadFileMutex.Unlock()
+	fake.DownloadFileStub = nil
+	fake.downloadFileReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeHubAPI) DownloadFileReturnsOnCall(i int, result1 error) {
+	fake.downloadFileMutex.Lock()
+	defer fake.downloadFileMutex.Unlock()
+	fake.DownloadFileStub = nil
+	if fake.downloadFileReturnsOnCall == nil {
+		fake.downloadFileReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.downloadFileReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeHubAPI) ListRepoFiles(arg1 context.Context, arg2 string, arg3 string, arg4 string) ([]modelscope.HubAPIRepoFile, error) {
+	fake.listRepoFilesMutex.Lock()
+	ret, specificReturn := fake.listRepoFilesReturnsOnCall[len(fake.listRepoFilesArgsForCall)]
+	fake.listRepoFilesArgsForCall = append(fake.listRepoFilesArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+		arg4 string
+	}{arg1, arg2, arg3, arg4})
+	stub := fake.ListRepoFilesStub
+	fakeReturns := fake.listRepoFilesReturns
+	fake.recordInvocation("ListRepoFiles", []interface{}{arg1, arg2, arg3, arg4})
+	fake.listRepoFilesMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeHubAPI) ListRepoFilesCallCount() int {
+	fake.listRepoFilesMutex.RLock()
+	defer fake.listRepoFilesMutex.RUnlock()
+	return len(fake.listRepoFilesArgsForCall)
+}
+
+func (fake *FakeHubAPI) ListRepoFilesCalls(stub func(context.Context, string, string, string) ([]modelscope.HubAPIRepoFile, error)) {
+	fake.listRepoFilesMutex.Lock()
+	defer fake.listRepoFilesMutex.Unlock()
+	fake.ListRepoFilesStub = stub
+}
+
+func (fake *FakeHubAPI) ListRepoFilesArgsForCall(i int) (context.Context, string, string, string) {
+	fake.listRepoFilesMutex.RLock()
+	defer fake.listRepoFilesMutex.RUnlock()
+	argsForCall := fake.listRepoFilesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeHubAPI) ListRepoFilesReturns(result1 []modelscope.HubAPIRepoFile, result2 error) {
+	fake.listRepoFilesMutex.Lock()
+	defer fake.listRepoFilesMutex.Unlock()
+	fake.ListRepoFilesStub = nil
+	fake.listRepoFilesReturns = struct {
+		result1 []modelscope.HubAPIRepoFile
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeHubAPI) ListRepoFilesReturnsOnCall(i int, result1 []modelscope.HubAPIRepoFile, result2 error) {
+	fake.listRepoFilesMutex.Lock()
+	defer fake.listRepoFilesMutex.Unlock()
+	fake.ListRepoFilesStub = nil
+	if fake.listRepoFilesReturnsOnCall == nil {
+		fake.listRepoFilesReturnsOnCall = make(map[int]struct {
+			result1 []modelscope.HubAPIRepoFile
+			result2 error
+		})
+	}
+	fake.listRepoFilesReturnsOnCall[i] = struct {
+		result1 []modelscope.HubAPIRepoFile
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeHubAPI) Login(arg1 context.Context, arg2 string) (*modelscope.HubAPIBaseResponse[modelscope.HubAPILoginResponse], error) {
 	fake.loginMutex.Lock()
 	ret, specificReturn := fake.loginReturnsOnCall[len(fake.loginArgsForCall)]
@@ -95,6 +260,10 @@ func (fake *FakeHubAPI) LoginReturnsOnCall(i int, result1 *modelscope.HubAPIBase
 func (fake *FakeHubAPI) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.downloadFileMutex.RLock()
+	defer fake.downloadFileMutex.RUnlock()
+	fake.listRepoFilesMutex.RLock()
+	defer fake.listRepoFilesMutex.RUnlock()
 	fake.loginMutex.RLock()
 	defer fake.loginMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -5,8 +5,23 @@ package modelscope
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+
+	"github.com/BaizeAI/dataset/pkg/utils"
 )
 
 type HubAPIBaseResponse[T any] struct {
@@ -24,6 +39,19 @@ type HubAPILoginResponse struct {
 	WorkNo      string `json:"WorkNo"`
 }
 
+type HubAPIRepoFile struct {
+	Name     string `json:"Name"`
+	Path     string `json:"Path"`
+	Type     string `json:"Type"`
+	Size     int64  `json:"Size"`
+	Sha256   string `json:"Sha256"`
+	Revision string `json:"Revision"`
+}
+
+type HubAPIRepoFilesResponse struct {
+	Files []HubAPIRepoFile `json:"Files"`
+}
+
 type HubAPIError struct {
 	HubAPIBaseResponse[any]
 }
@@ -42,25 +70,67 @@ const (
 	HubAPIEndpointDomain = "www.modelscope.cn"
 
 	hubAPIEndpointPathLogin = "/api/v1/login"
+
+	RepoTypeModel   = "model"
+	RepoTypeDataset = "dataset"
+
+	DefaultRevision = "master"
+
+	repoFileTypeTree = "tree"
+
+	datasetRepoTreePageSize = 100
 )
 
 //counterfeiter:generate -o fake/hub.go --fake-name FakeHubAPI . HubAPI
 type HubAPI interface {
 	Login(ctx context.Context, token string) (*HubAPIBaseResponse[HubAPILoginResponse], error)
+	ListRepoFiles(ctx context.Context, repoType, repoID, revision string) ([]HubAPIRepoFile, error)
+	DownloadFile(ctx context.Context, repoType, repoID, revision string, file HubAPIRepoFile, toPath string) error
 }
 
 type HubAPIClient struct {
 	client      *http.Client
 	apiEndpoint string
+	limiter     *rate.Limiter
+}
+
+type HubAPIClientOption func(*HubAPIClient)
+
+// WithEndpoint overrides the default https://www.modelscope.cn endpoint, e.g.
+// to use a self-hosted mirror.
+func WithEndpoint(endpoint string) HubAPIClientOption {
+	return func(c *HubAPIClient) {
+		c.apiEndpoint = strings.TrimSuffix(endpoint, "/")
+	}
+}
+
+// WithBandwidthLimit limits the total bandwidth of all downloads of the
+// client to bytesPerSecond, zero means unlimited.
+func WithBandwidthLimit(bytesPerSecond int64) HubAPIClientOption {
+	return func(c *HubAPIClient) {
+		if bytesPerSecond > 0 {
+			c.limiter = utils.NewBandwidthLimiter(bytesPerSecond)
+		}
+	}
 }
 
 // NewHubAPIClient creates a new HubAPIClient.
 //
+// Session cookies returned by Login are kept in the cookie jar of the client
+// and sent with the subsequent requests, the same way the Python SDK does.
+//
 // Source code: https://github.com/modelscope/modelscope/blob/058df0e34c8dad07659f326e71ffa68c133c4ec8/modelscope/hub/api.py#L62-L94
-func NewHubAPIClient() *HubAPIClient {
-	return &HubAPIClient{
-		client: &http.Client{},
+func NewHubAPIClient(opts ...HubAPIClientOption) *HubAPIClient {
+	jar, _ := cookiejar.New(nil)
+
+	c := &HubAPIClient{
+		client: &http.Client{Jar: jar},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+
+	return c
 }
 
 func (c *HubAPIClient) endpoint() string {
@@ -122,3 +192,292 @@ func (c *HubAPIClient) Login(ctx context.Context, token string) (*HubAPIBaseResp
 
 	return &response, nil
 }
+
+func (c *HubAPIClient) repoPath(repoType, repoID string) (string, error) {
+	segments := strings.Split(strings.Trim(repoID, "/"), "/")
+	if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+		return "", fmt.Errorf("invalid repo id %s, expected <namespace>/<name>", repoID)
+	}
+
+	var prefix string
+	switch repoType {
+	case RepoTypeModel, "":
+		prefix = "/api/v1/models/"
+	case RepoTypeDataset:
+		prefix = "/api/v1/datasets/"
+	default:
+		return "", fmt.Errorf("unsupported repo type %s", repoType)
+	}
+
+	return prefix + url.PathEscape(segments[0]) + "/" + url.PathEscape(segments[1]), nil
+}
+
+func getJSON[T any](ctx context.Context, c *HubAPIClient, requestURL string) (*HubAPIBaseResponse[T], error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var response HubAPIBaseResponse[T]
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response of %s with status %s: %w", requestURL, resp.Status, err)
+	}
+	if !response.Success {
+		return nil, &HubAPIError{HubAPIBaseResponse: HubAPIBaseResponse[any]{
+			Code:      response.Code,
+			Message:   response.Message,
+			RequestID: response.RequestID,
+			Success:   response.Success,
+		}}
+	}
+
+	return &response, nil
+}
+
+// ListRepoFiles lists all the files of the model or dataset repository at the
+// given revision recursively, directories are omitted.
+//
+// Source code:
+// - https://github.com/modelscope/modelscope/blob/058df0e34c8dad07659f326e71ffa68c133c4ec8/modelscope/hub/api.py#L541-L590
+// - https://github.com/modelscope/modelscope/blob/058df0e34c8dad07659f326e71ffa68c133c4ec8/modelscope/hub/api.py#L679-L706
+func (c *HubAPIClient) ListRepoFiles(ctx context.Context, repoType, repoID, revision string) ([]HubAPIRepoFile, error) {
+	repoPath, err := c.repoPath(repoType, repoID)
+	if err != nil {
+		return nil, err
+	}
+	if revision == "" {
+		revision = DefaultRevision
+	}
+
+	var files []HubAPIRepoFile
+	if repoType == RepoTypeDataset {
+		// dataset repositories are paginated
+		for page := 1; ; page++ {
+			query := url.Values{
+				"Revision":   []string{revision},
+				"Root":       []string{"/"},
+				"Recursive":  []string{"True"},
+				"PageNumber": []string{strconv.Itoa(page)},
+				"PageSize":   []string{strconv.Itoa(datasetRepoTreePageSize)},
+			}
+
+			response, err := getJSON[HubAPIRepoFilesResponse](ctx, c, c.endpoint()+repoPath+"/repo/tree?"+query.Encode())
+			if err != nil {
+				return nil, err
+			}
+			if response.Data == nil {
+				break
+			}
+
+			files = append(files, response.Data.Files...)
+			if len(response.Data.Files) < datasetRepoTreePageSize {
+				break
+			}
+		}
+	} else {
+		query := url.Values{
+			"Revision":  []string{revision},
+			"Recursive": []string{"True"},
+		}
+
+		response, err := getJSON[HubAPIRepoFilesResponse](ctx, c, c.endpoint()+repoPath+"/repo/files?"+query.Encode())
+		if err != nil {
+			return nil, err
+		}
+		if response.Data != nil {
+			files = response.Data.Files
+		}
+	}
+
+	blobs := make([]HubAPIRepoFile, 0, len(files))
+	for _, file := range files {
+		if file.Type == repoFileTypeTree {
+			continue
+		}
+
+		blobs = append(blobs, file)
+	}
+
+	return blobs, nil
+}
+
+// DownloadFile downloads a single file of the repository into toPath, keeping
+// the relative path of the file in the repository.
+//
+// Files that already exist with the expected size and sha256 are skipped.
+// Partially downloaded files are kept as <file>.incomplete and resumed with a
+// Range request, the sha256 of the file is verified before it is moved to its
+// final location.
+//
+// Source code: https://github.com/modelscope/modelscope/blob/058df0e34c8dad07659f326e71ffa68c133c4ec8/modelscope/hub/file_download.py#L187-L258
+func (c *HubAPIClient) DownloadFile(ctx context.Context, repoType, repoID, revision string, file HubAPIRepoFile, toPath string) error {
+	repoPath, err := c.repoPath(repoType, repoID)
+	if err != nil {
+		return err
+	}
+	if revision == "" {
+		revision = DefaultRevision
+	}
+
+	target := filepath.Join(toPath, filepath.FromSlash(file.Path))
+	rel, err := filepath.Rel(toPath, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid file path %s of repo %s", file.Path, repoID)
+	}
+
+	matched, err := FileMatches(target, file)
+	if err != nil {
+		return err
+	}
+	if matched {
+		return nil
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"Revision": []string{revision},
+		"FilePath": []string{file.Path},
+	}
+	if repoType == RepoTypeDataset {
+		query.Set("Source", "SDK")
+	}
+
+	incomplete := target + ".incomplete"
+	err = c.downloadTo(ctx, c.endpoint()+repoPath+"/repo?"+query.Encode(), incomplete, file.Size)
+	if err != nil {
+		return fmt.Errorf("failed to download %s of repo %s: %w", file.Path, repoID, err)
+	}
+
+	matched, err = FileMatches(incomplete, file)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		_ = os.Remove(incomplete)
+		return fmt.Errorf("failed to download %s of repo %s: size or sha256 mismatch", file.Path, repoID)
+	}
+
+	return os.Rename(incomplete, target)
+}
+
+func (c *HubAPIClient) downloadTo(ctx context.Context, requestURL string, toFile string, size int64) error {
+	f, err := os.OpenFile(toFile, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	offset := stat.Size()
+	if size > 0 && offset > size {
+		offset = 0
+	}
+	if size > 0 && offset == size {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server ignored the Range header, start over
+		offset = 0
+	default:
+		var response HubAPIBaseResponse[any]
+		if json.NewDecoder(resp.Body).Decode(&response) == nil && response.Message != "" {
+			return &HubAPIError{HubAPIBaseResponse: response}
+		}
+
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	err = f.Truncate(offset)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, utils.NewRateLimitedReader(ctx, resp.Body, c.limiter))
+	if err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// FileMatches tells whether the local file at path has the size and, if the
+// hub reports it, the sha256 of the file of the repo.
+func FileMatches(path string, file HubAPIRepoFile) (bool, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+	if stat.Size() != file.Size {
+		return false, nil
+	}
+	if file.Sha256 == "" {
+		return true, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return false, err
+	}
+
+	return strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), file.Sha256), nil
+}
@@ -1,11 +1,18 @@
 package modelscope
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/samber/lo"
@@ -100,3 +107,186 @@ func TestLogin(t *testing.T) {
 		assert.False(t, errResp.Success)
 	})
 }
+
+func TestListRepoFiles(t *testing.T) {
+	t.Run("Model", func(t *testing.T) {
+		c := NewHubAPIClient()
+
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/api/v1/models/ns/model/repo/files", req.URL.Path)
+			assert.Equal(t, "v1.0.0", req.URL.Query().Get("Revision"))
+			assert.Equal(t, "True", req.URL.Query().Get("Recursive"))
+
+			_, err := rw.Write(lo.Must(json.Marshal(&HubAPIBaseResponse[HubAPIRepoFilesResponse]{
+				Code: 200,
+				Data: &HubAPIRepoFilesResponse{
+					Files: []HubAPIRepoFile{
+						{Name: "config.json", Path: "config.json", Type: "blob", Size: 2},
+						{Name: "onnx", Path: "onnx", Type: "tree"},
+						{Name: "model.onnx", Path: "onnx/model.onnx", Type: "blob", Size: 4},
+					},
+				},
+				Success: true,
+			})))
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		c.apiEndpoint = server.URL
+		c.client = server.Client()
+
+		files, err := c.ListRepoFiles(context.Background(), RepoTypeModel, "ns/model", "v1.0.0")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"config.json", "onnx/model.onnx"}, lo.Map(files, func(f HubAPIRepoFile, _ int) string {
+			return f.Path
+		}))
+	})
+
+	t.Run("Dataset", func(t *testing.T) {
+		c := NewHubAPIClient()
+
+		var pages []string
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/api/v1/datasets/ns/dataset/repo/tree", req.URL.Path)
+			assert.Equal(t, DefaultRevision, req.URL.Query().Get("Revision"))
+			pages = append(pages, req.URL.Query().Get("PageNumber"))
+
+			count := datasetRepoTreePageSize
+			if len(pages) > 1 {
+				count = 1
+			}
+
+			files := make([]HubAPIRepoFile, 0, count)
+			for i := 0; i < count; i++ {
+				files = append(files, HubAPIRepoFile{Path: fmt.Sprintf("data/%d-%d.csv", len(pages), i), Type: "blob"})
+			}
+
+			_, err := rw.Write(lo.Must(json.Marshal(&HubAPIBaseResponse[HubAPIRepoFilesResponse]{
+				Data:    &HubAPIRepoFilesResponse{Files: files},
+				Success: true,
+			})))
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		c.apiEndpoint = server.URL
+		c.client = server.Client()
+
+		files, err := c.ListRepoFiles(context.Background(), RepoTypeDataset, "ns/dataset", "")
+		require.NoError(t, err)
+		assert.Len(t, files, datasetRepoTreePageSize+1)
+		assert.Equal(t, []string{"1", "2"}, pages)
+	})
+
+	t.Run("Error - repo not found", func(t *testing.T) {
+		c := NewHubAPIClient()
+
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusNotFound)
+			_, err := rw.Write(lo.Must(json.Marshal(&HubAPIBaseResponse[any]{
+				Code:    10010205001,
+				Message: "模型不存在",
+				Success: false,
+			})))
+			require.NoError(t, err)
+		}))
+		defer server.Close()
+
+		c.apiEndpoint = server.URL
+		c.client = server.Client()
+
+		_, err := c.ListRepoFiles(context.Background(), RepoTypeModel, "ns/model", "")
+		require.Error(t, err)
+		assert.True(t, IsHubAPIError(err))
+		assert.EqualError(t, err, "模型不存在")
+	})
+
+	t.Run("Error - invalid repo id", func(t *testing.T) {
+		c := NewHubAPIClient()
+
+		_, err := c.ListRepoFiles(context.Background(), RepoTypeModel, "model", "")
+		require.Error(t, err)
+	})
+}
+
+func TestDownloadFile(t *testing.T) {
+	content := []byte("0123456789")
+	sum := sha256.Sum256(content)
+	file := HubAPIRepoFile{
+		Path:   "weights/model.bin",
+		Type:   "blob",
+		Size:   int64(len(content)),
+		Sha256: hex.EncodeToString(sum[:]),
+	}
+
+	newServer := func(t *testing.T, body []byte, ranges *[]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			assert.Equal(t, "/api/v1/models/ns/model/repo", req.URL.Path)
+			assert.Equal(t, "weights/model.bin", req.URL.Query().Get("FilePath"))
+			*ranges = append(*ranges, req.Header.Get("Range"))
+
+			http.ServeContent(rw, req, "model.bin", time.Time{}, bytes.NewReader(body))
+		}))
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		var ranges []string
+		server := newServer(t, content, &ranges)
+		defer server.Close()
+
+		c := NewHubAPIClient(WithEndpoint(server.URL + "/"))
+		c.client = server.Client()
+
+		dir := t.TempDir()
+		err := c.DownloadFile(context.Background(), RepoTypeModel, "ns/model", "", file, dir)
+		require.NoError(t, err)
+		assert.Equal(t, content, lo.Must(os.ReadFile(filepath.Join(dir, "weights", "model.bin"))))
+		assert.Equal(t, []string{""}, ranges)
+
+		// already downloaded
+		err = c.DownloadFile(context.Background(), RepoTypeModel, "ns/model", "", file, dir)
+		require.NoError(t, err)
+		assert.Len(t, ranges, 1)
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		var ranges []string
+		server := newServer(t, content, &ranges)
+		defer server.Close()
+
+		c := NewHubAPIClient(WithEndpoint(server.URL))
+		c.client = server.Client()
+
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "weights"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "weights", "model.bin.incomplete"), content[:4], 0644))
+
+		err := c.DownloadFile(context.Background(), RepoTypeModel, "ns/model", "", file, dir)
+		require.NoError(t, err)
+		assert.Equal(t, content, lo.Must(os.ReadFile(filepath.Join(dir, "weights", "model.bin"))))
+		assert.Equal(t, []string{"bytes=4-"}, ranges)
+		assert.NoFileExists(t, filepath.Join(dir, "weights", "model.bin.incomplete"))
+	})
+
+	t.Run("Error - sha256 mismatch", func(t *testing.T) {
+		var ranges []string
+		server := newServer(t, []byte("9876543210"), &ranges)
+		defer server.Close()
+
+		c := NewHubAPIClient(WithEndpoint(server.URL))
+		c.client = server.Client()
+
+		dir := t.TempDir()
+		err := c.DownloadFile(context.Background(), RepoTypeModel, "ns/model", "", file, dir)
+		require.Error(t, err)
+		assert.NoFileExists(t, filepath.Join(dir, "weights", "model.bin"))
+		assert.NoFileExists(t, filepath.Join(dir, "weights", "model.bin.incomplete"))
+	})
+
+	t.Run("Error - path outside of the target directory", func(t *testing.T) {
+		c := NewHubAPIClient()
+
+		err := c.DownloadFile(context.Background(), RepoTypeModel, "ns/model", "", HubAPIRepoFile{Path: "../escape"}, t.TempDir())
+		require.Error(t, err)
+	})
+}
@@ -1,8 +1,8 @@
 package pip
 
 import (
+	"context"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -50,12 +50,12 @@ func (p *PipCLI) bin() string {
 }
 
 // Equivalent to `pip --version`
-func (p *PipCLI) Version(logger *logrus.Entry) (string, error) {
+func (p *PipCLI) Version(ctx context.Context, logger *logrus.Entry) (string, error) {
 	args := []string{
 		"--version",
 	}
 
-	cmd := exec.Command(p.bin(), args...) // #nosec G204
+	cmd := utils.CommandContext(ctx, p.bin(), args...) // #nosec G204
 	cmd.Env = os.Environ()
 
 	output, err := utils.ExecuteCommandWithOutput(logger, cmd, []string{})
@@ -70,14 +70,14 @@ func (p *PipCLI) Version(logger *logrus.Entry) (string, error) {
 }
 
 // Equivalent to `pip install -r requirements.txt`
-func (p *PipCLI) InstallWithRequirementsTxt(logger *logrus.Entry, requirementsTxt string) error {
+func (p *PipCLI) InstallWithRequirementsTxt(ctx context.Context, logger *logrus.Entry, requirementsTxt string) error {
 	args := []string{
 		"install",
 		"-r",
 		requirementsTxt,
 	}
 
-	cmd := exec.Command(p.bin(), args...) // #nosec G204
+	cmd := utils.CommandContext(ctx, p.bin(), args...) // #nosec G204
 	cmd.Env = lo.Filter(os.Environ(), func(item string, index int) bool {
 		return !strings.HasPrefix(item, "PATH=")
 	})
@@ -0,0 +1,132 @@
+package datasources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// PlanFile is a file syncing would transfer, delete or skip, the path is
+// slash separated and relative to the synced directory.
+type PlanFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Plan tells what syncing would do against the current contents of the synced
+// directory, computing it never writes anything.
+type Plan struct {
+	Revision string     `json:"revision,omitempty"`
+	Transfer []PlanFile `json:"transfer"`
+	Delete   []PlanFile `json:"delete"`
+	Skip     []PlanFile `json:"skip"`
+	// DeleteError is the error mirror mode would fail with before deleting
+	// anything, i.e. the files to delete exceed MaxDeletePercent.
+	DeleteError string `json:"deleteError,omitempty"`
+}
+
+// Planner is implemented by loaders that are able to tell what Sync would do
+// without doing it.
+type Planner interface {
+	Plan(ctx context.Context, fromURI string, toPath string) (*Plan, error)
+}
+
+// PlanSummary is written by data-loader plan as the termination message of its
+// container, and read back by the controller into status.plan.
+type PlanSummary struct {
+	Revision      string `json:"revision,omitempty"`
+	TransferFiles int    `json:"transferFiles"`
+	TransferBytes int64  `json:"transferBytes"`
+	DeleteFiles   int    `json:"deleteFiles"`
+	DeleteBytes   int64  `json:"deleteBytes"`
+	SkipFiles     int    `json:"skipFiles"`
+	SkipBytes     int64  `json:"skipBytes"`
+	DeleteError   string `json:"deleteError,omitempty"`
+}
+
+func newPlan(revision string) *Plan {
+	return &Plan{
+		Revision: revision,
+		Transfer: make([]PlanFile, 0),
+		Delete:   make([]PlanFile, 0),
+		Skip:     make([]PlanFile, 0),
+	}
+}
+
+func sumPlanFiles(files []PlanFile) int64 {
+	var size int64
+	for _, file := range files {
+		size += file.Size
+	}
+	return size
+}
+
+func (p *Plan) Summary() PlanSummary {
+	return PlanSummary{
+		Revision:      p.Revision,
+		TransferFiles: len(p.Transfer),
+		TransferBytes: sumPlanFiles(p.Transfer),
+		DeleteFiles:   len(p.Delete),
+		DeleteBytes:   sumPlanFiles(p.Delete),
+		SkipFiles:     len(p.Skip),
+		SkipBytes:     sumPlanFiles(p.Skip),
+		DeleteError:   p.DeleteError,
+	}
+}
+
+// add adds the upstream file to the files to transfer, or to skip if the
+// local copy is up to date.
+func (p *Plan) add(filePath string, size int64, upToDate bool) {
+	if upToDate {
+		p.Skip = append(p.Skip, PlanFile{Path: filePath, Size: size})
+	} else {
+		p.Transfer = append(p.Transfer, PlanFile{Path: filePath, Size: size})
+	}
+}
+
+// planDelete adds the local files under dir mirror mode would delete to the
+// plan, nothing is deleted in copy mode.
+func (p *Plan) planDelete(dir string, local []string, upstream []string, options Options) {
+	if options.SyncMode != SyncModeMirror {
+		return
+	}
+
+	stale := staleFiles(local, upstream)
+	for _, filePath := range stale {
+		var size int64
+		if stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(filePath))); err == nil {
+			size = stat.Size()
+		}
+		p.Delete = append(p.Delete, PlanFile{Path: filePath, Size: size})
+	}
+
+	err := checkDeletePercent(dir, len(stale), len(local), options)
+	if err != nil && !options.ForceDelete {
+		p.DeleteError = err.Error()
+	}
+}
+
+func (p *Plan) sort() {
+	for _, files := range [][]PlanFile{p.Transfer, p.Delete, p.Skip} {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Path < files[j].Path
+		})
+	}
+}
+
+// localFileUpToDate tells whether the local file has the size, and the
+// modification time unless it is zero, of the upstream file, which is how the
+// loaders decide to skip transferring a file.
+func localFileUpToDate(localPath string, size int64, modTime time.Time, modifyWindow time.Duration) bool {
+	stat, err := os.Stat(localPath)
+	if err != nil || !stat.Mode().IsRegular() || stat.Size() != size {
+		return false
+	}
+	if modTime.IsZero() {
+		return true
+	}
+
+	return stat.ModTime().Sub(modTime).Abs() <= modifyWindow
+}
@@ -1,19 +1,467 @@
 package datasources
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/sirupsen/logrus"
 
 	"github.com/BaizeAI/dataset/pkg/log"
 	"github.com/BaizeAI/dataset/pkg/utils"
 )
 
-func rcloneCliConfigTouch() error {
-	cmd := exec.Command("rclone", "config", "touch")
+// rcloneCliConfigDelete removes the temporary config created for a single
+// sync, it is deferred right after the config is created and thus runs even if
+// the sync has been interrupted.
+func rcloneCliConfigDelete(ctx context.Context, configName string) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+
+	cmd := utils.CommandContext(ctx, "rclone", "config", "delete", configName)
 	logger := log.WithField("command", cmd.String())
 
-	logger.Debug("executing command to touch rclone config")
+	logger.Debug("executing command to delete rclone config")
 
 	err := utils.ExecuteCommand(logger, cmd, nil)
+	if err != nil {
+		logger.Warnf("failed to delete rclone config %s, err: %s", configName, err)
+	}
+}
+
+// rcloneLimitArgs maps the common limits to the flags of rclone copy and sync.
+func rcloneLimitArgs(options Options) []string {
+	var args []string
+	if options.BandwidthLimit != "" {
+		args = append(args, "--bwlimit", options.BandwidthLimit)
+	}
+	if options.MaxConcurrency > 0 {
+		args = append(args, "--transfers", strconv.Itoa(options.MaxConcurrency))
+	}
+	if options.MaxRetries > 0 {
+		args = append(args, "--retries", strconv.Itoa(options.MaxRetries))
+	}
+
+	return args
+}
+
+// rcloneReadChangedFiles reads the files rclone copy transferred from the
+// report written with --combined, in which new files are marked with + and
+// files that differ with *. Identical files, marked with =, are included as
+// well if their mode or owner is still to be changed, e.g. as the previous
+// attempt was interrupted before the post copy stage.
+func rcloneReadChangedFiles(combinedPath string, dir string, options Options) ([]string, error) {
+	content, err := os.ReadFile(combinedPath) // #nosec G304
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	for _, line := range strings.Split(string(content), "\n") {
+		if len(line) < 3 || line[1] != ' ' {
+			continue
+		}
+		filePath := line[2:]
+		switch line[0] {
+		case '+', '*':
+			files = append(files, filePath)
+		case '=':
+			if needsPostCopy(filepath.Join(dir, filepath.FromSlash(filePath)), options) {
+				files = append(files, filePath)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// rcloneListFiles lists the files matching the filters under the remote path
+// with rclone lsf, newCommand creates the rclone command so that it runs with
+// the same working directory and environment as the copy.
+func rcloneListFiles(logger *logrus.Entry, newCommand func(args ...string) *exec.Cmd, remotePath string, filterArgs []string, secrets []string) ([]string, error) {
+	args := []string{
+		"lsf",
+		"-R",
+		"--files-only",
+		remotePath,
+	}
+	args = append(args, filterArgs...)
+	cmd := newCommand(args...)
+
+	logger = logger.WithField("command", cmd.String())
+	logger.Debug("executing command to list files")
+
+	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, secrets)
+	if err != nil {
+		logger.Errorf("rclone lsf command error: %s", errBuffer)
+		return nil, fmt.Errorf("failed to list files of %s with rclone command %s, err: %s", remotePath, cmd.String(), err)
+	}
+
+	return lo.Compact(lo.Map(strings.Split(outBuffer.String(), "\n"), func(line string, _ int) string {
+		return strings.TrimSpace(line)
+	})), nil
+}
+
+// rcloneMirrorDelete deletes the files copied to toPath earlier which are no
+// longer at the remote path, only files matching the filters are considered on
+// both sides, as rclone sync does.
+func rcloneMirrorDelete(ctx context.Context, logger *logrus.Entry, newCommand func(args ...string) *exec.Cmd, remotePath string, toPath string, filterArgs []string, options Options, secrets []string) error {
+	upstream, err := rcloneListFiles(logger, newCommand, remotePath, filterArgs, secrets)
+	if err != nil {
+		return err
+	}
+	local, err := rcloneListFiles(logger, newCommand, toPath, filterArgs, secrets)
+	if err != nil {
+		return err
+	}
+
+	return mirrorDelete(ctx, logger, resolveDir(options.Root, toPath), local, upstream, options)
+}
+
+// rcloneModifyWindow is the precision of the modification times rclone
+// compares when deciding whether a file needs to be copied.
+const rcloneModifyWindow = time.Second
+
+type rcloneFile struct {
+	Path    string    `json:"Path"`
+	Size    int64     `json:"Size"`
+	ModTime time.Time `json:"ModTime"`
+}
+
+// rcloneListFileInfos lists the files matching the filters under the remote
+// path together with their sizes and modification times with rclone lsjson.
+func rcloneListFileInfos(logger *logrus.Entry, newCommand func(args ...string) *exec.Cmd, remotePath string, filterArgs []string, secrets []string) ([]rcloneFile, error) {
+	args := []string{
+		"lsjson",
+		"-R",
+		"--files-only",
+		remotePath,
+	}
+	args = append(args, filterArgs...)
+	cmd := newCommand(args...)
+
+	logger = logger.WithField("command", cmd.String())
+	logger.Debug("executing command to list files")
+
+	outBuffer, errBuffer, err := utils.ExecuteCommandWithAllOutput(logger, cmd, secrets)
+	if err != nil {
+		logger.Errorf("rclone lsjson command error: %s", errBuffer)
+		return nil, fmt.Errorf("failed to list files of %s with rclone command %s, err: %s", remotePath, cmd.String(), err)
+	}
+
+	files := make([]rcloneFile, 0)
+	err = json.Unmarshal(outBuffer.Bytes(), &files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the files of %s listed by rclone: %w", remotePath, err)
+	}
+
+	return files, nil
+}
+
+// rclonePlan compares the files at the remote path with those copied to
+// toPath the way rclone copy does, by size and modification time.
+func rclonePlan(logger *logrus.Entry, newCommand func(args ...string) *exec.Cmd, remotePath string, toPath string, filterOptions RcloneFilterOptions, options Options, secrets []string) (*Plan, error) {
+	upstream, err := rcloneListFileInfos(logger, newCommand, remotePath, rcloneFilterArgs(filterOptions), secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := resolveDir(options.Root, toPath)
+	plan := newPlan("")
+	for _, file := range upstream {
+		plan.add(file.Path, file.Size, localFileUpToDate(filepath.Join(dir, filepath.FromSlash(file.Path)), file.Size, file.ModTime, rcloneModifyWindow))
+	}
+
+	filter, err := filterOptions.compile()
+	if err != nil {
+		return nil, err
+	}
+	local, err := listLocalFiles(dir, func(filePath string) bool {
+		stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(filePath)))
+		if err != nil {
+			return false
+		}
+		return filter.matchFile(filePath, stat.Size(), stat.ModTime())
+	})
+	if err != nil {
+		return nil, err
+	}
+	plan.planDelete(dir, local, lo.Map(upstream, func(file rcloneFile, _ int) string {
+		return file.Path
+	}), options)
+	plan.sort()
+
+	return plan, nil
+}
+
+// RcloneFilterOptions selects the files copied by the loaders backed by rclone.
+type RcloneFilterOptions struct {
+	// Include and Exclude are comma separated glob patterns, e.g. "*.json, data/**"
+	Include string `json:"include"`
+	Exclude string `json:"exclude"`
+	// IncludeRegex and ExcludeRegex are regular expressions matched against
+	// the whole path of the files, use | for alternatives
+	IncludeRegex string `json:"includeRegex"`
+	ExcludeRegex string `json:"excludeRegex"`
+	// MinSize and MaxSize are sizes such as 100K or 1.5G
+	MinSize string `json:"minSize"`
+	MaxSize string `json:"maxSize"`
+	// ModifiedAfter is a RFC 3339 time or a date such as 2024-01-02
+	ModifiedAfter string `json:"modifiedAfter"`
+}
+
+var modifiedAfterLayouts = []string{time.RFC3339, time.DateTime, time.DateOnly}
+
+func parseModifiedAfter(modifiedAfter string) (time.Time, error) {
+	for _, layout := range modifiedAfterLayouts {
+		t, err := time.Parse(layout, modifiedAfter)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid modifiedAfter %s, must be a RFC 3339 time or a date such as 2024-01-02", modifiedAfter)
+}
+
+func (o RcloneFilterOptions) validate() error {
+	_, err := o.compile()
+	if err != nil {
+		return err
+	}
+
+	var minSize, maxSize int64
+	if o.MinSize != "" {
+		minSize, err = utils.ParseSize(o.MinSize)
+		if err != nil {
+			return fmt.Errorf("invalid minSize: %w", err)
+		}
+	}
+	if o.MaxSize != "" {
+		maxSize, err = utils.ParseSize(o.MaxSize)
+		if err != nil {
+			return fmt.Errorf("invalid maxSize: %w", err)
+		}
+	}
+	if minSize > 0 && maxSize > 0 && minSize > maxSize {
+		return fmt.Errorf("minSize %s is larger than maxSize %s", o.MinSize, o.MaxSize)
+	}
+
+	if o.ModifiedAfter != "" {
+		if _, err := parseModifiedAfter(o.ModifiedAfter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rcloneFilterArgs maps the filters to rclone flags. Excludes are checked
+// before includes, and once any include is given the files matching none of
+// them are excluded, which is how the include and exclude options of the
+// other loaders behave.
+func rcloneFilterArgs(options RcloneFilterOptions) []string {
+	var args []string
+	for _, pattern := range splitPatterns(options.Exclude) {
+		args = append(args, "--filter", "- "+pattern)
+	}
+	if options.ExcludeRegex != "" {
+		args = append(args, "--filter", "- {{"+options.ExcludeRegex+"}}")
+	}
+
+	includes := splitPatterns(options.Include)
+	for _, pattern := range includes {
+		args = append(args, "--filter", "+ "+pattern)
+	}
+	if options.IncludeRegex != "" {
+		args = append(args, "--filter", "+ {{"+options.IncludeRegex+"}}")
+	}
+	if len(includes) > 0 || options.IncludeRegex != "" {
+		args = append(args, "--filter", "- **")
+	}
+
+	if options.MinSize != "" {
+		args = append(args, "--min-size", options.MinSize)
+	}
+	if options.MaxSize != "" {
+		args = append(args, "--max-size", options.MaxSize)
+	}
+	if options.ModifiedAfter != "" {
+		// validated by RcloneFilterOptions.validate
+		modifiedAfter, _ := parseModifiedAfter(options.ModifiedAfter)
+		args = append(args, "--max-age", modifiedAfter.UTC().Format(time.RFC3339))
+	}
+
+	return args
+}
+
+// rcloneFilter applies RcloneFilterOptions the way rclone does for the loaders
+// that transfer the files themselves.
+type rcloneFilter struct {
+	excludes      []*regexp.Regexp
+	includes      []*regexp.Regexp
+	minSize       int64
+	maxSize       int64
+	modifiedAfter time.Time
+}
+
+func (o RcloneFilterOptions) compile() (*rcloneFilter, error) {
+	f := new(rcloneFilter)
+	for _, pattern := range splitPatterns(o.Exclude) {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.excludes = append(f.excludes, re)
+	}
+	for _, pattern := range splitPatterns(o.Include) {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		f.includes = append(f.includes, re)
+	}
+	for _, expr := range []struct {
+		value string
+		into  *[]*regexp.Regexp
+	}{
+		{o.ExcludeRegex, &f.excludes},
+		{o.IncludeRegex, &f.includes},
+	} {
+		if expr.value == "" {
+			continue
+		}
+		re, err := regexp.Compile("(^|/)(?:" + expr.value + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %s: %w", expr.value, err)
+		}
+		*expr.into = append(*expr.into, re)
+	}
+
+	// the sizes and the time are validated by RcloneFilterOptions.validate
+	if o.MinSize != "" {
+		f.minSize, _ = utils.ParseSize(o.MinSize)
+	}
+	if o.MaxSize != "" {
+		f.maxSize, _ = utils.ParseSize(o.MaxSize)
+	}
+	if o.ModifiedAfter != "" {
+		f.modifiedAfter, _ = parseModifiedAfter(o.ModifiedAfter)
+	}
+
+	return f, nil
+}
+
+// matchPath tells whether the slash separated path relative to the root of
+// the source passes the include and exclude patterns.
+func (f *rcloneFilter) matchPath(filePath string) bool {
+	for _, re := range f.excludes {
+		if re.MatchString(filePath) {
+			return false
+		}
+	}
+	if len(f.includes) == 0 {
+		return true
+	}
+	for _, re := range f.includes {
+		if re.MatchString(filePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchFile additionally checks the size and the modification time of the
+// file, a negative size or a zero time is unknown and always passes.
+func (f *rcloneFilter) matchFile(filePath string, size int64, modTime time.Time) bool {
+	if !f.matchPath(filePath) {
+		return false
+	}
+	if size >= 0 && f.minSize > 0 && size < f.minSize {
+		return false
+	}
+	if size >= 0 && f.maxSize > 0 && size > f.maxSize {
+		return false
+	}
+	if !modTime.IsZero() && !f.modifiedAfter.IsZero() && modTime.Before(f.modifiedAfter) {
+		return false
+	}
+
+	return true
+}
+
+// globToRegexp converts a glob of rclone to a regular expression. * and ?
+// never match a slash while ** does, {a,b} matches either alternative. A glob
+// starting with a slash is anchored to the root, otherwise it matches the
+// end of the path at a slash, and a glob ending with a slash matches all the
+// files under the directory.
+func globToRegexp(glob string) (*regexp.Regexp, error) {
+	pattern := glob
+	var re strings.Builder
+	if strings.HasPrefix(pattern, "/") {
+		re.WriteString("^")
+		pattern = pattern[1:]
+	} else {
+		re.WriteString("(^|/)")
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	inBraces := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid glob pattern %s: unclosed [", glob)
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '{' && !inBraces:
+			re.WriteString("(?:")
+			inBraces = true
+		case c == '}' && inBraces:
+			re.WriteString(")")
+			inBraces = false
+		case c == ',' && inBraces:
+			re.WriteString("|")
+		case c == '\\' && i+1 < len(pattern):
+			re.WriteString(regexp.QuoteMeta(pattern[i+1 : i+2]))
+			i++
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if inBraces {
+		return nil, fmt.Errorf("invalid glob pattern %s: unclosed {", glob)
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid glob pattern %s: %w", glob, err)
+	}
 
-	return err
+	return compiled, nil
 }
@@ -0,0 +1,110 @@
+package datasources
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRcloneFilterArgs(t *testing.T) {
+	assert.Empty(t, rcloneFilterArgs(RcloneFilterOptions{}))
+
+	assert.Equal(t, []string{
+		"--filter", "- *.log",
+		"--filter", "- {{.*/tmp-[0-9]+/.*}}",
+		"--filter", "+ checkpoints/**",
+		"--filter", "+ *.json",
+		"--filter", "+ {{.*\\.safetensors}}",
+		"--filter", "- **",
+		"--min-size", "1K",
+		"--max-size", "10G",
+		"--max-age", "2024-01-02T07:04:05Z",
+	}, rcloneFilterArgs(RcloneFilterOptions{
+		Include:       "checkpoints/**, *.json",
+		Exclude:       "*.log",
+		IncludeRegex:  `.*\.safetensors`,
+		ExcludeRegex:  ".*/tmp-[0-9]+/.*",
+		MinSize:       "1K",
+		MaxSize:       "10G",
+		ModifiedAfter: "2024-01-02T15:04:05+08:00",
+	}))
+
+	assert.Equal(t, []string{
+		"--filter", "- {{.*\\.bin}}",
+	}, rcloneFilterArgs(RcloneFilterOptions{ExcludeRegex: `.*\.bin`}))
+}
+
+func TestRcloneFilterOptionsValidate(t *testing.T) {
+	assert.NoError(t, RcloneFilterOptions{
+		Include:       "*.json",
+		IncludeRegex:  "^data/.*$",
+		MinSize:       "1M",
+		MaxSize:       "1G",
+		ModifiedAfter: "2024-01-02 15:04:05",
+	}.validate())
+
+	for name, options := range map[string]RcloneFilterOptions{
+		"glob":          {Include: "data/[a-"},
+		"regex":         {ExcludeRegex: "(unclosed"},
+		"size":          {MinSize: "large"},
+		"size range":    {MinSize: "2G", MaxSize: "1G"},
+		"modifiedAfter": {ModifiedAfter: "yesterday"},
+	} {
+		assert.Error(t, options.validate(), name)
+	}
+}
+
+func TestRcloneFilterMatch(t *testing.T) {
+	filter, err := RcloneFilterOptions{
+		Include:       "*.{json,txt}, /checkpoints/**",
+		Exclude:       "logs/, tmp-?.txt",
+		ExcludeRegex:  `.*\.bak\.json`,
+		MinSize:       "1K",
+		MaxSize:       "1M",
+		ModifiedAfter: "2024-01-02",
+	}.compile()
+	require.NoError(t, err)
+
+	for filePath, expected := range map[string]bool{
+		"config.json":              true,
+		"data/notes.txt":           true,
+		"checkpoints/step-1/model": true,
+		"data/checkpoints/model":   false,
+		"model.bin":                false,
+		"logs/train.txt":           false,
+		"data/logs/train.txt":      false,
+		"tmp-1.txt":                false,
+		"tmp-10.txt":               true,
+		"config.bak.json":          false,
+	} {
+		assert.Equal(t, expected, filter.matchPath(filePath), filePath)
+	}
+
+	modTime := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, filter.matchFile("config.json", 2<<10, modTime))
+	assert.True(t, filter.matchFile("config.json", -1, time.Time{}))
+	assert.False(t, filter.matchFile("config.json", 100, modTime))
+	assert.False(t, filter.matchFile("config.json", 2<<20, modTime))
+	assert.False(t, filter.matchFile("config.json", 2<<10, modTime.AddDate(0, -2, 0)))
+}
+
+func TestRcloneReadChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "same.bin", "pending.bin")
+	require.NoError(t, os.Chmod(filepath.Join(dir, "same.bin"), 0640))
+	require.NoError(t, os.Chmod(filepath.Join(dir, "pending.bin"), 0600))
+
+	combined := filepath.Join(t.TempDir(), "combined")
+	require.NoError(t, os.WriteFile(combined, []byte("+ new.bin\n* sub/differ.bin\n= same.bin\n= pending.bin\n- missing.bin\n! error.bin\n"), 0600))
+
+	files, err := rcloneReadChangedFiles(combined, dir, Options{Mode: 0640, UID: os.Getuid(), GID: os.Getgid()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new.bin", "sub/differ.bin", "pending.bin"}, files)
+
+	_, err = rcloneReadChangedFiles(filepath.Join(t.TempDir(), "not-found"), dir, Options{})
+	assert.Error(t, err)
+}
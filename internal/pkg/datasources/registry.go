@@ -0,0 +1,225 @@
+package datasources
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+// Factory creates a loader from the raw --options of data-loader.
+type Factory func(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error)
+
+// Registration describes a type of data source that data-loader is able to
+// sync, it is the single place both data-loader and the controller learn
+// about a type from.
+type Registration struct {
+	Type    Type
+	Factory Factory
+
+	// Schemes are the URI schemes accepted by the loader.
+	Schemes []string
+	// Options are the keys accepted in spec.source.options, see OptionKeysOf.
+	Options []string
+	// AnyOptions accepts options with any key, e.g. HTTP headers.
+	AnyOptions bool
+	// RequiredSecretKeys must all be present in the secret referenced by
+	// spec.secretRef.
+	RequiredSecretKeys []SecretKey
+}
+
+var (
+	registryMu    sync.RWMutex
+	registry      = make(map[Type]Registration)
+	registryOrder []Type
+
+	// commonOptionKeys are accepted by all types, they are either consumed
+	// by data-loader itself or by the controller when building the job.
+	commonOptionKeys = []string{
+		"gpuType",
+		"extract",
+		"stripComponents",
+		"keepArchive",
+		"syncTimeout",
+		"extractTimeout",
+		"postCopyTimeout",
+		"syncMode",
+		"maxDeletePercent",
+		"forceDelete",
+	}
+)
+
+// Register registers a type of data source, it is meant to be called from
+// the init function of the file implementing the loader.
+func Register(registration Registration) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[registration.Type]; ok {
+		panic(fmt.Sprintf("data source type %s is already registered", registration.Type))
+	}
+
+	registry[registration.Type] = registration
+	registryOrder = append(registryOrder, registration.Type)
+}
+
+// Lookup returns the registration of the type.
+func Lookup(typ Type) (Registration, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	registration, ok := registry[typ]
+
+	return registration, ok
+}
+
+// SupportedTypes returns the registered types in the order they were
+// registered, external loaders are not included.
+func SupportedTypes() []Type {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return append([]Type(nil), registryOrder...)
+}
+
+func SupportedTypesString() []string {
+	return lo.Map(SupportedTypes(), func(typ Type, _ int) string {
+		return string(typ)
+	})
+}
+
+// OptionKeysOf returns the json keys of the exported fields of an options
+// struct such as GitLoaderOptions, including the fields of embedded structs.
+func OptionKeysOf(options any) []string {
+	t := reflect.TypeOf(options)
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			keys = append(keys, OptionKeysOf(reflect.Zero(field.Type).Interface())...)
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		keys = append(keys, name)
+	}
+
+	return keys
+}
+
+// NewLoader creates the loader of the type, types that are not registered
+// are looked up as external data-loader-<type> executables.
+func NewLoader(datasourceOptions map[string]string, options Options, secrets Secrets) (Loader, error) {
+	registration, ok := Lookup(options.Type)
+	if ok {
+		return registration.Factory(datasourceOptions, options, secrets)
+	}
+
+	executable, ok := LookupExternalLoader(options.Type)
+	if ok {
+		return NewExternalLoader(executable, datasourceOptions, options, secrets)
+	}
+
+	return nil, fmt.Errorf("data source type %s is not supported", options.Type)
+}
+
+// IsSupported reports whether the type is registered or an external loader
+// of it can be found.
+func IsSupported(typ Type) bool {
+	if _, ok := Lookup(typ); ok {
+		return true
+	}
+
+	_, ok := LookupExternalLoader(typ)
+
+	return ok
+}
+
+// ExternalLoaderExecutable returns the name of the executable implementing
+// an external loader, e.g. data-loader-oss for type OSS.
+func ExternalLoaderExecutable(typ Type) string {
+	return "data-loader-" + strings.ReplaceAll(strings.ToLower(string(typ)), "_", "-")
+}
+
+// LookupExternalLoader looks up the executable of an external loader in
+// PATH.
+func LookupExternalLoader(typ Type) (string, bool) {
+	if typ == "" {
+		return "", false
+	}
+
+	executable, err := exec.LookPath(ExternalLoaderExecutable(typ))
+	if err != nil {
+		return "", false
+	}
+
+	return executable, true
+}
+
+// ValidateSource validates the uri and options against the registration of
+// the type. Types that are not registered, i.e. external loaders, can not be
+// validated and are accepted as is.
+func ValidateSource(typ Type, uri string, options map[string]string) error {
+	registration, ok := Lookup(typ)
+	if !ok {
+		return nil
+	}
+
+	if len(registration.Schemes) > 0 {
+		parsedURL, err := url.Parse(uri)
+		if err != nil {
+			return fmt.Errorf("invalid uri %s: %w", uri, err)
+		}
+		if !lo.Contains(registration.Schemes, parsedURL.Scheme) {
+			return fmt.Errorf("invalid scheme %s of uri %s, supported schemes of %s are %s", parsedURL.Scheme, uri, typ, strings.Join(registration.Schemes, ", "))
+		}
+	}
+
+	if registration.AnyOptions {
+		return nil
+	}
+
+	unknown := lo.Filter(lo.Keys(options), func(key string, _ int) bool {
+		return !lo.Contains(registration.Options, key) && !lo.Contains(commonOptionKeys, key)
+	})
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		supported := append(append([]string(nil), registration.Options...), commonOptionKeys...)
+
+		return fmt.Errorf("unsupported options %s of %s, supported options are %s", strings.Join(unknown, ", "), typ, strings.Join(supported, ", "))
+	}
+
+	return nil
+}
+
+// ValidateSecretKeys checks that the secret referenced by the dataset holds
+// all the keys required by the type.
+func ValidateSecretKeys(typ Type, secretKeys []string) error {
+	registration, ok := Lookup(typ)
+	if !ok {
+		return nil
+	}
+
+	missing := lo.Filter(registration.RequiredSecretKeys, func(key SecretKey, _ int) bool {
+		return !lo.Contains(secretKeys, string(key))
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required secret keys %s of %s", strings.Join(lo.Map(missing, func(key SecretKey, _ int) string {
+			return string(key)
+		}), ", "), typ)
+	}
+
+	return nil
+}
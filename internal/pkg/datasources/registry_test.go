@@ -0,0 +1,143 @@
+package datasources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("builtin types", func(t *testing.T) {
+		for _, typ := range []Type{TypeS3, TypeGit, TypeHTTP, TypeConda, TypeHuggingFace, TypeModelScope} {
+			_, ok := Lookup(typ)
+			assert.True(t, ok, typ)
+			assert.Contains(t, SupportedTypes(), typ)
+			assert.True(t, IsSupported(typ))
+		}
+
+		assert.False(t, IsSupported("PVC"))
+		assert.Panics(t, func() {
+			Register(Registration{Type: TypeGit})
+		})
+	})
+
+	t.Run("new loader", func(t *testing.T) {
+		loader, err := NewLoader(map[string]string{"branch": "main"}, Options{Type: TypeGit, URI: "https://github.com/BaizeAI/dataset.git"}, Secrets{})
+		require.NoError(t, err)
+		assert.IsType(t, &GitLoader{}, loader)
+
+		_, err = NewLoader(nil, Options{Type: "UNKNOWN"}, Secrets{})
+		assert.EqualError(t, err, "data source type UNKNOWN is not supported")
+	})
+
+	t.Run("option keys", func(t *testing.T) {
+		assert.Equal(t, []string{
+			"provider", "region", "endpoint",
+			"include", "exclude", "includeRegex", "excludeRegex", "minSize", "maxSize", "modifiedAfter",
+		}, OptionKeysOf(S3LoaderOptions{}))
+	})
+
+	t.Run("validate source", func(t *testing.T) {
+		assert.NoError(t, ValidateSource(TypeGit, "https://github.com/BaizeAI/dataset.git", map[string]string{
+			"branch":  "main",
+			"extract": "auto",
+		}))
+		assert.NoError(t, ValidateSource(TypeHTTP, "https://example.com/data", map[string]string{
+			"X-Custom-Header": "value",
+		}))
+		assert.NoError(t, ValidateSource("UNKNOWN", "unknown://anything", map[string]string{
+			"anything": "value",
+		}))
+
+		assert.EqualError(t, ValidateSource(TypeS3, "https://bucket/path", nil),
+			"invalid scheme https of uri https://bucket/path, supported schemes of S3 are s3")
+		assert.ErrorContains(t, ValidateSource(TypeHuggingFace, "huggingface://org/model", map[string]string{
+			"revison": "main",
+			"brnach":  "main",
+		}), "unsupported options brnach, revison of HUGGING_FACE")
+	})
+
+	t.Run("validate secret keys", func(t *testing.T) {
+		typ := Type("TEST_REQUIRED_SECRETS")
+		Register(Registration{
+			Type:               typ,
+			RequiredSecretKeys: []SecretKey{SecretKeyAccessKey, SecretKeySecretKey},
+		})
+
+		assert.NoError(t, ValidateSecretKeys(typ, []string{"access-key", "secret-key", "token"}))
+		assert.EqualError(t, ValidateSecretKeys(typ, []string{"access-key"}), "missing required secret keys secret-key of TEST_REQUIRED_SECRETS")
+		assert.NoError(t, ValidateSecretKeys(TypeGit, nil))
+	})
+}
+
+func TestExternalLoader(t *testing.T) {
+	newExternalLoader := func(t *testing.T, script string) string {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ExternalLoaderExecutable("OSS")), []byte(fmt.Sprintf(`#!/usr/bin/env bash
+cat > "%s/request.json"
+%s
+`, dir, script)), 0755)) // nolint: gosec
+
+		t.Setenv("PATH", fmt.Sprintf("%s:%s", dir, os.Getenv("PATH")))
+
+		return dir
+	}
+
+	t.Run("default", func(t *testing.T) {
+		dir := newExternalLoader(t, `echo '{"revision": "v1"}'`)
+
+		assert.True(t, IsSupported("OSS"))
+
+		root := t.TempDir()
+		loader, err := NewLoader(map[string]string{"region": "cn-hangzhou"}, Options{
+			Type: "OSS",
+			URI:  "oss://bucket/path",
+			Root: root,
+		}, Secrets{Token: "test-token"})
+		require.NoError(t, err)
+		require.IsType(t, &ExternalLoader{}, loader)
+
+		err = loader.Sync(context.Background(), "oss://bucket/path", "data")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", loader.(Revisioner).Revision())
+
+		content, err := os.ReadFile(filepath.Join(dir, "request.json"))
+		require.NoError(t, err)
+
+		var request ExternalLoaderRequest
+		require.NoError(t, json.Unmarshal(content, &request))
+		assert.Equal(t, ExternalLoaderRequest{
+			Type:    "OSS",
+			URI:     "oss://bucket/path",
+			Path:    filepath.Join(root, "data"),
+			Options: map[string]string{"region": "cn-hangzhou"},
+			Secrets: map[SecretKey]string{SecretKeyToken: "test-token"},
+		}, request)
+	})
+
+	t.Run("error response", func(t *testing.T) {
+		newExternalLoader(t, `echo '{"error": "access denied"}'`)
+
+		loader, err := NewLoader(nil, Options{Type: "OSS"}, Secrets{})
+		require.NoError(t, err)
+
+		err = loader.Sync(context.Background(), "oss://bucket/path", t.TempDir())
+		assert.ErrorContains(t, err, "access denied")
+	})
+
+	t.Run("non-zero exit", func(t *testing.T) {
+		newExternalLoader(t, `exit 3`)
+
+		loader, err := NewLoader(nil, Options{Type: "OSS"}, Secrets{})
+		require.NoError(t, err)
+
+		err = loader.Sync(context.Background(), "oss://bucket/path", t.TempDir())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,76 @@
+package datasources
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// DirStats tells how big a synced directory is, symlinks are not followed so
+// that files linked to from within the directory are counted once.
+type DirStats struct {
+	Size      int64 `json:"size"`
+	FileCount int64 `json:"fileCount"`
+	// LargestFiles are the largest regular files, largest first.
+	LargestFiles []PlanFile `json:"largestFiles,omitempty"`
+}
+
+// StatDir walks dir to sum the sizes of the regular files in it, keeping the
+// largest ones. It stops walking once ctx is done.
+func StatDir(ctx context.Context, dir string, largest int) (*DirStats, error) {
+	stats := &DirStats{
+		LargestFiles: make([]PlanFile, 0, largest),
+	}
+
+	err := filepath.WalkDir(dir, func(walkPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Size += info.Size()
+		stats.FileCount++
+
+		rel, err := filepath.Rel(dir, walkPath)
+		if err != nil {
+			return err
+		}
+		stats.addLargestFile(PlanFile{Path: filepath.ToSlash(rel), Size: info.Size()}, largest)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+func (s *DirStats) addLargestFile(file PlanFile, largest int) {
+	if largest <= 0 {
+		return
+	}
+	if len(s.LargestFiles) == largest {
+		if file.Size <= s.LargestFiles[largest-1].Size {
+			return
+		}
+		s.LargestFiles = s.LargestFiles[:largest-1]
+	}
+
+	i := sort.Search(len(s.LargestFiles), func(i int) bool {
+		return s.LargestFiles[i].Size < file.Size
+	})
+	s.LargestFiles = append(s.LargestFiles, PlanFile{})
+	copy(s.LargestFiles[i+1:], s.LargestFiles[i:])
+	s.LargestFiles[i] = file
+}
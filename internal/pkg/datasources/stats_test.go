@@ -0,0 +1,42 @@
+package datasources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatDir(t *testing.T) {
+	dir := t.TempDir()
+	// the content of each file is its path
+	writeFiles(t, dir, "a.txt", "b/ccc.txt", "b/d/eeeee.txt", "f/gg.txt")
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(dir, "link.txt")))
+
+	stats, err := StatDir(context.Background(), dir, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), stats.FileCount)
+	assert.Equal(t, int64(len("a.txt")+len("b/ccc.txt")+len("b/d/eeeee.txt")+len("f/gg.txt")), stats.Size)
+	assert.Equal(t, []PlanFile{
+		{Path: "b/d/eeeee.txt", Size: int64(len("b/d/eeeee.txt"))},
+		{Path: "b/ccc.txt", Size: int64(len("b/ccc.txt"))},
+	}, stats.LargestFiles)
+
+	t.Run("empty", func(t *testing.T) {
+		stats, err := StatDir(context.Background(), t.TempDir(), 2)
+		require.NoError(t, err)
+		assert.Zero(t, stats.Size)
+		assert.Zero(t, stats.FileCount)
+		assert.Empty(t, stats.LargestFiles)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := StatDir(ctx, dir, 2)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
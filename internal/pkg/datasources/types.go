@@ -10,8 +10,3 @@ const (
 	TypeHuggingFace Type = "HUGGING_FACE"
 	TypeModelScope  Type = "MODEL_SCOPE"
 )
-
-var (
-	SupportedTypesString = []string{string(TypeS3), string(TypeGit), string(TypeHTTP), string(TypeConda), string(TypeHuggingFace), string(TypeModelScope)}
-	SupportedTypes       = []Type{TypeS3, TypeGit, TypeHTTP, TypeConda, TypeHuggingFace, TypeModelScope}
-)
@@ -2,9 +2,11 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -26,20 +28,32 @@ var (
 	AccessLog  = log.New()
 )
 
+// 日志中统一使用的字段名，controller 与 data-loader 的日志都使用这些字段，便于日志采集后检索
+const (
+	FieldComponent = "component"
+	FieldDataset   = "dataset"
+	FieldNamespace = "namespace"
+	FieldRound     = "round"
+	FieldType      = "type"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	mu sync.Mutex
+	// levels 为按组件设置的日志级别，未设置的组件使用全局级别
+	levels = make(map[string]log.Level)
+	// components 为 Component 创建的各组件 logger，配置变化时同步更新
+	components = make(map[string]*log.Logger)
+	// output 为当前打开的日志文件，重新配置时需要关闭
+	output io.Closer
+)
+
 func init() {
-	log.SetFormatter(&log.TextFormatter{
-		DisableTimestamp:       false,
-		FullTimestamp:          true,
-		DisableLevelTruncation: true,
-		DisableColors:          true,
-		CallerPrettyfier: func(f *runtime.Frame) (string, string) {
-			fs := strings.Split(f.File, "/")
-			filename := fs[len(fs)-1]
-			ff := strings.Split(f.Function, "/")
-			_f := ff[len(ff)-1]
-			return fmt.Sprintf("%s()", _f), fmt.Sprintf("%s:%d", filename, f.Line)
-		},
-	})
+	log.SetFormatter(newFormatter(FormatText))
 	log.SetOutput(os.Stdout)
 	log.SetLevel(log.InfoLevel)
 	log.SetReportCaller(true)
@@ -49,8 +63,32 @@ func init() {
 	})
 }
 
+func callerPrettyfier(f *runtime.Frame) (string, string) {
+	fs := strings.Split(f.File, "/")
+	filename := fs[len(fs)-1]
+	ff := strings.Split(f.Function, "/")
+	_f := ff[len(ff)-1]
+	return fmt.Sprintf("%s()", _f), fmt.Sprintf("%s:%d", filename, f.Line)
+}
+
+func newFormatter(format string) log.Formatter {
+	if format == FormatJSON {
+		return &log.JSONFormatter{
+			CallerPrettyfier: callerPrettyfier,
+		}
+	}
+	return &log.TextFormatter{
+		DisableTimestamp:       false,
+		FullTimestamp:          true,
+		DisableLevelTruncation: true,
+		DisableColors:          true,
+		CallerPrettyfier:       callerPrettyfier,
+	}
+}
+
 func SetDebug() {
 	log.SetLevel(log.DebugLevel)
+	syncComponents()
 }
 
 func GetLevel() log.Level {
@@ -60,10 +98,159 @@ func GetLevel() log.Level {
 type Config struct {
 	Output string `json:"output"` // 文件输出路径，不填输出终端
 	Debug  bool   `json:"debug"`
+	// Format 为日志格式，text 或 json，默认 text
+	Format string `json:"format"`
+	// Level 为全局日志级别，Debug 为 true 时固定为 debug，不填保持不变
+	Level string `json:"level"`
+	// Levels 为按组件设置的日志级别，如 controller-runtime: warn
+	Levels map[string]string `json:"levels"`
+	// MaxSizeMB 为输出到文件时单个文件的最大大小，超过后轮转，默认 100
+	MaxSizeMB int `json:"max_size_mb"`
+	// MaxBackups 为输出到文件时保留的轮转文件个数，默认 5
+	MaxBackups int `json:"max_backups"`
+}
+
+func InitEngine(config *Config) error {
+	if config == nil {
+		return nil
+	}
+
+	switch config.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %s, must be one of text or json", config.Format)
+	}
+
+	level := log.GetLevel()
+	if config.Debug {
+		level = log.DebugLevel
+	} else if config.Level != "" {
+		l, err := log.ParseLevel(config.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level %s: %w", config.Level, err)
+		}
+		level = l
+	}
+
+	componentLevels := make(map[string]log.Level, len(config.Levels))
+	for component, componentLevel := range config.Levels {
+		l, err := log.ParseLevel(componentLevel)
+		if err != nil {
+			return fmt.Errorf("invalid log level %s of component %s: %w", componentLevel, component, err)
+		}
+		componentLevels[strings.ToLower(component)] = l
+	}
+
+	var out io.Writer = os.Stdout
+	var closer io.Closer
+	switch config.Output {
+	case "", "stdout":
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := newRotatingFile(config.Output, int64(config.MaxSizeMB)*1024*1024, config.MaxBackups)
+		if err != nil {
+			return fmt.Errorf("failed to open log output %s: %w", config.Output, err)
+		}
+		out = file
+		closer = file
+	}
+
+	log.SetFormatter(newFormatter(config.Format))
+	log.SetOutput(out)
+	log.SetLevel(level)
+
+	mu.Lock()
+	levels = componentLevels
+	previous := output
+	output = closer
+	mu.Unlock()
+
+	syncComponents()
+	if previous != nil {
+		_ = previous.Close()
+	}
+
+	return nil
+}
+
+// AddFields 为之后的所有日志加上固定字段，用于标识 data-loader 所同步的 dataset 等
+func AddFields(fields log.Fields) {
+	log.AddHook(&fieldsHook{fields: fields})
+	syncComponents()
+}
+
+type fieldsHook struct {
+	fields log.Fields
+}
+
+func (h *fieldsHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *fieldsHook) Fire(entry *log.Entry) error {
+	for k, v := range h.fields {
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = v
+		}
+	}
+	return nil
 }
 
-func InitEngine(config *Config) {
-	if config != nil && config.Debug {
-		SetDebug()
+// Component 返回带有 component 字段的 logger，其日志级别可以通过 Config.Levels 单独设置，
+// 组件名以 . 分隔层级，未单独设置时依次使用上一级组件的级别
+func Component(name string) *log.Entry {
+	return componentLogger(name).WithField(FieldComponent, name)
+}
+
+func componentLogger(name string) *log.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+
+	logger, ok := components[name]
+	if !ok {
+		logger = log.New()
+		components[name] = logger
+		configureComponent(name, logger)
+	}
+	return logger
+}
+
+// componentLevel 返回组件的日志级别，需要持有 mu
+func componentLevel(name string) log.Level {
+	for component := strings.ToLower(name); component != ""; {
+		if level, ok := levels[component]; ok {
+			return level
+		}
+		i := strings.LastIndex(component, ".")
+		if i < 0 {
+			break
+		}
+		component = component[:i]
+	}
+	return log.GetLevel()
+}
+
+// configureComponent 使组件 logger 与全局 logger 的输出一致，需要持有 mu
+func configureComponent(name string, logger *log.Logger) {
+	std := log.StandardLogger()
+	hooks := make(log.LevelHooks)
+	for level, levelHooks := range std.Hooks {
+		hooks[level] = append(hooks[level], levelHooks...)
+	}
+
+	logger.SetOutput(std.Out)
+	logger.SetFormatter(std.Formatter)
+	logger.SetReportCaller(std.ReportCaller)
+	logger.ReplaceHooks(hooks)
+	logger.SetLevel(componentLevel(name))
+}
+
+func syncComponents() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for name, logger := range components {
+		configureComponent(name, logger)
 	}
 }
@@ -1,9 +1,16 @@
 package log
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInitEngine(t *testing.T) {
@@ -11,7 +18,8 @@ func TestInitEngine(t *testing.T) {
 		Output: "",
 		Debug:  true,
 	}
-	InitEngine(cfg)
+	err := InitEngine(cfg)
+	assert.NoError(t, err)
 	assert.Equal(t, "debug", GetLevel().String())
 }
 
@@ -19,3 +27,165 @@ func TestSetDebug(t *testing.T) {
 	SetDebug()
 	assert.Equal(t, "debug", GetLevel().String())
 }
+
+func readJSONLines(t *testing.T, path string) []map[string]any {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var entries []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := make(map[string]any)
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestInitEngineJSONOutput(t *testing.T) {
+	t.Cleanup(func() {
+		_ = InitEngine(&Config{Format: FormatText, Output: "stdout"})
+	})
+
+	output := filepath.Join(t.TempDir(), "logs", "controller.log")
+	err := InitEngine(&Config{
+		Format: FormatJSON,
+		Output: output,
+		Level:  "info",
+		Levels: map[string]string{
+			"controller-runtime": "warn",
+			"controller.queue":   "debug",
+		},
+	})
+	require.NoError(t, err)
+
+	Component("controller").WithField(FieldDataset, "test").Info("reconciled")
+	Component("controller").Debug("hidden")
+	Component("controller.queue").Debug("queued")
+	Component("controller-runtime").Info("hidden")
+	Component("controller-runtime.manager").Warn("leader lost")
+
+	entries := readJSONLines(t, output)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "reconciled", entries[0]["msg"])
+	assert.Equal(t, "info", entries[0]["level"])
+	assert.Equal(t, "controller", entries[0][FieldComponent])
+	assert.Equal(t, "test", entries[0][FieldDataset])
+	assert.Equal(t, "queued", entries[1]["msg"])
+	assert.Equal(t, "leader lost", entries[2]["msg"])
+	assert.Equal(t, "controller-runtime.manager", entries[2][FieldComponent])
+}
+
+func TestInitEngineInvalid(t *testing.T) {
+	for name, config := range map[string]*Config{
+		"format":          {Format: "xml"},
+		"level":           {Level: "verbose"},
+		"component level": {Levels: map[string]string{"controller": "verbose"}},
+	} {
+		assert.Error(t, InitEngine(config), name)
+	}
+}
+
+func TestNewLogr(t *testing.T) {
+	t.Cleanup(func() {
+		_ = InitEngine(&Config{Format: FormatText, Output: "stdout"})
+	})
+
+	output := filepath.Join(t.TempDir(), "controller.log")
+	err := InitEngine(&Config{
+		Format: FormatJSON,
+		Output: output,
+		Level:  "info",
+	})
+	require.NoError(t, err)
+
+	logger := NewLogr("controller-runtime").WithName("controller").WithValues("controller", "dataset")
+	logger.Info("starting workers", "worker count", 1)
+	logger.V(1).Info("hidden")
+	logger.Error(errors.New("failed"), "reconciler error", FieldNamespace, "default")
+
+	entries := readJSONLines(t, output)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "starting workers", entries[0]["msg"])
+	assert.Equal(t, "controller-runtime", entries[0][FieldComponent])
+	assert.Equal(t, "controller", entries[0]["logger"])
+	assert.Equal(t, "dataset", entries[0]["controller"])
+	assert.Equal(t, float64(1), entries[0]["worker count"])
+	assert.Equal(t, "error", entries[1]["level"])
+	assert.Equal(t, "failed", entries[1]["error"])
+	assert.Equal(t, "default", entries[1][FieldNamespace])
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data-loader.log")
+	w, err := newRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	defer func() {
+		_ = w.Close()
+	}()
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		_, err = w.Write([]byte(line))
+		require.NoError(t, err)
+	}
+
+	for path, content := range map[string]string{
+		path:        "line 4\n",
+		path + ".1": "line 3\n",
+		path + ".2": "line 2\n",
+	} {
+		actual, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(actual))
+	}
+	assert.NoFileExists(t, path+".3")
+}
+
+func TestZapFlags(t *testing.T) {
+	parse := func(t *testing.T, config *Config, args ...string) ([]string, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var zapFlags ZapFlags
+		zapFlags.BindFlags(fs)
+		require.NoError(t, fs.Parse(args))
+		return zapFlags.Apply(fs, config)
+	}
+
+	t.Run("not set", func(t *testing.T) {
+		config := &Config{Format: FormatJSON, Level: "warn"}
+		used, err := parse(t, config)
+		require.NoError(t, err)
+		assert.Empty(t, used)
+		assert.Equal(t, &Config{Format: FormatJSON, Level: "warn"}, config)
+	})
+
+	t.Run("mapped onto config", func(t *testing.T) {
+		config := &Config{Format: FormatText, Level: "warn"}
+		used, err := parse(t, config, "--zap-devel", "--zap-encoder=json", "--zap-log-level=error")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"--zap-devel", "--zap-encoder", "--zap-log-level"}, used)
+		assert.True(t, config.Debug)
+		assert.Equal(t, FormatJSON, config.Format)
+		assert.Equal(t, "error", config.Level)
+	})
+
+	t.Run("console encoder and verbosity", func(t *testing.T) {
+		config := &Config{Format: FormatJSON}
+		_, err := parse(t, config, "--zap-encoder=console", "--zap-log-level=3")
+		require.NoError(t, err)
+		assert.Equal(t, FormatText, config.Format)
+		assert.Equal(t, "debug", config.Level)
+		require.NoError(t, InitEngine(config))
+		assert.Equal(t, "debug", GetLevel().String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := parse(t, &Config{}, "--zap-encoder=xml")
+		assert.Error(t, err)
+		_, err = parse(t, &Config{}, "--zap-log-level=verbose")
+		assert.Error(t, err)
+		_, err = parse(t, &Config{}, "--zap-log-level=0")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	log "github.com/sirupsen/logrus"
+)
+
+// NewLogr 返回输出到 component 组件 logger 的 logr.Logger，用于 controller-runtime 等使用 logr 的库，
+// 使其日志与其他日志的格式、输出和级别配置一致。logr 的 V(0) 对应 info，更高的 V 对应 debug
+func NewLogr(component string) logr.Logger {
+	return logr.New(&logrSink{component: component})
+}
+
+type logrSink struct {
+	component string
+	name      string
+	fields    log.Fields
+}
+
+var _ logr.LogSink = &logrSink{}
+
+func (s *logrSink) Init(logr.RuntimeInfo) {}
+
+func (s *logrSink) Enabled(level int) bool {
+	return componentLogger(s.component).IsLevelEnabled(logrLevel(level))
+}
+
+func (s *logrSink) Info(level int, msg string, keysAndValues ...any) {
+	s.entry(keysAndValues).Log(logrLevel(level), msg)
+}
+
+func (s *logrSink) Error(err error, msg string, keysAndValues ...any) {
+	s.entry(keysAndValues).WithError(err).Error(msg)
+}
+
+func (s *logrSink) WithValues(keysAndValues ...any) logr.LogSink {
+	sink := *s
+	sink.fields = withValues(s.fields, keysAndValues)
+	return &sink
+}
+
+func (s *logrSink) WithName(name string) logr.LogSink {
+	sink := *s
+	if sink.name == "" {
+		sink.name = name
+	} else {
+		sink.name = sink.name + "." + name
+	}
+	return &sink
+}
+
+func (s *logrSink) entry(keysAndValues []any) *log.Entry {
+	entry := Component(s.component).WithFields(withValues(s.fields, keysAndValues))
+	if s.name != "" {
+		entry = entry.WithField("logger", s.name)
+	}
+	return entry
+}
+
+func logrLevel(level int) log.Level {
+	if level > 0 {
+		return log.DebugLevel
+	}
+	return log.InfoLevel
+}
+
+func withValues(fields log.Fields, keysAndValues []any) log.Fields {
+	merged := make(log.Fields, len(fields)+len(keysAndValues)/2)
+	for k, v := range fields {
+		merged[k] = v
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		key := fmt.Sprint(keysAndValues[i])
+		if i+1 < len(keysAndValues) {
+			merged[key] = keysAndValues[i+1]
+		} else {
+			merged[key] = nil
+		}
+	}
+	return merged
+}
@@ -0,0 +1,126 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 5
+)
+
+// rotatingFile 按大小轮转日志文件，超过 maxSize 时将 path 重命名为 path.1，
+// 已有的 path.N 依次重命名为 path.N+1，超过 maxBackups 的文件被删除
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = defaultMaxSizeMB * 1024 * 1024
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	err = w.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *rotatingFile) open() error {
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644) // #nosec G302
+	if err != nil {
+		return err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = stat.Size()
+
+	return nil
+}
+
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		err := w.rotate()
+		if err != nil {
+			return 0, fmt.Errorf("failed to rotate log file %s: %w", w.path, err)
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+func (w *rotatingFile) rotate() error {
+	err := w.file.Close()
+	if err != nil {
+		return err
+	}
+	w.file = nil
+
+	_ = os.Remove(w.backupPath(w.maxBackups))
+	for i := w.maxBackups - 1; i > 0; i-- {
+		err = os.Rename(w.backupPath(i), w.backupPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err = os.Rename(w.path, w.backupPath(1))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return w.open()
+}
+
+func (w *rotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", w.path, i)
+}
+
+func (w *rotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+
+	return err
+}
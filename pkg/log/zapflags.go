@@ -0,0 +1,67 @@
+package log
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ZapFlags 为之前 controller-runtime zap logger 的命令行参数，已废弃，仅为兼容保留，
+// 设置后映射到 Config 上并覆盖配置文件中的对应配置
+type ZapFlags struct {
+	Devel   bool
+	Encoder string
+	Level   string
+}
+
+// BindFlags 注册 --zap-devel、--zap-encoder 和 --zap-log-level 参数
+func (f *ZapFlags) BindFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&f.Devel, "zap-devel", false, "Deprecated: use log.debug in the config file instead.")
+	fs.StringVar(&f.Encoder, "zap-encoder", "", "Deprecated: use log.format in the config file instead. One of 'json' or 'console'.")
+	fs.StringVar(&f.Level, "zap-log-level", "", "Deprecated: use log.level in the config file instead. "+
+		"One of 'debug', 'info', 'warn', 'error' or an integer greater than 0 for debug.")
+}
+
+// Apply 将设置了的参数映射到 config 上，返回被使用的废弃参数
+func (f *ZapFlags) Apply(fs *flag.FlagSet, config *Config) ([]string, error) {
+	var used []string
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if err != nil || !strings.HasPrefix(fl.Name, "zap-") {
+			return
+		}
+		used = append(used, "--"+fl.Name)
+		switch fl.Name {
+		case "zap-devel":
+			// zap 的 development 模式输出 debug 级别的日志
+			config.Debug = f.Devel
+		case "zap-encoder":
+			switch f.Encoder {
+			case "json":
+				config.Format = FormatJSON
+			case "console":
+				config.Format = FormatText
+			default:
+				err = fmt.Errorf("invalid --zap-encoder %s, must be one of json or console", f.Encoder)
+			}
+		case "zap-log-level":
+			config.Level, err = zapLevel(f.Level)
+		}
+	})
+	return used, err
+}
+
+// zapLevel 将 zap 的日志级别转换为 logrus 的日志级别，zap 中大于 0 的整数表示更详细的 debug 日志
+func zapLevel(level string) (string, error) {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error", "panic", "fatal":
+		return strings.ToLower(level), nil
+	case "dpanic":
+		return "panic", nil
+	}
+	if v, err := strconv.Atoi(level); err == nil && v > 0 {
+		return "debug", nil
+	}
+	return "", fmt.Errorf("invalid --zap-log-level %s", level)
+}
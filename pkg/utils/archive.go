@@ -0,0 +1,439 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveFormatTar ArchiveFormat = "tar"
+	ArchiveFormatZip ArchiveFormat = "zip"
+)
+
+var (
+	archiveExtensions = map[string]ArchiveFormat{
+		".tar":      ArchiveFormatTar,
+		".tar.gz":   ArchiveFormatTar,
+		".tgz":      ArchiveFormatTar,
+		".tar.zst":  ArchiveFormatTar,
+		".tar.zstd": ArchiveFormatTar,
+		".tzst":     ArchiveFormatTar,
+		".tar.bz2":  ArchiveFormatTar,
+		".tbz2":     ArchiveFormatTar,
+		".zip":      ArchiveFormatZip,
+	}
+
+	gzipMagic  = []byte{0x1f, 0x8b}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic = []byte("BZh")
+)
+
+// DetectArchiveFormat detects the format of the archive by the extension of
+// its file name.
+func DetectArchiveFormat(name string) (ArchiveFormat, bool) {
+	lowered := strings.ToLower(name)
+	for ext, format := range archiveExtensions {
+		if strings.HasSuffix(lowered, ext) {
+			return format, true
+		}
+	}
+
+	return "", false
+}
+
+// ExtractArchive extracts the archive into destDir, the leading
+// stripComponents path elements of each entry are removed the same way as
+// tar --strip-components does. Entries that would be written outside of
+// destDir, including links pointing outside of it, are rejected. Extraction
+// stops between entries once ctx is done.
+func ExtractArchive(ctx context.Context, logger *logrus.Entry, archivePath string, destDir string, format ArchiveFormat, stripComponents int) error {
+	logger = logger.WithFields(logrus.Fields{
+		"archive": archivePath,
+		"dest":    destDir,
+		"format":  format,
+	})
+
+	destDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+
+	destDir, err = filepath.EvalSymlinks(destDir)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case ArchiveFormatTar:
+		return extractTar(ctx, logger, archivePath, destDir, stripComponents)
+	case ArchiveFormatZip:
+		return extractZip(ctx, logger, archivePath, destDir, stripComponents)
+	default:
+		return fmt.Errorf("unsupported archive format %s", format)
+	}
+}
+
+func decompressedReader(r io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(r)
+
+	header, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return gzip.NewReader(buffered)
+	case bytes.HasPrefix(header, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+	case bytes.HasPrefix(header, bzip2Magic):
+		return io.NopCloser(bzip2.NewReader(buffered)), nil
+	default:
+		return io.NopCloser(buffered), nil
+	}
+}
+
+// archiveEntryPath returns the path within destDir that the entry should be
+// extracted to, an empty path means the entry should be skipped.
+func archiveEntryPath(destDir string, name string, stripComponents int) (string, error) {
+	name = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "", nil
+	}
+
+	components := strings.Split(name, "/")
+	if len(components) <= stripComponents {
+		return "", nil
+	}
+
+	target := filepath.Join(destDir, filepath.FromSlash(strings.Join(components[stripComponents:], "/")))
+	if !isWithinDir(destDir, target) {
+		return "", fmt.Errorf("illegal file path %s in archive", name)
+	}
+
+	return target, nil
+}
+
+func isWithinDir(dir string, target string) bool {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil {
+		return false
+	}
+
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// resolvePath resolves the symlinks of an absolute path one component at a
+// time, components that do not exist yet are kept as is. Unlike
+// filepath.EvalSymlinks, ".." is applied after resolving the symlink before
+// it, which is what the kernel will do when the path is written to.
+func resolvePath(p string, depth int) (string, error) {
+	if depth > 255 {
+		return "", fmt.Errorf("too many levels of symbolic links in %s", p)
+	}
+
+	resolved := string(filepath.Separator)
+	components := strings.Split(filepath.ToSlash(p), "/")
+	for i, component := range components {
+		switch component {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, component)
+
+		stat, err := os.Lstat(next)
+		if err != nil {
+			if os.IsNotExist(err) {
+				resolved = next
+				continue
+			}
+
+			return "", err
+		}
+		if !IsSymlink(stat) {
+			resolved = next
+			continue
+		}
+
+		linkname, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(linkname) {
+			linkname = resolved + string(filepath.Separator) + linkname
+		}
+
+		rest := strings.Join(components[i+1:], "/")
+
+		return resolvePath(linkname+"/"+rest, depth+1)
+	}
+
+	return resolved, nil
+}
+
+// resolveArchiveTarget resolves the symlinks in the parent directories of
+// target and makes sure the result is still within destDir, so that links
+// extracted earlier can not be used to write outside of it. The parent
+// directories are created, an existing file or link at the target is removed
+// so that it is never written through.
+func resolveArchiveTarget(destDir string, target string) (string, error) {
+	parent, err := resolvePath(filepath.Dir(target), 0)
+	if err != nil {
+		return "", err
+	}
+	if !isWithinDir(destDir, parent) {
+		return "", fmt.Errorf("illegal file path %s in archive", target)
+	}
+
+	err = os.MkdirAll(parent, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	target = filepath.Join(parent, filepath.Base(target))
+
+	stat, err := os.Lstat(target)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return target, nil
+		}
+
+		return "", err
+	}
+	if stat.IsDir() {
+		return target, nil
+	}
+
+	err = os.Remove(target)
+	if err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+func extractArchiveDir(destDir string, target string) error {
+	target, err := resolveArchiveTarget(destDir, target)
+	if err != nil {
+		return err
+	}
+
+	return os.MkdirAll(target, 0755)
+}
+
+func extractArchiveSymlink(destDir string, target string, linkname string) error {
+	if filepath.IsAbs(linkname) {
+		return fmt.Errorf("illegal absolute link %s -> %s in archive", target, linkname)
+	}
+
+	target, err := resolveArchiveTarget(destDir, target)
+	if err != nil {
+		return err
+	}
+
+	resolved, err := resolvePath(filepath.Dir(target)+string(filepath.Separator)+linkname, 0)
+	if err != nil {
+		return err
+	}
+	if !isWithinDir(destDir, resolved) {
+		return fmt.Errorf("illegal link %s -> %s in archive", target, linkname)
+	}
+
+	return os.Symlink(linkname, target)
+}
+
+func extractArchiveHardlink(destDir string, target string, linkTarget string) error {
+	linkTarget, err := resolvePath(linkTarget, 0)
+	if err != nil {
+		return err
+	}
+	if !isWithinDir(destDir, linkTarget) {
+		return fmt.Errorf("illegal link %s -> %s in archive", target, linkTarget)
+	}
+
+	target, err = resolveArchiveTarget(destDir, target)
+	if err != nil {
+		return err
+	}
+
+	return os.Link(linkTarget, target)
+}
+
+func writeArchiveFile(destDir string, target string, r io.Reader, mode os.FileMode) error {
+	target, err := resolveArchiveTarget(destDir, target)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r) // #nosec G110
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func extractTar(ctx context.Context, logger *logrus.Entry, archivePath string, destDir string, stripComponents int) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	reader, err := decompressedReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", archivePath, err)
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	tarReader := tar.NewReader(reader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+
+		target, err := archiveEntryPath(destDir, header.Name, stripComponents)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = extractArchiveDir(destDir, target)
+		case tar.TypeReg:
+			err = writeArchiveFile(destDir, target, tarReader, header.FileInfo().Mode())
+		case tar.TypeSymlink:
+			err = extractArchiveSymlink(destDir, target, header.Linkname)
+		case tar.TypeLink:
+			var linkTarget string
+			linkTarget, err = archiveEntryPath(destDir, header.Linkname, stripComponents)
+			if err != nil {
+				return err
+			}
+			if linkTarget == "" {
+				continue
+			}
+
+			err = extractArchiveHardlink(destDir, target, linkTarget)
+		default:
+			logger.Warnf("skipping unsupported entry %s of type %c", header.Name, header.Typeflag)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to extract %s from %s: %w", header.Name, archivePath, err)
+		}
+	}
+}
+
+func extractZip(ctx context.Context, logger *logrus.Entry, archivePath string, destDir string, stripComponents int) error {
+	zipReader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+
+	defer func() {
+		_ = zipReader.Close()
+	}()
+
+	for _, file := range zipReader.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		target, err := archiveEntryPath(destDir, file.Name, stripComponents)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+
+		err = extractZipFile(logger, destDir, target, file)
+		if err != nil {
+			return fmt.Errorf("failed to extract %s from %s: %w", file.Name, archivePath, err)
+		}
+	}
+
+	return nil
+}
+
+func extractZipFile(logger *logrus.Entry, destDir string, target string, file *zip.File) error {
+	mode := file.Mode()
+	if mode.IsDir() {
+		return extractArchiveDir(destDir, target)
+	}
+
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = r.Close()
+	}()
+
+	if mode&os.ModeSymlink != 0 {
+		linkname, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		return extractArchiveSymlink(destDir, target, string(linkname))
+	}
+	if !mode.IsRegular() {
+		logger.Warnf("skipping unsupported entry %s of mode %s", file.Name, mode)
+		return nil
+	}
+	if mode.Perm() == 0 {
+		mode = 0644
+	}
+
+	return writeArchiveFile(destDir, target, r, mode)
+}
@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testArchiveEntry struct {
+	name     string
+	content  string
+	typeflag byte
+	linkname string
+}
+
+func writeTestTar(t *testing.T, path string, compress string, entries []testArchiveEntry) {
+	buffer := new(bytes.Buffer)
+	tarWriter := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Name:     entry.name,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+			Typeflag: entry.typeflag,
+			Linkname: entry.linkname,
+		}
+		if entry.typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		if header.Typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if header.Typeflag == tar.TypeDir {
+			header.Mode = 0755
+		}
+
+		require.NoError(t, tarWriter.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := tarWriter.Write([]byte(entry.content))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tarWriter.Close())
+
+	content := buffer.Bytes()
+	switch compress {
+	case "gzip":
+		compressed := new(bytes.Buffer)
+		gzipWriter := gzip.NewWriter(compressed)
+		_, err := gzipWriter.Write(content)
+		require.NoError(t, err)
+		require.NoError(t, gzipWriter.Close())
+		content = compressed.Bytes()
+	case "zstd":
+		encoder, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		content = encoder.EncodeAll(content, nil)
+		require.NoError(t, encoder.Close())
+	}
+
+	require.NoError(t, os.WriteFile(path, content, 0644))
+}
+
+func writeTestZip(t *testing.T, path string, entries []testArchiveEntry) {
+	buffer := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buffer)
+	for _, entry := range entries {
+		w, err := zipWriter.Create(entry.name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zipWriter.Close())
+	require.NoError(t, os.WriteFile(path, buffer.Bytes(), 0644))
+}
+
+func TestDetectArchiveFormat(t *testing.T) {
+	for name, expected := range map[string]ArchiveFormat{
+		"data.tar":        ArchiveFormatTar,
+		"data.tar.gz":     ArchiveFormatTar,
+		"DATA.TGZ":        ArchiveFormatTar,
+		"data.tar.zst":    ArchiveFormatTar,
+		"data.tar.bz2":    ArchiveFormatTar,
+		"data.zip":        ArchiveFormatZip,
+		"data.csv":        "",
+		"data.gz":         "",
+		"tar.gz.manifest": "",
+	} {
+		format, ok := DetectArchiveFormat(name)
+		assert.Equal(t, expected != "", ok, name)
+		assert.Equal(t, expected, format, name)
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	logger := logrus.NewEntry(logrus.New())
+	entries := []testArchiveEntry{
+		{name: "bundle/", typeflag: tar.TypeDir},
+		{name: "bundle/README.md", content: "readme"},
+		{name: "bundle/data/train.csv", content: "a,b\n1,2\n"},
+		{name: "bundle/latest", typeflag: tar.TypeSymlink, linkname: "data/train.csv"},
+	}
+
+	for _, compress := range []string{"", "gzip", "zstd"} {
+		t.Run("tar "+compress, func(t *testing.T) {
+			dir := t.TempDir()
+			archive := filepath.Join(dir, "bundle.tar")
+			writeTestTar(t, archive, compress, entries)
+
+			require.NoError(t, ExtractArchive(context.Background(), logger, archive, dir, ArchiveFormatTar, 0))
+
+			content, err := os.ReadFile(filepath.Join(dir, "bundle", "latest"))
+			require.NoError(t, err)
+			assert.Equal(t, "a,b\n1,2\n", string(content))
+			assert.FileExists(t, filepath.Join(dir, "bundle", "README.md"))
+		})
+	}
+
+	t.Run("tar w/ stripComponents", func(t *testing.T) {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "bundle.tar.gz")
+		writeTestTar(t, archive, "gzip", entries)
+
+		require.NoError(t, ExtractArchive(context.Background(), logger, archive, dir, ArchiveFormatTar, 1))
+
+		assert.FileExists(t, filepath.Join(dir, "README.md"))
+		assert.FileExists(t, filepath.Join(dir, "data", "train.csv"))
+		assert.NoDirExists(t, filepath.Join(dir, "bundle"))
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, "bundle.zip")
+		writeTestZip(t, archive, []testArchiveEntry{
+			{name: "bundle/README.md", content: "readme"},
+			{name: "bundle/data/train.csv", content: "a,b\n"},
+		})
+
+		require.NoError(t, ExtractArchive(context.Background(), logger, archive, dir, ArchiveFormatZip, 1))
+
+		content, err := os.ReadFile(filepath.Join(dir, "data", "train.csv"))
+		require.NoError(t, err)
+		assert.Equal(t, "a,b\n", string(content))
+	})
+
+	t.Run("zip slip", func(t *testing.T) {
+		root := t.TempDir()
+		dir := filepath.Join(root, "dest")
+		require.NoError(t, os.Mkdir(dir, 0755))
+		archive := filepath.Join(dir, "evil.zip")
+		writeTestZip(t, archive, []testArchiveEntry{
+			{name: "../../evil.txt", content: "evil"},
+		})
+
+		// leading .. are dropped like tar does, the entry stays within dir
+		require.NoError(t, ExtractArchive(context.Background(), logger, archive, dir, ArchiveFormatZip, 0))
+		assert.FileExists(t, filepath.Join(dir, "evil.txt"))
+		assert.NoFileExists(t, filepath.Join(root, "evil.txt"))
+	})
+
+	t.Run("symlink escaping", func(t *testing.T) {
+		for name, evil := range map[string][]testArchiveEntry{
+			"absolute": {
+				{name: "etc", typeflag: tar.TypeSymlink, linkname: "/etc"},
+			},
+			"relative": {
+				{name: "up", typeflag: tar.TypeSymlink, linkname: "../.."},
+			},
+			"through another symlink": {
+				{name: "self", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "self/up", typeflag: tar.TypeSymlink, linkname: "../outside"},
+			},
+			"write through symlink": {
+				{name: "self", typeflag: tar.TypeSymlink, linkname: "."},
+				{name: "self/../evil.txt", content: "evil"},
+				{name: "dir", typeflag: tar.TypeSymlink, linkname: "self/.."},
+			},
+			"hard link": {
+				{name: "passwd", typeflag: tar.TypeLink, linkname: "../../../etc/passwd"},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				root := t.TempDir()
+				dir := filepath.Join(root, "dest")
+				require.NoError(t, os.Mkdir(dir, 0755))
+				archive := filepath.Join(dir, "evil.tar")
+				writeTestTar(t, archive, "", evil)
+
+				err := ExtractArchive(context.Background(), logger, archive, dir, ArchiveFormatTar, 0)
+				assert.Error(t, err)
+				assert.NoFileExists(t, filepath.Join(root, "evil.txt"))
+				assert.NoFileExists(t, filepath.Join(dir, "passwd"))
+			})
+		}
+	})
+}
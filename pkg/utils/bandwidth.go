@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+var sizeUnits = map[byte]float64{
+	'B': 1,
+	'K': 1 << 10,
+	'M': 1 << 20,
+	'G': 1 << 30,
+}
+
+// ParseBandwidth parses a bandwidth in bytes per second the same way rclone
+// parses --bwlimit, e.g. 512K, 10M or 1.5G, a value without suffix is in KiB.
+func ParseBandwidth(bandwidth string) (int64, error) {
+	value, err := parseSizeSuffix(bandwidth)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %s, must be a positive number with an optional B, K, M or G suffix", bandwidth)
+	}
+
+	return value, nil
+}
+
+// ParseSize parses a size in bytes the same way rclone parses --min-size and
+// --max-size, a value without suffix is in KiB.
+func ParseSize(size string) (int64, error) {
+	value, err := parseSizeSuffix(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %s, must be a positive number with an optional B, K, M or G suffix", size)
+	}
+
+	return value, nil
+}
+
+// FormatSize formats a size in bytes with the largest binary unit that keeps
+// the value at least 1, e.g. 1.5 GiB.
+func FormatSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(size)
+	i := 0
+	for ; i < len(units)-1 && (value >= 1024 || value <= -1024); i++ {
+		value /= 1024
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
+
+func parseSizeSuffix(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	if size == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+
+	number, unit := size, float64(1<<10)
+	if multiplier, ok := sizeUnits[strings.ToUpper(size[len(size)-1:])[0]]; ok {
+		number, unit = size[:len(size)-1], multiplier
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid number %s", number)
+	}
+
+	return int64(value * unit), nil
+}
+
+// NewBandwidthLimiter returns a limiter shared by all the readers that should
+// not exceed bytesPerSecond in total.
+func NewBandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	// the burst bounds the size of each read
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(max(bytesPerSecond, 32<<10)))
+}
+
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rate.Limiter
+}
+
+// NewRateLimitedReader wraps reader so that reading from it waits for the
+// limiter, a nil limiter leaves reader as is.
+func NewRateLimitedReader(ctx context.Context, reader io.Reader, limiter *rate.Limiter) io.Reader {
+	if limiter == nil {
+		return reader
+	}
+
+	return &rateLimitedReader{ctx: ctx, reader: reader, limiter: limiter}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > r.limiter.Burst() {
+		p = p[:r.limiter.Burst()]
+	}
+
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	for bandwidth, expected := range map[string]int64{
+		"512":  512 << 10,
+		"512K": 512 << 10,
+		"10M":  10 << 20,
+		"10m":  10 << 20,
+		"1.5G": 3 << 29,
+		"100B": 100,
+	} {
+		actual, err := ParseBandwidth(bandwidth)
+		require.NoError(t, err, bandwidth)
+		assert.Equal(t, expected, actual, bandwidth)
+	}
+
+	for _, bandwidth := range []string{"", "M", "-1M", "10X", "off"} {
+		_, err := ParseBandwidth(bandwidth)
+		assert.Error(t, err, bandwidth)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	actual, err := ParseSize("1G")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1<<30), actual)
+
+	_, err = ParseSize("1T")
+	assert.Error(t, err)
+}
+
+func TestFormatSize(t *testing.T) {
+	for size, expected := range map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1536:          "1.5 KiB",
+		10 << 20:      "10.0 MiB",
+		3 << 40:       "3.0 TiB",
+		5<<50 + 1<<49: "5.5 PiB",
+	} {
+		assert.Equal(t, expected, FormatSize(size), size)
+	}
+}
+
+func TestRateLimitedReader(t *testing.T) {
+	content := bytes.Repeat([]byte("a"), 96<<10)
+
+	t.Run("limited", func(t *testing.T) {
+		start := time.Now()
+		// the first 32K are the burst, the remaining 64K take about 2s
+		read, err := io.ReadAll(NewRateLimitedReader(context.Background(), bytes.NewReader(content), NewBandwidthLimiter(32<<10)))
+		require.NoError(t, err)
+		assert.Equal(t, content, read)
+		assert.Greater(t, time.Since(start), 1500*time.Millisecond)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := io.ReadAll(NewRateLimitedReader(ctx, bytes.NewReader(content), NewBandwidthLimiter(32<<10)))
+		assert.Error(t, err)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		reader := bytes.NewReader(content)
+		assert.Same(t, reader, NewRateLimitedReader(context.Background(), reader, nil))
+	})
+}
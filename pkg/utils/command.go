@@ -2,15 +2,33 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+// CommandWaitDelay is how long a command is given to exit after it has been
+// asked to stop by the cancellation of its context before it gets killed.
+var CommandWaitDelay = 10 * time.Second
+
+// CommandContext is like exec.CommandContext, except that the command runs in
+// its own process group, and the whole group, e.g. git-remote-https spawned by
+// git, receives SIGTERM rather than SIGKILL once ctx is done, so that it has
+// a chance to clean up before being killed after CommandWaitDelay.
+func CommandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setCommandCancel(cmd)
+	cmd.WaitDelay = CommandWaitDelay
+
+	return cmd
+}
+
 func ExecuteCommandWithAllOutput(logger *logrus.Entry, cmd *exec.Cmd, secrets []string) (*bytes.Buffer, *bytes.Buffer, error) {
 	logger = logger.WithField("command", cmd.String())
 	logger.Debug("executing command")
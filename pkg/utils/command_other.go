@@ -0,0 +1,14 @@
+//go:build !unix
+
+package utils
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setCommandCancel(cmd *exec.Cmd) {
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+}
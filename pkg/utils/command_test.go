@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"context"
 	"os"
 	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -28,3 +30,19 @@ func TestExecuteCommandWithAllOutput(t *testing.T) {
 		assert.Equal(t, "test_output_0\n******\n", o.String())
 	})
 }
+
+func TestCommandContext(t *testing.T) {
+	logger := log.WithFields(logrus.Fields{
+		"test": "TestCommandContext",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	// the child sleep keeps stdout open, it has to be stopped together with sh
+	// for the command to return before CommandWaitDelay
+	_, _, err := ExecuteCommandWithAllOutput(logger, CommandContext(ctx, "sh", "-c", "sleep 30 & wait"), nil)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), CommandWaitDelay/2)
+}
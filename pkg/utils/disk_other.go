@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd)
+
+package utils
+
+import (
+	"errors"
+)
+
+// DiskUsage is not supported, the usage is unknown.
+func DiskUsage(_ string) (int64, int64, error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin || freebsd
+
+package utils
+
+import (
+	"syscall"
+)
+
+// DiskUsage returns the bytes used and the bytes available to unprivileged
+// users on the file system path is on.
+func DiskUsage(path string) (used int64, available int64, err error) {
+	var stat syscall.Statfs_t
+	err = syscall.Statfs(path, &stat)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the types of the fields differ across platforms
+	blockSize := int64(stat.Bsize)                              // #nosec G115
+	used = (int64(stat.Blocks) - int64(stat.Bfree)) * blockSize // #nosec G115
+	available = int64(stat.Bavail) * blockSize                  // #nosec G115
+
+	return used, available, nil
+}
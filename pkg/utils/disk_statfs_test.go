@@ -0,0 +1,20 @@
+//go:build linux || darwin || freebsd
+
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskUsage(t *testing.T) {
+	used, available, err := DiskUsage(t.TempDir())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, used, int64(0))
+	assert.GreaterOrEqual(t, available, int64(0))
+
+	_, _, err = DiskUsage("/not/existing")
+	assert.Error(t, err)
+}
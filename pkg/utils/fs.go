@@ -1,12 +1,15 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
 )
 
 func IsSymlink(fi os.FileInfo) bool {
@@ -17,6 +20,12 @@ func IsPermModeMatched(stat fs.FileInfo, desiredPerm fs.FileMode) bool {
 	return stat.Mode().Perm() == desiredPerm
 }
 
+// IsPermModeAndOwnerMatched tells whether both the permission mode and the
+// owner of the file are the desired ones.
+func IsPermModeAndOwnerMatched(stat fs.FileInfo, desiredPerm fs.FileMode, uid int, gid int) bool {
+	return IsPermModeMatched(stat, desiredPerm) && IsOwnerMatched(stat, uid, gid)
+}
+
 func readSymbolicLinkUntilRealPath(path string) (string, error) {
 	finalPath, err := filepath.EvalSymlinks(path)
 	if err != nil {
@@ -39,7 +48,167 @@ func ChmodIfUnmatched(logger *logrus.Entry, path string, stat fs.FileInfo, desir
 	return nil
 }
 
-func ChmodAndChownRecursively(logger *logrus.Entry, path string, uid int, gid int, mode os.FileMode) error {
+// defaultChmodChownConcurrency is high as changing the mode and owner is
+// bound by the latency of the file system rather than by the CPU, e.g. NFS.
+const defaultChmodChownConcurrency = 16
+
+// ChmodChownOptions are the permission modes and owner ChmodAndChownTree and
+// ChmodAndChownFiles set.
+type ChmodChownOptions struct {
+	UID int
+	GID int
+	// FileMode is the permission mode of the regular files.
+	FileMode os.FileMode
+	// DirMode is the permission mode of the directories, FileMode if zero.
+	DirMode os.FileMode
+	// Concurrency is the number of files changed in parallel,
+	// defaultChmodChownConcurrency if zero.
+	Concurrency int
+}
+
+func (o ChmodChownOptions) dirMode() os.FileMode {
+	if o.DirMode == 0 {
+		return o.FileMode
+	}
+	return o.DirMode
+}
+
+func (o ChmodChownOptions) concurrency() int {
+	if o.Concurrency <= 0 {
+		return defaultChmodChownConcurrency
+	}
+	return o.Concurrency
+}
+
+// ChmodAndChownRecursively stops walking once ctx is done.
+func ChmodAndChownRecursively(ctx context.Context, logger *logrus.Entry, path string, uid int, gid int, mode os.FileMode) error {
+	return ChmodAndChownTree(ctx, logger, path, ChmodChownOptions{
+		UID:      uid,
+		GID:      gid,
+		FileMode: mode,
+		DirMode:  mode,
+	})
+}
+
+// chmodAndChownIfUnmatched changes the mode and the owner of the file unless
+// they match already, and tells whether anything was changed. Symlinks are
+// followed, dangling ones are left as is.
+func chmodAndChownIfUnmatched(logger *logrus.Entry, path string, options ChmodChownOptions) (bool, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to get info of %s: %w", path, err)
+	}
+
+	if IsSymlink(stat) {
+		resolvedPath, err := readSymbolicLinkUntilRealPath(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("failed to resolve symlink %s: %w", path, err)
+		}
+
+		stat, err = os.Stat(resolvedPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("failed to get info of resolved symlink %s: %w", resolvedPath, err)
+		}
+	}
+
+	mode := options.FileMode
+	if stat.IsDir() {
+		mode = options.dirMode()
+	}
+	// only directories and regular files are chmoded
+	chmod := stat.IsDir() || stat.Mode().IsRegular()
+	if chmod && IsPermModeAndOwnerMatched(stat, mode, options.UID, options.GID) {
+		return false, nil
+	}
+
+	changed := false
+	if chmod && !IsPermModeMatched(stat, mode) {
+		err = ChmodIfUnmatched(logger, path, stat, mode)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	if !IsOwnerMatched(stat, options.UID, options.GID) {
+		err = os.Chown(path, options.UID, options.GID)
+		if err != nil {
+			return false, fmt.Errorf("failed to chown %s to %d:%d: %w", path, options.UID, options.GID, err)
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// chmodAndChownWorkers changes the mode and the owner of the files added with
+// add in parallel, skipping the files that match already.
+type chmodAndChownWorkers struct {
+	logger  *logrus.Entry
+	options ChmodChownOptions
+	group   *errgroup.Group
+	ctx     context.Context
+	changed atomic.Int64
+	skipped atomic.Int64
+}
+
+func newChmodAndChownWorkers(ctx context.Context, logger *logrus.Entry, options ChmodChownOptions) *chmodAndChownWorkers {
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(options.concurrency())
+
+	return &chmodAndChownWorkers{
+		logger:  logger,
+		options: options,
+		group:   group,
+		ctx:     groupCtx,
+	}
+}
+
+// add blocks until a worker is free.
+func (w *chmodAndChownWorkers) add(path string) {
+	w.group.Go(func() error {
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+
+		changed, err := chmodAndChownIfUnmatched(w.logger, path, w.options)
+		if err != nil {
+			return err
+		}
+		if changed {
+			w.changed.Add(1)
+		} else {
+			w.skipped.Add(1)
+		}
+
+		return nil
+	})
+}
+
+func (w *chmodAndChownWorkers) wait() error {
+	err := w.group.Wait()
+	w.logger.Infof("changed the mode or owner of %d files, %d files matched already", w.changed.Load(), w.skipped.Load())
+
+	return err
+}
+
+// ChmodAndChownTree changes the mode and the owner of path and everything
+// under it in parallel, the files whose mode and owner match already are
+// skipped so that rerunning it after an interruption only does what is left.
+// It stops walking once ctx is done.
+func ChmodAndChownTree(ctx context.Context, logger *logrus.Entry, path string, options ChmodChownOptions) error {
 	dir := path
 	logger = logger.WithFields(logrus.Fields{"dir": path})
 
@@ -53,68 +222,68 @@ func ChmodAndChownRecursively(logger *logrus.Entry, path string, uid int, gid in
 		logger.Warn("path is not a directory, fallback to parent directory instead")
 	}
 
-	err = ChmodIfUnmatched(logger, dir, stat, mode)
-	if err != nil {
-		return err
-	}
-
-	err = os.Chown(dir, uid, gid)
-	if err != nil {
-		return fmt.Errorf("failed to chown %s to %d:%d: %w", dir, uid, gid, err)
-	}
-
-	return fs.WalkDir(os.DirFS(dir), ".", func(walkPath string, walkDirEntry fs.DirEntry, err error) error {
+	workers := newChmodAndChownWorkers(ctx, logger, options)
+	err = fs.WalkDir(os.DirFS(dir), ".", func(walkPath string, walkDirEntry fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if walkPath == "." {
-			return nil
+		if err := workers.ctx.Err(); err != nil {
+			return err
 		}
 		if walkPath == ".." {
 			return nil
 		}
 
-		walkPath = filepath.Join(dir, walkPath)
+		workers.add(filepath.Join(dir, walkPath))
 
-		stat, err := walkDirEntry.Info()
-		if err != nil {
-			return fmt.Errorf("failed to get info of %s: %w", walkPath, err)
-		}
+		return nil
+	})
 
-		if IsSymlink(stat) {
-			resolvedWalkPath, err := readSymbolicLinkUntilRealPath(walkPath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					return nil
-				}
+	waitErr := workers.wait()
+	if waitErr != nil {
+		return waitErr
+	}
 
-				return fmt.Errorf("failed to resolve symlink %s: %w", walkPath, err)
-			}
+	return err
+}
 
-			stat, err = os.Stat(resolvedWalkPath)
-			if err != nil {
-				if os.IsNotExist(err) {
-					return nil
-				}
+// ChmodAndChownFiles changes the mode and the owner of the files, slash
+// separated and relative to dir, together with dir and the directories in
+// between, e.g. the files a loader reported as changed. Files that do not
+// exist are skipped, files outside dir are rejected before anything is
+// changed.
+func ChmodAndChownFiles(ctx context.Context, logger *logrus.Entry, dir string, files []string, options ChmodChownOptions) error {
+	logger = logger.WithFields(logrus.Fields{"dir": dir})
 
-				return fmt.Errorf("failed to get info of resolved symlink %s: %w", resolvedWalkPath, err)
-			}
+	paths := make(map[string]struct{}, len(files)+1)
+	paths["."] = struct{}{}
+	for _, file := range files {
+		target := filepath.Join(dir, filepath.FromSlash(file))
+		if !isWithinDir(dir, target) {
+			return fmt.Errorf("refusing to change %s which is outside %s", file, dir)
 		}
-		// optionally chmod
-		if stat.IsDir() || stat.Mode().IsRegular() {
-			err = ChmodIfUnmatched(logger, walkPath, stat, mode)
-			if err != nil {
-				return err
+
+		rel, err := filepath.Rel(dir, target)
+		if err != nil {
+			return err
+		}
+		for p := rel; p != "."; p = filepath.Dir(p) {
+			if _, ok := paths[p]; ok {
+				break
 			}
+			paths[p] = struct{}{}
 		}
+	}
 
-		err = os.Chown(walkPath, uid, gid)
-		if err != nil {
-			return fmt.Errorf("failed to chown %s to %d:%d: %w", walkPath, uid, gid, err)
+	workers := newChmodAndChownWorkers(ctx, logger, options)
+	for p := range paths {
+		if workers.ctx.Err() != nil {
+			break
 		}
+		workers.add(filepath.Join(dir, p))
+	}
 
-		return nil
-	})
+	return workers.wait()
 }
 
 func CleanupNotExistingSymlinks(logger *logrus.Entry, path string) error {
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package utils
+
+import (
+	"io/fs"
+)
+
+// IsOwnerMatched always reports false as the owner is unknown, the files are
+// always chowned.
+func IsOwnerMatched(_ fs.FileInfo, _ int, _ int) bool {
+	return false
+}
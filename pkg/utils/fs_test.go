@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
@@ -142,7 +143,7 @@ func TestChmodAndChownRecursively(t *testing.T) {
 	err = os.WriteFile(filepath.Join(tempDir, "subdir", "file2"), []byte("test"), 0600)
 	assert.NoError(t, err)
 
-	err = ChmodAndChownRecursively(logger, tempDir, os.Getuid(), os.Getgid(), 0755)
+	err = ChmodAndChownRecursively(context.Background(), logger, tempDir, os.Getuid(), os.Getgid(), 0755)
 	assert.NoError(t, err)
 
 	// Check permissions (ownership can't be reliably tested without root)
@@ -193,3 +194,118 @@ func TestCleanupNotExistingSymlinks(t *testing.T) {
 	_, err = os.Stat(danglingSymlink)
 	assert.True(t, os.IsNotExist(err))
 }
+
+func TestIsPermModeAndOwnerMatched(t *testing.T) {
+	tempFile := filepath.Join(t.TempDir(), "test")
+	assert.NoError(t, os.WriteFile(tempFile, []byte("test"), 0644))
+	assert.NoError(t, os.Chmod(tempFile, 0644))
+
+	info, err := os.Stat(tempFile)
+	assert.NoError(t, err)
+
+	assert.True(t, IsOwnerMatched(info, os.Getuid(), os.Getgid()))
+	assert.False(t, IsOwnerMatched(info, os.Getuid()+1, os.Getgid()))
+	assert.True(t, IsPermModeAndOwnerMatched(info, 0644, os.Getuid(), os.Getgid()))
+	assert.False(t, IsPermModeAndOwnerMatched(info, 0755, os.Getuid(), os.Getgid()))
+	assert.False(t, IsPermModeAndOwnerMatched(info, 0644, os.Getuid(), os.Getgid()+1))
+}
+
+func TestChmodAndChownTree(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.NewEntry(logrus.New())
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(tempDir, "subdir", "deeper"), 0700))
+	for _, file := range []string{"file1", "subdir/file2", "subdir/deeper/file3"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(tempDir, file), []byte("test"), 0600))
+	}
+	assert.NoError(t, os.Symlink("file1", filepath.Join(tempDir, "link")))
+	assert.NoError(t, os.Symlink("not-found", filepath.Join(tempDir, "dangling")))
+
+	options := ChmodChownOptions{
+		UID:         os.Getuid(),
+		GID:         os.Getgid(),
+		FileMode:    0640,
+		DirMode:     0750,
+		Concurrency: 2,
+	}
+	err := ChmodAndChownTree(context.Background(), logger, tempDir, options)
+	assert.NoError(t, err)
+
+	checkPerm := func(path string, expected os.FileMode) {
+		info, err := os.Stat(filepath.Join(tempDir, path))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, info.Mode().Perm(), path)
+	}
+	checkPerm(".", 0750)
+	checkPerm("subdir", 0750)
+	checkPerm("subdir/deeper", 0750)
+	checkPerm("file1", 0640)
+	checkPerm("subdir/file2", 0640)
+	checkPerm("subdir/deeper/file3", 0640)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = ChmodAndChownTree(ctx, logger, tempDir, options)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestChmodAndChownFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	logger := logrus.NewEntry(logrus.New())
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(tempDir, "changed", "deeper"), 0700))
+	assert.NoError(t, os.MkdirAll(filepath.Join(tempDir, "unchanged"), 0700))
+	for _, file := range []string{"changed/deeper/file1", "unchanged/file2"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(tempDir, file), []byte("test"), 0600))
+	}
+
+	err := ChmodAndChownFiles(context.Background(), logger, tempDir, []string{"changed/deeper/file1", "deleted/file3"}, ChmodChownOptions{
+		UID:      os.Getuid(),
+		GID:      os.Getgid(),
+		FileMode: 0640,
+	})
+	assert.NoError(t, err)
+
+	checkPerm := func(path string, expected os.FileMode) {
+		info, err := os.Stat(filepath.Join(tempDir, path))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, info.Mode().Perm(), path)
+	}
+	// directories fall back to the file mode
+	checkPerm(".", 0640)
+	checkPerm("changed", 0640)
+	checkPerm("changed/deeper", 0640)
+	checkPerm("changed/deeper/file1", 0640)
+	checkPerm("unchanged", 0700)
+	checkPerm("unchanged/file2", 0600)
+}
+
+func TestChmodAndChownFilesOutsideDir(t *testing.T) {
+	parentDir := t.TempDir()
+	tempDir := filepath.Join(parentDir, "dir")
+	logger := logrus.NewEntry(logrus.New())
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(tempDir, "inside"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(tempDir, "inside", "file1"), []byte("test"), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(parentDir, "outside"), []byte("test"), 0600))
+
+	for _, file := range []string{"../outside", "inside/../../outside", ".."} {
+		err := ChmodAndChownFiles(context.Background(), logger, tempDir, []string{"inside/file1", file}, ChmodChownOptions{
+			UID:      os.Getuid(),
+			GID:      os.Getgid(),
+			FileMode: 0640,
+		})
+		assert.ErrorContains(t, err, "outside", file)
+	}
+
+	// nothing is changed once a file is rejected
+	for path, expected := range map[string]os.FileMode{
+		"dir":              0700,
+		"dir/inside/file1": 0600,
+		"outside":          0600,
+	} {
+		info, err := os.Stat(filepath.Join(parentDir, path))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, info.Mode().Perm(), path)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package utils
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// IsOwnerMatched tells whether the file is owned by uid and gid.
+func IsOwnerMatched(stat fs.FileInfo, uid int, gid int) bool {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+
+	return int(sys.Uid) == uid && int(sys.Gid) == gid
+}
@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	semverRegexp = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+	// constraint operators, longer ones first so that >= is not parsed as >
+	semverConstraintOperators = []string{">=", "<=", "!=", ">", "<", "="}
+)
+
+// Semver is a parsed semantic version, e.g. v1.2.3-rc.1.
+type Semver struct {
+	Major      int64
+	Minor      int64
+	Patch      int64
+	Prerelease string
+
+	original string
+}
+
+// ParseSemver parses a semantic version with an optional v prefix. Minor and
+// patch may be omitted, in which case they are treated as 0.
+func ParseSemver(version string) (*Semver, error) {
+	matches := semverRegexp.FindStringSubmatch(strings.TrimSpace(version))
+	if matches == nil {
+		return nil, fmt.Errorf("invalid semantic version %s", version)
+	}
+
+	parsed := &Semver{Prerelease: matches[4], original: version}
+	for i, target := range []*int64{&parsed.Major, &parsed.Minor, &parsed.Patch} {
+		if matches[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(matches[i+1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semantic version %s: %w", version, err)
+		}
+
+		*target = n
+	}
+
+	return parsed, nil
+}
+
+func (v *Semver) String() string {
+	return v.original
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is lower than, equal to
+// or greater than other.
+func (v *Semver) Compare(other *Semver) int {
+	for _, pair := range [][2]int64{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] < pair[1] {
+			return -1
+		}
+		if pair[0] > pair[1] {
+			return 1
+		}
+	}
+
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// https://semver.org/#spec-item-11
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.ParseInt(aParts[i], 10, 64)
+		bNum, bErr := strconv.ParseInt(bParts[i], 10, 64)
+
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return lessToCompareResult(aNum < bNum)
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if aParts[i] != bParts[i] {
+				return lessToCompareResult(aParts[i] < bParts[i])
+			}
+		}
+	}
+
+	return lessToCompareResult(len(aParts) < len(bParts))
+}
+
+func lessToCompareResult(less bool) int {
+	if less {
+		return -1
+	}
+
+	return 1
+}
+
+type semverComparator struct {
+	operator string
+	version  *Semver
+}
+
+func (c semverComparator) matches(v *Semver) bool {
+	result := v.Compare(c.version)
+
+	switch c.operator {
+	case ">=":
+		return result >= 0
+	case "<=":
+		return result <= 0
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case "<":
+		return result < 0
+	default:
+		return result == 0
+	}
+}
+
+// SemverConstraint is a set of comparators such as ">=1.2 <2 || 3.0.0".
+// Comparators separated by spaces or commas must all match, groups separated
+// by || are alternatives.
+type SemverConstraint struct {
+	groups [][]semverComparator
+
+	allowPrerelease bool
+}
+
+// ParseSemverConstraint parses a constraint like ">=1.2 <2".
+func ParseSemverConstraint(constraint string) (*SemverConstraint, error) {
+	parsed := new(SemverConstraint)
+
+	for _, group := range strings.Split(constraint, "||") {
+		fields := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid semantic version constraint %q", constraint)
+		}
+
+		comparators := make([]semverComparator, 0, len(fields))
+		for _, field := range fields {
+			operator := "="
+			for _, op := range semverConstraintOperators {
+				if strings.HasPrefix(field, op) {
+					operator = op
+					field = strings.TrimPrefix(field, op)
+					break
+				}
+			}
+
+			version, err := ParseSemver(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid semantic version constraint %q: %w", constraint, err)
+			}
+			if version.Prerelease != "" {
+				parsed.allowPrerelease = true
+			}
+
+			comparators = append(comparators, semverComparator{operator: operator, version: version})
+		}
+
+		parsed.groups = append(parsed.groups, comparators)
+	}
+
+	return parsed, nil
+}
+
+// Check reports whether the version satisfies the constraint. Prerelease
+// versions only match when the constraint mentions a prerelease itself.
+func (c *SemverConstraint) Check(v *Semver) bool {
+	if v.Prerelease != "" && !c.allowPrerelease {
+		return false
+	}
+
+	for _, group := range c.groups {
+		matched := true
+		for _, comparator := range group {
+			if !comparator.matches(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HighestMatchingSemver returns the highest of the given versions that
+// satisfies the constraint, versions that can not be parsed are ignored.
+func HighestMatchingSemver(versions []string, constraint *SemverConstraint) (string, bool) {
+	matched := make([]*Semver, 0, len(versions))
+	for _, version := range versions {
+		parsed, err := ParseSemver(version)
+		if err != nil {
+			continue
+		}
+		if constraint.Check(parsed) {
+			matched = append(matched, parsed)
+		}
+	}
+	if len(matched) == 0 {
+		return "", false
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Compare(matched[j]) < 0
+	})
+
+	return matched[len(matched)-1].String(), true
+}
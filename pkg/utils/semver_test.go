@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSemver(t *testing.T) {
+	v, err := ParseSemver("v1.2.3-rc.1+build.5")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v.Major)
+	assert.Equal(t, int64(2), v.Minor)
+	assert.Equal(t, int64(3), v.Patch)
+	assert.Equal(t, "rc.1", v.Prerelease)
+	assert.Equal(t, "v1.2.3-rc.1+build.5", v.String())
+
+	v, err = ParseSemver("2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v.Major)
+	assert.Equal(t, int64(0), v.Minor)
+
+	_, err = ParseSemver("release-2024")
+	assert.Error(t, err)
+}
+
+func TestSemverCompare(t *testing.T) {
+	compare := func(a, b string) int {
+		av, err := ParseSemver(a)
+		require.NoError(t, err)
+		bv, err := ParseSemver(b)
+		require.NoError(t, err)
+
+		return av.Compare(bv)
+	}
+
+	assert.Equal(t, -1, compare("1.2.3", "1.10.0"))
+	assert.Equal(t, 0, compare("v1.2", "1.2.0"))
+	assert.Equal(t, 1, compare("1.0.0", "1.0.0-rc.1"))
+	assert.Equal(t, -1, compare("1.0.0-alpha", "1.0.0-alpha.1"))
+	assert.Equal(t, -1, compare("1.0.0-alpha.2", "1.0.0-alpha.10"))
+	assert.Equal(t, 1, compare("1.0.0-beta", "1.0.0-alpha.1"))
+}
+
+func TestSemverConstraint(t *testing.T) {
+	check := func(constraint, version string) bool {
+		c, err := ParseSemverConstraint(constraint)
+		require.NoError(t, err)
+		v, err := ParseSemver(version)
+		require.NoError(t, err)
+
+		return c.Check(v)
+	}
+
+	assert.True(t, check(">=1.2 <2", "1.2.0"))
+	assert.True(t, check(">=1.2 <2", "v1.9.9"))
+	assert.False(t, check(">=1.2 <2", "2.0.0"))
+	assert.False(t, check(">=1.2 <2", "1.1.9"))
+	assert.False(t, check(">=1.2 <2", "1.5.0-rc.1"))
+	assert.True(t, check(">=1.5.0-rc.0, <2", "1.5.0-rc.1"))
+	assert.True(t, check("<1 || >=3", "3.1.0"))
+	assert.True(t, check("1.4.2", "v1.4.2"))
+	assert.True(t, check("!=1.4.2", "1.4.3"))
+
+	_, err := ParseSemverConstraint(">=foo")
+	assert.Error(t, err)
+	_, err = ParseSemverConstraint("")
+	assert.Error(t, err)
+}
+
+func TestHighestMatchingSemver(t *testing.T) {
+	c, err := ParseSemverConstraint(">=1.2 <2")
+	require.NoError(t, err)
+
+	highest, ok := HighestMatchingSemver([]string{"v1.1.0", "v1.2.0", "v1.10.1", "v1.11.0-rc.1", "v2.0.0", "nightly"}, c)
+	assert.True(t, ok)
+	assert.Equal(t, "v1.10.1", highest)
+
+	_, ok = HighestMatchingSemver([]string{"v3.0.0"}, c)
+	assert.False(t, ok)
+}